@@ -0,0 +1,129 @@
+package main
+
+import (
+	"elsa-xml/pkg/archive"
+	"elsa-xml/pkg/extractor"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	ErrorFormat   = "Error: %v\n"
+	envVarArchive = "ARCHIVE_DIR"
+	usage         = `usage:
+  archive store [-direction inbound|outbound] [-time <RFC3339>] <file> ...
+  archive query [-txid <TxID>] [-party <InstructingParty>] [-type <MessageType>] [-received-from <BIC>] [-direction <direction>]
+               [-since <RFC3339>] [-until <RFC3339>]
+  archive show <hash>
+
+The archive directory is set with -dir or the ARCHIVE_DIR environment variable.`
+)
+
+// main stores messages in the archive, queries the index and prints archived messages.
+// The message type of stored files is derived from the file name as in the demo (e.g. sese.024.001.10_iso_ok.xml).
+func main() {
+	if len(os.Args) < 2 {
+		exitWithError(errors.New(usage))
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dir := fs.String("dir", os.Getenv(envVarArchive), "archive directory")
+	direction := fs.String("direction", "", "message direction (inbound, outbound)")
+	at := fs.String("time", "", "archive time of stored messages (RFC3339), defaults to now")
+	txID := fs.String("txid", "", "select messages by TxID")
+	party := fs.String("party", "", "select messages by InstructingParty")
+	msgType := fs.String("type", "", "select messages by MessageType")
+	receivedFrom := fs.String("received-from", "", "select messages by ReceivedFrom")
+	since := fs.String("since", "", "select messages archived at or after the time (RFC3339)")
+	until := fs.String("until", "", "select messages archived at or before the time (RFC3339)")
+	_ = fs.Parse(os.Args[2:])
+
+	a, err := archive.Open(*dir)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	switch os.Args[1] {
+	case "store":
+		store(a, fs.Args(), *direction, *at)
+	case "query":
+		q := archive.Query{TxID: *txID, InstructingParty: *party, MessageType: *msgType, ReceivedFrom: *receivedFrom, Direction: *direction}
+		if q.From, err = parseTime(*since); err != nil {
+			exitWithError(err)
+		}
+		if q.To, err = parseTime(*until); err != nil {
+			exitWithError(err)
+		}
+		query(a, q)
+	case "show":
+		if fs.NArg() != 1 {
+			exitWithError(errors.New(usage))
+		}
+		data, err := a.Load(fs.Arg(0))
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Print(string(data))
+	default:
+		exitWithError(errors.New(usage))
+	}
+}
+
+func store(a *archive.Archive, files []string, direction, at string) {
+	if len(files) == 0 {
+		exitWithError(errors.New(usage))
+	}
+	if direction == "" {
+		direction = archive.DirectionInbound
+	}
+	t := time.Now()
+	if at != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, at); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	for _, fPath := range files {
+		xmlFile, err := os.ReadFile(fPath)
+		if err != nil {
+			exitWithError(err)
+		}
+		msgType, err := extractor.MsgTypeFromFileName(fPath)
+		if err != nil {
+			exitWithError(err)
+		}
+		e, err := a.Store(xmlFile, msgType, direction, t)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("%s %s\n", e.Hash, fPath)
+	}
+}
+
+func query(a *archive.Archive, q archive.Query) {
+	entries, err := a.Find(q)
+	if err != nil {
+		exitWithError(err)
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %-8s  %-18s  %-16s  %-12s  %-12s  %s\n",
+			e.Time.Format(time.RFC3339Nano), e.Direction, e.MsgType, e.TxID, e.MessageType, e.ReceivedFrom, e.Hash)
+	}
+}
+
+// parseTime parses an RFC3339 time, an empty value is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func exitWithError(err error) {
+	fmt.Printf(ErrorFormat, err)
+	os.Exit(1)
+}
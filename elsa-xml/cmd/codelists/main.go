@@ -0,0 +1,53 @@
+package main
+
+import (
+	"elsa-xml/pkg/codelist"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	ErrorFormat        = "Error: %v\n"
+	envVarT2SSchemaDir = "SCHEMA_DIR_T2S"
+	envVarISOSchemaDir = "SCHEMA_DIR_ISO"
+)
+
+// main derives the code lists from the schemas in SCHEMA_DIR_ISO and SCHEMA_DIR_T2S and writes them to the
+// enumerations file in the given directory (usually schemas/codelists). It has to be run whenever the schemas
+// change, the T2S reason-code catalogue in the same directory has to be updated to the new version by hand.
+func main() {
+	if len(os.Args) != 2 {
+		exitWithError(errors.New("usage: codelists <output dir>"))
+	}
+	isoDir := os.Getenv(envVarISOSchemaDir)
+	t2sDir := os.Getenv(envVarT2SSchemaDir)
+	if isoDir == "" || t2sDir == "" {
+		exitWithError(errors.New("SCHEMA_DIR_ISO and SCHEMA_DIR_T2S environment variables must be set"))
+	}
+
+	version, isoFiles, t2sFiles, err := codelist.SchemaFiles(isoDir, t2sDir)
+	if err != nil {
+		exitWithError(err)
+	}
+	e, err := codelist.Generate(version, isoFiles, t2sFiles)
+	if err != nil {
+		exitWithError(err)
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		exitWithError(err)
+	}
+	out := filepath.Join(os.Args[1], codelist.EnumerationsFile)
+	if err := os.WriteFile(out, append(data, '\n'), 0o644); err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Code lists of version %s (%d ISO, %d T2S schemas) written to %s\n", version, len(e.ISO), len(e.T2S), out)
+}
+
+func exitWithError(err error) {
+	fmt.Printf(ErrorFormat, err)
+	os.Exit(1)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"elsa-xml/pkg/codelist"
 	"elsa-xml/pkg/extractor"
 	"elsa-xml/pkg/validator"
 	"errors"
@@ -12,6 +13,7 @@ import (
 const (
 	ErrorFormat    = "Error: %v\n"
 	envVarTestData = "TEST_DATA_DIR"
+	envVarCodeList = "CODELIST_DIR"
 )
 
 func main() {
@@ -23,6 +25,12 @@ func main() {
 	if err != nil {
 		exitWithError(err)
 	}
+	var codes *codelist.Dictionary
+	if dir := os.Getenv(envVarCodeList); dir != "" {
+		if codes, err = codelist.Load(dir); err != nil {
+			exitWithError(err)
+		}
+	}
 
 	// get all xml files from testDataDir
 	files, err := os.ReadDir(testDataDir)
@@ -57,13 +65,21 @@ func main() {
 		fmt.Printf("File %s is valid\n", fName)
 
 		// Extract data according to type
-		result, err := extractor.Extract(xmlFile, msgName(fName))
+		msgType, err := extractor.MsgTypeFromFileName(fName)
+		if err != nil {
+			fmt.Printf("<<< Error >>>: %v\n", err)
+			continue
+		}
+		result, err := extractor.Extract(xmlFile, msgType)
 		if err != nil {
 			fmt.Printf("<<< Error >>>: %v\n", err)
 			continue
 		}
 		if result != nil {
 			printResult(result)
+			if codes != nil {
+				printCodes(codes, result, msgType)
+			}
 		} else {
 			fmt.Println(">>> No Data! <<<")
 		}
@@ -72,20 +88,6 @@ func main() {
 	}
 }
 
-func msgName(fileName string) string {
-	parts := strings.Split(fileName, "_")
-	if len(parts) < 2 {
-		fmt.Printf("Error: %s is not a valid schema name\n", fileName)
-		return ""
-	}
-
-	mName := parts[0]
-	if parts[1] == "t2s" {
-		mName = strings.ReplaceAll(parts[0], ".", "") + "plus"
-	}
-	return mName
-}
-
 func schemaName(fileName string) string {
 	parts := strings.Split(fileName, "_")
 	if len(parts) < 2 {
@@ -113,6 +115,25 @@ func printResult(result *extractor.ExtractionResult) {
 	}
 }
 
+func printCodes(codes *codelist.Dictionary, result *extractor.ExtractionResult, msgType string) {
+	enriched, err := codes.Enrich(result, msgType)
+	if err != nil {
+		fmt.Printf("<<< Error >>>: %v\n", err)
+		return
+	}
+	for _, k := range []string{extractor.MovementTypeKey, extractor.PaymentTypeKey, extractor.StatusReasonKey} {
+		c, ok := enriched[k]
+		if !ok {
+			continue
+		}
+		known := ""
+		if !c.Known {
+			known = " <<< unknown code >>>"
+		}
+		fmt.Printf("%-16s: %s (%s, %s)%s\n", k, c.Value, c.Name, c.Category, known)
+	}
+}
+
 func exitWithError(err error) {
 	fmt.Printf(ErrorFormat, err)
 	os.Exit(1)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"elsa-xml/pkg/extractor"
+	"elsa-xml/pkg/lifecycle"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ErrorFormat = "Error: %v\n"
+)
+
+// main checks the lifecycle of one instruction. The message files are passed in the order they were
+// exchanged, the message type is derived from the file name as in the demo (e.g. sese.024.001.10_iso_ok.xml).
+func main() {
+	if len(os.Args) < 2 {
+		exitWithError(errors.New("usage: lifecycle <instruction file> [<message file> ...]"))
+	}
+
+	msgs := make([]lifecycle.Message, 0, len(os.Args)-1)
+	for _, fPath := range os.Args[1:] {
+		xmlFile, err := os.ReadFile(fPath)
+		if err != nil {
+			exitWithError(err)
+		}
+		msgType, err := extractor.MsgTypeFromFileName(fPath)
+		if err != nil {
+			exitWithError(err)
+		}
+		msgs = append(msgs, lifecycle.Message{Type: msgType, XML: xmlFile})
+	}
+
+	report, err := lifecycle.Check(msgs)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	fmt.Printf("%-17s: %s\n", "TxID", report.TxID)
+	fmt.Printf("%-17s: %s\n", "MktInfrstrctrTxID", report.MktInfrstrctrTxID)
+	fmt.Printf("%-17s: %s\n", "PrcrTxID", report.PrcrTxID)
+	fmt.Printf("%-17s: %s\n", "State", report.State)
+	fmt.Printf("%-17s: %s\n", "CxlState", report.CxlState)
+	if report.Valid() {
+		fmt.Println("Lifecycle is consistent")
+		return
+	}
+	fmt.Println(strings.Repeat("-", 80))
+	for _, v := range report.Violations {
+		fmt.Println(v)
+	}
+	os.Exit(1)
+}
+
+func exitWithError(err error) {
+	fmt.Printf(ErrorFormat, err)
+	os.Exit(1)
+}
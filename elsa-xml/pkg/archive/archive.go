@@ -0,0 +1,239 @@
+// Package archive keeps every inbound and outbound message for audit.
+//
+// The raw XML is stored content-addressed by its SHA-256 hash below <dir>/objects, identical messages are
+// stored once. Every archived message is recorded in the index <dir>/index.jsonl together with the values
+// extractor.Extract yields, one JSON object per line in the order the messages were archived.
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"elsa-xml/pkg/extractor"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Directions of archived messages
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+
+	objectsDir = "objects"
+	indexFile  = "index.jsonl"
+)
+
+// Entry is the index entry of an archived message.
+type Entry struct {
+	Hash             string    `json:"hash"`
+	Time             time.Time `json:"time"`
+	Direction        string    `json:"direction"`
+	MsgType          string    `json:"msgType"`
+	TxID             string    `json:"txId,omitempty"`
+	InstructingParty string    `json:"instructingParty,omitempty"`
+	MessageType      string    `json:"messageType,omitempty"`
+	ReceivedFrom     string    `json:"receivedFrom,omitempty"`
+}
+
+// Query selects index entries, empty fields match all entries.
+// From and To limit the archive time, both are inclusive.
+type Query struct {
+	TxID             string
+	InstructingParty string
+	MessageType      string
+	ReceivedFrom     string
+	Direction        string
+	From, To         time.Time
+}
+
+// matches reports whether the entry is selected by the query.
+func (q Query) matches(e Entry) bool {
+	switch {
+	case q.TxID != "" && e.TxID != q.TxID,
+		q.InstructingParty != "" && e.InstructingParty != q.InstructingParty,
+		q.MessageType != "" && e.MessageType != q.MessageType,
+		q.ReceivedFrom != "" && e.ReceivedFrom != q.ReceivedFrom,
+		q.Direction != "" && e.Direction != q.Direction,
+		!q.From.IsZero() && e.Time.Before(q.From),
+		!q.To.IsZero() && e.Time.After(q.To):
+		return false
+	}
+	return true
+}
+
+// Archive is a message archive in a local directory. It is safe for concurrent use within one process.
+type Archive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the archive in the given directory, the directory is created if it does not exist.
+func Open(dir string) (*Archive, error) {
+	if dir == "" {
+		return nil, errors.New("archive - no directory set")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, objectsDir), 0o755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Store archives a message. msgType is the message type as expected by extractor.Extract, the extracted values
+// are added to the index. The message is archived even if extraction fails, an unsupported message type is
+// only indexed by hash, time, direction and type.
+func (a *Archive) Store(xml []byte, msgType, direction string, at time.Time) (*Entry, error) {
+	if len(xml) == 0 {
+		return nil, errors.New("archive - empty message")
+	}
+	if direction != DirectionInbound && direction != DirectionOutbound {
+		return nil, fmt.Errorf("archive - invalid direction %s", direction)
+	}
+
+	sum := sha256.Sum256(xml)
+	e := Entry{
+		Hash:      hex.EncodeToString(sum[:]),
+		Time:      at.UTC(),
+		Direction: direction,
+		MsgType:   msgType,
+	}
+	if res, err := extractor.Extract(xml, msgType); err == nil {
+		e.TxID = res.Value(extractor.TxIDKey)
+		e.InstructingParty = res.Value(extractor.InstructingPartyKey)
+		e.MessageType = res.Value(extractor.MessageTypeKey)
+		e.ReceivedFrom = res.Value(extractor.ReceivedFromKey)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.writeObject(e.Hash, xml); err != nil {
+		return nil, err
+	}
+	if err := a.appendIndex(e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Load returns the message with the given hash. The content is verified against the hash.
+// Hashes which are not hex encoded SHA-256 hashes are rejected, so no path outside the objects can be read.
+func (a *Archive) Load(hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("archive - invalid hash %q", hash)
+	}
+	data, err := os.ReadFile(a.objectPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return nil, fmt.Errorf("archive - content of %s does not match its hash", hash)
+	}
+	return data, nil
+}
+
+// Find returns the index entries selected by the query in chronological order.
+// Entries with the same time keep the order in which they were archived.
+func (a *Archive) Find(q Query) ([]Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(a.dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("archive - index line %d: %w", line, err)
+		}
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// ByTxID returns all entries of the given transaction id in chronological order.
+func (a *Archive) ByTxID(txID string) ([]Entry, error) {
+	return a.Find(Query{TxID: txID})
+}
+
+// validHash reports whether the hash consists of the 64 lowercase hex digits of a SHA-256 hash.
+func validHash(hash string) bool {
+	if len(hash) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// objectPath returns the path of the object with the given valid hash, objects are spread over sub directories
+// named by the first two characters of the hash.
+func (a *Archive) objectPath(hash string) string {
+	return filepath.Join(a.dir, objectsDir, hash[:2], hash)
+}
+
+// writeObject writes the content of a message unless it is stored already.
+// The content is written to a temporary file first, so a stored object is always complete.
+func (a *Archive) writeObject(hash string, xml []byte) error {
+	path := a.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(xml); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendIndex appends an entry to the index.
+func (a *Archive) appendIndex(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(a.dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"elsa-xml/pkg/extractor"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDataDir = "../../testdata/full"
+
+func TestStoreAndFind(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	instr := readFixture(t, "sese.023_t2s_ok.xml")
+	other := readFixture(t, "sese.023.001.10_iso_ok.xml")
+	t0 := time.Date(2024, 4, 12, 10, 0, 0, 0, time.UTC)
+
+	// archived out of chronological order, the same message twice
+	second, err := a.Store(instr, extractor.MsgTypeSese023Plus, DirectionOutbound, t0.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := a.Store(instr, extractor.MsgTypeSese023Plus, DirectionInbound, t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Store(other, extractor.MsgTypeSese023, DirectionInbound, t0); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Hash != second.Hash {
+		t.Errorf("same content, different hashes %s and %s", first.Hash, second.Hash)
+	}
+	if first.TxID == "" || first.MessageType == "" {
+		t.Errorf("extracted values missing: %+v", first)
+	}
+
+	entries, err := a.ByTxID(first.TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Direction != DirectionInbound || entries[1].Direction != DirectionOutbound {
+		t.Errorf("entries not in chronological order: %+v", entries)
+	}
+
+	data, err := a.Load(first.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(instr) {
+		t.Error("loaded content differs from stored content")
+	}
+
+	if entries, err = a.Find(Query{TxID: first.TxID, Direction: DirectionOutbound}); err != nil || len(entries) != 1 {
+		t.Errorf("query by TxID and direction: %d entries, %v", len(entries), err)
+	}
+	if entries, err = a.Find(Query{From: t0.Add(time.Second)}); err != nil || len(entries) != 1 {
+		t.Errorf("query by time: %d entries, %v", len(entries), err)
+	}
+}
+
+func TestLoadDetectsCorruption(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := a.Store(readFixture(t, "sese.024.001.10_iso_ok.xml"), extractor.MsgTypeSese024, DirectionInbound, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(a.objectPath(e.Hash), []byte("<x/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Load(e.Hash); err == nil {
+		t.Error("expected error for corrupted object")
+	}
+}
+
+func TestLoadRejectsInvalidHash(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := a.Store(readFixture(t, "sese.024.001.10_iso_ok.xml"), extractor.MsgTypeSese024, DirectionInbound, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a file outside of the objects whose content matches the hash of its name
+	if err := os.WriteFile(filepath.Join(dir, "outside"), readFixture(t, "sese.024.001.10_iso_ok.xml"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range []string{
+		"",
+		"../../outside",
+		"../../../" + e.Hash,
+		strings.ToUpper(e.Hash),
+		e.Hash[:63],
+		e.Hash + "0",
+		e.Hash[:62] + "/x",
+	} {
+		if _, err := a.Load(hash); err == nil || !strings.Contains(err.Error(), "invalid hash") {
+			t.Errorf("Load(%q): got %v, want an invalid hash error", hash, err)
+		}
+	}
+	if _, err := a.Load(e.Hash); err != nil {
+		t.Error(err)
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(testDataDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,234 @@
+// Package codelist enriches extracted codes (e.g. movement type, payment type or status reason) with their
+// description and category.
+//
+// The valid codes are derived from the enumerations of the ISO and T2S schemas by cmd/codelists and stored in
+// enumerations.json, including the names and definitions documented in the T2S schemas. The T2S reason-code
+// catalogue t2s_reason_codes.json adds categories and descriptions on top. Both files are kept in
+// schemas/codelists and carry the version of the schemas they belong to (schemas/T2S/version.xml), Load
+// rejects files of different versions.
+package codelist
+
+import (
+	"elsa-xml/pkg/extractor"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	EnumerationsFile = "enumerations.json"
+	CatalogueFile    = "t2s_reason_codes.json"
+)
+
+// Enumerations holds the code lists derived from the schemas.
+// ISO and T2S hold the lists per schema, keyed by message identifier (e.g. sese.024.001.10).
+type Enumerations struct {
+	Version string                  `json:"version"`
+	ISO     map[string]*SchemaLists `json:"iso"`
+	T2S     map[string]*SchemaLists `json:"t2s"`
+}
+
+// SchemaLists holds the code lists of a single schema.
+// Fields maps extraction keys to the code list of the element they are extracted from, StatusReasons maps the
+// processing statuses to the code lists of their reasons.
+type SchemaLists struct {
+	Fields        map[string]string   `json:"fields"`
+	StatusReasons map[string][]string `json:"statusReasons"`
+	Lists         map[string][]Enum   `json:"lists"`
+}
+
+// Enum is a code of a code list. Name and definition are documented in the T2S schemas only.
+type Enum struct {
+	Code       string `json:"code"`
+	Name       string `json:"name,omitempty"`
+	Definition string `json:"definition,omitempty"`
+}
+
+// Catalogue is the T2S reason-code catalogue.
+type Catalogue struct {
+	Version string           `json:"version"`
+	Codes   []CatalogueEntry `json:"codes"`
+}
+
+// CatalogueEntry describes a code. If Lists is set, the entry applies to these code lists only (without T2S
+// suffix). An empty description keeps the one documented in the schemas.
+type CatalogueEntry struct {
+	Code        string   `json:"code"`
+	Description string   `json:"description,omitempty"`
+	Category    string   `json:"category"`
+	Lists       []string `json:"lists,omitempty"`
+}
+
+// Code is an enriched code. Known is false if the code is not part of the code list it was looked up in.
+type Code struct {
+	Value       string
+	Name        string
+	Description string
+	Category    string
+	List        string
+	Known       bool
+}
+
+// Dictionary holds the code lists and the catalogue of one schema version.
+type Dictionary struct {
+	Version      string
+	enumerations *Enumerations
+	catalogue    map[string][]CatalogueEntry
+}
+
+// Load loads the code lists and the catalogue from the given directory.
+func Load(dir string) (*Dictionary, error) {
+	var e Enumerations
+	if err := readJSON(filepath.Join(dir, EnumerationsFile), &e); err != nil {
+		return nil, err
+	}
+	var c Catalogue
+	if err := readJSON(filepath.Join(dir, CatalogueFile), &c); err != nil {
+		return nil, err
+	}
+	if e.Version == "" {
+		return nil, errors.New("codelist - enumerations without version")
+	}
+	if e.Version != c.Version {
+		return nil, fmt.Errorf("codelist - version of enumerations %s differs from catalogue version %s", e.Version, c.Version)
+	}
+
+	d := &Dictionary{
+		Version:      e.Version,
+		enumerations: &e,
+		catalogue:    make(map[string][]CatalogueEntry),
+	}
+	for _, entry := range c.Codes {
+		d.catalogue[entry.Code] = append(d.catalogue[entry.Code], entry)
+	}
+	return d, nil
+}
+
+// Lookup looks up a code in the code lists of a schema. t2s selects the T2S variant of the schema.
+// The first list containing the code is used, codes not contained in any list are returned with Known false.
+// Name and description are taken from the schema documentation, for ISO schemas from the T2S variant of the
+// list. A catalogue entry overrides the description, the category defaults to the given one.
+func (d *Dictionary) Lookup(schema string, t2s bool, lists []string, value, category string) Code {
+	c := Code{Value: value, Category: category}
+	if len(lists) > 0 {
+		c.List = lists[0]
+	}
+	if sl := d.schemaLists(schema, t2s); sl != nil {
+		for _, list := range lists {
+			if e, ok := findEnum(sl.Lists[list], value); ok {
+				c.List = list
+				c.Known = true
+				c.Name, c.Description = e.Name, e.Definition
+				break
+			}
+		}
+	}
+	if c.Known && c.Name == "" {
+		c.Name, c.Description = d.documentation(c.List, value)
+	}
+
+	for _, entry := range d.catalogue[value] {
+		if len(entry.Lists) > 0 && !contains(entry.Lists, baseList(c.List)) {
+			continue
+		}
+		if entry.Description != "" {
+			c.Description = entry.Description
+		}
+		if entry.Category != "" {
+			c.Category = entry.Category
+		}
+		break
+	}
+	return c
+}
+
+// documentation returns name and definition of a code from the T2S lists with the same base name.
+func (d *Dictionary) documentation(list, value string) (string, string) {
+	for _, sl := range d.enumerations.T2S {
+		for name, enums := range sl.Lists {
+			if baseList(name) != baseList(list) {
+				continue
+			}
+			if e, ok := findEnum(enums, value); ok && e.Name != "" {
+				return e.Name, e.Definition
+			}
+		}
+	}
+	return "", ""
+}
+
+// Enrich enriches the codes of an extraction result. msgType is the message type the result was extracted
+// with, messages received from T2S (e.g. extractor.MsgTypeSese023Plus) are looked up in the T2S lists.
+// The result is keyed by extraction key and holds only the keys with a value.
+func (d *Dictionary) Enrich(res *extractor.ExtractionResult, msgType string) (map[string]Code, error) {
+	schema := res.Value(extractor.MessageTypeKey)
+	t2s := strings.HasSuffix(msgType, "plus")
+	sl := d.schemaLists(schema, t2s)
+	if sl == nil {
+		return nil, fmt.Errorf("codelist - no code lists for message type %s", schema)
+	}
+
+	codes := make(map[string]Code)
+	for _, key := range []string{extractor.MovementTypeKey, extractor.PaymentTypeKey} {
+		if v := res.Value(key); v != "" {
+			codes[key] = d.Lookup(schema, t2s, []string{sl.Fields[key]}, v, key)
+		}
+	}
+	if v := res.Value(extractor.StatusReasonKey); v != "" {
+		status := res.Value(extractor.ProcessingStatusKey)
+		codes[extractor.StatusReasonKey] = d.Lookup(schema, t2s, sl.StatusReasons[status], v, status)
+	}
+	return codes, nil
+}
+
+// schemaLists returns the code lists of a schema or nil if the schema is not known.
+func (d *Dictionary) schemaLists(schema string, t2s bool) *SchemaLists {
+	if t2s {
+		return d.enumerations.T2S[schema]
+	}
+	return d.enumerations.ISO[schema]
+}
+
+// readJSON reads a JSON file into v.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("codelist - %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// findEnum returns the code with the given value.
+func findEnum(enums []Enum, value string) (Enum, bool) {
+	for _, e := range enums {
+		if e.Code == value {
+			return e, true
+		}
+	}
+	return Enum{}, false
+}
+
+// baseList returns the name of a code list without the suffix of T2S restrictions, e.g. RejectionReason37Code
+// for RejectionReason37Code__1.
+func baseList(list string) string {
+	if i := strings.Index(list, "__"); i > 0 {
+		return list[:i]
+	}
+	return list
+}
+
+// contains reports whether the slice contains the value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
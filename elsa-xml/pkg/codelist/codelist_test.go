@@ -0,0 +1,101 @@
+package codelist
+
+import (
+	"bytes"
+	"elsa-xml/pkg/extractor"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	codelistDir = "../../schemas/codelists"
+	isoDir      = "../../schemas/ISO"
+	t2sDir      = "../../schemas/T2S"
+	testDataDir = "../../testdata/full"
+)
+
+// TestEnumerationsUpToDate fails if the schemas changed without regenerating the code lists with cmd/codelists.
+func TestEnumerationsUpToDate(t *testing.T) {
+	version, isoFiles, t2sFiles, err := SchemaFiles(isoDir, t2sDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Generate(version, isoFiles, t2sFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(codelistDir, EnumerationsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(want, '\n'), got) {
+		t.Errorf("%s is out of date, regenerate it with cmd/codelists", EnumerationsFile)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	d, err := Load(codelistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file    string
+		msgType string
+		key     string
+		want    Code
+	}{
+		{"sese.023_t2s_ok.xml", extractor.MsgTypeSese023Plus, extractor.MovementTypeKey,
+			Code{Value: "DELI", Name: "Delivery", List: "ReceiveDelivery1Code", Category: extractor.MovementTypeKey, Known: true}},
+		{"sese.023.001.10_iso_ok.xml", extractor.MsgTypeSese023, extractor.PaymentTypeKey,
+			Code{Value: "APMT", Name: "AgainstPaymentSettlement", List: "DeliveryReceiptType2Code", Category: extractor.PaymentTypeKey, Known: true}},
+		{"sese.024.001.10_iso_ok.xml", extractor.MsgTypeSese024, extractor.StatusReasonKey,
+			Code{Value: "OTHR", Name: "Other", List: "RejectionReason37Code", Category: "other", Known: true}},
+		{"sese.027.001.05_iso_ok.xml", extractor.MsgTypeSese027, extractor.StatusReasonKey,
+			Code{Value: "NORE", Name: "NoReason", List: "NoReasonCode", Category: "no-reason", Known: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+tt.key, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(testDataDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := extractor.Extract(data, tt.msgType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes, err := d.Enrich(res, tt.msgType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := codes[tt.key]
+			got.Description = ""
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if codes[tt.key].Description == "" {
+				t.Error("description missing")
+			}
+		})
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	d, err := Load(codelistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.Lookup(extractor.MsgTypeSese024, false, []string{"RejectionReason37Code"}, "XXXX", "Rjctd")
+	if c.Known {
+		t.Errorf("unknown code not flagged: %+v", c)
+	}
+	if c.Category != "Rjctd" || c.List != "RejectionReason37Code" {
+		t.Errorf("unexpected result %+v", c)
+	}
+}
@@ -0,0 +1,200 @@
+package codelist
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fieldElements maps the extraction keys enriched with a code list to the element holding the code.
+var fieldElements = map[string]string{
+	"MovementType": "SctiesMvmntTp",
+	"PaymentType":  "Pmt",
+}
+
+const (
+	// elements used to find the reason code lists of the processing statuses
+	processingStatusElement = "PrcgSts"
+	reasonElement           = "Rsn"
+	codeElement             = "Cd"
+	noReasonElement         = "NoSpcfdRsn"
+)
+
+// xsdSchema holds the parts of a schema document needed to derive the code lists.
+type xsdSchema struct {
+	TargetNamespace string       `xml:"targetNamespace,attr"`
+	SimpleTypes     []xsdSimple  `xml:"simpleType"`
+	ComplexTypes    []xsdComplex `xml:"complexType"`
+}
+
+type xsdSimple struct {
+	Name  string `xml:"name,attr"`
+	Enums []struct {
+		Value string   `xml:"value,attr"`
+		Docs  []xsdDoc `xml:"annotation>documentation"`
+	} `xml:"restriction>enumeration"`
+}
+
+// xsdDoc is the documentation of a component, the T2S schemas document the name and definition of each code.
+type xsdDoc struct {
+	Source string `xml:"source,attr"`
+	Text   string `xml:",chardata"`
+}
+
+type xsdComplex struct {
+	Name           string       `xml:"name,attr"`
+	Sequence       []xsdElement `xml:"sequence>element"`
+	Choice         []xsdElement `xml:"choice>element"`
+	SequenceChoice []xsdElement `xml:"sequence>choice>element"`
+}
+
+// elements returns the child elements of a complex type.
+func (ct xsdComplex) elements() []xsdElement {
+	var res []xsdElement
+	res = append(res, ct.Sequence...)
+	res = append(res, ct.Choice...)
+	return append(res, ct.SequenceChoice...)
+}
+
+type xsdElement struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// Generate derives the code lists from the enumerations of the given ISO and T2S schema files.
+// The schemas are keyed by the message identifier of their target namespace, e.g. sese.024.001.10.
+func Generate(version string, isoFiles, t2sFiles []string) (*Enumerations, error) {
+	e := &Enumerations{
+		Version: version,
+		ISO:     make(map[string]*SchemaLists),
+		T2S:     make(map[string]*SchemaLists),
+	}
+	for _, set := range []struct {
+		files  []string
+		target map[string]*SchemaLists
+	}{{isoFiles, e.ISO}, {t2sFiles, e.T2S}} {
+		for _, f := range set.files {
+			name, lists, err := generateSchema(f)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := set.target[name]; ok {
+				return nil, fmt.Errorf("codelist - %s: schema %s defined twice", f, name)
+			}
+			set.target[name] = lists
+		}
+	}
+	return e, nil
+}
+
+// generateSchema derives the code lists of a single schema file.
+func generateSchema(path string) (string, *SchemaLists, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	var s xsdSchema
+	if err := xml.Unmarshal(data, &s); err != nil {
+		return "", nil, fmt.Errorf("codelist - %s: %w", filepath.Base(path), err)
+	}
+	name := s.TargetNamespace[strings.LastIndex(s.TargetNamespace, ":")+1:]
+	if name == "" {
+		return "", nil, fmt.Errorf("codelist - %s: no target namespace", filepath.Base(path))
+	}
+
+	sl := &SchemaLists{
+		Fields:        make(map[string]string),
+		StatusReasons: make(map[string][]string),
+		Lists:         make(map[string][]Enum),
+	}
+	for _, st := range s.SimpleTypes {
+		for _, enum := range st.Enums {
+			e := Enum{Code: enum.Value}
+			for _, doc := range enum.Docs {
+				switch doc.Source {
+				case "Name":
+					e.Name = strings.TrimSpace(doc.Text)
+				case "Definition":
+					e.Definition = strings.TrimSpace(doc.Text)
+				}
+			}
+			sl.Lists[st.Name] = append(sl.Lists[st.Name], e)
+		}
+	}
+	complexTypes := make(map[string]xsdComplex)
+	for _, ct := range s.ComplexTypes {
+		complexTypes[ct.Name] = ct
+	}
+
+	// child returns the type of the named child element of a complex type
+	child := func(typeName, element string) string {
+		ct := complexTypes[localName(typeName)]
+		for _, e := range ct.elements() {
+			if e.Name == element {
+				return localName(e.Type)
+			}
+		}
+		return ""
+	}
+
+	for _, ct := range s.ComplexTypes {
+		for _, e := range ct.elements() {
+			for key, element := range fieldElements {
+				if _, ok := sl.Fields[key]; !ok && e.Name == element && sl.Lists[localName(e.Type)] != nil {
+					sl.Fields[key] = localName(e.Type)
+				}
+			}
+			if e.Name != processingStatusElement {
+				continue
+			}
+			status := complexTypes[localName(e.Type)]
+			for _, st := range status.elements() {
+				var lists []string
+				reason := child(child(child(st.Type, reasonElement), codeElement), codeElement)
+				if sl.Lists[reason] != nil {
+					lists = append(lists, reason)
+				}
+				if noReason := child(st.Type, noReasonElement); sl.Lists[noReason] != nil {
+					lists = append(lists, noReason)
+				}
+				if len(lists) > 0 {
+					sl.StatusReasons[st.Name] = lists
+				}
+			}
+		}
+	}
+	return name, sl, nil
+}
+
+// localName strips the namespace prefix of a type name.
+func localName(qName string) string {
+	return qName[strings.Index(qName, ":")+1:]
+}
+
+// SchemaFiles returns the schema version and the schema files the code lists are derived from: all schemas of
+// the ISO directory and the sese schemas of the T2S directory. The version is read from version.xml of the
+// T2S directory.
+func SchemaFiles(isoDir, t2sDir string) (string, []string, []string, error) {
+	data, err := os.ReadFile(filepath.Join(t2sDir, "version.xml"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var v struct {
+		ExternalVersion string `xml:"ExternalVersion"`
+	}
+	if err := xml.Unmarshal(data, &v); err != nil {
+		return "", nil, nil, fmt.Errorf("codelist - version.xml: %w", err)
+	}
+
+	isoFiles, err := filepath.Glob(filepath.Join(isoDir, "*.xsd"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	t2sFiles, err := filepath.Glob(filepath.Join(t2sDir, "ISO_T2S_Xml", "sese.*.xsd"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return v.ExternalVersion, isoFiles, t2sFiles, nil
+}
@@ -7,15 +7,32 @@ const (
 const (
 	// msg types
 	MsgTypeSese023Plus = "sese023plus"
+	MsgTypeSese020Plus = "sese020plus"
+	MsgTypeSese023     = "sese.023.001.10"
+	MsgTypeSese024     = "sese.024.001.10"
+	MsgTypeSese025     = "sese.025.001.09"
+	MsgTypeSese020     = "sese.020.001.06"
+	MsgTypeSese027     = "sese.027.001.05"
 )
 const (
 	// Result keys
-	TxIDKey             = "TxID"
-	MovementTypeKey     = "MovementType"
-	PaymentTypeKey      = "PaymentType"
-	MessageTypeKey      = "MessageType"
-	ReceivedFromKey     = "ReceivedFrom"
-	InstructingPartyKey = "InstructingParty"
+	TxIDKey               = "TxID"
+	MovementTypeKey       = "MovementType"
+	PaymentTypeKey        = "PaymentType"
+	MessageTypeKey        = "MessageType"
+	ReceivedFromKey       = "ReceivedFrom"
+	InstructingPartyKey   = "InstructingParty"
+	MktInfrstrctrTxIDKey  = "MktInfrstrctrTxID"
+	PrcrTxIDKey           = "PrcrTxID"
+	ISINKey               = "ISIN"
+	QuantityKey           = "Quantity"
+	DeliveringPartyKey    = "DeliveringParty"
+	ReceivingPartyKey     = "ReceivingParty"
+	ProcessingStatusKey   = "ProcessingStatus"
+	MatchingStatusKey     = "MatchingStatus"
+	StatusReasonKey       = "StatusReason"
+	SettlementDateKey     = "SettlementDate"
+	SafekeepingAccountKey = "SafekeepingAccount"
 )
 
 const (
@@ -29,4 +46,72 @@ const (
 	sese023AppHdrRltd       = "/CST2SMsg/T2SPayload/cst2s:AppHdr/Rltd/Fr/FIId/FinInstnId/BICFI"
 	sese023AppHdrMsgDefIdfr = "/CST2SMsg/T2SPayload/cst2s:AppHdr/MsgDefIdr"
 	sese023ReceivedFrom     = "/CST2SMsg/CSPayload/IntApplHead/ApplFrom/Id"
+	sese023ISIN             = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/FinInstrmId/ISIN"
+	sese023Quantity         = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/QtyAndAcctDtls/SttlmQty/Qty/*"
+	sese023DeliveringParty  = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/DlvrgSttlmPties/Pty1/Id"
+	sese023ReceivingParty   = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/RcvgSttlmPties/Pty1/Id"
+	sese023SettlementDate   = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/TradDtls/SttlmDt/Dt/Dt"
+	sese023SfkpgAcct        = "/CST2SMsg/T2SPayload/Document/SctiesSttlmTxInstr/QtyAndAcctDtls/SfkpgAcct/Id"
+
+	// sese 023 ex CREATION (pure ISO)
+	sese023IsoTxID            = "/Document/SctiesSttlmTxInstr/TxId"
+	sese023IsoMovementType    = "/Document/SctiesSttlmTxInstr/SttlmTpAndAddtlParams/SctiesMvmntTp"
+	sese023IsoPaymentType     = "/Document/SctiesSttlmTxInstr/SttlmTpAndAddtlParams/Pmt"
+	sese023IsoISIN            = "/Document/SctiesSttlmTxInstr/FinInstrmId/ISIN"
+	sese023IsoQuantity        = "/Document/SctiesSttlmTxInstr/QtyAndAcctDtls/SttlmQty/Qty/*"
+	sese023IsoDeliveringParty = "/Document/SctiesSttlmTxInstr/DlvrgSttlmPties/Pty1/Id"
+	sese023IsoReceivingParty  = "/Document/SctiesSttlmTxInstr/RcvgSttlmPties/Pty1/Id"
+	sese023IsoSettlementDate  = "/Document/SctiesSttlmTxInstr/TradDtls/SttlmDt/Dt/Dt"
+	sese023IsoSfkpgAcct       = "/Document/SctiesSttlmTxInstr/QtyAndAcctDtls/SfkpgAcct/Id"
+
+	// sese 024 (pure ISO)
+	sese024TxID              = "/Document/SctiesSttlmTxStsAdvc/TxId/AcctOwnrTxId"
+	sese024MktInfrstrctrTxID = "/Document/SctiesSttlmTxStsAdvc/TxId/MktInfrstrctrTxId"
+	sese024PrcrTxID          = "/Document/SctiesSttlmTxStsAdvc/TxId/PrcrTxId"
+	sese024ProcessingStatus  = "/Document/SctiesSttlmTxStsAdvc/PrcgSts"
+	sese024MatchingStatus    = "/Document/SctiesSttlmTxStsAdvc/MtchgSts"
+	sese024StatusReason      = "/Document/SctiesSttlmTxStsAdvc/PrcgSts/*/Rsn/Cd/Cd | /Document/SctiesSttlmTxStsAdvc/PrcgSts/*/NoSpcfdRsn"
+	sese024MovementType      = "/Document/SctiesSttlmTxStsAdvc/TxDtls/SctiesMvmntTp"
+	sese024PaymentType       = "/Document/SctiesSttlmTxStsAdvc/TxDtls/Pmt"
+	sese024ISIN              = "/Document/SctiesSttlmTxStsAdvc/TxDtls/FinInstrmId/ISIN"
+	sese024Quantity          = "/Document/SctiesSttlmTxStsAdvc/TxDtls/SttlmQty/Qty/*"
+	sese024DeliveringParty   = "/Document/SctiesSttlmTxStsAdvc/TxDtls/DlvrgSttlmPties/Pty1/Id"
+	sese024ReceivingParty    = "/Document/SctiesSttlmTxStsAdvc/TxDtls/RcvgSttlmPties/Pty1/Id"
+
+	// sese 025 (pure ISO)
+	sese025TxID              = "/Document/SctiesSttlmTxConf/TxIdDtls/AcctOwnrTxId"
+	sese025MktInfrstrctrTxID = "/Document/SctiesSttlmTxConf/TxIdDtls/MktInfrstrctrTxId"
+	sese025PrcrTxID          = "/Document/SctiesSttlmTxConf/TxIdDtls/PrcrTxId"
+	sese025MovementType      = "/Document/SctiesSttlmTxConf/TxIdDtls/SctiesMvmntTp"
+	sese025PaymentType       = "/Document/SctiesSttlmTxConf/TxIdDtls/Pmt"
+	sese025ISIN              = "/Document/SctiesSttlmTxConf/FinInstrmId/ISIN"
+	sese025Quantity          = "/Document/SctiesSttlmTxConf/QtyAndAcctDtls/SttldQty/Qty/*"
+	sese025DeliveringParty   = "/Document/SctiesSttlmTxConf/DlvrgSttlmPties/Pty1/Id"
+	sese025ReceivingParty    = "/Document/SctiesSttlmTxConf/RcvgSttlmPties/Pty1/Id"
+
+	// sese 020 (pure ISO), the T2S variant uses the same paths below sese020T2SRoot
+	sese020TxID              = "/Document/SctiesTxCxlReq/AcctOwnrTxId/SctiesSttlmTxId/TxId"
+	sese020MovementType      = "/Document/SctiesTxCxlReq/AcctOwnrTxId/SctiesSttlmTxId/SctiesMvmntTp"
+	sese020PaymentType       = "/Document/SctiesTxCxlReq/AcctOwnrTxId/SctiesSttlmTxId/Pmt"
+	sese020MktInfrstrctrTxID = "/Document/SctiesTxCxlReq/MktInfrstrctrTxId"
+	sese020PrcrTxID          = "/Document/SctiesTxCxlReq/PrcrTxId"
+	sese020ISIN              = "/Document/SctiesTxCxlReq/TxDtls/FinInstrmId/ISIN"
+	sese020Quantity          = "/Document/SctiesTxCxlReq/TxDtls/SttlmQty/Qty/*"
+	sese020DeliveringParty   = "/Document/SctiesTxCxlReq/TxDtls/DlvrgSttlmPties/Pty1/Id"
+	sese020ReceivingParty    = "/Document/SctiesTxCxlReq/TxDtls/RcvgSttlmPties/Pty1/Id"
+	sese020T2SRoot           = "/CST2SMsg/T2SPayload"
+	sese020AppHdrMsgDefIdfr  = "/CST2SMsg/T2SPayload/cst2s:AppHdr/MsgDefIdr"
+
+	// sese 027 (pure ISO)
+	sese027TxID              = "/Document/SctiesTxCxlReqStsAdvc/TxId/AcctOwnrTxId/SctiesSttlmTxId/TxId"
+	sese027MovementType      = "/Document/SctiesTxCxlReqStsAdvc/TxId/AcctOwnrTxId/SctiesSttlmTxId/SctiesMvmntTp"
+	sese027PaymentType       = "/Document/SctiesTxCxlReqStsAdvc/TxId/AcctOwnrTxId/SctiesSttlmTxId/Pmt"
+	sese027MktInfrstrctrTxID = "/Document/SctiesTxCxlReqStsAdvc/TxId/MktInfrstrctrTxId"
+	sese027PrcrTxID          = "/Document/SctiesTxCxlReqStsAdvc/TxId/PrcrTxId"
+	sese027ProcessingStatus  = "/Document/SctiesTxCxlReqStsAdvc/PrcgSts"
+	sese027StatusReason      = "/Document/SctiesTxCxlReqStsAdvc/PrcgSts/*/Rsn/Cd/Cd | /Document/SctiesTxCxlReqStsAdvc/PrcgSts/*/NoSpcfdRsn"
+	sese027ISIN              = "/Document/SctiesTxCxlReqStsAdvc/TxDtls/FinInstrmId/ISIN"
+	sese027Quantity          = "/Document/SctiesTxCxlReqStsAdvc/TxDtls/SttlmQty/Qty/*"
+	sese027DeliveringParty   = "/Document/SctiesTxCxlReqStsAdvc/TxDtls/DlvrgSttlmPties/Pty1/Id"
+	sese027ReceivingParty    = "/Document/SctiesTxCxlReqStsAdvc/TxDtls/RcvgSttlmPties/Pty1/Id"
 )
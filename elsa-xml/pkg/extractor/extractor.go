@@ -58,6 +58,18 @@ func getExParams(msgType string) []extractionParam {
 	switch msgType {
 	case MsgTypeSese023Plus:
 		return sese023tsExtractors()
+	case MsgTypeSese023:
+		return sese023Extractors()
+	case MsgTypeSese024:
+		return sese024Extractors()
+	case MsgTypeSese025:
+		return sese025Extractors()
+	case MsgTypeSese020:
+		return sese020Extractors()
+	case MsgTypeSese020Plus:
+		return sese020t2sExtractors()
+	case MsgTypeSese027:
+		return sese027Extractors()
 	default:
 		return nil
 	}
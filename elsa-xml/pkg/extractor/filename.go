@@ -0,0 +1,22 @@
+package extractor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MsgTypeFromFileName derives the message type from the name of a test data file, e.g. sese.024.001.10 of
+// sese.024.001.10_iso_ok.xml and sese023plus of sese.023_t2s_ok.xml. A directory of the path is ignored.
+func MsgTypeFromFileName(fileName string) (string, error) {
+	parts := strings.Split(filepath.Base(fileName), "_")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("%s is not a valid schema name", fileName)
+	}
+
+	mName := parts[0]
+	if parts[1] == "t2s" {
+		mName = strings.ReplaceAll(parts[0], ".", "") + "plus"
+	}
+	return mName, nil
+}
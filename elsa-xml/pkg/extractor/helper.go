@@ -16,3 +16,54 @@ func findOne(node *xmlquery.Node, path string) string {
 	}
 	return res.InnerText()
 }
+
+// xPathMapping maps a result key to the XPath expression its value is read from.
+type xPathMapping struct {
+	mapKey string
+	xPath  string
+}
+
+// simpleExtractors creates an extractionParam for each mapping (no logic, just value retrieval).
+func simpleExtractors(mappings []xPathMapping) []extractionParam {
+	res := make([]extractionParam, 0, len(mappings))
+	for _, m := range mappings {
+		res = append(res, extractionParam{m.mapKey, createExtractorFunc(m.xPath)})
+	}
+	return res
+}
+
+// createStatusExtractorFunc creates an extractor function that returns the element name of the chosen
+// status (e.g. "AckdAccptd", "Rjctd" or "Mtchd") below the given status choice element.
+// If the status choice is not present, it returns an empty string.
+func createStatusExtractorFunc(path string) extractorFunc {
+	return func(node *xmlquery.Node) string {
+		res := xmlquery.FindOne(node, path+"/*")
+		if res == nil {
+			return ""
+		}
+		return res.Data
+	}
+}
+
+// createPartyExtractorFunc creates an extractor function that returns the identification of a settlement party.
+// The party is either identified by a BIC or by a proprietary identification, the latter is returned as "issuer/id".
+func createPartyExtractorFunc(path string) extractorFunc {
+	return func(node *xmlquery.Node) string {
+		if bic := findOne(node, path+"/AnyBIC"); bic != "" {
+			return bic
+		}
+		id := findOne(node, path+"/PrtryId/Id")
+		if id == "" {
+			return ""
+		}
+		return findOne(node, path+"/PrtryId/Issr") + "/" + id
+	}
+}
+
+// constantExtractorFunc creates an extractor function that always returns the given value.
+// It is used for values which are implied by the message type rather than contained in the message.
+func constantExtractorFunc(value string) extractorFunc {
+	return func(_ *xmlquery.Node) string {
+		return value
+	}
+}
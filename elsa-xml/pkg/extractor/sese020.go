@@ -0,0 +1,31 @@
+package extractor
+
+// sese020Extractors extracts parameters from the pure ISO sese020 cancellation request.
+func sese020Extractors() []extractionParam {
+	return append(sese020Params(""), extractionParam{MessageTypeKey, constantExtractorFunc(MsgTypeSese020)})
+}
+
+// sese020t2sExtractors extracts parameters from the ISO20022+ sese020 cancellation request received from T2S.
+func sese020t2sExtractors() []extractionParam {
+	return append(sese020Params(sese020T2SRoot), extractionParam{MessageTypeKey, createExtractorFunc(sese020AppHdrMsgDefIdfr)})
+}
+
+// sese020Params returns the extraction parameters shared by both sese020 variants.
+// The root is prepended to every path, as the ISO20022+ message wraps the ISO document.
+func sese020Params(root string) []extractionParam {
+	res := simpleExtractors([]xPathMapping{
+		{TxIDKey, root + sese020TxID},
+		{MovementTypeKey, root + sese020MovementType},
+		{PaymentTypeKey, root + sese020PaymentType},
+		{MktInfrstrctrTxIDKey, root + sese020MktInfrstrctrTxID},
+		{PrcrTxIDKey, root + sese020PrcrTxID},
+		{ISINKey, root + sese020ISIN},
+		{QuantityKey, root + sese020Quantity},
+	})
+
+	res = append(res,
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(root + sese020DeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(root + sese020ReceivingParty)},
+	)
+	return res
+}
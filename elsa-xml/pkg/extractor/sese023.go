@@ -0,0 +1,21 @@
+package extractor
+
+// sese023Extractors extracts parameters from the pure ISO sese023 message (as exchanged with CREATION).
+func sese023Extractors() []extractionParam {
+	res := simpleExtractors([]xPathMapping{
+		{TxIDKey, sese023IsoTxID},
+		{MovementTypeKey, sese023IsoMovementType},
+		{PaymentTypeKey, sese023IsoPaymentType},
+		{ISINKey, sese023IsoISIN},
+		{QuantityKey, sese023IsoQuantity},
+		{SettlementDateKey, sese023IsoSettlementDate},
+		{SafekeepingAccountKey, sese023IsoSfkpgAcct},
+	})
+
+	res = append(res,
+		extractionParam{MessageTypeKey, constantExtractorFunc(MsgTypeSese023)},
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(sese023IsoDeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(sese023IsoReceivingParty)},
+	)
+	return res
+}
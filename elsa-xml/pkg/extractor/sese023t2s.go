@@ -5,26 +5,25 @@ import "github.com/antchfx/xmlquery"
 // sese023tsExtractors extracts parameters from the sese023 message.
 // It returns a slice of extractionParam which contains the mapping keys and their corresponding extractor functions.
 func sese023tsExtractors() []extractionParam {
-	res := make([]extractionParam, 0)
-
 	// simple extractions (no logic, just value retrieval)
-	extractions := []struct {
-		mapKey string
-		xPath  string
-	}{
+	res := simpleExtractors([]xPathMapping{
 		{TxIDKey, sese023TxID},
 		{MovementTypeKey, sese023MovementType},
 		{PaymentTypeKey, sese023PaymentType},
 		{MessageTypeKey, sese023AppHdrMsgDefIdfr},
 		{ReceivedFromKey, sese023ReceivedFrom},
-	}
-
-	for _, v := range extractions {
-		res = append(res, extractionParam{v.mapKey, createExtractorFunc(v.xPath)})
-	}
+		{ISINKey, sese023ISIN},
+		{QuantityKey, sese023Quantity},
+		{SettlementDateKey, sese023SettlementDate},
+		{SafekeepingAccountKey, sese023SfkpgAcct},
+	})
 
 	// special extraction (logic involved)
-	res = append(res, extractionParam{InstructingPartyKey, sese023t2sInstructingPartyKey()})
+	res = append(res,
+		extractionParam{InstructingPartyKey, sese023t2sInstructingPartyKey()},
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(sese023DeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(sese023ReceivingParty)},
+	)
 	return res
 }
 
@@ -0,0 +1,25 @@
+package extractor
+
+// sese024Extractors extracts parameters from the sese024 status advice.
+// TxID holds the account owner transaction id, i.e. the TxId of the instruction the advice refers to.
+func sese024Extractors() []extractionParam {
+	res := simpleExtractors([]xPathMapping{
+		{TxIDKey, sese024TxID},
+		{MktInfrstrctrTxIDKey, sese024MktInfrstrctrTxID},
+		{PrcrTxIDKey, sese024PrcrTxID},
+		{MovementTypeKey, sese024MovementType},
+		{PaymentTypeKey, sese024PaymentType},
+		{ISINKey, sese024ISIN},
+		{QuantityKey, sese024Quantity},
+		{StatusReasonKey, sese024StatusReason},
+	})
+
+	res = append(res,
+		extractionParam{MessageTypeKey, constantExtractorFunc(MsgTypeSese024)},
+		extractionParam{ProcessingStatusKey, createStatusExtractorFunc(sese024ProcessingStatus)},
+		extractionParam{MatchingStatusKey, createStatusExtractorFunc(sese024MatchingStatus)},
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(sese024DeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(sese024ReceivingParty)},
+	)
+	return res
+}
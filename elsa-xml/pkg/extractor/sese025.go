@@ -0,0 +1,22 @@
+package extractor
+
+// sese025Extractors extracts parameters from the sese025 settlement confirmation.
+// TxID holds the account owner transaction id, Quantity the settled quantity.
+func sese025Extractors() []extractionParam {
+	res := simpleExtractors([]xPathMapping{
+		{TxIDKey, sese025TxID},
+		{MktInfrstrctrTxIDKey, sese025MktInfrstrctrTxID},
+		{PrcrTxIDKey, sese025PrcrTxID},
+		{MovementTypeKey, sese025MovementType},
+		{PaymentTypeKey, sese025PaymentType},
+		{ISINKey, sese025ISIN},
+		{QuantityKey, sese025Quantity},
+	})
+
+	res = append(res,
+		extractionParam{MessageTypeKey, constantExtractorFunc(MsgTypeSese025)},
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(sese025DeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(sese025ReceivingParty)},
+	)
+	return res
+}
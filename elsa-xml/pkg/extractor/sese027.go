@@ -0,0 +1,23 @@
+package extractor
+
+// sese027Extractors extracts parameters from the sese027 cancellation request status advice.
+func sese027Extractors() []extractionParam {
+	res := simpleExtractors([]xPathMapping{
+		{TxIDKey, sese027TxID},
+		{MovementTypeKey, sese027MovementType},
+		{PaymentTypeKey, sese027PaymentType},
+		{MktInfrstrctrTxIDKey, sese027MktInfrstrctrTxID},
+		{PrcrTxIDKey, sese027PrcrTxID},
+		{ISINKey, sese027ISIN},
+		{QuantityKey, sese027Quantity},
+		{StatusReasonKey, sese027StatusReason},
+	})
+
+	res = append(res,
+		extractionParam{MessageTypeKey, constantExtractorFunc(MsgTypeSese027)},
+		extractionParam{ProcessingStatusKey, createStatusExtractorFunc(sese027ProcessingStatus)},
+		extractionParam{DeliveringPartyKey, createPartyExtractorFunc(sese027DeliveringParty)},
+		extractionParam{ReceivingPartyKey, createPartyExtractorFunc(sese027ReceivingParty)},
+	)
+	return res
+}
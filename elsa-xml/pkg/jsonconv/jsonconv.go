@@ -0,0 +1,199 @@
+// Package jsonconv converts ISO20022 and T2S messages between XML and a canonical JSON representation.
+//
+// The conversion from XML uses the schema of the message, the conversion back to XML does not need it:
+//
+//   - every element becomes a member named by its local name, the document element is the only member
+//     of the top-level object
+//   - elements declared as repeating (maxOccurs > 1 of the element or of an enclosing group) are arrays,
+//     even if only one element is present
+//   - elements with simple content become scalar values: decimals are written as JSON numbers with the
+//     lexical representation of the message (no loss of precision), booleans as JSON booleans and
+//     everything else as strings
+//   - attributes are members with the prefix "@", e.g. "@Ccy", attributes in a namespace are written as
+//     "@{namespace}local"
+//   - the namespace of an element is written as member "@xmlns" if it differs from the one of its parent,
+//     elements with attributes or a namespace declaration hold their simple content in the member "#text"
+//   - if elements with the same name are not adjacent (e.g. in a repeating choice), the children are written
+//     in document order as array "#content" of single member objects
+//
+// Namespace prefixes and whitespace between elements are not kept, the converted document is equivalent
+// to the original one with respect to element names, namespaces, attributes and character data.
+package jsonconv
+
+import (
+	"bytes"
+	"elsa-xml/pkg/schema"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const (
+	attrPrefix = "@"
+	xmlnsKey   = "@xmlns"
+	textKey    = "#text"
+	contentKey = "#content"
+)
+
+// numberTypes are the built-in types written as JSON numbers.
+var numberTypes = map[string]bool{
+	"decimal": true, "integer": true, "long": true, "int": true, "short": true, "byte": true,
+	"nonNegativeInteger": true, "positiveInteger": true, "double": true, "float": true,
+}
+
+// jsonNumber matches the values which can be written as JSON number literal unchanged.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+
+// FromXML validates a message against the schema set and converts it to its canonical JSON representation.
+// A *schema.ValidationError is returned if the message is not valid.
+func FromXML(s *schema.Set, data []byte) ([]byte, error) {
+	root, err := s.Annotate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeString(&buf, root.Name.Local)
+	buf.WriteByte(':')
+	writeElement(&buf, root, "")
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeElement writes the value of an element, parentNS is the namespace of its parent.
+func writeElement(buf *bytes.Buffer, e *schema.Element, parentNS string) {
+	simple := e.Type != "" || (len(e.Children) == 0 && strings.TrimSpace(e.Text) != "")
+	nsChanged := e.Name.Space != parentNS
+	if simple && !nsChanged && len(e.Attrs) == 0 {
+		writeScalar(buf, e)
+		return
+	}
+
+	m := members{buf: buf}
+	buf.WriteByte('{')
+	if nsChanged {
+		m.key(xmlnsKey)
+		writeString(buf, e.Name.Space)
+	}
+	for _, a := range e.Attrs {
+		name := a.Name.Local
+		if a.Name.Space != "" {
+			name = "{" + a.Name.Space + "}" + name
+		}
+		m.key(attrPrefix + name)
+		writeString(buf, a.Value)
+	}
+	if simple {
+		m.key(textKey)
+		writeScalar(buf, e)
+	} else {
+		if strings.TrimSpace(e.Text) != "" {
+			// character data of mixed content
+			m.key(textKey)
+			writeString(buf, e.Text)
+		}
+		writeChildren(&m, e)
+	}
+	buf.WriteByte('}')
+}
+
+// writeChildren writes the child elements of an element as members, grouping repeating elements into arrays.
+func writeChildren(m *members, e *schema.Element) {
+	runs, ordered := childRuns(e.Children)
+	if ordered {
+		m.key(contentKey)
+		m.buf.WriteByte('[')
+		for i, child := range e.Children {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			m.buf.WriteByte('{')
+			writeString(m.buf, child.Name.Local)
+			m.buf.WriteByte(':')
+			writeElement(m.buf, child, e.Name.Space)
+			m.buf.WriteByte('}')
+		}
+		m.buf.WriteByte(']')
+		return
+	}
+
+	for _, run := range runs {
+		m.key(run[0].Name.Local)
+		if len(run) == 1 && !run[0].Repeated {
+			writeElement(m.buf, run[0], e.Name.Space)
+			continue
+		}
+		m.buf.WriteByte('[')
+		for i, child := range run {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			writeElement(m.buf, child, e.Name.Space)
+		}
+		m.buf.WriteByte(']')
+	}
+}
+
+// childRuns groups adjacent elements with the same name. ordered is true if the runs cannot be written as
+// members because a local name is used by more than one run.
+func childRuns(children []*schema.Element) ([][]*schema.Element, bool) {
+	var runs [][]*schema.Element
+	seen := make(map[string]bool)
+	for _, child := range children {
+		if len(runs) > 0 {
+			last := runs[len(runs)-1]
+			if last[0].Name == child.Name {
+				runs[len(runs)-1] = append(last, child)
+				continue
+			}
+		}
+		if seen[child.Name.Local] {
+			return nil, true
+		}
+		seen[child.Name.Local] = true
+		runs = append(runs, []*schema.Element{child})
+	}
+	return runs, false
+}
+
+// writeScalar writes the simple content of an element according to its type.
+func writeScalar(buf *bytes.Buffer, e *schema.Element) {
+	switch {
+	case numberTypes[e.Type] && jsonNumber.MatchString(e.Text):
+		buf.WriteString(e.Text)
+	case e.Type == "boolean" && (e.Text == "true" || e.Text == "false"):
+		buf.WriteString(e.Text)
+	default:
+		writeString(buf, e.Text)
+	}
+}
+
+// writeString writes a JSON string without escaping HTML characters.
+func writeString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)           // encoding a string does not fail
+	buf.Truncate(buf.Len() - 1) // remove the newline added by Encode
+}
+
+// members writes the separators between the members of an object.
+type members struct {
+	buf   *bytes.Buffer
+	count int
+}
+
+// key writes the key of the next member.
+func (m *members) key(k string) {
+	if m.count > 0 {
+		m.buf.WriteByte(',')
+	}
+	m.count++
+	writeString(m.buf, k)
+	m.buf.WriteByte(':')
+}
@@ -0,0 +1,166 @@
+package jsonconv
+
+import (
+	"bytes"
+	"elsa-xml/pkg/schema"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const (
+	schemaDir   = "../../schemas"
+	testDataDir = "../../testdata/full"
+)
+
+// TestRoundTrip converts every valid fixture to JSON and back and checks that the documents are equivalent.
+// Invalid fixtures must be rejected.
+func TestRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(testDataDir, "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	sets := make(map[string]*schema.Set)
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			s := loadSchema(t, sets, name)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			j, err := FromXML(s, data)
+			if strings.Contains(name, "not_ok") {
+				var vErr *schema.ValidationError
+				if !errors.As(err, &vErr) {
+					t.Fatalf("invalid fixture: expected validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromXML: %v", err)
+			}
+			if !json.Valid(j) {
+				t.Fatalf("FromXML returned invalid JSON:\n%s", j)
+			}
+
+			x, err := ToXML(j)
+			if err != nil {
+				t.Fatalf("ToXML: %v", err)
+			}
+			if want, got := canonical(t, data), canonical(t, x); want != got {
+				t.Errorf("documents differ after round trip\nwant:\n%s\ngot:\n%s", want, got)
+			}
+
+			j2, err := FromXML(s, x)
+			if err != nil {
+				t.Fatalf("FromXML of converted document: %v", err)
+			}
+			if !bytes.Equal(j, j2) {
+				t.Errorf("JSON differs after round trip\nwant:\n%s\ngot:\n%s", j, j2)
+			}
+		})
+	}
+}
+
+// TestRepresentation checks arrays for repeating elements, decimal precision and namespaces.
+func TestRepresentation(t *testing.T) {
+	s := loadSchema(t, map[string]*schema.Set{}, "sese.023_t2s_ok.xml")
+	data, err := os.ReadFile(filepath.Join(testDataDir, "sese.023_t2s_ok.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(">500<"), []byte(">500.12340<"), 1)
+
+	j, err := FromXML(s, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"FaceAmt": 500.12340`,
+		`"@xmlns": "urn:iso:std:iso:20022:tech:xsd:sese.023.001.09"`,
+		`"@Ccy": "EUR"`,
+		`"TradId": [`,
+	} {
+		if !bytes.Contains(j, []byte(want)) {
+			t.Errorf("JSON does not contain %s:\n%s", want, j)
+		}
+	}
+}
+
+// loadSchema loads the schema of a fixture, the schema name is derived from the file name as in the demo.
+func loadSchema(t *testing.T, sets map[string]*schema.Set, fileName string) *schema.Set {
+	t.Helper()
+	parts := strings.Split(fileName, "_")
+	path := filepath.Join(schemaDir, "ISO", parts[0]+".xsd")
+	if parts[1] == "t2s" {
+		path = filepath.Join(schemaDir, "T2S", "CST2SMsg.valid.xsd")
+	}
+	if s, ok := sets[path]; ok {
+		return s
+	}
+	s, err := schema.Load(path)
+	if err != nil {
+		t.Fatalf("load schema %s: %v", path, err)
+	}
+	sets[path] = s
+	return s
+}
+
+// canonical returns a line per element with its path, attributes and character data.
+// Whitespace-only character data between elements is ignored.
+func canonical(t *testing.T, data []byte) string {
+	t.Helper()
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var lines, path []string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			flushText(&lines, path, &text)
+			path = append(path, "{"+tok.Name.Space+"}"+tok.Name.Local)
+			var attrs []string
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				attrs = append(attrs, fmt.Sprintf("{%s}%s=%q", a.Name.Space, a.Name.Local, a.Value))
+			}
+			sort.Strings(attrs)
+			lines = append(lines, strings.Join(path, "/")+" "+strings.Join(attrs, " "))
+		case xml.EndElement:
+			flushText(&lines, path, &text)
+			path = path[:len(path)-1]
+		case xml.CharData:
+			text.Write(tok)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flushText adds the collected character data as line unless it is whitespace only.
+func flushText(lines *[]string, path []string, text *strings.Builder) {
+	if strings.TrimSpace(text.String()) != "" {
+		*lines = append(*lines, strings.Join(path, "/")+" = "+fmt.Sprintf("%q", text.String()))
+	}
+	text.Reset()
+}
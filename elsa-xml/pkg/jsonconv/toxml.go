@@ -0,0 +1,249 @@
+package jsonconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// member is a member of a JSON object, objects are read as slices of members to keep the member order.
+type member struct {
+	key   string
+	value any
+}
+
+// object is a JSON object with its members in document order.
+type object []member
+
+// ToXML converts the canonical JSON representation of a message back to XML.
+func ToXML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := readValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jsonconv - unexpected data after the document")
+	}
+	root, ok := v.(object)
+	if !ok || len(root) != 1 {
+		return nil, errors.New("jsonconv - the document must be an object with a single member")
+	}
+
+	w := &xmlWriter{}
+	w.buf.WriteString(xml.Header)
+	if err := w.element(root[0].key, root[0].value, ""); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// readValue reads a JSON value, objects are returned as object, numbers as json.Number.
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	switch d {
+	case '{':
+		var o object
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: k.(string), value: v})
+		}
+		_, err = dec.Token()
+		return o, err
+	case '[':
+		a := []any{}
+		for dec.More() {
+			v, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err = dec.Token()
+		return a, err
+	}
+	return nil, fmt.Errorf("jsonconv - unexpected delimiter %s", d)
+}
+
+// xmlWriter writes the XML document.
+type xmlWriter struct {
+	buf bytes.Buffer
+}
+
+// element writes the element with the given local name, parentNS is the namespace of its parent.
+// Arrays are written as repeated elements.
+func (w *xmlWriter) element(name string, v any, parentNS string) error {
+	if strings.HasPrefix(name, attrPrefix) || strings.HasPrefix(name, "#") {
+		return fmt.Errorf("jsonconv - unexpected member %s", name)
+	}
+	if a, ok := v.([]any); ok {
+		for _, item := range a {
+			if _, ok := item.([]any); ok {
+				return fmt.Errorf("jsonconv - nested array in %s", name)
+			}
+			if err := w.element(name, item, parentNS); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	o, ok := v.(object)
+	if !ok {
+		text, err := scalar(name, v)
+		if err != nil {
+			return err
+		}
+		w.buf.WriteString("<" + name + ">")
+		escape(&w.buf, text)
+		w.buf.WriteString("</" + name + ">")
+		return nil
+	}
+
+	ns := parentNS
+	var attrs []member
+	var text *string
+	var children object
+	for _, m := range o {
+		switch {
+		case m.key == xmlnsKey:
+			s, ok := m.value.(string)
+			if !ok {
+				return fmt.Errorf("jsonconv - %s: namespace must be a string", name)
+			}
+			ns = s
+		case m.key == textKey:
+			t, err := scalar(name, m.value)
+			if err != nil {
+				return err
+			}
+			text = &t
+		case m.key == contentKey:
+			items, ok := m.value.([]any)
+			if !ok {
+				return fmt.Errorf("jsonconv - %s: %s must be an array", name, contentKey)
+			}
+			for _, item := range items {
+				c, ok := item.(object)
+				if !ok || len(c) != 1 {
+					return fmt.Errorf("jsonconv - %s: %s items must be objects with a single member", name, contentKey)
+				}
+				children = append(children, c[0])
+			}
+		case strings.HasPrefix(m.key, attrPrefix):
+			attrs = append(attrs, m)
+		default:
+			children = append(children, m)
+		}
+	}
+
+	w.buf.WriteString("<" + name)
+	if ns != parentNS {
+		w.buf.WriteString(` xmlns="`)
+		escape(&w.buf, ns)
+		w.buf.WriteString(`"`)
+	}
+	if err := w.attributes(name, attrs); err != nil {
+		return err
+	}
+	w.buf.WriteString(">")
+	if text != nil {
+		escape(&w.buf, *text)
+	}
+	for _, c := range children {
+		if err := w.element(c.key, c.value, ns); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// attributes writes the attributes of an element, declaring prefixes for attributes in a namespace.
+func (w *xmlWriter) attributes(elem string, attrs []member) error {
+	prefixes := make(map[string]string)
+	var decls, values bytes.Buffer
+	for _, a := range attrs {
+		value, err := scalar(elem, a.value)
+		if err != nil {
+			return err
+		}
+		name := attrName(a.key)
+		qName := name.Local
+		switch name.Space {
+		case "":
+		case "http://www.w3.org/XML/1998/namespace":
+			qName = "xml:" + name.Local
+		default:
+			prefix, ok := prefixes[name.Space]
+			if !ok {
+				prefix = "ns" + strconv.Itoa(len(prefixes)+1)
+				if name.Space == "http://www.w3.org/2001/XMLSchema-instance" {
+					prefix = "xsi"
+				}
+				prefixes[name.Space] = prefix
+				decls.WriteString(" xmlns:" + prefix + `="`)
+				escape(&decls, name.Space)
+				decls.WriteString(`"`)
+			}
+			qName = prefix + ":" + name.Local
+		}
+		values.WriteString(" " + qName + `="`)
+		escape(&values, value)
+		values.WriteString(`"`)
+	}
+	w.buf.Write(decls.Bytes())
+	w.buf.Write(values.Bytes())
+	return nil
+}
+
+// scalar returns the text of a scalar value.
+func scalar(name string, v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("jsonconv - %s: unexpected value", name)
+}
+
+// escape writes the text with XML escaping.
+func escape(buf *bytes.Buffer, s string) {
+	_ = xml.EscapeText(buf, []byte(s)) // writing to a buffer does not fail
+}
+
+// attrName parses the name of an attribute member.
+func attrName(key string) xml.Name {
+	name := strings.TrimPrefix(key, attrPrefix)
+	if strings.HasPrefix(name, "{") {
+		if space, local, ok := strings.Cut(name[1:], "}"); ok {
+			return xml.Name{Space: space, Local: local}
+		}
+	}
+	return xml.Name{Local: name}
+}
@@ -0,0 +1,319 @@
+// Package lifecycle checks the consistency of all messages exchanged for one securities settlement instruction.
+//
+// A sese023 instruction is followed by sese024 status advices, sese020 cancellation requests, sese027
+// cancellation status advices and finally sese025 settlement confirmations. The messages are linked by
+// their transaction identifications. The checker verifies that the references, the ISIN, the quantity
+// and the parties stay consistent and that the sequence of statuses describes a legal state transition
+// path. The settled quantities of partial settlements add up to the instructed quantity.
+package lifecycle
+
+import (
+	"elsa-xml/pkg/extractor"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// noRef is used by ISO20022 for transaction identifications which are not known to the sender.
+const noRef = "NONREF"
+
+const (
+	// Violation rules
+	RuleOrder       = "order"
+	RuleReference   = "reference"
+	RuleConsistency = "consistency"
+	RuleStatus      = "status"
+	RuleTransition  = "transition"
+)
+
+// Message is a single message of an instruction lifecycle.
+// Type is the message type as expected by extractor.Extract, e.g. extractor.MsgTypeSese024.
+type Message struct {
+	Type string
+	XML  []byte
+}
+
+// Violation describes a single inconsistency found in a lifecycle.
+// Index is the position of the offending message in the checked slice.
+type Violation struct {
+	Index   int
+	MsgType string
+	Rule    string
+	Detail  string
+}
+
+// String returns a human-readable description of the violation.
+func (v Violation) String() string {
+	return fmt.Sprintf("message %d (%s): %s: %s", v.Index, v.MsgType, v.Rule, v.Detail)
+}
+
+// Report holds the result of a lifecycle check.
+type Report struct {
+	TxID              string
+	MktInfrstrctrTxID string
+	PrcrTxID          string
+	State             State
+	CxlState          State
+	Violations        []Violation
+}
+
+// Valid reports whether the lifecycle is free of violations.
+func (r *Report) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// consistentKeys are the values which must not change over the lifecycle of an instruction.
+var consistentKeys = []string{
+	extractor.ISINKey,
+	extractor.QuantityKey,
+	extractor.MovementTypeKey,
+	extractor.PaymentTypeKey,
+	extractor.DeliveringPartyKey,
+	extractor.ReceivingPartyKey,
+}
+
+// Check checks the given messages, ordered as they were exchanged, for one instruction.
+// The first message must be the instruction. Findings are returned as violations in the report,
+// an error is only returned if the messages could not be processed at all.
+func Check(msgs []Message) (*Report, error) {
+	if len(msgs) == 0 {
+		return nil, errors.New("lifecycle - no messages")
+	}
+
+	c := checker{report: &Report{}}
+	for i, m := range msgs {
+		k := messageKind(m.Type)
+		if k == kindUnknown {
+			return nil, fmt.Errorf("lifecycle - message %d: unsupported message type %s", i, m.Type)
+		}
+		res, err := extractor.Extract(m.XML, m.Type)
+		if err != nil {
+			return nil, fmt.Errorf("lifecycle - message %d: %w", i, err)
+		}
+		if i == 0 {
+			if k != kindInstruction {
+				c.violation(i, m.Type, RuleOrder, "lifecycle does not start with an instruction")
+				return c.report, nil
+			}
+			c.instruction = res
+			c.report.TxID = res.Value(extractor.TxIDKey)
+			c.report.State = StateInstructed
+			continue
+		}
+		c.check(i, m.Type, k, res)
+	}
+	return c.report, nil
+}
+
+// checker holds the state of a running lifecycle check.
+// settled is the quantity confirmed by the settlement confirmations so far.
+type checker struct {
+	instruction *extractor.ExtractionResult
+	report      *Report
+	settled     *big.Rat
+}
+
+// violation adds a violation to the report.
+func (c *checker) violation(i int, msgType, rule, format string, args ...any) {
+	c.report.Violations = append(c.report.Violations, Violation{
+		Index:   i,
+		MsgType: msgType,
+		Rule:    rule,
+		Detail:  fmt.Sprintf(format, args...),
+	})
+}
+
+// check checks a single message following the instruction.
+// Once the instruction reached a terminal state its state is not changed anymore.
+func (c *checker) check(i int, msgType string, k kind, res *extractor.ExtractionResult) {
+	c.checkReferences(i, msgType, res)
+	c.checkConsistency(i, msgType, k, res)
+	if terminal(c.report.State) {
+		c.violation(i, msgType, RuleOrder, "message received after terminal state %s", c.report.State)
+		return
+	}
+
+	switch k {
+	case kindInstruction:
+		c.violation(i, msgType, RuleOrder, "instruction repeated")
+	case kindStatusAdvice:
+		c.checkStatusAdvice(i, msgType, res)
+	case kindCxlRequest:
+		c.cxlTransition(i, msgType, StateCxlReq)
+	case kindCxlStatusAdvice:
+		c.checkCxlStatusAdvice(i, msgType, res)
+	case kindSettlementConfirmation:
+		c.checkSettlement(i, msgType, res)
+	}
+}
+
+// checkReferences checks that the message references the instruction and that the market infrastructure
+// and processor transaction ids do not change once they are known.
+func (c *checker) checkReferences(i int, msgType string, res *extractor.ExtractionResult) {
+	acctOwnrTxID := res.Value(extractor.TxIDKey)
+	if acctOwnrTxID == noRef {
+		acctOwnrTxID = ""
+	}
+	if acctOwnrTxID != "" && acctOwnrTxID != c.report.TxID {
+		c.violation(i, msgType, RuleReference, "account owner transaction id %s does not match instruction %s", acctOwnrTxID, c.report.TxID)
+	}
+
+	linked := acctOwnrTxID == c.report.TxID
+	for _, ref := range []struct {
+		name  string
+		value string
+		known *string
+	}{
+		{"market infrastructure transaction id", res.Value(extractor.MktInfrstrctrTxIDKey), &c.report.MktInfrstrctrTxID},
+		{"processor transaction id", res.Value(extractor.PrcrTxIDKey), &c.report.PrcrTxID},
+	} {
+		if ref.value == "" || ref.value == noRef {
+			continue
+		}
+		if ref.value == c.report.TxID {
+			linked = true
+		}
+		if *ref.known == "" {
+			*ref.known = ref.value
+			continue
+		}
+		if *ref.known != ref.value {
+			c.violation(i, msgType, RuleReference, "%s %s does not match %s", ref.name, ref.value, *ref.known)
+			continue
+		}
+		linked = true
+	}
+
+	if !linked {
+		c.violation(i, msgType, RuleReference, "message does not reference instruction %s", c.report.TxID)
+	}
+}
+
+// checkConsistency checks that the values of the instruction which are repeated in the message did not change.
+// The quantity of a settlement confirmation is the settled part, it is checked by checkSettlement.
+func (c *checker) checkConsistency(i int, msgType string, k kind, res *extractor.ExtractionResult) {
+	for _, key := range consistentKeys {
+		if key == extractor.QuantityKey && k == kindSettlementConfirmation {
+			continue
+		}
+		want := c.instruction.Value(key)
+		got := res.Value(key)
+		if want == "" || got == "" || equalValue(key, want, got) {
+			continue
+		}
+		c.violation(i, msgType, RuleConsistency, "%s %s differs from instruction value %s", key, got, want)
+	}
+}
+
+// equalValue compares two extracted values, quantities are compared numerically.
+func equalValue(key, a, b string) bool {
+	if key != extractor.QuantityKey {
+		return a == b
+	}
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return a == b
+	}
+	return ra.Cmp(rb) == 0
+}
+
+// quantity formats a quantity for messages. ISO20022 quantities have at most 17 fraction digits.
+func quantity(r *big.Rat) string {
+	if r.IsInt() {
+		return r.RatString()
+	}
+	return strings.TrimRight(r.FloatString(17), "0")
+}
+
+// checkStatusAdvice applies the processing and matching status of a sese024 to the instruction state.
+func (c *checker) checkStatusAdvice(i int, msgType string, res *extractor.ExtractionResult) {
+	statuses := []struct {
+		value  string
+		states map[string]State
+	}{
+		{res.Value(extractor.ProcessingStatusKey), sese024States},
+		{res.Value(extractor.MatchingStatusKey), sese024MatchingStates},
+	}
+	for _, st := range statuses {
+		if st.value == "" {
+			continue
+		}
+		s, ok := st.states[st.value]
+		if !ok {
+			c.violation(i, msgType, RuleStatus, "unsupported status %s", st.value)
+			continue
+		}
+		if reconfirmed(c.report.State, s) {
+			continue
+		}
+		c.transition(i, msgType, s)
+	}
+}
+
+// checkSettlement adds the settled quantity of a sese025 to the quantities settled before. The instruction
+// is settled once the instructed quantity is reached, before it is partially settled. Settling more than
+// the instructed quantity is a violation. Without comparable quantities a confirmation settles the instruction.
+func (c *checker) checkSettlement(i int, msgType string, res *extractor.ExtractionResult) {
+	instructed, okInstructed := new(big.Rat).SetString(c.instruction.Value(extractor.QuantityKey))
+	qty, ok := new(big.Rat).SetString(res.Value(extractor.QuantityKey))
+	if !okInstructed || !ok {
+		c.transition(i, msgType, StateSettled)
+		return
+	}
+
+	if c.settled == nil {
+		c.settled = new(big.Rat)
+	}
+	c.settled.Add(c.settled, qty)
+	switch c.settled.Cmp(instructed) {
+	case -1:
+		c.transition(i, msgType, StatePartSettled)
+	case 1:
+		c.violation(i, msgType, RuleConsistency, "settled quantity %s exceeds instruction value %s",
+			quantity(c.settled), c.instruction.Value(extractor.QuantityKey))
+		c.transition(i, msgType, StateSettled)
+	default:
+		c.transition(i, msgType, StateSettled)
+	}
+}
+
+// checkCxlStatusAdvice applies the processing status of a sese027 to the cancellation state.
+func (c *checker) checkCxlStatusAdvice(i int, msgType string, res *extractor.ExtractionResult) {
+	status := res.Value(extractor.ProcessingStatusKey)
+	s, ok := sese027States[status]
+	if !ok {
+		c.violation(i, msgType, RuleStatus, "unsupported status %s", status)
+		return
+	}
+	c.cxlTransition(i, msgType, s)
+}
+
+// transition moves the instruction to the given state, reporting a violation if the transition is not legal.
+func (c *checker) transition(i int, msgType string, to State) {
+	if !legal(transitions, c.report.State, to) {
+		c.violation(i, msgType, RuleTransition, "instruction transition from %s to %s", c.report.State, to)
+	}
+	c.report.State = to
+}
+
+// cxlTransition moves the cancellation to the given state, reporting a violation if the transition is not legal.
+// A successful cancellation also moves the instruction to StateCancelled.
+func (c *checker) cxlTransition(i int, msgType string, to State) {
+	if !legal(cxlTransitions, c.report.CxlState, to) {
+		c.violation(i, msgType, RuleTransition, "cancellation transition from %s to %s", stateName(c.report.CxlState), to)
+	}
+	c.report.CxlState = to
+	if to == StateCancelled {
+		c.report.State = StateCancelled
+	}
+}
+
+// stateName returns the name of the state for messages, including the empty initial state.
+func stateName(s State) string {
+	if s == StateNone {
+		return "none"
+	}
+	return string(s)
+}
@@ -0,0 +1,172 @@
+package lifecycle
+
+import (
+	"elsa-xml/pkg/extractor"
+	"fmt"
+	"os"
+	"testing"
+)
+
+const (
+	txID    = "SRA2QG78B0FDP4QX"
+	mitiRef = "MITI000000000001"
+)
+
+// instruction returns a sese023 instruction with the given quantity
+func instruction(qty string) Message {
+	return Message{Type: extractor.MsgTypeSese023, XML: []byte(fmt.Sprintf(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.023.001.10">
+ <SctiesSttlmTxInstr>
+  <TxId>%s</TxId>
+  <FinInstrmId><ISIN>GB0002771383</ISIN></FinInstrmId>
+  <QtyAndAcctDtls><SttlmQty><Qty><Unit>%s</Unit></Qty></SttlmQty></QtyAndAcctDtls>
+ </SctiesSttlmTxInstr>
+</Document>`, txID, qty))}
+}
+
+// advice returns a sese024 status advice with the processing and matching status, empty ones are left out
+func advice(prcgSts, mtchgSts string) Message {
+	statuses := ""
+	if prcgSts != "" {
+		statuses += fmt.Sprintf("<PrcgSts><%s><NoSpcfdRsn>NORE</NoSpcfdRsn></%[1]s></PrcgSts>", prcgSts)
+	}
+	if mtchgSts != "" {
+		statuses += fmt.Sprintf("<MtchgSts><%s><NoSpcfdRsn>NORE</NoSpcfdRsn></%[1]s></MtchgSts>", mtchgSts)
+	}
+	return Message{Type: extractor.MsgTypeSese024, XML: []byte(fmt.Sprintf(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10">
+ <SctiesSttlmTxStsAdvc>
+  <TxId><AcctOwnrTxId>%s</AcctOwnrTxId><MktInfrstrctrTxId>%s</MktInfrstrctrTxId></TxId>
+  %s
+ </SctiesSttlmTxStsAdvc>
+</Document>`, txID, mitiRef, statuses))}
+}
+
+// confirmation returns a sese025 settlement confirmation of the settled quantity
+func confirmation(qty string) Message {
+	return Message{Type: extractor.MsgTypeSese025, XML: []byte(fmt.Sprintf(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.025.001.09">
+ <SctiesSttlmTxConf>
+  <TxIdDtls><AcctOwnrTxId>%s</AcctOwnrTxId><MktInfrstrctrTxId>%s</MktInfrstrctrTxId></TxIdDtls>
+  <FinInstrmId><ISIN>GB0002771383</ISIN></FinInstrmId>
+  <QtyAndAcctDtls><SttldQty><Qty><Unit>%s</Unit></Qty></SttldQty></QtyAndAcctDtls>
+ </SctiesSttlmTxConf>
+</Document>`, txID, mitiRef, qty))}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		msgs  []Message
+		state State
+		rules []string // rules of the expected violations in order
+	}{
+		{"instructed", []Message{instruction("4200")}, StateInstructed, nil},
+		{"accepted and settled", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", ""),
+			advice("AckdAccptd", "Umtchd"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("4200.0"),
+		}, StateSettled, nil},
+		{"matching advices repeat the acceptance", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", ""),
+			advice("", "Umtchd"),
+			advice("AckdAccptd", "Mtchd"),
+			advice("AckdAccptd", ""),
+		}, StateMatched, nil},
+		{"repair", []Message{
+			instruction("4200"),
+			advice("Rpr", ""),
+			advice("AckdAccptd", "Mtchd"),
+		}, StateMatched, nil},
+		{"rejected", []Message{instruction("4200"), advice("Rjctd", "")}, StateRejected, nil},
+		{"message after the settlement", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("4200"),
+			advice("AckdAccptd", ""),
+		}, StateSettled, []string{RuleOrder}},
+		{"settled before matched", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Umtchd"),
+			confirmation("4200"),
+		}, StateSettled, []string{RuleTransition}},
+		{"matched instruction back in repair", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			advice("Rpr", ""),
+		}, StateRepair, []string{RuleTransition}},
+		{"partial settlement", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("2100"),
+			advice("AckdAccptd", "Mtchd"),
+		}, StatePartSettled, nil},
+		{"partial settlements up to the instructed quantity", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("2100.5"),
+			confirmation("1000"),
+			confirmation("1099.5"),
+		}, StateSettled, nil},
+		{"over-settlement", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("2100"),
+			confirmation("2100.25"),
+		}, StateSettled, []string{RuleConsistency}},
+		{"remainder cancelled after a partial settlement", []Message{
+			instruction("4200"),
+			advice("AckdAccptd", "Mtchd"),
+			confirmation("2100"),
+			advice("Canc", ""),
+		}, StateCancelled, nil},
+		{"unsupported status", []Message{instruction("4200"), advice("ModReqd", "")}, StateInstructed, []string{RuleStatus}},
+		{"no instruction", []Message{advice("AckdAccptd", "")}, StateNone, []string{RuleOrder}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Check(tt.msgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.State != tt.state {
+				t.Errorf("got state %s, want %s", stateName(r.State), stateName(tt.state))
+			}
+			var rules []string
+			for _, v := range r.Violations {
+				rules = append(rules, v.Rule)
+			}
+			if fmt.Sprint(rules) != fmt.Sprint(tt.rules) {
+				t.Errorf("got violations %v, want rules %v", r.Violations, tt.rules)
+			}
+		})
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	var msgs []Message
+	for _, f := range []struct{ file, msgType string }{
+		{"sese.023.001.10_iso_ok.xml", extractor.MsgTypeSese023},
+		{"sese.020.001.06_iso_ok.xml", extractor.MsgTypeSese020},
+	} {
+		data, err := os.ReadFile("../../testdata/full/" + f.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, Message{Type: f.msgType, XML: data})
+	}
+	r, err := Check(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TxID == "" || r.CxlState != StateCxlReq {
+		t.Errorf("got %+v", r)
+	}
+
+	if _, err := Check(nil); err == nil {
+		t.Error("no error for an empty lifecycle")
+	}
+	if _, err := Check([]Message{{Type: "sese.099", XML: msgs[0].XML}}); err == nil {
+		t.Error("no error for an unsupported message type")
+	}
+}
@@ -0,0 +1,133 @@
+package lifecycle
+
+import "elsa-xml/pkg/extractor"
+
+// State is the state of an instruction as implied by the messages exchanged for it.
+type State string
+
+const (
+	StateNone        State = ""
+	StateInstructed  State = "instructed"
+	StatePending     State = "pending"
+	StateRepair      State = "repair"
+	StateAccepted    State = "accepted"
+	StateRejected    State = "rejected"
+	StateUnmatched   State = "unmatched"
+	StateMatched     State = "matched"
+	StatePartSettled State = "partially_settled"
+	StateSettled     State = "settled"
+	StateCancelled   State = "cancelled"
+	StateCxlReq      State = "cancellation_requested"
+	StateCxlPending  State = "cancellation_pending"
+	StateCxlDenied   State = "cancellation_denied"
+	StateCxlRejected State = "cancellation_rejected"
+)
+
+// transitions holds the legal instruction state transitions. Status advices may be repeated,
+// therefore most states may transition to themselves. A repeated acceptance of a matched or unmatched
+// instruction does not move it back, see reconfirmed. A partially settled instruction stays partially
+// settled until the settlement confirmations add up to the instructed quantity.
+var transitions = map[State][]State{
+	StateNone:        {StateInstructed},
+	StateInstructed:  {StatePending, StateRepair, StateAccepted, StateRejected, StateCancelled},
+	StatePending:     {StatePending, StateRepair, StateAccepted, StateRejected, StateUnmatched, StateMatched, StateCancelled},
+	StateRepair:      {StateRepair, StatePending, StateAccepted, StateRejected, StateCancelled},
+	StateAccepted:    {StateAccepted, StatePending, StateUnmatched, StateMatched, StateCancelled},
+	StateUnmatched:   {StateUnmatched, StatePending, StateMatched, StateCancelled},
+	StateMatched:     {StateMatched, StatePending, StatePartSettled, StateSettled, StateCancelled},
+	StatePartSettled: {StatePartSettled, StateSettled, StateCancelled},
+}
+
+// cxlTransitions holds the legal transitions of a cancellation request, which runs in parallel to the instruction.
+// StateNone means no cancellation was requested; after a denied or rejected cancellation a new one may be requested.
+var cxlTransitions = map[State][]State{
+	StateNone:        {StateCxlReq},
+	StateCxlReq:      {StateCxlPending, StateCxlDenied, StateCxlRejected, StateCancelled},
+	StateCxlPending:  {StateCxlPending, StateCxlDenied, StateCxlRejected, StateCancelled},
+	StateCxlDenied:   {StateCxlReq},
+	StateCxlRejected: {StateCxlReq},
+}
+
+// terminal reports whether no further message may follow an instruction in the given state.
+func terminal(s State) bool {
+	return s == StateRejected || s == StateCancelled || s == StateSettled
+}
+
+// reconfirmed reports whether an instruction in the given state was already accepted and a processing
+// status implying the state to only repeats that acceptance. Status advices on the matching repeat the
+// processing status AckdAccptd, it leaves the matching state unchanged. Likewise a partially settled
+// instruction stays partially settled on a repeated acceptance or matching.
+func reconfirmed(from, to State) bool {
+	switch from {
+	case StateUnmatched, StateMatched:
+		return to == StateAccepted
+	case StatePartSettled:
+		return to == StateAccepted || to == StateMatched
+	}
+	return false
+}
+
+// legal reports whether the transition from one state to another is allowed by the given table.
+func legal(table map[State][]State, from, to State) bool {
+	for _, s := range table[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// sese024States maps the processing status of a sese024 to the instruction state it implies.
+var sese024States = map[string]State{
+	"AckdAccptd": StateAccepted,
+	"PdgPrcg":    StatePending,
+	"Rjctd":      StateRejected,
+	"Rpr":        StateRepair,
+	"Canc":       StateCancelled,
+}
+
+// sese024MatchingStates maps the matching status of a sese024 to the instruction state it implies.
+var sese024MatchingStates = map[string]State{
+	"Mtchd":  StateMatched,
+	"Umtchd": StateUnmatched,
+}
+
+// sese027States maps the processing status of a sese027 to the cancellation state it implies.
+var sese027States = map[string]State{
+	"AckdAccptd": StateCxlPending,
+	"PdgCxl":     StateCxlPending,
+	"Rpr":        StateCxlPending,
+	"Rjctd":      StateCxlRejected,
+	"Dnd":        StateCxlDenied,
+	"Canc":       StateCancelled,
+}
+
+// kind classifies the message types known to the checker.
+type kind int
+
+const (
+	kindUnknown kind = iota
+	kindInstruction
+	kindStatusAdvice
+	kindCxlRequest
+	kindCxlStatusAdvice
+	kindSettlementConfirmation
+)
+
+// messageKind returns the kind of the given message type.
+func messageKind(msgType string) kind {
+	switch msgType {
+	case extractor.MsgTypeSese023, extractor.MsgTypeSese023Plus:
+		return kindInstruction
+	case extractor.MsgTypeSese024:
+		return kindStatusAdvice
+	case extractor.MsgTypeSese025:
+		return kindSettlementConfirmation
+	case extractor.MsgTypeSese020, extractor.MsgTypeSese020Plus:
+		return kindCxlRequest
+	case extractor.MsgTypeSese027:
+		return kindCxlStatusAdvice
+	default:
+		return kindUnknown
+	}
+}
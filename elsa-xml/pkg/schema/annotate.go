@@ -0,0 +1,45 @@
+package schema
+
+import "encoding/xml"
+
+// Element is an element of a valid document together with the schema information of its declaration.
+type Element struct {
+	Name     xml.Name
+	Attrs    []xml.Attr // without namespace declarations
+	Children []*Element
+	// Text is the character data of the element, for elements with element-only content it is only whitespace.
+	Text string
+	// Repeated is true if the element is declared by a particle which may occur more than once,
+	// e.g. an element with maxOccurs="unbounded" or an element of a repeating sequence.
+	Repeated bool
+	// Type is the built-in type (e.g. "decimal" or "string") of elements with simple content,
+	// it is empty for elements with element-only content or elements not declared by the schema.
+	Type string
+}
+
+// Annotate validates an XML document against the schema set and returns its elements annotated with
+// the schema information. Errors are returned as in Validate.
+func (s *Set) Annotate(data []byte) (*Element, error) {
+	root, err := parseTree(data)
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[*node]*nodeInfo)
+	if err := s.validate(root, info); err != nil {
+		return nil, err
+	}
+	return annotated(root, info), nil
+}
+
+// annotated converts a node and its children into elements.
+func annotated(n *node, info map[*node]*nodeInfo) *Element {
+	e := &Element{Name: n.name, Attrs: n.attrs, Text: n.text}
+	if ni, ok := info[n]; ok {
+		e.Repeated = ni.repeated
+		e.Type = ni.builtin
+	}
+	for _, child := range n.children {
+		e.Children = append(e.Children, annotated(child, info))
+	}
+	return e
+}
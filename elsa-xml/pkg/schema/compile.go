@@ -0,0 +1,478 @@
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unbounded is the maxOccurs value of particles without upper limit.
+const unbounded = -1
+
+// particleKind is the kind of a content model particle.
+type particleKind int
+
+const (
+	pElement particleKind = iota
+	pSequence
+	pChoice
+	pAny
+	pGroup
+)
+
+// particle is a term of a content model together with its occurrence constraints.
+type particle struct {
+	kind     particleKind
+	min, max int
+	children []*particle // sequence and choice
+	elem     *elementDecl
+	ref      xml.Name // element or group reference, resolved during validation
+
+	// wildcard
+	anyNS     bool
+	otherNS   bool
+	namespace []string
+	targetNS  string
+	process   string
+}
+
+// elementDecl is an element declaration. The type is either referenced by name or declared anonymously.
+type elementDecl struct {
+	name     xml.Name
+	typeName *xml.Name
+	simple   *simpleType
+	complex  *complexType
+	nillable bool
+	fixed    *string
+}
+
+// complexType is a complex type definition.
+// Types with simple content hold the name of their base type, which is either a simple type or a
+// complex type with simple content itself.
+type complexType struct {
+	name       xml.Name
+	content    *particle
+	simpleBase *xml.Name
+	attrs      []*attributeDecl
+	anyAttr    bool
+	mixed      bool
+	anyType    bool
+}
+
+// attributeDecl is a local attribute declaration.
+type attributeDecl struct {
+	name     xml.Name
+	typeName *xml.Name
+	simple   *simpleType
+	required bool
+	fixed    *string
+}
+
+// anyType is the complex type used for xs:anyType and elements declared without a type.
+var anyType = &complexType{name: xml.Name{Space: xsdNS, Local: "anyType"}, anyType: true}
+
+// compiler compiles the components of a single schema document into the set.
+type compiler struct {
+	set       *Set
+	file      string
+	targetNS  string
+	qualified bool
+}
+
+// global compiles a top-level child of the schema element.
+func (c *compiler) global(n *node) error {
+	if n.name.Space != xsdNS {
+		return fmt.Errorf("line %d: unexpected element %s", n.line, formatName(n.name))
+	}
+	switch n.name.Local {
+	case "annotation":
+		return nil
+	case "import", "include":
+		loc, ok := n.attr("schemaLocation")
+		if !ok {
+			// imports without location only declare the namespace to be used
+			return nil
+		}
+		return c.set.loadFile(filepath.Join(filepath.Dir(c.file), loc))
+	case "redefine":
+		if err := c.set.loadFile(filepath.Join(filepath.Dir(c.file), n.attrOr("schemaLocation", ""))); err != nil {
+			return err
+		}
+		// the redefined components replace the ones loaded above
+		for _, child := range n.children {
+			if err := c.global(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "element":
+		e, err := c.element(n, true)
+		if err != nil {
+			return err
+		}
+		c.set.elements[e.name] = e
+		return nil
+	case "complexType":
+		ct, err := c.complexType(n)
+		if err != nil {
+			return err
+		}
+		c.set.types[ct.name] = ct
+		return nil
+	case "simpleType":
+		st, err := c.simpleType(n)
+		if err != nil {
+			return err
+		}
+		c.set.types[st.name] = st
+		return nil
+	case "group":
+		if len(n.children) == 0 {
+			return fmt.Errorf("line %d: empty group", n.line)
+		}
+		for _, child := range n.children {
+			if isXSD(child, "annotation") {
+				continue
+			}
+			p, err := c.particle(child)
+			if err != nil {
+				return err
+			}
+			c.set.groups[c.qName(n)] = p
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: unsupported schema component %s", n.line, n.name.Local)
+	}
+}
+
+// qName returns the name of a global component, qualified with the target namespace.
+func (c *compiler) qName(n *node) xml.Name {
+	return xml.Name{Space: c.targetNS, Local: n.attrOr("name", "")}
+}
+
+// element compiles an element declaration.
+func (c *compiler) element(n *node, global bool) (*elementDecl, error) {
+	e := &elementDecl{name: xml.Name{Local: n.attrOr("name", "")}}
+	if global || c.qualified || n.attrOr("form", "") == "qualified" {
+		e.name.Space = c.targetNS
+	}
+	if v, ok := n.attr("nillable"); ok {
+		e.nillable = v == "true" || v == "1"
+	}
+	if v, ok := n.attr("fixed"); ok {
+		e.fixed = &v
+	}
+	if _, ok := n.attr("substitutionGroup"); ok {
+		return nil, fmt.Errorf("line %d: substitution groups are not supported", n.line)
+	}
+	if t, ok := n.attr("type"); ok {
+		name, err := n.resolve(t)
+		if err != nil {
+			return nil, err
+		}
+		e.typeName = &name
+	}
+
+	for _, child := range n.children {
+		var err error
+		switch {
+		case isXSD(child, "annotation"):
+		case isXSD(child, "complexType"):
+			e.complex, err = c.complexType(child)
+		case isXSD(child, "simpleType"):
+			e.simple, err = c.simpleType(child)
+		default:
+			err = fmt.Errorf("line %d: unsupported element content %s", child.line, child.name.Local)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if e.typeName == nil && e.complex == nil && e.simple == nil {
+		e.complex = anyType
+	}
+	return e, nil
+}
+
+// complexType compiles a named or anonymous complex type definition.
+func (c *compiler) complexType(n *node) (*complexType, error) {
+	ct := &complexType{name: c.qName(n)}
+	if v, ok := n.attr("mixed"); ok {
+		ct.mixed = v == "true" || v == "1"
+	}
+	for _, child := range n.children {
+		if child.name.Space != xsdNS {
+			return nil, fmt.Errorf("line %d: unexpected element %s", child.line, formatName(child.name))
+		}
+		switch child.name.Local {
+		case "annotation":
+		case "sequence", "choice", "group":
+			p, err := c.particle(child)
+			if err != nil {
+				return nil, err
+			}
+			ct.content = p
+		case "attribute", "anyAttribute":
+			if err := c.attribute(child, ct); err != nil {
+				return nil, err
+			}
+		case "simpleContent":
+			if err := c.simpleContent(child, ct); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unsupported complex type content %s", child.line, child.name.Local)
+		}
+	}
+	return ct, nil
+}
+
+// simpleContent compiles the simple content extension of a complex type.
+func (c *compiler) simpleContent(n *node, ct *complexType) error {
+	for _, child := range n.children {
+		switch {
+		case isXSD(child, "annotation"):
+		case isXSD(child, "extension"):
+			base, err := child.resolve(child.attrOr("base", ""))
+			if err != nil {
+				return err
+			}
+			ct.simpleBase = &base
+			for _, attr := range child.children {
+				if isXSD(attr, "annotation") {
+					continue
+				}
+				if err := c.attribute(attr, ct); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("line %d: unsupported simple content %s", child.line, child.name.Local)
+		}
+	}
+	if ct.simpleBase == nil {
+		return fmt.Errorf("line %d: simple content without extension", n.line)
+	}
+	return nil
+}
+
+// attribute compiles an attribute declaration or attribute wildcard of a complex type.
+func (c *compiler) attribute(n *node, ct *complexType) error {
+	if isXSD(n, "anyAttribute") {
+		ct.anyAttr = true
+		return nil
+	}
+	if !isXSD(n, "attribute") {
+		return fmt.Errorf("line %d: unsupported attribute declaration %s", n.line, n.name.Local)
+	}
+	if _, ok := n.attr("ref"); ok {
+		return fmt.Errorf("line %d: attribute references are not supported", n.line)
+	}
+	a := &attributeDecl{
+		name:     xml.Name{Local: n.attrOr("name", "")},
+		required: n.attrOr("use", "optional") == "required",
+	}
+	if v, ok := n.attr("fixed"); ok {
+		a.fixed = &v
+	}
+	if t, ok := n.attr("type"); ok {
+		name, err := n.resolve(t)
+		if err != nil {
+			return err
+		}
+		a.typeName = &name
+	}
+	for _, child := range n.children {
+		if isXSD(child, "simpleType") {
+			st, err := c.simpleType(child)
+			if err != nil {
+				return err
+			}
+			a.simple = st
+		}
+	}
+	ct.attrs = append(ct.attrs, a)
+	return nil
+}
+
+// particle compiles a sequence, choice, group reference, element or wildcard particle.
+func (c *compiler) particle(n *node) (*particle, error) {
+	p := &particle{min: 1, max: 1}
+	var err error
+	if p.min, err = occurs(n, "minOccurs"); err != nil {
+		return nil, err
+	}
+	if p.max, err = occurs(n, "maxOccurs"); err != nil {
+		return nil, err
+	}
+	if n.name.Space != xsdNS {
+		return nil, fmt.Errorf("line %d: unexpected element %s", n.line, formatName(n.name))
+	}
+
+	switch n.name.Local {
+	case "sequence", "choice":
+		p.kind = pSequence
+		if n.name.Local == "choice" {
+			p.kind = pChoice
+		}
+		for _, child := range n.children {
+			if isXSD(child, "annotation") {
+				continue
+			}
+			cp, err := c.particle(child)
+			if err != nil {
+				return nil, err
+			}
+			p.children = append(p.children, cp)
+		}
+	case "element":
+		p.kind = pElement
+		if ref, ok := n.attr("ref"); ok {
+			if p.ref, err = n.resolve(ref); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if p.elem, err = c.element(n, false); err != nil {
+			return nil, err
+		}
+	case "group":
+		p.kind = pGroup
+		if p.ref, err = n.resolve(n.attrOr("ref", "")); err != nil {
+			return nil, err
+		}
+	case "any":
+		p.kind = pAny
+		p.targetNS = c.targetNS
+		p.process = n.attrOr("processContents", "strict")
+		switch ns := n.attrOr("namespace", "##any"); ns {
+		case "##any":
+			p.anyNS = true
+		case "##other":
+			p.otherNS = true
+		default:
+			p.namespace = strings.Fields(ns)
+		}
+	default:
+		return nil, fmt.Errorf("line %d: unsupported particle %s", n.line, n.name.Local)
+	}
+	return p, nil
+}
+
+// occurs returns the value of the minOccurs or maxOccurs attribute, both default to 1.
+func occurs(n *node, attr string) (int, error) {
+	v, ok := n.attr(attr)
+	if !ok {
+		return 1, nil
+	}
+	if v == "unbounded" {
+		return unbounded, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("line %d: invalid %s %q", n.line, attr, v)
+	}
+	return i, nil
+}
+
+// simpleType compiles a named or anonymous simple type definition (restrictions only).
+func (c *compiler) simpleType(n *node) (*simpleType, error) {
+	st := &simpleType{name: c.qName(n), length: -1, minLength: -1, maxLength: -1, totalDigits: -1, fractionDigits: -1}
+	var restriction *node
+	for _, child := range n.children {
+		switch {
+		case isXSD(child, "annotation"):
+		case isXSD(child, "restriction"):
+			restriction = child
+		default:
+			return nil, fmt.Errorf("line %d: unsupported simple type %s", child.line, child.name.Local)
+		}
+	}
+	if restriction == nil {
+		return nil, fmt.Errorf("line %d: simple type without restriction", n.line)
+	}
+
+	if b, ok := restriction.attr("base"); ok {
+		base, err := restriction.resolve(b)
+		if err != nil {
+			return nil, err
+		}
+		st.base = &base
+	}
+	var patterns []string
+	for _, f := range restriction.children {
+		if f.name.Space != xsdNS {
+			return nil, fmt.Errorf("line %d: unexpected element %s", f.line, formatName(f.name))
+		}
+		value := f.attrOr("value", "")
+		var err error
+		switch f.name.Local {
+		case "annotation":
+		case "simpleType":
+			st.baseType, err = c.simpleType(f)
+		case "enumeration":
+			st.enums = append(st.enums, value)
+		case "pattern":
+			patterns = append(patterns, value)
+		case "length":
+			st.length, err = facetInt(f)
+		case "minLength":
+			st.minLength, err = facetInt(f)
+		case "maxLength":
+			st.maxLength, err = facetInt(f)
+		case "totalDigits":
+			st.totalDigits, err = facetInt(f)
+		case "fractionDigits":
+			st.fractionDigits, err = facetInt(f)
+		case "minInclusive":
+			st.minInclusive = &value
+		case "maxInclusive":
+			st.maxInclusive = &value
+		case "minExclusive":
+			st.minExclusive = &value
+		case "maxExclusive":
+			st.maxExclusive = &value
+		case "whiteSpace":
+			// the whitespace handling follows the primitive type
+		default:
+			err = fmt.Errorf("line %d: unsupported facet %s", f.line, f.name.Local)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if st.base == nil && st.baseType == nil {
+		return nil, fmt.Errorf("line %d: restriction without base type", restriction.line)
+	}
+	if len(patterns) > 0 {
+		// patterns of the same derivation step are alternatives
+		expr := ""
+		for i, p := range patterns {
+			if i > 0 {
+				expr += "|"
+			}
+			expr += "(?:" + translatePattern(p) + ")"
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern: %w", restriction.line, err)
+		}
+		st.pattern = re
+		st.patternSrc = patterns
+	}
+	return st, nil
+}
+
+// facetInt returns the non-negative integer value of a facet.
+func facetInt(f *node) (int, error) {
+	v := f.attrOr("value", "")
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("line %d: invalid %s %q", f.line, f.name.Local, v)
+	}
+	return i, nil
+}
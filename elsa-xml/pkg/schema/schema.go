@@ -0,0 +1,88 @@
+// Package schema provides a pure Go XML schema (XSD 1.0) validator.
+//
+// It covers the subset of XSD used by the ISO20022 and T2S schemas: global and local element declarations,
+// named and anonymous complex and simple types, sequence, choice, group and wildcard particles with
+// occurrence constraints, simple content extensions with attributes, restrictions with the facets
+// enumeration, pattern, (min/max)length, totalDigits, fractionDigits and (min/max)(in/ex)clusive, as well
+// as import, include and redefine of schema documents.
+// Constructs outside of this subset (e.g. complexContent, list and union types or identity constraints)
+// are rejected when the schema is loaded.
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	xsdNS = "http://www.w3.org/2001/XMLSchema"
+	xsiNS = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+)
+
+// Set holds the components of a schema document and of all schema documents it imports, includes or redefines.
+type Set struct {
+	elements map[xml.Name]*elementDecl
+	types    map[xml.Name]any // *simpleType or *complexType
+	groups   map[xml.Name]*particle
+	loaded   map[string]bool
+}
+
+// Load loads the schema document at the given path together with all schema documents it references.
+func Load(path string) (*Set, error) {
+	s := &Set{
+		elements: make(map[xml.Name]*elementDecl),
+		types:    make(map[xml.Name]any),
+		groups:   make(map[xml.Name]*particle),
+		loaded:   make(map[string]bool),
+	}
+	if err := s.loadFile(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadFile loads a single schema document and registers its global components.
+// Documents which were loaded before are skipped.
+func (s *Set) loadFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if s.loaded[absPath] {
+		return nil
+	}
+	s.loaded[absPath] = true
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return err
+	}
+	root, err := parseTree(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", absPath, err)
+	}
+	if !isXSD(root, "schema") {
+		return fmt.Errorf("%s: root element is not a schema", absPath)
+	}
+
+	c := &compiler{
+		set:       s,
+		file:      absPath,
+		targetNS:  root.attrOr("targetNamespace", ""),
+		qualified: root.attrOr("elementFormDefault", "unqualified") == "qualified",
+	}
+	for _, child := range root.children {
+		if err := c.global(child); err != nil {
+			return fmt.Errorf("%s: %w", absPath, err)
+		}
+	}
+	return nil
+}
+
+// isXSD reports whether the node is the XML schema element with the given local name.
+func isXSD(n *node, local string) bool {
+	return n.name.Space == xsdNS && n.name.Local == local
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSchema uses the facets and particles of the ISO20022 schemas on a small document type.
+const testSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+ <xs:element name="Doc" type="Doc"/>
+ <xs:complexType name="Doc">
+  <xs:sequence>
+   <xs:element name="Id" type="Id"/>
+   <xs:element name="Qty" type="Qty" minOccurs="0"/>
+   <xs:choice>
+    <xs:element name="Dlvr" type="Code"/>
+    <xs:element name="Rcv" type="Code"/>
+   </xs:choice>
+   <xs:element name="Note" type="Note" minOccurs="0" maxOccurs="2"/>
+  </xs:sequence>
+ </xs:complexType>
+ <xs:simpleType name="Id">
+  <xs:restriction base="xs:string"><xs:pattern value="[A-Z]{2}[0-9]{2}"/></xs:restriction>
+ </xs:simpleType>
+ <xs:simpleType name="Qty">
+  <xs:restriction base="xs:decimal">
+   <xs:totalDigits value="5"/><xs:fractionDigits value="2"/><xs:minInclusive value="0"/>
+  </xs:restriction>
+ </xs:simpleType>
+ <xs:simpleType name="Code">
+  <xs:restriction base="xs:string"><xs:enumeration value="DELI"/><xs:enumeration value="RECE"/></xs:restriction>
+ </xs:simpleType>
+ <xs:simpleType name="Note">
+  <xs:restriction base="xs:string"><xs:minLength value="1"/><xs:maxLength value="5"/></xs:restriction>
+ </xs:simpleType>
+</xs:schema>`
+
+// loadSchema writes the schema document to a temporary directory and loads it.
+func loadSchema(t *testing.T, xsd string) (*Set, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.xsd")
+	if err := os.WriteFile(path, []byte(xsd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+// doc returns a document with one child element per line, the first child is on line 2.
+func doc(children ...string) []byte {
+	return []byte("<Doc xmlns=\"urn:test\">\n" + strings.Join(children, "\n") + "\n</Doc>")
+}
+
+func TestValidate(t *testing.T) {
+	s, err := loadSchema(t, testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		errs []string // substrings of the expected errors in order, none for a valid document
+	}{
+		{"all elements", doc("<Id>AB12</Id>", "<Qty>123.45</Qty>", "<Dlvr>DELI</Dlvr>", "<Note>a</Note>", "<Note>b</Note>"), nil},
+		{"optional elements left out", doc("<Id>AB12</Id>", "<Rcv>RECE</Rcv>"), nil},
+		{"pattern", doc("<Id>ab12</Id>", "<Rcv>RECE</Rcv>"), []string{"line 2: Element '{urn:test}Id': [facet 'pattern']"}},
+		{"totalDigits", doc("<Id>AB12</Id>", "<Qty>1234.56</Qty>", "<Rcv>RECE</Rcv>"), []string{"line 3: Element '{urn:test}Qty': [facet 'totalDigits']"}},
+		{"fractionDigits", doc("<Id>AB12</Id>", "<Qty>1.234</Qty>", "<Rcv>RECE</Rcv>"), []string{"line 3: Element '{urn:test}Qty': [facet 'fractionDigits']"}},
+		{"minInclusive", doc("<Id>AB12</Id>", "<Qty>-1</Qty>", "<Rcv>RECE</Rcv>"), []string{"line 3: Element '{urn:test}Qty': [facet 'minInclusive']"}},
+		{"not a decimal", doc("<Id>AB12</Id>", "<Qty>1,5</Qty>", "<Rcv>RECE</Rcv>"), []string{"line 3: Element '{urn:test}Qty': '1,5' is not a valid value of the atomic type 'xs:decimal'"}},
+		{"enumeration", doc("<Id>AB12</Id>", "<Dlvr>FREE</Dlvr>"), []string{"line 3: Element '{urn:test}Dlvr': [facet 'enumeration']"}},
+		{"minLength", doc("<Id>AB12</Id>", "<Rcv>RECE</Rcv>", "<Note></Note>"), []string{"line 4: Element '{urn:test}Note': [facet 'minLength']"}},
+		{"maxLength", doc("<Id>AB12</Id>", "<Rcv>RECE</Rcv>", "<Note>toolong</Note>"), []string{"line 4: Element '{urn:test}Note': [facet 'maxLength']"}},
+		{"errors of several elements", doc("<Id>ab12</Id>", "<Dlvr>FREE</Dlvr>"), []string{"line 2: Element '{urn:test}Id'", "line 3: Element '{urn:test}Dlvr'"}},
+		{"choice missing", doc("<Id>AB12</Id>", "<Note>a</Note>"), []string{"line 3: Element '{urn:test}Note': This element is not expected. Expected is ( {urn:test}Dlvr, {urn:test}Rcv )"}},
+		{"choice twice", doc("<Id>AB12</Id>", "<Dlvr>DELI</Dlvr>", "<Rcv>RECE</Rcv>"), []string{"line 4: Element '{urn:test}Rcv': This element is not expected"}},
+		{"maxOccurs exceeded", doc("<Id>AB12</Id>", "<Rcv>RECE</Rcv>", "<Note>a</Note>", "<Note>b</Note>", "<Note>c</Note>"), []string{"line 6: Element '{urn:test}Note': This element is not expected"}},
+		{"sequence order", doc("<Rcv>RECE</Rcv>", "<Id>AB12</Id>"), []string{"line 2: Element '{urn:test}Rcv': This element is not expected. Expected is ( {urn:test}Id )"}},
+		{"content missing", doc("<Id>AB12</Id>"), []string{"line 1: Element '{urn:test}Doc': Missing child element(s)"}},
+		{"unknown root", []byte("<Other xmlns=\"urn:test\"/>"), []string{"line 1: Element '{urn:test}Other': No matching global declaration"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.data)
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			if len(verr.Errors()) != len(tt.errs) {
+				t.Fatalf("got errors %q, want %d", verr.Error(), len(tt.errs))
+			}
+			for i, want := range tt.errs {
+				if got := verr.Errors()[i].Error(); !strings.Contains(got, want) {
+					t.Errorf("got error %q, want %q", got, want)
+				}
+			}
+		})
+	}
+
+	err = s.Validate([]byte("<Doc xmlns=\"urn:test\"><Id>AB12</Id>"))
+	var verr *ValidationError
+	if err == nil || errors.As(err, &verr) {
+		t.Errorf("got %v for a document which is not well-formed, want a parse error", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		xsd  string
+		want string
+	}{
+		{"not a schema", `<schema/>`, "root element is not a schema"},
+		{"unsupported construct", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+ <xs:complexType name="T">
+  <xs:complexContent/>
+ </xs:complexType>
+</xs:schema>`, "line 3: unsupported complex type content complexContent"},
+		{"invalid occurrence", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+ <xs:complexType name="T">
+  <xs:sequence>
+   <xs:element name="E" type="xs:string" maxOccurs="many"/>
+  </xs:sequence>
+ </xs:complexType>
+</xs:schema>`, `line 4: invalid maxOccurs "many"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadSchema(t, tt.xsd)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.xsd")); err == nil {
+		t.Error("no error for a missing schema document")
+	}
+}
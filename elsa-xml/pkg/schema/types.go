@@ -0,0 +1,407 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// simpleType is a simple type defined by restriction of a base type, or a built-in type.
+// Integer facets which are not set are -1.
+type simpleType struct {
+	name       xml.Name
+	builtin    string
+	base       *xml.Name
+	baseType   *simpleType
+	enums      []string
+	pattern    *regexp.Regexp
+	patternSrc []string
+
+	length, minLength, maxLength int
+	totalDigits, fractionDigits  int
+	minInclusive, maxInclusive   *string
+	minExclusive, maxExclusive   *string
+}
+
+// builtinLexical holds the lexical representation of the supported built-in types.
+// Built-in types derived from string which are not listed accept any value.
+var builtinLexical = map[string]*regexp.Regexp{
+	"decimal":            regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`),
+	"integer":            regexp.MustCompile(`^[+-]?\d+$`),
+	"long":               regexp.MustCompile(`^[+-]?\d+$`),
+	"int":                regexp.MustCompile(`^[+-]?\d+$`),
+	"short":              regexp.MustCompile(`^[+-]?\d+$`),
+	"byte":               regexp.MustCompile(`^[+-]?\d+$`),
+	"nonNegativeInteger": regexp.MustCompile(`^\+?\d+$|^-0+$`),
+	"positiveInteger":    regexp.MustCompile(`^\+?\d+$`),
+	"boolean":            regexp.MustCompile(`^(true|false|1|0)$`),
+	"date":               regexp.MustCompile(`^-?\d{4,}-(\d{2})-(\d{2})(Z|[+-]\d{2}:\d{2})?$`),
+	"dateTime":           regexp.MustCompile(`^-?\d{4,}-(\d{2})-(\d{2})T(\d{2}):(\d{2}):(\d{2})(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`),
+	"time":               regexp.MustCompile(`^(\d{2}):(\d{2}):(\d{2})(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`),
+	"gYear":              regexp.MustCompile(`^-?\d{4,}(Z|[+-]\d{2}:\d{2})?$`),
+	"gYearMonth":         regexp.MustCompile(`^-?\d{4,}-(\d{2})(Z|[+-]\d{2}:\d{2})?$`),
+	"double":             regexp.MustCompile(`^([+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?|INF|-INF|NaN)$`),
+	"float":              regexp.MustCompile(`^([+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?|INF|-INF|NaN)$`),
+	"hexBinary":          regexp.MustCompile(`^([0-9a-fA-F]{2})*$`),
+	"NCName":             regexp.MustCompile(`^[\pL_][\pL\pN._\-]*$`),
+	"ID":                 regexp.MustCompile(`^[\pL_][\pL\pN._\-]*$`),
+	"IDREF":              regexp.MustCompile(`^[\pL_][\pL\pN._\-]*$`),
+	"Name":               regexp.MustCompile(`^[\pL_:][\pL\pN._:\-]*$`),
+	"NMTOKEN":            regexp.MustCompile(`^[\pL\pN._:\-]+$`),
+	"QName":              regexp.MustCompile(`^([\pL_][\pL\pN._\-]*:)?[\pL_][\pL\pN._\-]*$`),
+}
+
+// builtinRanges holds the value range of the bounded integer types.
+var builtinRanges = map[string][2]int64{
+	"int":   {-1 << 31, 1<<31 - 1},
+	"short": {-1 << 15, 1<<15 - 1},
+	"byte":  {-1 << 7, 1<<7 - 1},
+}
+
+// builtinString lists the built-in types whose value space is a string.
+var builtinString = map[string]bool{
+	"string": true, "normalizedString": true, "token": true, "language": true, "anyURI": true,
+	"NCName": true, "ID": true, "IDREF": true, "Name": true, "NMTOKEN": true, "QName": true,
+	"anySimpleType": true,
+}
+
+// builtinDecimal lists the built-in types whose value space is a decimal number.
+var builtinDecimal = map[string]bool{
+	"decimal": true, "integer": true, "long": true, "int": true, "short": true, "byte": true,
+	"nonNegativeInteger": true, "positiveInteger": true,
+}
+
+// builtinOther lists the remaining supported built-in types.
+var builtinOther = map[string]bool{
+	"boolean": true, "date": true, "dateTime": true, "time": true, "gYear": true, "gYearMonth": true,
+	"double": true, "float": true, "hexBinary": true, "base64Binary": true,
+}
+
+// builtinType returns the built-in simple type with the given local name, or nil if it is not supported.
+func builtinType(local string) *simpleType {
+	if !builtinString[local] && !builtinDecimal[local] && !builtinOther[local] {
+		return nil
+	}
+	return &simpleType{
+		name:    xml.Name{Space: xsdNS, Local: local},
+		builtin: local,
+		length:  -1, minLength: -1, maxLength: -1, totalDigits: -1, fractionDigits: -1,
+	}
+}
+
+// primitive returns the built-in type a simple type is derived from.
+func (s *Set) primitive(st *simpleType) (string, error) {
+	for i := 0; st.builtin == ""; i++ {
+		if i > 100 {
+			return "", fmt.Errorf("type %s: circular derivation", formatName(st.name))
+		}
+		base, err := s.baseOf(st)
+		if err != nil {
+			return "", err
+		}
+		st = base
+	}
+	return st.builtin, nil
+}
+
+// baseOf returns the base type of a derived simple type.
+func (s *Set) baseOf(st *simpleType) (*simpleType, error) {
+	if st.baseType != nil {
+		return st.baseType, nil
+	}
+	return s.simpleTypeByName(*st.base)
+}
+
+// simpleTypeByName looks up a simple type by name.
+func (s *Set) simpleTypeByName(name xml.Name) (*simpleType, error) {
+	if name.Space == xsdNS {
+		if bt := builtinType(name.Local); bt != nil {
+			return bt, nil
+		}
+		return nil, fmt.Errorf("unsupported built-in type %s", name.Local)
+	}
+	t, ok := s.types[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", formatName(name))
+	}
+	st, ok := t.(*simpleType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a simple type", formatName(name))
+	}
+	return st, nil
+}
+
+// normalize applies the whitespace handling of the primitive type to a value.
+func normalize(value, primitive string) string {
+	switch primitive {
+	case "string", "anySimpleType":
+		return value
+	case "normalizedString":
+		return strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, value)
+	default:
+		return strings.Join(strings.Fields(value), " ")
+	}
+}
+
+// checkSimple checks a value against a simple type, including all facets of the types it is derived from.
+func (s *Set) checkSimple(st *simpleType, value string) error {
+	prim, err := s.primitive(st)
+	if err != nil {
+		return err
+	}
+	return s.checkValue(st, normalize(value, prim), prim)
+}
+
+// checkValue checks a normalized value against a simple type and its base types.
+func (s *Set) checkValue(st *simpleType, value, prim string) error {
+	if st.builtin != "" {
+		return checkBuiltin(st.builtin, value)
+	}
+	base, err := s.baseOf(st)
+	if err != nil {
+		return err
+	}
+	if err := s.checkValue(base, value, prim); err != nil {
+		return err
+	}
+
+	if len(st.enums) > 0 && !contains(st.enums, value) {
+		return fmt.Errorf("[facet 'enumeration'] The value '%s' is not an element of the set {'%s'}", value, strings.Join(st.enums, "', '"))
+	}
+	if st.pattern != nil && !st.pattern.MatchString(value) {
+		return fmt.Errorf("[facet 'pattern'] The value '%s' is not accepted by the pattern '%s'", value, strings.Join(st.patternSrc, "|"))
+	}
+	if builtinString[prim] {
+		if err := checkLength(st, value); err != nil {
+			return err
+		}
+	}
+	if builtinDecimal[prim] {
+		return checkDecimal(st, value)
+	}
+	return nil
+}
+
+// checkLength checks the length facets of a string value, the length is measured in characters.
+func checkLength(st *simpleType, value string) error {
+	l := utf8.RuneCountInString(value)
+	switch {
+	case st.length >= 0 && l != st.length:
+		return fmt.Errorf("[facet 'length'] The value '%s' has a length of '%d'; this differs from the allowed length of '%d'", value, l, st.length)
+	case st.minLength >= 0 && l < st.minLength:
+		return fmt.Errorf("[facet 'minLength'] The value '%s' has a length of '%d'; this underruns the allowed minimum length of '%d'", value, l, st.minLength)
+	case st.maxLength >= 0 && l > st.maxLength:
+		return fmt.Errorf("[facet 'maxLength'] The value '%s' has a length of '%d'; this exceeds the allowed maximum length of '%d'", value, l, st.maxLength)
+	}
+	return nil
+}
+
+// checkDecimal checks the digit and range facets of a decimal value.
+func checkDecimal(st *simpleType, value string) error {
+	intDigits, fracDigits := digits(value)
+	if st.totalDigits >= 0 && intDigits+fracDigits > st.totalDigits {
+		return fmt.Errorf("[facet 'totalDigits'] The value '%s' has more digits than are allowed ('%d')", value, st.totalDigits)
+	}
+	if st.fractionDigits >= 0 && fracDigits > st.fractionDigits {
+		return fmt.Errorf("[facet 'fractionDigits'] The value '%s' has more fractional digits than are allowed ('%d')", value, st.fractionDigits)
+	}
+
+	v, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return fmt.Errorf("'%s' is not a valid decimal value", value)
+	}
+	bounds := []struct {
+		facet  string
+		limit  *string
+		failOn func(cmp int) bool
+		text   string
+	}{
+		{"minInclusive", st.minInclusive, func(cmp int) bool { return cmp < 0 }, "less than the minimum value allowed"},
+		{"maxInclusive", st.maxInclusive, func(cmp int) bool { return cmp > 0 }, "greater than the maximum value allowed"},
+		{"minExclusive", st.minExclusive, func(cmp int) bool { return cmp <= 0 }, "must be greater than"},
+		{"maxExclusive", st.maxExclusive, func(cmp int) bool { return cmp >= 0 }, "must be less than"},
+	}
+	for _, b := range bounds {
+		if b.limit == nil {
+			continue
+		}
+		limit, ok := new(big.Rat).SetString(*b.limit)
+		if !ok {
+			return fmt.Errorf("invalid %s facet '%s'", b.facet, *b.limit)
+		}
+		if b.failOn(v.Cmp(limit)) {
+			return fmt.Errorf("[facet '%s'] The value '%s' is %s ('%s')", b.facet, value, b.text, *b.limit)
+		}
+	}
+	return nil
+}
+
+// digits returns the number of significant integer and fraction digits of a decimal value.
+func digits(value string) (int, int) {
+	value = strings.TrimLeft(value, "+-")
+	intPart, fracPart, _ := strings.Cut(value, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart = strings.TrimRight(fracPart, "0")
+	return len(intPart), len(fracPart)
+}
+
+// checkBuiltin checks the lexical representation of a value of a built-in type.
+func checkBuiltin(builtin, value string) error {
+	invalid := fmt.Errorf("'%s' is not a valid value of the atomic type 'xs:%s'", value, builtin)
+	if builtin == "base64Binary" {
+		if _, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), "")); err != nil {
+			return invalid
+		}
+		return nil
+	}
+	re, ok := builtinLexical[builtin]
+	if !ok {
+		return nil
+	}
+	m := re.FindStringSubmatch(value)
+	if m == nil {
+		return invalid
+	}
+
+	switch builtin {
+	case "date", "gYearMonth":
+		if !validDate(m[1], dayOrFirst(m, builtin)) {
+			return invalid
+		}
+	case "dateTime":
+		if !validDate(m[1], m[2]) || !validTime(m[3], m[4], m[5]) {
+			return invalid
+		}
+	case "time":
+		if !validTime(m[1], m[2], m[3]) {
+			return invalid
+		}
+	case "int", "short", "byte":
+		i, err := strconv.ParseInt(value, 10, 64)
+		r := builtinRanges[builtin]
+		if err != nil || i < r[0] || i > r[1] {
+			return invalid
+		}
+	}
+	return nil
+}
+
+// dayOrFirst returns the day of a date match, gYearMonth values have no day.
+func dayOrFirst(m []string, builtin string) string {
+	if builtin == "gYearMonth" {
+		return "01"
+	}
+	return m[2]
+}
+
+// validDate checks month and day of a date. February 29th is accepted for every year.
+func validDate(month, day string) bool {
+	mo, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	daysIn := []int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	return mo >= 1 && mo <= 12 && d >= 1 && d <= daysIn[mo-1]
+}
+
+// validTime checks hour, minute and second of a time.
+func validTime(hour, minute, second string) bool {
+	h, _ := strconv.Atoi(hour)
+	mi, _ := strconv.Atoi(minute)
+	s, _ := strconv.Atoi(second)
+	if h == 24 {
+		return mi == 0 && s == 0
+	}
+	return h < 24 && mi < 60 && s < 60
+}
+
+// contains reports whether the slice contains the value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRepeat is the largest repetition count accepted by the regexp package.
+const maxRepeat = 1000
+
+// repeatExpr matches the counted repetition of a regular expression, e.g. {1,2046}.
+var repeatExpr = regexp.MustCompile(`\{(\d+),(\d+)\}`)
+
+// translatePattern translates an XSD regular expression to the syntax of the regexp package.
+// XSD patterns are anchored implicitly, this is done by the caller. The regexp package does not
+// accept repetition counts above 1000, such repetitions are split into consecutive optional
+// repetitions, which accept the same language.
+func translatePattern(p string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range repeatExpr.FindAllStringSubmatchIndex(p, -1) {
+		if loc[0] > 0 && p[loc[0]-1] == '\\' {
+			continue
+		}
+		lo, _ := strconv.Atoi(p[loc[2]:loc[3]])
+		hi, _ := strconv.Atoi(p[loc[4]:loc[5]])
+		if hi <= maxRepeat {
+			continue
+		}
+		atomStart := atomStart(p[:loc[0]])
+		if atomStart < last {
+			continue
+		}
+		atom := p[atomStart:loc[0]]
+		b.WriteString(p[last:atomStart])
+		b.WriteString(atom + "{" + strconv.Itoa(lo) + "}")
+		for rest := hi - lo; rest > 0; rest -= maxRepeat {
+			b.WriteString(atom + "{0," + strconv.Itoa(min(rest, maxRepeat)) + "}")
+		}
+		last = loc[1]
+	}
+	b.WriteString(p[last:])
+	return b.String()
+}
+
+// atomStart returns the start index of the last atom (character, escape, class or group) of an expression.
+func atomStart(p string) int {
+	if p == "" {
+		return 0
+	}
+	switch p[len(p)-1] {
+	case ']':
+		for i := len(p) - 2; i >= 0; i-- {
+			if p[i] == '[' && (i == 0 || p[i-1] != '\\') {
+				return i
+			}
+		}
+	case ')':
+		depth := 0
+		for i := len(p) - 1; i >= 0; i-- {
+			if i > 0 && p[i-1] == '\\' {
+				continue
+			}
+			switch p[i] {
+			case ')':
+				depth++
+			case '(':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+	}
+	if len(p) >= 2 && p[len(p)-2] == '\\' {
+		return len(p) - 2
+	}
+	return len(p) - 1
+}
@@ -0,0 +1,566 @@
+package schema
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ValidationError holds all errors found while validating a document against a schema set.
+type ValidationError struct {
+	errors []error
+}
+
+// Error returns the errors found, one per line.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.errors))
+	for i, err := range e.errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Errors returns the single errors found.
+func (e *ValidationError) Errors() []error {
+	return e.errors
+}
+
+// Validate validates an XML document against the schema set.
+// A *ValidationError is returned if the document is not valid, any other error means the document could
+// not be parsed.
+func (s *Set) Validate(data []byte) error {
+	root, err := parseTree(data)
+	if err != nil {
+		return err
+	}
+	return s.validate(root, nil)
+}
+
+// validate validates a parsed document, recording schema information in info if it is not nil.
+func (s *Set) validate(root *node, info map[*node]*nodeInfo) error {
+	v := &validation{set: s, info: info}
+	decl, ok := s.elements[root.name]
+	if !ok {
+		v.errorf(root, "No matching global declaration available for the validation root")
+	} else {
+		v.element(root, decl)
+	}
+	if len(v.errors) > 0 {
+		return &ValidationError{errors: v.errors}
+	}
+	return nil
+}
+
+// validation collects the errors of a single document validation.
+// If info is set, the schema information of every validated element is recorded as well.
+type validation struct {
+	set    *Set
+	errors []error
+	info   map[*node]*nodeInfo
+}
+
+// nodeInfo is the schema information recorded for an element.
+type nodeInfo struct {
+	repeated bool
+	builtin  string
+}
+
+// note returns the schema information recorded for an element, or nil if it is not recorded.
+func (v *validation) note(n *node) *nodeInfo {
+	if v.info == nil {
+		return nil
+	}
+	ni, ok := v.info[n]
+	if !ok {
+		ni = &nodeInfo{}
+		v.info[n] = ni
+	}
+	return ni
+}
+
+// errorf adds an error for the given element.
+func (v *validation) errorf(n *node, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	v.errors = append(v.errors, fmt.Errorf("line %d: Element '%s': %s.", n.line, formatName(n.name), msg))
+}
+
+// element validates an element against its declaration.
+func (v *validation) element(n *node, decl *elementDecl) {
+	if nilValue, ok := xsiAttr(n, "nil"); ok && (nilValue == "true" || nilValue == "1") {
+		if !decl.nillable {
+			v.errorf(n, "The element is not nillable")
+		} else if len(n.children) > 0 || n.hasText {
+			v.errorf(n, "The element cannot have content since it is nilled")
+		}
+		return
+	}
+
+	t, err := v.set.typeOf(decl)
+	if err != nil {
+		v.errorf(n, "%v", err)
+		return
+	}
+	switch t := t.(type) {
+	case *simpleType:
+		v.checkAttributes(n, nil, false)
+		if len(n.children) > 0 {
+			v.errorf(n, "Element content is not allowed, because the type definition is simple")
+			return
+		}
+		v.checkText(n, t, decl.fixed)
+	case *complexType:
+		v.complex(n, t, decl.fixed)
+	}
+}
+
+// complex validates an element against a complex type.
+func (v *validation) complex(n *node, ct *complexType, fixed *string) {
+	if ct.anyType {
+		v.lax(n)
+		return
+	}
+
+	attrs := ct.attrs
+	anyAttr := ct.anyAttr
+	if ct.simpleBase != nil {
+		st, baseAttrs, err := v.set.simpleContent(ct)
+		if err != nil {
+			v.errorf(n, "%v", err)
+			return
+		}
+		attrs = append(baseAttrs, attrs...)
+		v.checkAttributes(n, attrs, anyAttr)
+		if len(n.children) > 0 {
+			v.errorf(n, "Element content is not allowed, because the content type is a simple type definition")
+			return
+		}
+		v.checkText(n, st, fixed)
+		return
+	}
+
+	v.checkAttributes(n, attrs, anyAttr)
+	if n.hasText && !ct.mixed {
+		v.errorf(n, "Character content other than whitespace is not allowed because the content type is 'element-only'")
+	}
+	if ct.content == nil {
+		if len(n.children) > 0 {
+			v.errorf(n.children[0], "This element is not expected")
+		}
+		return
+	}
+
+	m := &matcher{v: v, children: n.children}
+	i, err := m.match(ct.content, 0)
+	switch {
+	case err != nil && err.at == nil:
+		v.errorf(n, "Missing child element(s). Expected is ( %s )", strings.Join(err.expected, ", "))
+	case err != nil:
+		v.errorf(err.at, "This element is not expected. Expected is ( %s )", strings.Join(err.expected, ", "))
+	case i < len(n.children):
+		v.errorf(n.children[i], "This element is not expected")
+	}
+}
+
+// lax validates the content of an element of type xs:anyType. Child elements with a global declaration
+// are validated, all other content is accepted.
+func (v *validation) lax(n *node) {
+	for _, child := range n.children {
+		if decl, ok := v.set.elements[child.name]; ok {
+			v.element(child, decl)
+			continue
+		}
+		v.lax(child)
+	}
+}
+
+// checkAttributes checks the attributes of an element against the declared attributes.
+// Attributes of the XML schema instance namespace are always allowed.
+func (v *validation) checkAttributes(n *node, decls []*attributeDecl, anyAttr bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == xsiNS {
+			continue
+		}
+		decl := findAttr(decls, a.Name)
+		if decl == nil {
+			if !anyAttr {
+				v.errorf(n, "The attribute '%s' is not allowed", formatName(a.Name))
+			}
+			continue
+		}
+		st, err := v.set.attributeType(decl)
+		if err != nil {
+			v.errorf(n, "%v", err)
+			continue
+		}
+		if err := v.set.checkSimple(st, a.Value); err != nil {
+			v.errorf(n, "attribute '%s': %v", formatName(a.Name), err)
+			continue
+		}
+		if decl.fixed != nil && a.Value != *decl.fixed {
+			v.errorf(n, "attribute '%s': The value '%s' does not match the fixed value constraint '%s'", formatName(a.Name), a.Value, *decl.fixed)
+		}
+	}
+	for _, decl := range decls {
+		if decl.required && !hasAttr(n, decl.name) {
+			v.errorf(n, "The attribute '%s' is required but missing", formatName(decl.name))
+		}
+	}
+}
+
+// checkText checks the character data of an element with simple content.
+func (v *validation) checkText(n *node, st *simpleType, fixed *string) {
+	if ni := v.note(n); ni != nil {
+		ni.builtin, _ = v.set.primitive(st)
+	}
+	if err := v.set.checkSimple(st, n.text); err != nil {
+		v.errorf(n, "%v", err)
+		return
+	}
+	if fixed != nil && strings.TrimSpace(n.text) != *fixed {
+		v.errorf(n, "The value '%s' does not match the fixed value constraint '%s'", n.text, *fixed)
+	}
+}
+
+// matchError describes a content model mismatch. at is the unexpected element, nil at the end of the content.
+type matchError struct {
+	at       *node
+	expected []string
+}
+
+// matcher matches the child elements of an element against a content model.
+// The schemas satisfy the unique particle attribution constraint, so the next child element always
+// determines the particle to use and no backtracking is needed.
+type matcher struct {
+	v        *validation
+	children []*node
+	repeat   int // number of enclosing particles which may occur more than once
+}
+
+// match matches a particle with its occurrence constraints, starting at child i.
+// It returns the index of the first child not consumed.
+func (m *matcher) match(p *particle, i int) (int, *matchError) {
+	if p.max != 1 {
+		m.repeat++
+		defer func() { m.repeat-- }()
+	}
+	for count := 0; p.max == unbounded || count < p.max; count++ {
+		if count >= p.min && (i >= len(m.children) || !m.canStart(p, m.children[i].name)) {
+			break
+		}
+		j, err := m.matchOnce(p, i)
+		if err != nil {
+			return i, err
+		}
+		if j == i {
+			// the term matched empty content, further repetitions would as well
+			break
+		}
+		i = j
+	}
+	return i, nil
+}
+
+// matchOnce matches a single occurrence of the term of a particle.
+func (m *matcher) matchOnce(p *particle, i int) (int, *matchError) {
+	switch p.kind {
+	case pElement, pAny:
+		if i >= len(m.children) || !m.canStart(p, m.children[i].name) {
+			return i, m.mismatch(p, i)
+		}
+		if ni := m.v.note(m.children[i]); ni != nil {
+			ni.repeated = m.repeat > 0
+		}
+		m.child(p, m.children[i])
+		return i + 1, nil
+	case pSequence:
+		for _, c := range p.children {
+			var err *matchError
+			if i, err = m.match(c, i); err != nil {
+				return i, err
+			}
+		}
+		return i, nil
+	case pChoice:
+		if i < len(m.children) {
+			for _, c := range p.children {
+				if m.canStart(c, m.children[i].name) {
+					return m.match(c, i)
+				}
+			}
+		}
+		if m.nullable(p) {
+			return i, nil
+		}
+		return i, m.mismatch(p, i)
+	case pGroup:
+		g, ok := m.v.set.groups[p.ref]
+		if !ok {
+			return i, &matchError{at: m.at(i), expected: []string{"group " + formatName(p.ref) + " not found"}}
+		}
+		return m.match(g, i)
+	}
+	return i, nil
+}
+
+// mismatch returns the error for a particle which does not match at child i.
+func (m *matcher) mismatch(p *particle, i int) *matchError {
+	return &matchError{at: m.at(i), expected: m.first(p)}
+}
+
+// at returns child i or nil at the end of the content.
+func (m *matcher) at(i int) *node {
+	if i >= len(m.children) {
+		return nil
+	}
+	return m.children[i]
+}
+
+// child validates a child element matched by an element or wildcard particle.
+func (m *matcher) child(p *particle, n *node) {
+	if p.kind == pElement {
+		decl, err := m.decl(p)
+		if err != nil {
+			m.v.errorf(n, "%v", err)
+			return
+		}
+		m.v.element(n, decl)
+		return
+	}
+
+	switch p.process {
+	case "skip":
+	case "lax":
+		if decl, ok := m.v.set.elements[n.name]; ok {
+			m.v.element(n, decl)
+		} else {
+			m.v.lax(n)
+		}
+	default:
+		decl, ok := m.v.set.elements[n.name]
+		if !ok {
+			m.v.errorf(n, "No matching global element declaration available, but demanded by the strict wildcard")
+			return
+		}
+		m.v.element(n, decl)
+	}
+}
+
+// decl returns the element declaration of an element particle, resolving references.
+func (m *matcher) decl(p *particle) (*elementDecl, error) {
+	if p.elem != nil {
+		return p.elem, nil
+	}
+	decl, ok := m.v.set.elements[p.ref]
+	if !ok {
+		return nil, fmt.Errorf("element declaration %s not found", formatName(p.ref))
+	}
+	return decl, nil
+}
+
+// canStart reports whether an element with the given name can be the first element matched by a particle.
+func (m *matcher) canStart(p *particle, name xml.Name) bool {
+	if p.max == 0 {
+		return false
+	}
+	switch p.kind {
+	case pElement:
+		decl, err := m.decl(p)
+		return err == nil && decl.name == name
+	case pAny:
+		return p.allows(name.Space)
+	case pSequence:
+		for _, c := range p.children {
+			if m.canStart(c, name) {
+				return true
+			}
+			if !m.nullable(c) {
+				return false
+			}
+		}
+		return false
+	case pChoice:
+		for _, c := range p.children {
+			if m.canStart(c, name) {
+				return true
+			}
+		}
+		return false
+	case pGroup:
+		g, ok := m.v.set.groups[p.ref]
+		return ok && m.canStart(g, name)
+	}
+	return false
+}
+
+// nullable reports whether a particle can match empty content.
+func (m *matcher) nullable(p *particle) bool {
+	if p.min == 0 {
+		return true
+	}
+	switch p.kind {
+	case pSequence:
+		for _, c := range p.children {
+			if !m.nullable(c) {
+				return false
+			}
+		}
+		return true
+	case pChoice:
+		for _, c := range p.children {
+			if m.nullable(c) {
+				return true
+			}
+		}
+		return false
+	case pGroup:
+		g, ok := m.v.set.groups[p.ref]
+		return ok && m.nullable(g)
+	}
+	return false
+}
+
+// first returns the names of the elements a particle can start with, used for error messages.
+func (m *matcher) first(p *particle) []string {
+	switch p.kind {
+	case pElement:
+		decl, err := m.decl(p)
+		if err != nil {
+			return []string{formatName(p.ref)}
+		}
+		return []string{formatName(decl.name)}
+	case pAny:
+		return []string{"##" + p.process}
+	case pSequence:
+		var names []string
+		for _, c := range p.children {
+			names = append(names, m.first(c)...)
+			if !m.nullable(c) {
+				break
+			}
+		}
+		return names
+	case pChoice:
+		var names []string
+		for _, c := range p.children {
+			names = append(names, m.first(c)...)
+		}
+		return names
+	case pGroup:
+		if g, ok := m.v.set.groups[p.ref]; ok {
+			return m.first(g)
+		}
+	}
+	return nil
+}
+
+// allows reports whether a wildcard allows elements of the given namespace.
+func (p *particle) allows(space string) bool {
+	switch {
+	case p.anyNS:
+		return true
+	case p.otherNS:
+		return space != p.targetNS && space != ""
+	}
+	for _, ns := range p.namespace {
+		switch ns {
+		case "##targetNamespace":
+			ns = p.targetNS
+		case "##local":
+			ns = ""
+		}
+		if ns == space {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the type of an element declaration, either a *simpleType or a *complexType.
+func (s *Set) typeOf(decl *elementDecl) (any, error) {
+	switch {
+	case decl.simple != nil:
+		return decl.simple, nil
+	case decl.complex != nil:
+		return decl.complex, nil
+	}
+	return s.typeByName(*decl.typeName)
+}
+
+// typeByName looks up a simple or complex type by name, including the built-in types.
+func (s *Set) typeByName(name xml.Name) (any, error) {
+	if name.Space == xsdNS {
+		if name.Local == "anyType" {
+			return anyType, nil
+		}
+		return s.simpleTypeByName(name)
+	}
+	t, ok := s.types[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", formatName(name))
+	}
+	return t, nil
+}
+
+// simpleContent resolves the simple type of a complex type with simple content and collects the
+// attributes declared by its complex base types.
+func (s *Set) simpleContent(ct *complexType) (*simpleType, []*attributeDecl, error) {
+	var attrs []*attributeDecl
+	for i := 0; i < 100; i++ {
+		t, err := s.typeByName(*ct.simpleBase)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := t.(type) {
+		case *simpleType:
+			return t, attrs, nil
+		case *complexType:
+			if t.simpleBase == nil {
+				return nil, nil, fmt.Errorf("type %s has no simple content", formatName(t.name))
+			}
+			attrs = append(attrs, t.attrs...)
+			ct = t
+		}
+	}
+	return nil, nil, fmt.Errorf("type %s: circular derivation", formatName(ct.name))
+}
+
+// attributeType returns the simple type of an attribute declaration, attributes without type accept any value.
+func (s *Set) attributeType(decl *attributeDecl) (*simpleType, error) {
+	switch {
+	case decl.simple != nil:
+		return decl.simple, nil
+	case decl.typeName != nil:
+		return s.simpleTypeByName(*decl.typeName)
+	}
+	return builtinType("anySimpleType"), nil
+}
+
+// findAttr returns the declaration of the attribute with the given name or nil.
+func findAttr(decls []*attributeDecl, name xml.Name) *attributeDecl {
+	for _, d := range decls {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// hasAttr reports whether the element has the attribute with the given name.
+func hasAttr(n *node, name xml.Name) bool {
+	for _, a := range n.attrs {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// xsiAttr returns the value of the attribute with the given local name in the XML schema instance namespace.
+func xsiAttr(n *node, local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == xsiNS && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// node is a namespace-resolved element of an XML document, used for both schema and instance documents.
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr // without namespace declarations
+	ns       map[string]string
+	children []*node
+	text     string // concatenated character data of the element itself
+	hasText  bool   // true if the element contains non-whitespace character data
+	line     int
+	raw      xml.Name // name as written in the document, used to match the end element
+}
+
+// attr returns the value of the unqualified attribute with the given local name.
+func (n *node) attr(local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// attrOr returns the value of the unqualified attribute with the given local name or def if it is not present.
+func (n *node) attrOr(local, def string) string {
+	if v, ok := n.attr(local); ok {
+		return v
+	}
+	return def
+}
+
+// resolve resolves a QName (e.g. "xs:string") using the namespace declarations in scope of the node.
+func (n *node) resolve(qName string) (xml.Name, error) {
+	prefix, local, found := strings.Cut(qName, ":")
+	if !found {
+		return xml.Name{Space: n.ns[""], Local: prefix}, nil
+	}
+	space, ok := n.ns[prefix]
+	if !ok {
+		return xml.Name{}, fmt.Errorf("line %d: undeclared namespace prefix %s", n.line, prefix)
+	}
+	return xml.Name{Space: space, Local: local}, nil
+}
+
+// parseTree parses an XML document into a node tree.
+func parseTree(data []byte) (*node, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty xml")
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// Tokens are read raw to keep the namespace declarations, names are resolved below.
+	var root *node
+	var stack []*node
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			line, _ := dec.InputPos()
+			n := &node{line: line, raw: t.Name}
+			decls := map[string]string{}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					decls[a.Name.Local] = a.Value
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					decls[""] = a.Value
+				default:
+					n.attrs = append(n.attrs, a)
+				}
+			}
+			n.ns = decls
+			if len(stack) > 0 {
+				// the scope of the parent is shared unless the element declares namespaces itself
+				parentNS := stack[len(stack)-1].ns
+				if len(decls) == 0 {
+					n.ns = parentNS
+				} else {
+					for k, v := range parentNS {
+						if _, ok := decls[k]; !ok {
+							decls[k] = v
+						}
+					}
+				}
+			}
+			var err error
+			if n.name, err = n.resolveName(t.Name, true); err != nil {
+				return nil, err
+			}
+			for i, a := range n.attrs {
+				if n.attrs[i].Name, err = n.resolveName(a.Name, false); err != nil {
+					return nil, err
+				}
+			}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, errors.New("more than one root element")
+				}
+				root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].raw != t.Name {
+				line, _ := dec.InputPos()
+				return nil, fmt.Errorf("line %d: unexpected end element %s", line, t.Name.Local)
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			n := stack[len(stack)-1]
+			n.text += string(t)
+			if strings.TrimSpace(string(t)) != "" {
+				n.hasText = true
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	}
+	if len(stack) > 0 {
+		return nil, errors.New("unexpected end of document")
+	}
+	return root, nil
+}
+
+// resolveName resolves the prefix of an element or attribute name. Unprefixed attributes have no namespace.
+func (n *node) resolveName(name xml.Name, element bool) (xml.Name, error) {
+	if name.Space == "" {
+		if element {
+			return xml.Name{Space: n.ns[""], Local: name.Local}, nil
+		}
+		return name, nil
+	}
+	if name.Space == "xml" {
+		return xml.Name{Space: xmlNS, Local: name.Local}, nil
+	}
+	space, ok := n.ns[name.Space]
+	if !ok {
+		return xml.Name{}, fmt.Errorf("line %d: undeclared namespace prefix %s", n.line, name.Space)
+	}
+	return xml.Name{Space: space, Local: name.Local}, nil
+}
+
+// formatName formats a name the way validation errors show it: {namespace}local.
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
@@ -0,0 +1,28 @@
+//go:build cgo
+
+package validator
+
+import "testing"
+
+// TestConformance validates every testdata fixture with the libxml2 backend, which must agree with the
+// expectation encoded in the file name (_ok / _not_ok) and with the Go backend.
+func TestConformance(t *testing.T) {
+	lx, err := NewLibXML2Validator(isoSchemaDir, t2sSchemaDir)
+	if err != nil {
+		t.Fatalf("libxml2 backend: %v", err)
+	}
+	gv, err := NewGoValidator(isoSchemaDir, t2sSchemaDir)
+	if err != nil {
+		t.Fatalf("go backend: %v", err)
+	}
+	forEachFixture(t, func(t *testing.T, data []byte, sName string, wantValid bool) {
+		lxErr := lx.Validate(data, sName)
+		goErr := gv.Validate(data, sName)
+		if (lxErr == nil) != wantValid {
+			t.Errorf("libxml2: valid=%t, want %t: %v", lxErr == nil, wantValid, lxErr)
+		}
+		if (lxErr == nil) != (goErr == nil) {
+			t.Errorf("backends disagree: libxml2 %v, go %v", lxErr, goErr)
+		}
+	})
+}
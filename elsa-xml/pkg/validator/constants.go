@@ -3,4 +3,8 @@ package validator
 const (
 	envVarT2SSchemaDir = "SCHEMA_DIR_T2S"
 	envVarISOSchemaDir = "SCHEMA_DIR_ISO"
+	envVarBackend      = "VALIDATOR_BACKEND"
+
+	t2sSchemaFile = "CST2SMsg.valid.xsd"
+	t2sSchemaName = "CST2SMsg"
 )
@@ -0,0 +1,62 @@
+package validator
+
+import (
+	"elsa-xml/pkg/schema"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoValidator validates messages with the pure Go schema package, it does not require cgo.
+type GoValidator struct {
+	schemas map[string]*schema.Set
+}
+
+// NewGoValidator creates a pure Go validator with the schemas of the given directories, an empty directory is skipped.
+func NewGoValidator(isoDir, t2sDir string) (*GoValidator, error) {
+	v := GoValidator{
+		schemas: make(map[string]*schema.Set),
+	}
+
+	if isoDir != "" {
+		files, err := os.ReadDir(isoDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			s, err := schema.Load(filepath.Join(isoDir, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			v.schemas[strings.TrimSuffix(file.Name(), ".xsd")] = s
+		}
+	}
+
+	if t2sDir != "" {
+		s, err := schema.Load(filepath.Join(t2sDir, t2sSchemaFile))
+		if err != nil {
+			return nil, err
+		}
+		v.schemas[t2sSchemaName] = s
+	}
+
+	return &v, nil
+}
+
+func (v *GoValidator) Validate(xml []byte, schemaName string) error {
+	s, ok := v.schemas[schemaName]
+	if !ok {
+		return fmt.Errorf("schema %s not found", schemaName)
+	}
+
+	err := s.Validate(xml)
+	if err != nil {
+		printErrors(err)
+		return err
+	}
+	return nil
+}
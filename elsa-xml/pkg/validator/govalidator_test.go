@@ -0,0 +1,65 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	isoSchemaDir = "../../schemas/ISO"
+	t2sSchemaDir = "../../schemas/T2S"
+	testDataDir  = "../../testdata"
+)
+
+// TestGoValidator validates every testdata fixture with the Go backend against the expectation encoded
+// in the file name (_ok / _not_ok).
+func TestGoValidator(t *testing.T) {
+	gv, err := NewGoValidator(isoSchemaDir, t2sSchemaDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forEachFixture(t, func(t *testing.T, data []byte, sName string, wantValid bool) {
+		err := gv.Validate(data, sName)
+		if (err == nil) != wantValid {
+			t.Errorf("valid=%t, want %t: %v", err == nil, wantValid, err)
+		}
+		if err != nil {
+			if _, ok := err.(SchemaValidationError); !ok {
+				t.Errorf("error is not a schema validation error: %v", err)
+			}
+		}
+	})
+}
+
+// forEachFixture runs test for every testdata fixture with its schema name and expected validity.
+func forEachFixture(t *testing.T, test func(t *testing.T, data []byte, sName string, wantValid bool)) {
+	files, err := filepath.Glob(filepath.Join(testDataDir, "*", "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(filepath.Base(filepath.Dir(file))+"/"+name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, data, fixtureSchema(name), !strings.Contains(name, "not_ok"))
+		})
+	}
+}
+
+// fixtureSchema derives the schema name from a fixture file name as the demo does.
+func fixtureSchema(fileName string) string {
+	parts := strings.Split(fileName, "_")
+	if len(parts) > 1 && parts[1] == "t2s" {
+		return t2sSchemaName
+	}
+	return parts[0]
+}
@@ -0,0 +1,88 @@
+//go:build cgo
+
+package validator
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/libxml2"
+	"github.com/lestrrat-go/libxml2/xsd"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultBackend = BackendLibXML2
+
+// LibXML2Validator validates messages with libxml2, it requires cgo.
+type LibXML2Validator struct {
+	parsedSchemas map[string]*xsd.Schema
+}
+
+// NewLibXML2Validator creates a libxml2 validator with the schemas of the given directories, an empty directory is skipped.
+func NewLibXML2Validator(isoDir, t2sDir string) (*LibXML2Validator, error) {
+	v := LibXML2Validator{
+		parsedSchemas: make(map[string]*xsd.Schema),
+	}
+
+	if isoDir != "" {
+		err := v.loadISOSchemas(isoDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if t2sDir != "" {
+		err := v.loadT2SSchemas(t2sDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &v, nil
+}
+
+func (v *LibXML2Validator) Validate(xml []byte, schema string) error {
+	doc, err := libxml2.Parse(xml)
+	if err != nil {
+		return err
+	}
+	defer doc.Free()
+
+	if _, ok := v.parsedSchemas[schema]; !ok {
+		return fmt.Errorf("schema %s not found", schema)
+	}
+
+	err = v.parsedSchemas[schema].Validate(doc)
+	if err != nil {
+		printErrors(err)
+		return err
+	}
+	return nil
+}
+
+func (v *LibXML2Validator) loadISOSchemas(isoDir string) error {
+	isoFiles, err := os.ReadDir(isoDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range isoFiles {
+		if file.IsDir() {
+			continue
+		}
+		schema, err := xsd.ParseFromFile(filepath.Join(isoDir, file.Name()))
+		if err != nil {
+			return err
+		}
+		v.parsedSchemas[strings.TrimSuffix(file.Name(), ".xsd")] = schema
+	}
+	return nil
+}
+
+func (v *LibXML2Validator) loadT2SSchemas(t2sDir string) error {
+	schema, err := xsd.ParseFromFile(filepath.Join(t2sDir, t2sSchemaFile))
+	if err != nil {
+		return err
+	}
+	v.parsedSchemas[t2sSchemaName] = schema
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !cgo
+
+package validator
+
+import "errors"
+
+const defaultBackend = BackendGo
+
+// LibXML2Validator is not available in builds without cgo.
+type LibXML2Validator struct{}
+
+// NewLibXML2Validator returns an error, libxml2 requires cgo.
+func NewLibXML2Validator(_, _ string) (*LibXML2Validator, error) {
+	return nil, errors.New("libxml2 validator backend requires cgo, use VALIDATOR_BACKEND=go")
+}
+
+func (v *LibXML2Validator) Validate(_ []byte, _ string) error {
+	return errors.New("libxml2 validator backend requires cgo")
+}
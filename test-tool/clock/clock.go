@@ -0,0 +1,134 @@
+// Package clock provides the time of the mock ELSA server and the scenarios. The real clock follows the
+// wall clock, a manual clock only moves when it is advanced, so that timeouts and retries which take
+// minutes or hours in ELSA can be tested in seconds.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs functions after a duration
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine (Real) or in the goroutine advancing the clock (Manual)
+	// once the duration passed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call of AfterFunc
+type Timer interface {
+	// Stop prevents the call, it reports whether the call was still pending
+	Stop() bool
+}
+
+// Real is the wall clock
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// Manual is a clock which only moves when it is advanced, it is safe for concurrent use
+type Manual struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer // pending, in order of creation
+	seq    int            // sequence number of the timer created last
+}
+
+// NewManual returns a manual clock standing at start
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *Manual) AfterFunc(d time.Duration, f func()) Timer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	t := &manualTimer{clock: m, due: m.now.Add(d), seq: m.seq, f: f}
+	m.timers = append(m.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d. The functions of the timers due until then are called in the
+// order they are due, each one with the clock standing at its due time, so that timers started by them
+// fire within the same advance if they are due by its end. It returns the number of calls.
+func (m *Manual) Advance(d time.Duration) int {
+	m.mu.Lock()
+	end := m.now.Add(d)
+	m.mu.Unlock()
+
+	calls := 0
+	for {
+		m.mu.Lock()
+		t := m.nextDue(end)
+		if t == nil {
+			if end.After(m.now) {
+				m.now = end
+			}
+			m.mu.Unlock()
+			return calls
+		}
+		m.remove(t)
+		if t.due.After(m.now) {
+			m.now = t.due
+		}
+		m.mu.Unlock()
+
+		t.f() // without the lock, the function may use the clock
+		calls++
+	}
+}
+
+// Pending returns the number of timers not yet due
+func (m *Manual) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.timers)
+}
+
+// nextDue returns the timer due first if it is due by end, m.mu must be held
+func (m *Manual) nextDue(end time.Time) *manualTimer {
+	sort.SliceStable(m.timers, func(i, j int) bool {
+		if m.timers[i].due.Equal(m.timers[j].due) {
+			return m.timers[i].seq < m.timers[j].seq
+		}
+		return m.timers[i].due.Before(m.timers[j].due)
+	})
+	if len(m.timers) == 0 || m.timers[0].due.After(end) {
+		return nil
+	}
+	return m.timers[0]
+}
+
+// remove removes a pending timer, it reports whether it was pending. m.mu must be held.
+func (m *Manual) remove(t *manualTimer) bool {
+	for i, pending := range m.timers {
+		if pending == t {
+			m.timers = append(m.timers[:i], m.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type manualTimer struct {
+	clock *Manual
+	due   time.Time
+	seq   int
+	f     func()
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
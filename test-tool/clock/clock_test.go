@@ -0,0 +1,47 @@
+package clock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManual(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	m := NewManual(start)
+
+	var calls []string
+	at := func(name string) func() {
+		return func() { calls = append(calls, name+" "+m.Now().Format("15:04")) }
+	}
+	m.AfterFunc(30*time.Minute, at("b"))
+	m.AfterFunc(10*time.Minute, at("a"))
+	stopped := m.AfterFunc(20*time.Minute, at("stopped"))
+	m.AfterFunc(30*time.Minute, func() {
+		calls = append(calls, "c "+m.Now().Format("15:04"))
+		m.AfterFunc(15*time.Minute, at("chained")) // due within the advance
+		m.AfterFunc(time.Hour, at("late"))
+	})
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop should report a pending timer once")
+	}
+
+	if n := m.Advance(45 * time.Minute); n != 4 {
+		t.Errorf("got %d calls", n)
+	}
+	want := []string{"a 09:10", "b 09:30", "c 09:30", "chained 09:45"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+	if !m.Now().Equal(start.Add(45*time.Minute)) || m.Pending() != 1 {
+		t.Errorf("got %s with %d pending timers", m.Now(), m.Pending())
+	}
+
+	if n := m.Advance(time.Minute); n != 0 {
+		t.Errorf("got %d calls", n)
+	}
+	m.Advance(time.Hour)
+	if calls[len(calls)-1] != "late 10:30" || m.Pending() != 0 {
+		t.Errorf("got calls %v", calls)
+	}
+}
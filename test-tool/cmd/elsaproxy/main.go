@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"test-tool/mock_elsa_server"
+)
+
+// main runs a reverse proxy in front of the ELSA API which records the request/response pairs into a
+// cassette. Point the test-tool (elsaApiBaseUrl) or Postman at the proxy, the mock ELSA API server replays
+// the cassette with the step 'the mock ELSA API server replays the cassette "<file>"'.
+func main() {
+	target := flag.String("target", "", "URL of the ELSA API, e.g. http://elsa-test:8080")
+	listen := flag.String("listen", "localhost:8090", "address the proxy listens on")
+	out := flag.String("o", "elsa_cassette.json", "cassette file to write")
+	flag.Parse()
+
+	if *target == "" {
+		exitWithError(fmt.Errorf("-target is required"))
+	}
+	proxy, err := mock_elsa_server.NewRecordingProxy(*target, *out)
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Recording %s into %s, proxy listening on http://%s\n", *target, *out, *listen)
+	if err := http.ListenAndServe(*listen, proxy); err != nil {
+		exitWithError(err)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"test-tool/flowgen"
+	"test-tool/mock_elsa_server"
+)
+
+// main writes the scenarios for all paths through the business flow, to stdout if no output file is given.
+// With -list the paths and transitions of the model are listed instead.
+func main() {
+	out := flag.String("o", "", "feature file to write")
+	config := flag.String("config", flowgen.DefaultOptions.Config, "configuration file used in the Background")
+	all := flag.Bool("all-permutations", false, "run every path with all movement and payment types")
+	timeout := flag.Int("timeout", flowgen.DefaultOptions.TimeoutSeconds, "seconds a timeout path checks the status is kept")
+	list := flag.Bool("list", false, "list the paths and transitions of the model")
+	flag.Parse()
+
+	paths := mock_elsa_server.Paths()
+	if *list {
+		for i, p := range paths {
+			fmt.Printf("Path %d - %s\n", i+1, p)
+			for _, t := range p.Transitions {
+				fmt.Printf("    %s\n", t)
+			}
+		}
+		return
+	}
+
+	opts := flowgen.DefaultOptions
+	opts.Config = *config
+	opts.TimeoutSeconds = *timeout
+	if *all {
+		opts.Permutations = flowgen.AllPermutations
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := flowgen.Generate(w, paths, opts); err != nil {
+		exitWithError(err)
+	}
+	if *out != "" {
+		fmt.Printf("%d scenarios written to %s\n", len(paths), *out)
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"time"
+)
+
+// config is the configuration file of the standalone mock, see testdata/config/mock_elsa.json
+type config struct {
+	Listen     string `json:"listen"`     // address the mock listens on, e.g. localhost:8080
+	APIBaseURL string `json:"apiBaseUrl"` // URL the links in the responses point to, derived from listen if empty
+	Cassette   string `json:"cassette"`   // recorded responses to replay, see cmd/elsaproxy
+
+	// Business flow: with queues the statuses are derived from the messages like ELSA does, the
+	// queue names are the ones of elsa_services.json. Without the mock only serves the state set
+	// through the control API.
+	QueueTransport              *queue.Config `json:"queueTransport"`
+	T2SClientRequestQueuePath   string        `json:"t2sClientRequestQueuePath"`
+	T2SAcceptanceQueuePath      string        `json:"t2sAcceptanceQueuePath"`
+	CreationRequestQueuePath    string        `json:"creationRequestQueuePath"`
+	CreationAcceptanceQueuePath string        `json:"creationAcceptanceQueuePath"`
+	T2SOutboundQueuePath        string        `json:"t2sOutboundQueuePath"`
+
+	Instructions []seedInstruction        `json:"instructions"` // created at start
+	Faults       []mock_elsa_server.Fault `json:"faults"`
+	QueueFaults  []queue.Fault            `json:"queueFaults"`
+
+	// Retry of unanswered messages, e.g. {"timeout": "30m", "maxRetries": 3}. With ControlledClock the
+	// clock only moves when advanced through the control API (POST /_mock/clock/advance).
+	Retry           mock_elsa_server.RetryPolicy `json:"retry"`
+	ControlledClock bool                         `json:"controlledClock"`
+}
+
+type seedInstruction struct {
+	TXID string `json:"txID"`
+	mock_elsa_server.InstructionUpdate
+}
+
+// main runs the mock ELSA API server as a standalone process, e.g. for Postman or a manual test of the
+// primary systems. Its state is controlled through the control API below /_mock, see newControlMux.
+func main() {
+	configPath := flag.String("config", "", "configuration file of the mock, e.g. testdata/config/mock_elsa.json")
+	listen := flag.String("listen", "", "address the mock listens on, overrides the configuration")
+	flag.Parse()
+
+	cfg := config{Listen: "localhost:8080"}
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to read configuration: %w", err))
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			exitWithError(fmt.Errorf("failed to parse configuration %s: %w", *configPath, err))
+		}
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	if cfg.APIBaseURL == "" {
+		cfg.APIBaseURL = "http://" + cfg.Listen
+	}
+
+	s, err := newMock(cfg)
+	if err != nil {
+		exitWithError(err)
+	}
+	defer s.StopFlow()
+
+	srv := &http.Server{Addr: cfg.Listen, Handler: s}
+	srv.RegisterOnShutdown(s.CloseEvents)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Mock ELSA API server listening on %s, control API at %s/_mock\n", cfg.APIBaseURL, cfg.APIBaseURL)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		exitWithError(err)
+	}
+}
+
+// newMock creates the mock with the state of the configuration
+func newMock(cfg config) (*mock_elsa_server.MockElsaAPIServer, error) {
+	s := mock_elsa_server.NewMockElsaAPIServer()
+	s.SetAPIBaseURL(cfg.APIBaseURL)
+	if cfg.ControlledClock {
+		s.ControlClock(time.Time{})
+	}
+	if cfg.Cassette != "" {
+		cassette, err := mock_elsa_server.LoadCassette(cfg.Cassette)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.ReplayCassette(cassette, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to replay cassette %s: %w", cfg.Cassette, err)
+		}
+	}
+	for _, seed := range cfg.Instructions {
+		if seed.TXID == "" {
+			return nil, fmt.Errorf("instruction of the configuration without txID")
+		}
+		s.PutInstruction(seed.TXID, seed.InstructionUpdate)
+	}
+	for _, fault := range cfg.Faults {
+		if _, err := s.AddFault(fault); err != nil {
+			return nil, fmt.Errorf("invalid fault of the configuration: %w", err)
+		}
+	}
+	for _, fault := range cfg.QueueFaults {
+		if _, err := s.QueueFaults().Add(fault); err != nil {
+			return nil, fmt.Errorf("invalid queue fault of the configuration: %w", err)
+		}
+	}
+	if err := s.SetRetryPolicy(cfg.Retry); err != nil {
+		return nil, fmt.Errorf("invalid retry of the configuration: %w", err)
+	}
+	if cfg.QueueTransport != nil {
+		if err := startFlow(s, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// startFlow opens the queues of the configuration and starts the business flow of the mock
+func startFlow(s *mock_elsa_server.MockElsaAPIServer, cfg config) error {
+	if cfg.QueueTransport.Type == queue.TransportMemory {
+		return fmt.Errorf("queue transport %s is only reachable within the process, use %s or %s", queue.TransportMemory, queue.TransportDirectory, queue.TransportAMQP)
+	}
+	broker, err := queue.Open(*cfg.QueueTransport)
+	if err != nil {
+		return err
+	}
+	var queues mock_elsa_server.FlowQueues
+	for _, q := range []struct {
+		key, name string
+		dst       *queue.Queue
+	}{
+		{"t2sClientRequestQueuePath", cfg.T2SClientRequestQueuePath, nil},
+		{"t2sAcceptanceQueuePath", cfg.T2SAcceptanceQueuePath, nil},
+		{"creationAcceptanceQueuePath", cfg.CreationAcceptanceQueuePath, &queues.CreationInbound},
+		{"t2sOutboundQueuePath", cfg.T2SOutboundQueuePath, &queues.T2SOutbound},
+		{"creationRequestQueuePath", cfg.CreationRequestQueuePath, &queues.CreationOutbound},
+	} {
+		if q.name == "" {
+			return fmt.Errorf("%s is required with a queue transport", q.key)
+		}
+		opened, err := broker.Queue(q.name)
+		if err != nil {
+			return fmt.Errorf("failed to open queue %s: %w", q.name, err)
+		}
+		opened = s.QueueFaults().Wrap(opened)
+		if q.dst == nil {
+			queues.T2SInbound = append(queues.T2SInbound, opened)
+		} else {
+			*q.dst = opened
+		}
+	}
+	if err := s.StartFlow(queues); err != nil {
+		return fmt.Errorf("failed to start the business flow: %w", err)
+	}
+	return nil
+}
+
+func exitWithError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"test-tool/postman"
+)
+
+// main converts a Postman collection (v2.1) into a feature file, to stdout if no output file is given.
+// The items which could not be translated are listed, they are marked MANUAL in the feature.
+func main() {
+	collection := flag.String("collection", "", "Postman collection file (v2.1)")
+	environment := flag.String("environment", "", "Postman environment file, optional")
+	out := flag.String("o", "", "feature file to write")
+	config := flag.String("config", postman.DefaultOptions.Config, "configuration file used in the Background")
+	baseURL := flag.String("base-url-variable", postman.DefaultOptions.BaseURLVariable, "variable set to the ELSA API base URL of the configuration, empty to keep its value")
+	flag.Parse()
+
+	if *collection == "" {
+		exitWithError(fmt.Errorf("-collection is required"))
+	}
+	c, err := postman.LoadCollection(*collection)
+	if err != nil {
+		exitWithError(err)
+	}
+	var env *postman.Environment
+	if *environment != "" {
+		if env, err = postman.LoadEnvironment(*environment); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	issues, err := postman.Convert(w, c, env, postman.Options{Config: *config, BaseURLVariable: *baseURL})
+	if err != nil {
+		exitWithError(err)
+	}
+	if *out != "" {
+		fmt.Printf("Collection %q written to %s\n", c.Info.Name, *out)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%d items need manual work:\n", len(issues))
+		for _, issue := range issues {
+			fmt.Fprintf(os.Stderr, "  %s\n", issue)
+		}
+	}
+}
+
+func exitWithError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,185 @@
+// Package db runs read-only queries against the database the ELSA services share, for the state the
+// REST API does not expose, e.g. retry counters.
+//
+// The database is accessed through database/sql, the driver is selected by the "database" section of
+// elsa_services.json. Only the drivers imported in drivers.go are linked into the test tool, SQLite
+// (driver "sqlite") is always available for local tests. The queries are named and read from a file,
+// see LoadQueries, their parameters are written as :name and bound in the style of the driver.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Null is the text of a NULL value in a Result
+const Null = "NULL"
+
+// Config selects the database, the "database" section of elsa_services.json
+type Config struct {
+	Driver              string `json:"driver"`              // name of the database/sql driver, e.g. sqlite, no database if empty
+	DSN                 string `json:"dsn"`                 // data source name of the driver, usually a secret reference
+	Queries             string `json:"queries"`             // file of the named queries, see LoadQueries
+	QueryTimeoutSeconds int    `json:"queryTimeoutSeconds"` // limit of a query
+}
+
+// Enabled reports whether a database is configured
+func (c Config) Enabled() bool {
+	return c.Driver != ""
+}
+
+// Drivers returns the names of the linked database/sql drivers
+func Drivers() []string {
+	drivers := sql.Drivers()
+	sort.Strings(drivers)
+	return drivers
+}
+
+// DB is a database only read from
+type DB struct {
+	db      *sql.DB
+	driver  string
+	timeout time.Duration
+}
+
+// Open opens the configured database, the connection is checked
+func Open(cfg Config) (*DB, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("no database configured, set database.driver and database.dsn")
+	}
+	conn, err := sql.Open(cfg.Driver, readOnlyDSN(cfg.Driver, cfg.DSN))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database (driver %s): %w", cfg.Driver, err)
+	}
+	d := &DB{db: conn, driver: cfg.Driver, timeout: time.Duration(cfg.QueryTimeoutSeconds) * time.Second}
+	if d.timeout <= 0 {
+		d.timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to database (driver %s): %w", cfg.Driver, err)
+	}
+	return d, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Result holds the rows of a query, the values as text
+type Result struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Column returns the index of a column, the names are compared case-insensitively
+func (r *Result) Column(name string) (int, bool) {
+	for i, c := range r.Columns {
+		if strings.EqualFold(c, name) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Query runs a read-only query with named parameters, see Bind. Only a SELECT statement is accepted (see
+// checkReadOnly), it runs in a read-only transaction which is rolled back. Not all drivers enforce the
+// read-only transaction, SQLite is opened read-only instead, see readOnlyDSN.
+func (d *DB) Query(ctx context.Context, query string, params map[string]string) (*Result, error) {
+	if err := checkReadOnly(query); err != nil {
+		return nil, err
+	}
+	stmt, args, err := Bind(query, params, placeholderStyle(d.driver))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = text(v)
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return res, nil
+}
+
+// text returns the text of a value scanned by database/sql
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return Null
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// modifyingKeywords start the statements changing data, also within a common table expression
+var modifyingKeywords = map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true}
+
+// checkReadOnly rejects everything but a single SELECT (or WITH ... SELECT) statement. A WITH query must
+// not contain a statement changing data, e.g. WITH gone AS (DELETE FROM t RETURNING *) SELECT * FROM gone.
+func checkReadOnly(query string) error {
+	tokens := tokenize(query)
+	for i, t := range tokens {
+		if t.kind == tokenSemicolon && i != len(tokens)-1 {
+			return errors.New("query must be a single statement")
+		}
+	}
+	if len(tokens) == 0 {
+		return errors.New("query is empty")
+	}
+	switch strings.ToUpper(tokens[0].text) {
+	case "SELECT":
+		return nil
+	case "WITH":
+		for i, t := range tokens {
+			word := strings.ToUpper(t.text)
+			replace := word == "REPLACE" && i+1 < len(tokens) && strings.EqualFold(tokens[i+1].text, "INTO")
+			if t.kind == tokenWord && (modifyingKeywords[word] || replace) {
+				return fmt.Errorf("query must not change data, got %s", t.text)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("query must be a SELECT statement, got %s", tokens[0].text)
+}
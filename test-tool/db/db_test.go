@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+CREATE TABLE instruction (client_txid TEXT PRIMARY KEY, status TEXT, retry_count INTEGER, settled_at TEXT);
+INSERT INTO instruction VALUES ('TXN_1', 'created', 2, NULL);
+INSERT INTO instruction VALUES ('TXN_2', 'settled', 0, '2026-01-02');
+`
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "elsa.db")
+	if err := CreateSQLite(context.Background(), path, testSchema); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Open(Config{Driver: "sqlite", DSN: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestQuery(t *testing.T) {
+	d := openTestDB(t)
+	res, err := d.Query(context.Background(), `
+		-- the status ':txid' is not a parameter
+		SELECT client_txid AS TXID, status, retry_count, settled_at FROM instruction
+		WHERE client_txid = :txid OR status = :status ORDER BY client_txid;`,
+		map[string]string{"txid": "TXN_1", "status": "settled", "unused": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"TXN_1", "created", "2", Null}, {"TXN_2", "settled", "0", "2026-01-02"}}
+	if !reflect.DeepEqual(res.Rows, want) {
+		t.Errorf("got %v, want %v", res.Rows, want)
+	}
+	if i, ok := res.Column("txid"); !ok || i != 0 {
+		t.Errorf("column txid: got %d %v", i, ok)
+	}
+
+	for query, want := range map[string]string{
+		"DELETE FROM instruction":                                                     "must be a SELECT statement, got DELETE",
+		"SELECT 1; DELETE FROM instruction":                                           "single statement",
+		"SELECT * FROM instruction WHERE client_txid = :x":                            "query parameters :x missing, given: :txid",
+		"WITH gone AS (DELETE FROM instruction RETURNING *) SELECT * FROM gone":       "must not change data, got DELETE",
+		"WITH t AS (SELECT 1) REPLACE INTO instruction (client_txid) SELECT * FROM t": "must not change data, got REPLACE",
+	} {
+		if _, err := d.Query(context.Background(), query, map[string]string{"txid": "TXN_1"}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", query, err, want)
+		}
+	}
+
+	res, err = d.Query(context.Background(), "WITH s AS (SELECT replace(status, 'd', 'D') AS status FROM instruction) SELECT * FROM s ORDER BY status", nil)
+	if err != nil || len(res.Rows) != 2 || res.Rows[0][0] != "createD" {
+		t.Errorf("WITH query: got %v, %v", res, err)
+	}
+	// the database itself refuses writes, not only the checks of Query
+	if _, err := d.db.ExecContext(context.Background(), "DELETE FROM instruction"); err == nil {
+		t.Error("SQLite database opened writable")
+	}
+}
+
+func TestBind(t *testing.T) {
+	query := "SELECT a::text FROM t WHERE b = :txid AND c = ':no' AND d = :txid /* :no */ AND e = :n_1"
+	params := map[string]string{"txid": "T", "n_1": "1"}
+	for style, want := range map[Style]string{
+		StyleQuestion: "SELECT a::text FROM t WHERE b = ? AND c = ':no' AND d = ? /* :no */ AND e = ?",
+		StyleDollar:   "SELECT a::text FROM t WHERE b = $1 AND c = ':no' AND d = $2 /* :no */ AND e = $3",
+		StyleColon:    "SELECT a::text FROM t WHERE b = :1 AND c = ':no' AND d = :2 /* :no */ AND e = :3",
+		StyleAt:       "SELECT a::text FROM t WHERE b = @p1 AND c = ':no' AND d = @p2 /* :no */ AND e = @p3",
+	} {
+		got, args, err := Bind(query, params, style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want || !reflect.DeepEqual(args, []interface{}{"T", "T", "1"}) {
+			t.Errorf("style %d: got %s %v", style, got, args)
+		}
+	}
+}
+
+func TestLoadQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.sql")
+	content := "-- queries of the tests\n\n-- name: first\nSELECT 1;\n\n-- name: second\nSELECT *\nFROM t\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	queries, err := LoadQueries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"first": "SELECT 1", "second": "SELECT *\nFROM t"}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("got %q", queries)
+	}
+
+	if err := os.WriteFile(path, []byte("-- name: a\nSELECT 1\n-- name: a\nSELECT 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadQueries(path); err == nil || !strings.Contains(err.Error(), "defined twice") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	// The database/sql drivers linked into the test tool, add the driver of the ELSA database here
+	_ "modernc.org/sqlite" // "sqlite", pure Go
+)
+
+// CreateSQLite creates an SQLite database file with the statements of script, e.g. the tables and rows of
+// a local stand-in for the ELSA database. It and InsertSQLite are the only functions of the package writing
+// to a database, a database opened by Open is only read.
+func CreateSQLite(ctx context.Context, path, script string) error {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to create SQLite database %s: %w", path, err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("failed to set up SQLite database %s: %w", path, err)
+	}
+	return nil
+}
+
+// readOnlyDSN returns the data source name of a connection refusing writes if the driver supports it.
+// SQLite runs the pragma query_only on every connection, the driver ignores read-only transactions.
+func readOnlyDSN(driver, dsn string) string {
+	if driver != "sqlite" {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=query_only(1)"
+}
+
+// sqlIdentifier matches the table and column names InsertSQLite accepts
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// InsertSQLite inserts rows into a table of an SQLite database created by CreateSQLite, a value Null is
+// inserted as NULL
+func InsertSQLite(ctx context.Context, path, table string, columns []string, rows [][]string) error {
+	for _, name := range append([]string{table}, columns...) {
+		if !sqlIdentifier.MatchString(name) {
+			return fmt.Errorf("invalid table or column name %q", name)
+		}
+	}
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	defer conn.Close()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders)
+	for _, row := range rows {
+		args := make([]interface{}, len(row))
+		for i, v := range row {
+			if v != Null {
+				args[i] = v
+			}
+		}
+		if _, err := conn.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryName starts a query in a queries file
+var queryName = regexp.MustCompile(`^--\s*name:\s*([A-Za-z0-9_.-]+)\s*$`)
+
+// LoadQueries reads the named queries of a file. Each query starts with a comment line naming it:
+//
+//	-- name: instruction_retries
+//	SELECT retry_count FROM instruction WHERE client_txid = :txid
+func LoadQueries(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+	queries := make(map[string]string)
+	name := ""
+	var body []string
+	finish := func() error {
+		if name == "" {
+			return nil
+		}
+		q := strings.TrimRight(strings.TrimSpace(strings.Join(body, "\n")), ";")
+		if q == "" {
+			return fmt.Errorf("%s: query %s is empty", path, name)
+		}
+		queries[name] = q
+		return nil
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if m := queryName.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if _, ok := queries[m[1]]; ok {
+				return nil, fmt.Errorf("%s:%d: query %s is defined twice", path, i+1, m[1])
+			}
+			name, body = m[1], nil
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if name == "" && trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			return nil, fmt.Errorf("%s:%d: statement before the first -- name: line", path, i+1)
+		}
+		body = append(body, line)
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// Style is how a driver expects positional parameters
+type Style int
+
+const (
+	StyleQuestion Style = iota // ?, e.g. SQLite and MySQL
+	StyleDollar                // $1, PostgreSQL
+	StyleColon                 // :1, Oracle
+	StyleAt                    // @p1, SQL Server
+)
+
+// placeholderStyle returns the style of the driver, the question mark for unknown drivers
+func placeholderStyle(driver string) Style {
+	switch driver {
+	case "postgres", "pgx", "pgx/v5":
+		return StyleDollar
+	case "godror", "oracle", "oci8":
+		return StyleColon
+	case "sqlserver", "mssql":
+		return StyleAt
+	}
+	return StyleQuestion
+}
+
+func (s Style) placeholder(n int) string {
+	switch s {
+	case StyleDollar:
+		return "$" + strconv.Itoa(n)
+	case StyleColon:
+		return ":" + strconv.Itoa(n)
+	case StyleAt:
+		return "@p" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Bind replaces the named parameters :name of the query by the placeholders of the style, it returns
+// the arguments in their order. Parameters in strings and comments and casts like ::text are kept.
+func Bind(query string, params map[string]string, style Style) (string, []interface{}, error) {
+	var b strings.Builder
+	var args []interface{}
+	var missing []string
+	last := 0
+	for _, t := range tokenize(query) {
+		if t.kind != tokenParam {
+			continue
+		}
+		name := t.text[1:]
+		v, ok := params[name]
+		if !ok {
+			missing = append(missing, t.text)
+			continue
+		}
+		args = append(args, v)
+		b.WriteString(query[last:t.start])
+		b.WriteString(style.placeholder(len(args)))
+		last = t.end
+	}
+	if len(missing) > 0 {
+		given := make([]string, 0, len(params))
+		for name := range params {
+			given = append(given, ":"+name)
+		}
+		sort.Strings(given)
+		return "", nil, fmt.Errorf("query parameters %s missing, given: %s", strings.Join(missing, ", "), strings.Join(given, ", "))
+	}
+	b.WriteString(query[last:])
+	return b.String(), args, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenParam
+	tokenSemicolon
+)
+
+// token is a word, parameter or semicolon of a query, start and end are byte offsets
+type token struct {
+	kind       tokenKind
+	text       string
+	start, end int
+}
+
+// tokenize returns the words, parameters and semicolons of a query outside of strings, quoted
+// identifiers and comments
+func tokenize(query string) []token {
+	var tokens []token
+	isWord := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			i++
+			for i < len(query) {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c { // doubled quote
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case strings.HasPrefix(query[i:], "::"):
+			i += 2
+		case c == ':' && i+1 < len(query) && isWord(query[i+1]) && query[i+1] > '9':
+			end := i + 1
+			for end < len(query) && isWord(query[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokenParam, query[i:end], i, end})
+			i = end
+		case c == ';':
+			tokens = append(tokens, token{tokenSemicolon, ";", i, i + 1})
+			i++
+		case isWord(c):
+			end := i
+			for end < len(query) && isWord(query[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, query[i:end], i, end})
+			i = end
+		default:
+			i++
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,18 @@
+Feature: Replay of recorded ELSA API responses
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+
+  # recorded with cmd/elsaproxy in front of the ELSA API, the TXID of the recording is replaced by the one polled
+  Scenario: Instruction sent to CREATION is replayed from a cassette
+    Given the mock ELSA API server replays the cassette "instruction_sent_to_creation.json"
+    Then ELSA instruction "TXN_REPLAY_001" should have status "sent_to_creation" within configured polling limits
+    And ELSA instruction "TXN_REPLAY_001" should report "txID" as "miti-TXN_REPLAY_001"
+    And ELSA instruction "TXN_REPLAY_001" should have the status history:
+      | Status           |
+      | created          |
+      | accepted_by_t2s  |
+      | sent_to_creation |
+    And the audit trail of ELSA instruction "TXN_REPLAY_001" should contain the action "status_changed" for status "sent_to_creation"
+    And the collection of ELSA instruction "TXN_REPLAY_001" should contain the message of status "accepted_by_t2s"
+    And all ELSA API responses of the scenario should conform to the OpenAPI spec
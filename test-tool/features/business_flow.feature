@@ -0,0 +1,116 @@
+Feature: ELSA business flow driven by the mock state machine
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+
+  Scenario: Client copy accepted by T2S is sent to CREATION
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_FLOW_001 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | FREE         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FLOW_001"
+    Then ELSA instruction "TXN_FLOW_001" should have status "created" within configured polling limits
+    And ELSA instruction "TXN_FLOW_001" should report "instructingParty" as "DAKVDEFFLIO"
+    And ELSA instruction "TXN_FLOW_001" should report "movementType" as "DELI"
+    And ELSA instruction "TXN_FLOW_001" should report "paymentType" as "FREE"
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_001 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_001"
+    Then ELSA instruction "TXN_FLOW_001" should have status "sent_to_creation" within configured polling limits
+
+  Scenario: Client copy rejected by T2S
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_FLOW_002 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | RECE         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FLOW_002"
+    Then ELSA instruction "TXN_FLOW_002" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_rejection.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_002 |
+      | ReasonCode    | SAFE         |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_002"
+    Then ELSA instruction "TXN_FLOW_002" should have status "rejected_by_t2s" within configured polling limits
+
+  Scenario: Instruction settled with CREATION replies per step
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_FLOW_003 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FLOW_003"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_003 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_003"
+    Then CREATION receives a request for "TXN_FLOW_003" within configured polling limits
+    And the received CREATION message should contain "SttlmTpAndAddtlParams/SctiesMvmntTp" with value "DELI"
+    And the received CREATION message should contain "SttlmTpAndAddtlParams/Pmt" with value "APMT"
+    When CREATION accepts the instruction "TXN_FLOW_003"
+    Then ELSA instruction "TXN_FLOW_003" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_003 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_003"
+    Then CREATION receives a match for "TXN_FLOW_003" within configured polling limits
+    When CREATION settles the instruction "TXN_FLOW_003"
+    Then ELSA instruction "TXN_FLOW_003" should have status "released" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_settled_copy.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_003 |
+      | MovementType  | DELI         |
+      | PaymentType   | APMT         |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_003"
+    Then ELSA instruction "TXN_FLOW_003" should have status "settled" within configured polling limits
+
+  Scenario: Request rejected by CREATION is cancelled in T2S
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_FLOW_004 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | RECE         |
+      | PaymentType      | FREE         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FLOW_004"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_004 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_004"
+    Then CREATION receives a request for "TXN_FLOW_004" within configured polling limits
+    When CREATION rejects the instruction "TXN_FLOW_004" with values:
+      | Field          | Value                 |
+      | ReasonCode     | SAFE                  |
+      | AdditionalInfo | UNKNOWN SAFEKEEPING   |
+    Then ELSA instruction "TXN_FLOW_004" should have status "cancelled" within configured polling limits
+    And ELSA instruction "TXN_FLOW_004" should report "cancellationRequested" as "true"
+
+  Scenario: CREATION simulator replies automatically
+    Given the CREATION simulator automatically accepts requests and settles matches
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_FLOW_005 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FLOW_005"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_005 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_005"
+    Then ELSA instruction "TXN_FLOW_005" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_FLOW_005 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FLOW_005"
+    Then ELSA instruction "TXN_FLOW_005" should have status "released" within configured polling limits
@@ -0,0 +1,18 @@
+Feature: Configuration profiles
+  The configuration file is overlaid by the profile file of testdata/config/profiles, the environment
+  variables ELSA_TEST_* and the flag config.set. A feature selects its profile in the Background.
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the configuration profile is "ci"
+
+  Scenario: The profile overrides the values of the configuration file
+    Then the configuration value "pollingIntervalSeconds" should be "2"
+    And the configuration value "pollingTimeoutSeconds" should be "120"
+    And the configuration value "queueTransport.type" should be "directory"
+    And the configuration value "schemaDir" should be "../elsa-xml/schemas/ISO"
+
+  Scenario: The profile is kept when another configuration file is loaded
+    Given the system is configured from "elsa_services_memory.json"
+    Then the configuration value "pollingTimeoutSeconds" should be "120"
+    And the configuration value "queueTransport.type" should be "memory"
@@ -0,0 +1,48 @@
+Feature: Assertions on the ELSA database
+  The database steps run the named queries of testdata/db/elsa_queries.sql read-only against the ELSA
+  database of the configuration (database.driver and database.dsn). The scenarios below use a local
+  SQLite stand-in set up by the scenario.
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the local ELSA database is set up from "elsa_local.sql"
+
+  Scenario: Retry counter of an instruction
+    Given the local ELSA database table "instruction" has the rows:
+      | client_txid | status           | retry_count | last_retry_at        |
+      | TXN_DB_001  | sent_to_creation | 3           | 2026-05-04T10:00:00Z |
+      | TXN_DB_002  | created          | 0           | NULL                 |
+    When the ELSA database is queried with "instruction_retries" for instruction "TXN_DB_001"
+    Then the database result should have 1 row
+    And the database value "retry_count" should be "3"
+    And the database value "LAST_RETRY_AT" should be "2026-05-04T10:00:00Z"
+    When the ELSA database is queried with "instruction_retries" for instruction "TXN_DB_002"
+    Then the database value "last_retry_at" should be "NULL"
+    When the ELSA database is queried with "instruction_retries" for instruction "TXN_DB_404"
+    Then the database result should have 0 rows
+
+  Scenario: Messages of a collection
+    Given the local ELSA database table "collection_message" has the rows:
+      | collection_id | sequence | client_txid | message_type | status   |
+      | COL_{{today}} | 1        | TXN_DB_010  | sese.023     | received |
+      | COL_{{today}} | 2        | TXN_DB_011  | sese.023     | received |
+      | COL_{{today}} | 3        | TXN_DB_010  | sese.024     | sent     |
+    When the ELSA database is queried with "collection_messages" for collection "COL_{{today}}"
+    Then the database result should have 3 rows
+    And the database result should contain the rows:
+      | message_type | client_txid |
+      | sese.024     | TXN_DB_010  |
+      | sese.023     | TXN_DB_011  |
+
+  Scenario: Query with parameters
+    Given the local ELSA database table "instruction" has the rows:
+      | client_txid | status  | retry_count |
+      | TXN_DB_020  | created | 1           |
+      | TXN_DB_021  | created | 5           |
+      | TXN_DB_022  | settled | 7           |
+    When the ELSA database is queried with "instructions_retried_in_status" and the parameters:
+      | Name       | Value   |
+      | status     | created |
+      | minRetries | 2       |
+    Then the database result should have 1 row
+    And the database value "client_txid" should be "TXN_DB_021"
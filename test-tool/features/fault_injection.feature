@@ -0,0 +1,98 @@
+Feature: Behaviour under faults of the ELSA API and the queues
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+
+  Scenario: Status polling rides out failing, malformed and slow API responses
+    Given the mock ELSA API injects the faults:
+      | TXID          | Path            | Latency | Status | Malformed | Times |
+      | TXN_FAULT_001 | /instructions/* |         | 500    |           | 2     |
+      | TXN_FAULT_001 | /instructions/* |         |        | true      | 1     |
+      | TXN_FAULT_001 | /instructions/* | 300ms   |        |           | 1     |
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_FAULT_001 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | DELI          |
+      | PaymentType      | APMT          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FAULT_001"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_FAULT_001 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FAULT_001"
+    Then ELSA instruction "TXN_FAULT_001" should have status "sent_to_creation" within configured polling limits
+    And all injected faults should have been applied
+
+  Scenario: Duplicated client copy does not create the instruction twice
+    Given the queues inject the faults:
+      | Queue                     | Kind      | TXID          | Times |
+      | t2sClientRequestQueueName | duplicate | TXN_FAULT_002 | 1     |
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_FAULT_002 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | RECE          |
+      | PaymentType      | FREE          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FAULT_002"
+    Then ELSA instruction "TXN_FAULT_002" should have status "created" within configured polling limits
+    And ELSA instruction "TXN_FAULT_002" should keep status "created" for 2 seconds
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_FAULT_002 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FAULT_002"
+    Then ELSA instruction "TXN_FAULT_002" should have the status history:
+      | Status           |
+      | created          |
+      | accepted_by_t2s  |
+      | sent_to_creation |
+    And all injected faults should have been applied
+
+  Scenario: Acceptance overtaking the client copy is not processed
+    # the T2S queues are separate, the acceptance is read before the client copy is sent
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_FAULT_003 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FAULT_003"
+    And ELSA should send no message to CREATION for "TXN_FAULT_003" within 1 second
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_FAULT_003 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | DELI          |
+      | PaymentType      | FREE          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FAULT_003"
+    Then ELSA instruction "TXN_FAULT_003" should have status "created" within configured polling limits
+    And ELSA instruction "TXN_FAULT_003" should keep status "created" for 2 seconds
+    And ELSA instruction "TXN_FAULT_003" should never have had status "accepted_by_t2s"
+
+  Scenario: Delayed message to T2S does not block the ELSA API
+    # the mock puts its messages outside of its lock, the API answers while the put to T2S waits
+    Given the queues inject the faults:
+      | Queue                | Kind  | TXID          | Delay | Times |
+      | t2sOutboundQueueName | delay | TXN_FAULT_004 | 5s    | 1     |
+    And the value "baseUrl" is the ELSA API base URL
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_FAULT_004 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | RECE          |
+      | PaymentType      | APMT          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_FAULT_004"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_FAULT_004 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_FAULT_004"
+    And CREATION receives a request for "TXN_FAULT_004" within configured polling limits
+    And CREATION accepts the instruction "TXN_FAULT_004"
+    Then ELSA instruction "TXN_FAULT_004" should have status "matching_sent_to_t2s" within configured polling limits
+    And ELSA should send no message to T2S for "TXN_FAULT_004" within 2 seconds
+    When I send a GET request to "{{ref "baseUrl"}}/instructions/TXN_FAULT_004"
+    Then the response status should be 200
+    And the response time should be below 500 ms
+    And ELSA sends a "sese.024" message to T2S for "TXN_FAULT_004" within configured polling limits
+    And all injected faults should have been applied
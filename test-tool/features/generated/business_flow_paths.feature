@@ -0,0 +1,295 @@
+# Code generated by flowgen from the business flow model of the mock ELSA server. DO NOT EDIT.
+@generated
+Feature: All paths through the ELSA business flow
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+
+  Scenario Outline: Path 1 - timeout in created
+    # (new) --T2S clientCopy--> created
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    And ELSA instruction "<TXID>" should keep status "created" for 2 seconds
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status  |
+      | created |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_01_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 2 - timeout in sent_to_creation
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And ELSA instruction "<TXID>" should keep status "sent_to_creation" for 2 seconds
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status           |
+      | created          |
+      | accepted_by_t2s  |
+      | sent_to_creation |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_02_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 3 - timeout in matching_sent_to_t2s
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    # sent_to_creation --CREATION accepted--> accepted_by_creation, matching_sent_to_t2s
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And CREATION receives a request for "<TXID>" within configured polling limits
+    When CREATION accepts the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "matching_sent_to_t2s" within configured polling limits
+    And ELSA instruction "<TXID>" should keep status "matching_sent_to_t2s" for 2 seconds
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status               |
+      | created              |
+      | accepted_by_t2s      |
+      | sent_to_creation     |
+      | accepted_by_creation |
+      | matching_sent_to_t2s |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_03_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 4 - timeout in match_sent_to_creation
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    # sent_to_creation --CREATION accepted--> accepted_by_creation, matching_sent_to_t2s
+    # matching_sent_to_t2s --T2S matched--> matched, match_sent_to_creation
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And CREATION receives a request for "<TXID>" within configured polling limits
+    When CREATION accepts the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "match_sent_to_creation" within configured polling limits
+    And ELSA instruction "<TXID>" should keep status "match_sent_to_creation" for 2 seconds
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status                 |
+      | created                |
+      | accepted_by_t2s        |
+      | sent_to_creation       |
+      | accepted_by_creation   |
+      | matching_sent_to_t2s   |
+      | matched                |
+      | match_sent_to_creation |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_04_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 5 - timeout in released
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    # sent_to_creation --CREATION accepted--> accepted_by_creation, matching_sent_to_t2s
+    # matching_sent_to_t2s --T2S matched--> matched, match_sent_to_creation
+    # match_sent_to_creation --CREATION settled--> settled_by_creation, released
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And CREATION receives a request for "<TXID>" within configured polling limits
+    When CREATION accepts the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "match_sent_to_creation" within configured polling limits
+    And CREATION receives a match for "<TXID>" within configured polling limits
+    When CREATION settles the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "released" within configured polling limits
+    And ELSA instruction "<TXID>" should keep status "released" for 2 seconds
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status                 |
+      | created                |
+      | accepted_by_t2s        |
+      | sent_to_creation       |
+      | accepted_by_creation   |
+      | matching_sent_to_t2s   |
+      | matched                |
+      | match_sent_to_creation |
+      | settled_by_creation    |
+      | released               |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_05_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 6 - settled
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    # sent_to_creation --CREATION accepted--> accepted_by_creation, matching_sent_to_t2s
+    # matching_sent_to_t2s --T2S matched--> matched, match_sent_to_creation
+    # match_sent_to_creation --CREATION settled--> settled_by_creation, released
+    # released --T2S settled--> settled
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And CREATION receives a request for "<TXID>" within configured polling limits
+    When CREATION accepts the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "match_sent_to_creation" within configured polling limits
+    And CREATION receives a match for "<TXID>" within configured polling limits
+    When CREATION settles the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "released" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_settled_copy.xml" with values:
+      | Field         | Value          |
+      | TransactionId | <TXID>         |
+      | MovementType  | <MovementType> |
+      | PaymentType   | <PaymentType>  |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "settled" within configured polling limits
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status                 |
+      | created                |
+      | accepted_by_t2s        |
+      | sent_to_creation       |
+      | accepted_by_creation   |
+      | matching_sent_to_t2s   |
+      | matched                |
+      | match_sent_to_creation |
+      | settled_by_creation    |
+      | released               |
+      | settled                |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_06_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 7 - cancelled
+    # (new) --T2S clientCopy--> created
+    # created --T2S accepted--> accepted_by_t2s, sent_to_creation
+    # sent_to_creation --CREATION rejected--> rejected_by_creation, cancelled
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "sent_to_creation" within configured polling limits
+    And CREATION receives a request for "<TXID>" within configured polling limits
+    When CREATION rejects the instruction "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "cancelled" within configured polling limits
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status               |
+      | created              |
+      | accepted_by_t2s      |
+      | sent_to_creation     |
+      | rejected_by_creation |
+      | cancelled            |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_07_1 | DELI         | APMT        |
+
+  Scenario Outline: Path 8 - rejected_by_t2s
+    # (new) --T2S clientCopy--> created
+    # created --T2S rejected--> rejected_by_t2s
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value          |
+      | TransactionId    | <TXID>         |
+      | InstructingParty | DAKVDEFFLIO    |
+      | MovementType     | <MovementType> |
+      | PaymentType      | <PaymentType>  |
+      | ISIN             | AT0000A28768   |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "created" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_rejection.xml" with values:
+      | Field         | Value  |
+      | TransactionId | <TXID> |
+      | ReasonCode    | SAFE   |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"
+    Then ELSA instruction "<TXID>" should have status "rejected_by_t2s" within configured polling limits
+    And ELSA instruction "<TXID>" should have the status history:
+      | Status          |
+      | created         |
+      | rejected_by_t2s |
+
+    Examples:
+      | TXID         | MovementType | PaymentType |
+      | TXN_GEN_08_1 | DELI         | APMT        |
@@ -0,0 +1,78 @@
+Feature: Status history of ELSA instructions
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+
+  Scenario: Settled instruction went through all statuses in order
+    Given the CREATION simulator automatically accepts requests and settles matches
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_HIST_001 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_HIST_001"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_HIST_001 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_HIST_001"
+    Then ELSA instruction "TXN_HIST_001" should have the status history:
+      | Status               | Within |
+      | created              |        |
+      | accepted_by_t2s      | 5s     |
+      | sent_to_creation     | 1s     |
+      | accepted_by_creation | 10s    |
+      | matching_sent_to_t2s | 1s     |
+    And ELSA instruction "TXN_HIST_001" should never have had status "rejected_by_creation"
+    And the message of status "accepted_by_t2s" of ELSA instruction "TXN_HIST_001" should contain:
+      | XPath                  | Value             |
+      | TxId/AcctOwnrTxId      | TXN_HIST_001      |
+      | TxId/MktInfrstrctrTxId | miti-TXN_HIST_001 |
+    And the message of status "sent_to_creation" of ELSA instruction "TXN_HIST_001" should contain:
+      | XPath                   | Value        |
+      | SctiesSttlmTxInstr/TxId | TXN_HIST_001 |
+      | FinInstrmId/ISIN        | AT0000A28768 |
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_HIST_001 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_HIST_001"
+    Then ELSA instruction "TXN_HIST_001" should have the status history:
+      | Status   |
+      | created  |
+      | *        |
+      | matched  |
+      | ?        |
+      | *        |
+      | released |
+
+  Scenario: Rejected request never reaches matching
+    Given the CREATION simulator automatically rejects requests and settles matches
+    And T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_HIST_002 |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | RECE         |
+      | PaymentType      | FREE         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_HIST_002"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value        |
+      | TransactionId | TXN_HIST_002 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_HIST_002"
+    Then ELSA instruction "TXN_HIST_002" should have the status history:
+      | Status               |
+      | created              |
+      | *                    |
+      | rejected_by_creation |
+      | cancelled            |
+    And ELSA instruction "TXN_HIST_002" should never have had status "matching_sent_to_t2s"
+    # cancelled is a final status, the check ends without waiting for the polling timeout
+    And ELSA instruction "TXN_HIST_002" should NOT have status "matched" within configured polling limits
+    And the message of status "cancelled" of ELSA instruction "TXN_HIST_002" should contain:
+      | XPath             | Value             |
+      | MktInfrstrctrTxId | miti-TXN_HIST_002 |
+    And the audit trail of ELSA instruction "TXN_HIST_002" should contain the action "cancellation_requested" for status "cancelled"
+    And the collection of ELSA instruction "TXN_HIST_002" should contain the message of status "rejected_by_creation"
+    And all ELSA API responses of the scenario should conform to the OpenAPI spec
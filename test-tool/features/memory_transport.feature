@@ -0,0 +1,26 @@
+Feature: Business flow over the in-memory queue transport
+
+  Background:
+    Given the system is configured from "elsa_services_memory.json"
+    And the mock ELSA API server follows the business flow
+    And the CREATION simulator automatically accepts requests and settles matches
+
+  Scenario: Instruction settled without touching the file system
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value       |
+      | TransactionId    | TXN_MEM_001 |
+      | InstructingParty | DAKVDEFFLIO |
+      | MovementType     | DELI        |
+      | PaymentType      | APMT        |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_MEM_001"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value       |
+      | TransactionId | TXN_MEM_001 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_MEM_001"
+    Then ELSA instruction "TXN_MEM_001" should have status "matching_sent_to_t2s" within configured polling limits
+    Given T2S prepares an acceptance message using template "t2s_match.xml" with values:
+      | Field         | Value       |
+      | TransactionId | TXN_MEM_001 |
+    When T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_MEM_001"
+    Then ELSA instruction "TXN_MEM_001" should have status "released" within configured polling limits
@@ -0,0 +1,79 @@
+Feature: Messages ELSA sends to T2S and CREATION
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+
+  Scenario: Request to CREATION carries the instruction details
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_OUT_001  |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | FREE         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_OUT_001"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value       |
+      | TransactionId | TXN_OUT_001 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_OUT_001"
+    Then ELSA sends a "sese.023" message to CREATION for "TXN_OUT_001" within configured polling limits
+    And the outbound message should be valid against its schema
+    And the outbound message should contain:
+      | XPath                                | Value        |
+      | SctiesSttlmTxInstr/TxId              | TXN_OUT_001  |
+      | SttlmTpAndAddtlParams/SctiesMvmntTp  | DELI         |
+      | SttlmTpAndAddtlParams/Pmt            | FREE         |
+      | FinInstrmId/ISIN                     | AT0000A28768 |
+    And ELSA should send no message to T2S for "TXN_OUT_001" within 2 seconds
+
+  Scenario: Acceptance by CREATION is reported to T2S and matched
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_OUT_002  |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | RECE         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_OUT_002"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value       |
+      | TransactionId | TXN_OUT_002 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_OUT_002"
+    And CREATION receives a request for "TXN_OUT_002" within configured polling limits
+    And CREATION accepts the instruction "TXN_OUT_002"
+    Then ELSA sends a "sese.024" message to T2S for "TXN_OUT_002" within configured polling limits
+    And the outbound message should be valid against its schema
+    And the outbound message should contain:
+      | XPath                         | Value            |
+      | TxId/AcctOwnrTxId             | TXN_OUT_002      |
+      | TxId/MktInfrstrctrTxId        | miti-TXN_OUT_002 |
+      | PrcgSts/AckdAccptd/NoSpcfdRsn | NORE             |
+    And ELSA sends a "sese.023" message to T2S for "TXN_OUT_002" within configured polling limits
+    And the outbound message should be valid against its schema
+    And the outbound message should contain:
+      | XPath                   | Value            |
+      | SctiesSttlmTxInstr/TxId | miti-TXN_OUT_002 |
+    And ELSA should send no further message to T2S for "TXN_OUT_002" within 2 seconds
+
+  Scenario: Rejection by CREATION cancels the client copy in T2S
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value        |
+      | TransactionId    | TXN_OUT_003  |
+      | InstructingParty | DAKVDEFFLIO  |
+      | MovementType     | DELI         |
+      | PaymentType      | APMT         |
+      | ISIN             | AT0000A28768 |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_OUT_003"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value       |
+      | TransactionId | TXN_OUT_003 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_OUT_003"
+    And CREATION receives a request for "TXN_OUT_003" within configured polling limits
+    And CREATION rejects the instruction "TXN_OUT_003"
+    Then ELSA sends a "sese.020" message to T2S for "TXN_OUT_003" within configured polling limits
+    And the outbound message should be valid against its schema
+    And the outbound message should contain:
+      | XPath                                   | Value            |
+      | AcctOwnrTxId/SctiesSttlmTxId/TxId       | TXN_OUT_003      |
+      | MktInfrstrctrTxId                       | miti-TXN_OUT_003 |
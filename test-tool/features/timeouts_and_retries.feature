@@ -0,0 +1,52 @@
+Feature: Timeouts and retries of unanswered messages with a controlled clock
+
+  Background:
+    Given the system is configured from "elsa_services.json"
+    And the mock ELSA API server follows the business flow
+    And the time is controlled by the scenario
+    And the mock ELSA API retries unanswered messages after 30 minutes, at most 2 times
+
+  Scenario: Unanswered creation request is retried until the instruction times out
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_RETRY_001 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | DELI          |
+      | PaymentType      | APMT          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_RETRY_001"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_RETRY_001 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_RETRY_001"
+    Then CREATION receives a request for "TXN_RETRY_001" within configured polling limits
+    When 29 minutes pass
+    Then ELSA should send no further message to CREATION for "TXN_RETRY_001" within 1 second
+    When 1 minute passes
+    Then CREATION receives a request for "TXN_RETRY_001" within configured polling limits
+    When 30 minutes pass
+    Then CREATION receives a request for "TXN_RETRY_001" within configured polling limits
+    And the mock ELSA API should have retried instruction "TXN_RETRY_001" 2 times
+    When 30 minutes pass
+    Then ELSA instruction "TXN_RETRY_001" should have status "timed_out" within configured polling limits
+
+  Scenario: Answer within the timeout stops the retries
+    Given T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:
+      | Field            | Value         |
+      | TransactionId    | TXN_RETRY_002 |
+      | InstructingParty | DAKVDEFFLIO   |
+      | MovementType     | RECE          |
+      | PaymentType      | FREE          |
+      | ISIN             | AT0000A28768  |
+    When T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "TXN_RETRY_002"
+    And T2S prepares an acceptance message using template "t2s_acceptance.xml" with values:
+      | Field         | Value         |
+      | TransactionId | TXN_RETRY_002 |
+    And T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "TXN_RETRY_002"
+    Then CREATION receives a request for "TXN_RETRY_002" within configured polling limits
+    When 20 minutes pass
+    And CREATION accepts the instruction "TXN_RETRY_002"
+    Then ELSA instruction "TXN_RETRY_002" should have status "matching_sent_to_t2s" within configured polling limits
+    When 20 minutes pass
+    Then ELSA should send no further message to CREATION for "TXN_RETRY_002" within 1 second
+    And the mock ELSA API should have retried instruction "TXN_RETRY_002" 0 times
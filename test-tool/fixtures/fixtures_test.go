@@ -0,0 +1,148 @@
+package fixtures
+
+import (
+	"elsa-xml/pkg/validator"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const templateDir = "../testdata/messages/templates"
+
+func TestISINCheckDigit(t *testing.T) {
+	for _, isin := range []string{"AT0000A28768", "US0378331005", "DE0007164600", "GB0002771383"} {
+		if got := isinCheckDigit(isin[:11]); got != int(isin[11]-'0') {
+			t.Errorf("%s: check digit %d", isin, got)
+		}
+	}
+	if isin := ISIN("at"); len(isin) != 12 || !strings.HasPrefix(isin, "AT") || isinCheckDigit(isin[:11]) != int(isin[11]-'0') {
+		t.Errorf("invalid generated ISIN %s", isin)
+	}
+}
+
+func TestBIC(t *testing.T) {
+	pattern := regexp.MustCompile(`^[A-Z]{4}DE[A-Z2-9][A-NP-Z0-9]XXX$`) // AnyBICIdentifier of the T2S schemas
+	for i := 0; i < 1000; i++ {
+		if bic := BIC("DE"); !pattern.MatchString(bic) {
+			t.Fatalf("invalid generated BIC %s", bic)
+		}
+	}
+}
+
+func TestBusinessDate(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		from string
+		days int
+		want string
+	}{
+		{"2025-04-15", 0, "2025-04-15"},  // Tuesday
+		{"2025-04-19", 0, "2025-04-22"},  // Saturday before Easter, Easter Monday is closed
+		{"2025-04-17", 1, "2025-04-22"},  // over Good Friday and Easter Monday
+		{"2025-04-22", -1, "2025-04-17"}, // back over Easter
+		{"2025-12-23", 2, "2025-12-29"},  // over Christmas and the weekend
+		{"2025-12-31", 1, "2026-01-02"},  // New Year
+		{"2025-04-30", 1, "2025-05-02"},  // Labour Day
+	}
+	for _, tt := range tests {
+		if got := BusinessDate(date(tt.from), tt.days).Format(time.DateOnly); got != tt.want {
+			t.Errorf("BusinessDate(%s, %d) = %s, want %s", tt.from, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := UniqueID("S12")
+		if seen[id] || len(id) > 16 || !regexp.MustCompile(`^S12[0-9A-Z]+$`).MatchString(id) {
+			t.Fatalf("invalid or duplicate ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestExpand(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "ISIN" {
+			return "AT0000A28768", true
+		}
+		return "", false
+	}
+	got, err := Expand(`{{ref "ISIN"}}/{{businessDate 0}}`, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AT0000A28768/" + BusinessDate(time.Now(), 0).Format(time.DateOnly); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, _ := Expand("TXN_001", nil); got != "TXN_001" {
+		t.Errorf("plain value changed to %s", got)
+	}
+	if _, err := Expand(`{{ref "Unknown"}}`, lookup); err == nil {
+		t.Error("expected an error for an unknown value")
+	}
+}
+
+// TestTemplatesAreValid renders all templates of the test-tool and validates them against the schemas of elsa-xml
+func TestTemplatesAreValid(t *testing.T) {
+	v, err := validator.NewGoValidator("../../elsa-xml/schemas/ISO", "../../elsa-xml/schemas/T2S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := NewLibrary(templateDir, v)
+	files, err := filepath.Glob(filepath.Join(templateDir, "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"TXID":             UniqueID("T"),
+		"InstructingParty": "DAKVDEFFLIO",
+		"MovementType":     "DELI",
+		"PaymentType":      "APMT",
+		"ReasonCode":       "SAFE",
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			if _, err := lib.Render(name, data, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRenderRejectsInvalidMessages(t *testing.T) {
+	v, err := validator.NewGoValidator("../../elsa-xml/schemas/ISO", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := NewLibrary(templateDir, v)
+	if _, err := lib.Render("creation_rejection.xml", map[string]interface{}{"TXID": "T1", "ReasonCode": "NOT A CODE"}, nil); err == nil {
+		t.Error("expected a validation error for an unknown reason code")
+	}
+}
+
+func TestSchemaName(t *testing.T) {
+	tests := map[string]string{
+		`<?xml version="1.0"?><Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10"/>`: "sese.024.001.10",
+		`<CST2SMsg xmlns="cst2s.schema.clearstream"><T2SPayload/></CST2SMsg>`:                     "CST2SMsg",
+	}
+	for msg, want := range tests {
+		if got, err := SchemaName([]byte(msg)); err != nil || got != want {
+			t.Errorf("SchemaName(%s) = %s, %v, want %s", msg, got, err, want)
+		}
+	}
+	if _, err := SchemaName([]byte(`<ClientRequest/>`)); err == nil {
+		t.Error("expected an error for a message which is no ISO20022 message")
+	}
+}
@@ -0,0 +1,130 @@
+package fixtures
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	letters      = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// characters of the BIC location code: the first one is no 0 or 1 (test BICs), the second one no O
+	locationFirst  = "23456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	locationSecond = "0123456789ABCDEFGHIJKLMNPQRSTUVWXYZ"
+)
+
+// idSequence makes the generated IDs unique, it starts at the time the test run started
+// so IDs of different runs do not collide either
+var idSequence atomic.Int64
+
+func init() {
+	idSequence.Store(time.Now().UnixMilli())
+}
+
+// UniqueID returns an ID which is unique within the test run and in all later runs. It consists of
+// the prefix and 8 characters, only upper case letters and digits are used so it is a valid
+// T2S reference (max. 16 characters) for prefixes of up to 8 characters.
+func UniqueID(prefix string) string {
+	return prefix + strings.ToUpper(strconv.FormatInt(idSequence.Add(1), 36))
+}
+
+// BusinessDate returns the date 'days' TARGET2 business days after 'from', before it if days is negative.
+// For 0 days 'from' is returned if it is a business day, the next business day otherwise.
+func BusinessDate(from time.Time, days int) time.Time {
+	d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	step := 1
+	if days < 0 {
+		step, days = -1, -days
+	}
+	for !isBusinessDay(d) {
+		d = d.AddDate(0, 0, step)
+	}
+	for ; days > 0; days-- {
+		d = d.AddDate(0, 0, step)
+		for !isBusinessDay(d) {
+			d = d.AddDate(0, 0, step)
+		}
+	}
+	return d
+}
+
+// isBusinessDay reports whether T2S settles on the day: not on weekends and TARGET2 holidays
+func isBusinessDay(d time.Time) bool {
+	if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		return false
+	}
+	switch {
+	case d.Month() == time.January && d.Day() == 1,
+		d.Month() == time.May && d.Day() == 1,
+		d.Month() == time.December && (d.Day() == 25 || d.Day() == 26):
+		return false
+	}
+	easter := easterSunday(d.Year())
+	return !d.Equal(easter.AddDate(0, 0, -2)) && !d.Equal(easter.AddDate(0, 0, 1)) // Good Friday, Easter Monday
+}
+
+// easterSunday computes the date of Easter of the gregorian calendar (anonymous gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// BIC returns a random BIC11 of the country, e.g. "QXTRDEK3XXX"
+func BIC(country string) string {
+	return randomString(letters, 4) + strings.ToUpper(country) + randomString(locationFirst, 1) + randomString(locationSecond, 1) + "XXX"
+}
+
+// ISIN returns a random ISIN of the country with a valid check digit
+func ISIN(country string) string {
+	isin := strings.ToUpper(country) + randomString(alphanumeric, 9)
+	return isin + strconv.Itoa(isinCheckDigit(isin))
+}
+
+// isinCheckDigit computes the check digit of the first 11 characters of an ISIN: letters are
+// replaced by their number (A=10), the digits are summed with the Luhn algorithm.
+func isinCheckDigit(isin string) int {
+	var digits strings.Builder
+	for _, r := range isin {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+
+	s := digits.String()
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		n := int(s[i] - '0')
+		if (len(s)-1-i)%2 == 0 { // every second digit from the right, starting with the rightmost
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return (10 - sum%10) % 10
+}
+
+func randomString(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rand.IntN(len(alphabet))]
+	}
+	return string(b)
+}
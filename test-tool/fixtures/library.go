@@ -0,0 +1,172 @@
+// Package fixtures renders the message templates the test steps send to ELSA. Templates are
+// text/template files which can generate values (IDs, business dates, BICs, ISINs) and refer to
+// values of earlier steps, every rendered message is validated against its schema.
+package fixtures
+
+import (
+	"bytes"
+	"elsa-xml/pkg/validator"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// t2sSchemaName is the schema of the messages in the T2S wrapper format, see elsa-xml validator
+const t2sSchemaName = "CST2SMsg"
+
+// Lookup returns a value of the scenario by name, it backs the ref function of the templates
+type Lookup func(name string) (string, bool)
+
+// Library renders the templates of a directory. The parsed templates are cached, a library
+// can be used by concurrent scenarios.
+type Library struct {
+	dir       string
+	validator validator.Validator // nil if the messages are not validated
+
+	mu        sync.Mutex
+	templates map[string]*template.Template
+}
+
+// NewLibrary creates a library for the templates of dir. Rendered messages are validated by v,
+// which may be nil to skip the validation.
+func NewLibrary(dir string, v validator.Validator) *Library {
+	return &Library{
+		dir:       dir,
+		validator: v,
+		templates: make(map[string]*template.Template),
+	}
+}
+
+// funcs returns the helper functions of the templates:
+//
+//	uniqueID "PREFIX"   unique ID, e.g. for TXIDs
+//	today               current date, YYYY-MM-DD
+//	now                 current time, ISO date time in UTC
+//	businessDate 2      date 2 TARGET2 business days from today, negative values go back
+//	bic "DE"            random BIC11 of the country
+//	isin "DE"           random ISIN of the country
+//	ref "Name"          value of the scenario, e.g. of a field of an earlier message
+func funcs(lookup Lookup) template.FuncMap {
+	return template.FuncMap{
+		"uniqueID":     UniqueID,
+		"today":        func() string { return time.Now().Format(time.DateOnly) },
+		"now":          func() string { return time.Now().UTC().Format("2006-01-02T15:04:05Z") },
+		"businessDate": func(days int) string { return BusinessDate(time.Now(), days).Format(time.DateOnly) },
+		"bic":          BIC,
+		"isin":         ISIN,
+		"ref": func(name string) (string, error) {
+			if lookup != nil {
+				if v, ok := lookup(name); ok {
+					return v, nil
+				}
+			}
+			return "", fmt.Errorf("no value %q in this scenario", name)
+		},
+	}
+}
+
+// load returns the parsed template, it is read once
+func (l *Library) load(name string) (*template.Template, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if tmpl, ok := l.templates[name]; ok {
+		return tmpl, nil
+	}
+
+	path := filepath.Join(l.dir, name)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file %s: %w", path, err)
+	}
+	tmpl, err := template.New(name).Funcs(funcs(nil)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	l.templates[name] = tmpl
+	return tmpl, nil
+}
+
+// Render renders the template with the data and validates the message against its schema
+func (l *Library) Render(name string, data map[string]interface{}, lookup Lookup) ([]byte, error) {
+	tmpl, err := l.load(name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err = tmpl.Clone() // the functions are bound to the lookup of the caller
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(funcs(lookup)).Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template %s with data %+v: %w", name, data, err)
+	}
+	if err := l.Validate(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("message rendered from template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Expand evaluates the helper functions in a value, e.g. "{{businessDate 2}}" of a data table.
+// Values without actions are returned unchanged.
+func Expand(value string, lookup Lookup) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tmpl, err := template.New("value").Funcs(funcs(lookup)).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse value %q: %w", value, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("failed to evaluate value %q: %w", value, err)
+	}
+	return buf.String(), nil
+}
+
+// Validate validates the message against the schema of its type, see SchemaName
+func (l *Library) Validate(msg []byte) error {
+	if l.validator == nil {
+		return nil
+	}
+	schema, err := SchemaName(msg)
+	if err != nil {
+		return err
+	}
+	if err := l.validator.Validate(msg, schema); err != nil {
+		return fmt.Errorf("not valid against %s: %w", schema, err)
+	}
+	return nil
+}
+
+// SchemaName returns the name of the schema the message is validated with: CST2SMsg for the T2S
+// wrapper format, the message type (e.g. sese.024.001.10) for an ISO20022 Document.
+func SchemaName(msg []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(msg))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("empty message")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse message: %w", err)
+		}
+		root, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case root.Name.Local == t2sSchemaName:
+			return t2sSchemaName, nil
+		case root.Name.Local == "Document" && strings.HasPrefix(root.Name.Space, "urn:iso:std:iso:20022:tech:xsd:"):
+			return strings.TrimPrefix(root.Name.Space, "urn:iso:std:iso:20022:tech:xsd:"), nil
+		}
+		return "", fmt.Errorf("root element %s is neither an ISO20022 Document nor %s", root.Name.Local, t2sSchemaName)
+	}
+}
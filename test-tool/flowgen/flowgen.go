@@ -0,0 +1,176 @@
+// Package flowgen generates Gherkin scenarios for all paths through the ELSA business flow.
+//
+// The paths are taken from the flow model of the mock ELSA server (mock_elsa_server.Paths), every
+// transition is translated into the steps simulating the message of T2S or CREATION followed by a
+// status check. Each path becomes a scenario outline whose examples are the movement and payment
+// type permutations to run it with.
+package flowgen
+
+//go:generate go run ../cmd/flowgen -o ../features/generated/business_flow_paths.feature
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"test-tool/mock_elsa_server"
+)
+
+// Permutation is a combination of instruction details a path is run with
+type Permutation struct {
+	MovementType string
+	PaymentType  string
+}
+
+// DefaultPermutations runs every path once
+var DefaultPermutations = []Permutation{{"DELI", "APMT"}}
+
+// AllPermutations runs every path with all movement and payment types
+var AllPermutations = []Permutation{{"DELI", "APMT"}, {"DELI", "FREE"}, {"RECE", "APMT"}, {"RECE", "FREE"}}
+
+// Options control the generated scenarios
+type Options struct {
+	Config         string        // configuration file of the Background, e.g. elsa_services.json
+	Permutations   []Permutation // examples of each scenario outline
+	TimeoutSeconds int           // how long a timeout path checks the status is kept
+}
+
+// DefaultOptions are used for the checked in feature file
+var DefaultOptions = Options{
+	Config:         "elsa_services.json",
+	Permutations:   DefaultPermutations,
+	TimeoutSeconds: 2,
+}
+
+// step is a line of a scenario, keyword is Given, When or Then
+type step struct {
+	keyword string
+	text    string
+	table   [][]string
+}
+
+func t2sMessage(template string, values ...[]string) []step {
+	table := append([][]string{{"Field", "Value"}, {"TransactionId", "<TXID>"}}, values...)
+	return []step{
+		{"Given", fmt.Sprintf(`T2S prepares an acceptance message using template "%s" with values:`, template), table},
+		{"When", `T2S sends the prepared message to the "t2sAcceptanceQueueName" queue with correlation ID "<TXID>"`, nil},
+	}
+}
+
+func creationReply(received, reply string) []step {
+	return []step{
+		{"Then", fmt.Sprintf(`CREATION receives a %s for "<TXID>" within configured polling limits`, received), nil},
+		{"When", fmt.Sprintf(`CREATION %s the instruction "<TXID>"`, reply), nil},
+	}
+}
+
+// transitionSteps simulate the message of a transition, keyed by party and message kind
+var transitionSteps = map[string][]step{
+	"T2S clientCopy": {
+		{"Given", `T2S prepares an initial client request message using template "t2s_client_copy.xml" with values:`, [][]string{
+			{"Field", "Value"},
+			{"TransactionId", "<TXID>"},
+			{"InstructingParty", "DAKVDEFFLIO"},
+			{"MovementType", "<MovementType>"},
+			{"PaymentType", "<PaymentType>"},
+			{"ISIN", "AT0000A28768"},
+		}},
+		{"When", `T2S sends the prepared message to the "t2sClientRequestQueueName" queue with correlation ID "<TXID>"`, nil},
+	},
+	"T2S accepted":      t2sMessage("t2s_acceptance.xml"),
+	"T2S rejected":      t2sMessage("t2s_rejection.xml", []string{"ReasonCode", "SAFE"}),
+	"T2S matched":       t2sMessage("t2s_match.xml"),
+	"T2S settled":       t2sMessage("t2s_settled_copy.xml", []string{"MovementType", "<MovementType>"}, []string{"PaymentType", "<PaymentType>"}),
+	"CREATION accepted": creationReply("request", "accepts"),
+	"CREATION rejected": creationReply("request", "rejects"),
+	"CREATION settled":  creationReply("match", "settles"),
+}
+
+// Generate writes a feature file with one scenario outline per path
+func Generate(w io.Writer, paths []mock_elsa_server.FlowPath, opts Options) error {
+	var b strings.Builder
+	b.WriteString("# Code generated by flowgen from the business flow model of the mock ELSA server. DO NOT EDIT.\n")
+	b.WriteString("@generated\n")
+	b.WriteString("Feature: All paths through the ELSA business flow\n\n")
+	b.WriteString("  Background:\n")
+	fmt.Fprintf(&b, "    Given the system is configured from %q\n", opts.Config)
+	b.WriteString("    And the mock ELSA API server follows the business flow\n")
+
+	for i, p := range paths {
+		steps, err := pathSteps(p, opts)
+		if err != nil {
+			return fmt.Errorf("path %d (%s): %w", i+1, p, err)
+		}
+		fmt.Fprintf(&b, "\n  Scenario Outline: Path %d - %s\n", i+1, p)
+		for _, t := range p.Transitions {
+			fmt.Fprintf(&b, "    # %s\n", t)
+		}
+		writeSteps(&b, steps)
+
+		b.WriteString("\n    Examples:\n")
+		examples := [][]string{{"TXID", "MovementType", "PaymentType"}}
+		for j, perm := range opts.Permutations {
+			examples = append(examples, []string{fmt.Sprintf("TXN_GEN_%02d_%d", i+1, j+1), perm.MovementType, perm.PaymentType})
+		}
+		writeTable(&b, "      ", examples)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pathSteps returns the steps of the path, every transition is followed by a check of the reached status
+// and the path ends with a check of the full status history
+func pathSteps(p mock_elsa_server.FlowPath, opts Options) ([]step, error) {
+	var steps []step
+	for _, t := range p.Transitions {
+		ts, ok := transitionSteps[fmt.Sprintf("%s %s", t.Party, t.Kind)]
+		if !ok {
+			return nil, fmt.Errorf("no steps for %s %s messages", t.Party, t.Kind)
+		}
+		steps = append(steps, ts...)
+		steps = append(steps, step{"Then", fmt.Sprintf(`ELSA instruction "<TXID>" should have status "%s" within configured polling limits`, t.To()), nil})
+	}
+	if p.Timeout {
+		steps = append(steps, step{"Then", fmt.Sprintf(`ELSA instruction "<TXID>" should keep status "%s" for %d seconds`, p.End(), opts.TimeoutSeconds), nil})
+	}
+
+	history := [][]string{{"Status"}}
+	for _, s := range p.Statuses() {
+		history = append(history, []string{s})
+	}
+	steps = append(steps, step{"Then", `ELSA instruction "<TXID>" should have the status history:`, history})
+	return steps, nil
+}
+
+// writeSteps writes the steps, a keyword repeating the one of the previous step is written as And
+func writeSteps(b *strings.Builder, steps []step) {
+	previous := ""
+	for _, s := range steps {
+		keyword := s.keyword
+		if keyword == previous {
+			keyword = "And"
+		}
+		previous = s.keyword
+		fmt.Fprintf(b, "    %s %s\n", keyword, s.text)
+		if s.table != nil {
+			writeTable(b, "      ", s.table)
+		}
+	}
+}
+
+// writeTable writes the rows as Gherkin table with aligned columns
+func writeTable(b *strings.Builder, indent string, rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range rows {
+		b.WriteString(indent + "|")
+		for i, cell := range row {
+			fmt.Fprintf(b, " %-*s |", widths[i], cell)
+		}
+		b.WriteString("\n")
+	}
+}
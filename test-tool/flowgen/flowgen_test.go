@@ -0,0 +1,47 @@
+package flowgen
+
+import (
+	"os"
+	"strings"
+	"test-tool/mock_elsa_server"
+	"testing"
+)
+
+const generatedFeature = "../features/generated/business_flow_paths.feature"
+
+func TestGeneratedFeatureIsUpToDate(t *testing.T) {
+	var b strings.Builder
+	if err := Generate(&b, mock_elsa_server.Paths(), DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(generatedFeature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != b.String() {
+		t.Errorf("%s is outdated, run go generate ./flowgen", generatedFeature)
+	}
+}
+
+func TestGenerateCoversAllTransitions(t *testing.T) {
+	paths := mock_elsa_server.Paths()
+	var b strings.Builder
+	opts := DefaultOptions
+	opts.Permutations = AllPermutations
+	if err := Generate(&b, paths, opts); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	if n := strings.Count(out, "Scenario Outline:"); n != len(paths) {
+		t.Errorf("%d scenario outlines for %d paths", n, len(paths))
+	}
+	if !strings.Contains(out, "| TXN_GEN_01_4 | RECE         | FREE        |") {
+		t.Error("examples of all permutations missing")
+	}
+	for _, tr := range mock_elsa_server.Transitions() {
+		if !strings.Contains(out, "# "+tr.String()+"\n") {
+			t.Errorf("transition %s is not exercised by any scenario", tr)
+		}
+	}
+}
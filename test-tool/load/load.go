@@ -0,0 +1,109 @@
+// Package load runs the scenarios of the test suite as load or soak test: the scenarios are started
+// at a configured rate, optionally ramped up, and the end-to-end latencies they measure (from the put
+// of the first message of a TXID to a status being reached) are summarised as percentiles and checked
+// against SLA thresholds.
+package load
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Profile describes when the scenarios are started
+type Profile struct {
+	Rate       float64       // scenario starts per second after the ramp, 0 starts all at once
+	Ramp       time.Duration // the rate increases linearly from 0 to Rate during the ramp
+	Duration   time.Duration // scenarios are started until the end of the duration (soak test)
+	Iterations int           // number of scenario starts, it takes precedence over Duration
+}
+
+// Enabled reports whether a load test is configured
+func (p Profile) Enabled() bool {
+	return p.Iterations > 0 || p.Duration > 0
+}
+
+// Validate checks the profile
+func (p Profile) Validate() error {
+	switch {
+	case p.Rate < 0:
+		return fmt.Errorf("load rate must not be negative")
+	case p.Ramp < 0 || p.Duration < 0 || p.Iterations < 0:
+		return fmt.Errorf("load ramp, duration and iterations must not be negative")
+	case p.Iterations == 0 && p.Duration > 0 && p.Rate == 0:
+		return fmt.Errorf("a load duration requires a rate")
+	}
+	return nil
+}
+
+// Starts returns the number of scenario starts of the profile
+func (p Profile) Starts() int {
+	if p.Iterations > 0 || p.Duration == 0 {
+		return p.Iterations
+	}
+	return int(math.Ceil(p.startsUntil(p.Duration)))
+}
+
+// startsUntil returns the number of starts scheduled before t
+func (p Profile) startsUntil(t time.Duration) float64 {
+	secs, ramp := t.Seconds(), p.Ramp.Seconds()
+	if ramp == 0 {
+		return p.Rate * secs
+	}
+	if secs <= ramp {
+		return p.Rate * secs * secs / (2 * ramp)
+	}
+	return p.Rate*ramp/2 + p.Rate*(secs-ramp)
+}
+
+// Offset returns the time of the i-th start (counted from 0) after the start of the test
+func (p Profile) Offset(i int) time.Duration {
+	if p.Rate == 0 || i <= 0 {
+		return 0
+	}
+	n, ramp := float64(i), p.Ramp.Seconds()
+	var secs float64
+	if rampStarts := p.Rate * ramp / 2; n <= rampStarts {
+		secs = math.Sqrt(2 * ramp * n / p.Rate)
+	} else {
+		secs = ramp + (n-rampStarts)/p.Rate
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// Pacer hands out the start times of the profile to the scenarios
+type Pacer struct {
+	profile Profile
+	start   time.Time
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewPacer returns a pacer whose schedule begins at start
+func NewPacer(profile Profile, start time.Time) *Pacer {
+	return &Pacer{profile: profile, start: start}
+}
+
+// Wait blocks until the next start of the schedule and returns its number
+func (p *Pacer) Wait(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	i := p.next
+	p.next++
+	p.mu.Unlock()
+
+	delay := time.Until(p.start.Add(p.profile.Offset(i)))
+	if delay <= 0 {
+		return i, nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return i, nil
+	case <-ctx.Done():
+		return i, ctx.Err()
+	}
+}
@@ -0,0 +1,117 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		starts  int
+		offsets []time.Duration // of the first starts
+	}{
+		{Profile{Iterations: 3}, 3, []time.Duration{0, 0, 0}},
+		{Profile{Rate: 2, Iterations: 3}, 3, []time.Duration{0, 500 * time.Millisecond, time.Second}},
+		{Profile{Rate: 2, Duration: 10 * time.Second}, 20, []time.Duration{0, 500 * time.Millisecond}},
+		// ramp to 2/s in 2s: 2 starts during the ramp, at 0s and 1.414s, then every 0.5s
+		{Profile{Rate: 2, Ramp: 2 * time.Second, Duration: 4 * time.Second}, 6, []time.Duration{0, 1414 * time.Millisecond, 2 * time.Second, 2500 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		if err := tt.profile.Validate(); err != nil {
+			t.Errorf("%+v: %v", tt.profile, err)
+		}
+		if got := tt.profile.Starts(); got != tt.starts {
+			t.Errorf("%+v: %d starts, want %d", tt.profile, got, tt.starts)
+		}
+		for i, want := range tt.offsets {
+			if got := tt.profile.Offset(i).Truncate(time.Millisecond); got != want {
+				t.Errorf("%+v: start %d at %s, want %s", tt.profile, i, got, want)
+			}
+		}
+	}
+	for _, p := range []Profile{{Rate: -1}, {Duration: time.Minute}, {Iterations: -1}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("invalid profile %+v accepted", p)
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	start := time.Now()
+	p := NewPacer(Profile{Rate: 20, Iterations: 3}, start)
+	for want := 0; want < 3; want++ {
+		if i, err := p.Wait(context.Background()); err != nil || i != want {
+			t.Fatalf("got start %d, %v, want %d", i, err, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 starts at 20/s took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewPacer(Profile{Rate: 0.001, Iterations: 2}, time.Now()).Wait(ctx); err != nil {
+		t.Errorf("first start waited: %v", err)
+	}
+	slow := NewPacer(Profile{Rate: 0.001, Iterations: 2}, time.Now())
+	slow.Wait(ctx)
+	if _, err := slow.Wait(ctx); err == nil {
+		t.Error("cancelled wait returned no error")
+	}
+}
+
+func TestRecorderAndSLAs(t *testing.T) {
+	r := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		r.Record("sent_to_creation", time.Duration(i)*time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		r.Record(Scenario, time.Second)
+	}
+	r.Fail(Scenario)
+	var none *Recorder
+	none.Record(Scenario, time.Second) // no load test
+
+	stats := r.Summary()
+	if len(stats) != 2 || stats[0].Name != Scenario {
+		t.Fatalf("got %+v", stats)
+	}
+	s := stats[1]
+	if s.Count != 100 || s.Min != time.Millisecond || s.P50 != 50*time.Millisecond || s.P95 != 95*time.Millisecond || s.P99 != 99*time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Errorf("percentiles: got %+v", s)
+	}
+	if rate := stats[0].ErrorRate(); rate != 25 {
+		t.Errorf("error rate %f, want 25", rate)
+	}
+
+	slas, err := ParseSLAs("sent_to_creation:p95<=95ms, sent_to_creation:p99<=50ms,scenario:errors<=10%,matched:max<=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.Result(time.Now().Add(-time.Minute), time.Now(), 4, slas)
+	if res.Passed() || len(res.Violations) != 3 {
+		t.Fatalf("violations: got %v", res.Violations)
+	}
+	for i, want := range []string{"p99 is 99ms", "error rate 25.00%", "no measurements of matched"} {
+		if !strings.Contains(res.Violations[i], want) {
+			t.Errorf("violation %d: got %q, want %q", i, res.Violations[i], want)
+		}
+	}
+	var buf bytes.Buffer
+	if err := res.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "sent_to_creation") || !strings.Contains(buf.String(), "SLA VIOLATED") {
+		t.Errorf("text report:\n%s", buf.String())
+	}
+
+	for _, text := range []string{"scenario", "scenario:p42<=1s", "scenario:p95<=fast", "scenario:errors<=x%"} {
+		if _, err := ParseSLAs(text); err == nil {
+			t.Errorf("invalid SLA %q accepted", text)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Measurement names besides the statuses
+const (
+	Scenario = "scenario" // duration of a whole scenario, its errors are the failed scenarios
+)
+
+// Recorder collects the latencies of a load test by measurement, e.g. by the status reached.
+// A nil Recorder records nothing, so the steps need no checks outside of a load test.
+type Recorder struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	errors  map[string]int
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{samples: make(map[string][]time.Duration), errors: make(map[string]int)}
+}
+
+// Record adds a latency of the measurement
+func (r *Recorder) Record(name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples[name] = append(r.samples[name], d)
+}
+
+// Fail counts a failed measurement, e.g. a status not reached within the polling timeout
+func (r *Recorder) Fail(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors[name]++
+}
+
+// Stats summarises the latencies of one measurement
+type Stats struct {
+	Name   string
+	Count  int // successful measurements
+	Errors int // failed measurements
+	Min    time.Duration
+	Mean   time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// MarshalJSON writes the latencies in milliseconds
+func (s Stats) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(struct {
+		Name      string  `json:"name"`
+		Count     int     `json:"count"`
+		Errors    int     `json:"errors"`
+		ErrorRate float64 `json:"errorRatePercent"`
+		Min       float64 `json:"minMs"`
+		Mean      float64 `json:"meanMs"`
+		P50       float64 `json:"p50Ms"`
+		P90       float64 `json:"p90Ms"`
+		P95       float64 `json:"p95Ms"`
+		P99       float64 `json:"p99Ms"`
+		Max       float64 `json:"maxMs"`
+	}{s.Name, s.Count, s.Errors, s.ErrorRate(), ms(s.Min), ms(s.Mean), ms(s.P50), ms(s.P90), ms(s.P95), ms(s.P99), ms(s.Max)})
+}
+
+// ErrorRate returns the share of failed measurements in percent
+func (s Stats) ErrorRate() float64 {
+	if s.Count+s.Errors == 0 {
+		return 0
+	}
+	return 100 * float64(s.Errors) / float64(s.Count+s.Errors)
+}
+
+// Summary returns the statistics of all measurements sorted by name, the scenarios first
+func (r *Recorder) Summary() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make(map[string]bool)
+	for name := range r.samples {
+		names[name] = true
+	}
+	for name := range r.errors {
+		names[name] = true
+	}
+	var res []Stats
+	for name := range names {
+		res = append(res, summarize(name, r.samples[name], r.errors[name]))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if (res[i].Name == Scenario) != (res[j].Name == Scenario) {
+			return res[i].Name == Scenario
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+func summarize(name string, samples []time.Duration, errors int) Stats {
+	s := Stats{Name: name, Count: len(samples), Errors: errors}
+	if len(samples) == 0 {
+		return s
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	s.Min, s.Max, s.Mean = sorted[0], sorted[len(sorted)-1], sum/time.Duration(len(sorted))
+	s.P50, s.P90, s.P95, s.P99 = percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 95), percentile(sorted, 99)
+	return s
+}
+
+// percentile returns the nearest-rank percentile of the sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// SLA is a threshold of a measurement, e.g. sent_to_creation:p95<=5s or scenario:errors<=1%
+type SLA struct {
+	Name      string
+	Metric    string        // p50, p90, p95, p99, max, mean or errors
+	Limit     time.Duration // of the latency metrics
+	ErrorRate float64       // of the errors metric, in percent
+}
+
+func (sla SLA) String() string {
+	if sla.Metric == "errors" {
+		return fmt.Sprintf("%s:errors<=%s%%", sla.Name, strconv.FormatFloat(sla.ErrorRate, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s:%s<=%s", sla.Name, sla.Metric, sla.Limit)
+}
+
+// ParseSLAs parses a comma separated list of thresholds like "scenario:errors<=1%,sent_to_creation:p95<=5s"
+func ParseSLAs(text string) ([]SLA, error) {
+	var res []SLA
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, threshold, ok := strings.Cut(item, ":")
+		metric, limit, ok2 := strings.Cut(threshold, "<=")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("invalid SLA %q, expected <measurement>:<metric><=<limit>", item)
+		}
+		sla := SLA{Name: name, Metric: metric}
+		switch metric {
+		case "errors":
+			rate, err := strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid error rate of SLA %q: %w", item, err)
+			}
+			sla.ErrorRate = rate
+		case "p50", "p90", "p95", "p99", "max", "mean":
+			d, err := time.ParseDuration(limit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid limit of SLA %q: %w", item, err)
+			}
+			sla.Limit = d
+		default:
+			return nil, fmt.Errorf("unknown metric %q of SLA %q, expected p50, p90, p95, p99, max, mean or errors", metric, item)
+		}
+		res = append(res, sla)
+	}
+	return res, nil
+}
+
+// Check returns the violation of the SLA by the statistics, "" if it is met. A measurement without
+// samples violates latency thresholds, the load test did not show they are met.
+func (sla SLA) Check(stats []Stats) string {
+	var s *Stats
+	for i := range stats {
+		if stats[i].Name == sla.Name {
+			s = &stats[i]
+		}
+	}
+	if s == nil {
+		return fmt.Sprintf("%s: no measurements of %s", sla, sla.Name)
+	}
+	if sla.Metric == "errors" {
+		if rate := s.ErrorRate(); rate > sla.ErrorRate {
+			return fmt.Sprintf("%s: error rate %.2f%%", sla, rate)
+		}
+		return ""
+	}
+	if s.Count == 0 {
+		return fmt.Sprintf("%s: no successful measurements of %s", sla, sla.Name)
+	}
+	value := map[string]time.Duration{"p50": s.P50, "p90": s.P90, "p95": s.P95, "p99": s.P99, "max": s.Max, "mean": s.Mean}[sla.Metric]
+	if value > sla.Limit {
+		return fmt.Sprintf("%s: %s is %s", sla, sla.Metric, value)
+	}
+	return ""
+}
+
+// Result is the outcome of a load test, it is written as JSON report
+type Result struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Starts     int       `json:"starts"`
+	Stats      []Stats   `json:"stats"`
+	SLAs       []string  `json:"slas,omitempty"`
+	Violations []string  `json:"violations,omitempty"`
+}
+
+// Passed reports whether all SLAs are met
+func (res *Result) Passed() bool {
+	return len(res.Violations) == 0
+}
+
+// Result summarises the recorded latencies and checks them against the SLAs
+func (r *Recorder) Result(start, end time.Time, starts int, slas []SLA) *Result {
+	res := &Result{Start: start, End: end, Starts: starts, Stats: r.Summary()}
+	for _, sla := range slas {
+		res.SLAs = append(res.SLAs, sla.String())
+		if violation := sla.Check(res.Stats); violation != "" {
+			res.Violations = append(res.Violations, violation)
+		}
+	}
+	return res
+}
+
+// WriteText writes the percentiles as table followed by the SLA results
+func (res *Result) WriteText(w io.Writer) error {
+	elapsed := res.End.Sub(res.Start)
+	fmt.Fprintf(w, "Load test: %d scenarios in %s (%.2f/s)\n", res.Starts, elapsed.Round(time.Millisecond), float64(res.Starts)/elapsed.Seconds())
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "measurement\tcount\terrors\tmin\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	ms := func(d time.Duration) string { return strconv.FormatInt(d.Milliseconds(), 10) + "ms" }
+	for _, s := range res.Stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Name, s.Count, s.Errors, ms(s.Min), ms(s.Mean), ms(s.P50), ms(s.P90), ms(s.P95), ms(s.P99), ms(s.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, sla := range res.SLAs {
+		fmt.Fprintf(w, "SLA %s\n", sla)
+	}
+	for _, violation := range res.Violations {
+		fmt.Fprintf(w, "SLA VIOLATED %s\n", violation)
+	}
+	return nil
+}
+
+// WriteFile writes the result as JSON
+func (res *Result) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"test-tool/load"
 	"test-tool/step_definitions" // Ensure this path is correct based on your go.mod
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/cucumber/godog/colors"
@@ -16,19 +23,74 @@ var opts = godog.Options{
 	Format: "progress", // "pretty" or "progress"
 	Paths:  []string{"features"},
 	Strict: true, // Fail if there are undefined or pending steps
+	// Scenarios are isolated by the scope the suite gives them (own mock server, queues and TXIDs) and run concurrently
+	Concurrency: 4,
 	// Tags: "", // Add tags to filter scenarios
 }
 
+// suite holds the state shared by the scenarios of the run, all step groups of the library are registered
+var suite = step_definitions.NewSuite("")
+
+// reportDir receives the HTML timeline of the run and the Cucumber JSON report for CI dashboards
+var reportDir = pflag.String("report.dir", "reports", "directory of the HTML timeline and the Cucumber JSON report, empty for none")
+
+// Configuration layers above the configuration files and the environment, see step_definitions.Suite.LoadConfig
+var (
+	configProfile = pflag.String("config.profile", "", "configuration profile of testdata/config/profiles, default $ELSA_TEST_PROFILE or local")
+	configSet     = pflag.StringArray("config.set", nil, "configuration value overriding all other layers, e.g. pollingTimeoutSeconds=120, repeatable")
+)
+
+// Load test mode: the selected scenarios are repeated with generated TXIDs at the configured rate,
+// the run passes if the latencies meet the SLAs
+var (
+	loadIterations  = pflag.Int("load.iterations", 0, "run a load test with this number of scenario starts")
+	loadDuration    = pflag.Duration("load.duration", 0, "run a soak test starting scenarios for this duration")
+	loadRate        = pflag.Float64("load.rate", 0, "scenario starts per second of the load test, 0 for all at once")
+	loadRamp        = pflag.Duration("load.ramp", 0, "time the load test takes to increase the rate from 0 to load.rate")
+	loadConcurrency = pflag.Int("load.concurrency", 50, "maximum number of scenarios running at the same time in the load test")
+	loadSLA         = pflag.String("load.sla", "", "SLA thresholds of the load test, e.g. scenario:errors<=1%,sent_to_creation:p95<=5s")
+)
+
+// loadFile is the name of the load test result in the report directory
+const loadFile = "load.json"
+
 func init() {
 	godog.BindCommandLineFlags("godog.", &opts) // Allow godog CLI flags
 }
 
+// addCucumberReport writes the Cucumber JSON report to the report directory in addition to the configured formats,
+// unless a cucumber format is configured already
+func addCucumberReport() error {
+	if *reportDir == "" || strings.Contains(opts.Format, "cucumber") {
+		return nil
+	}
+	if err := os.MkdirAll(*reportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	opts.Format += ",cucumber:" + filepath.Join(*reportDir, "cucumber.json")
+	return nil
+}
+
 func TestMain(m *testing.M) {
 	pflag.Parse()
 	opts.Paths = pflag.Args()
 	if len(opts.Paths) == 0 {
 		opts.Paths = []string{"features"} // Default to features directory
 	}
+	if err := suite.SetConfigOverrides(*configProfile, *configSet); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	profile := load.Profile{Rate: *loadRate, Ramp: *loadRamp, Duration: *loadDuration, Iterations: *loadIterations}
+	if profile.Enabled() {
+		status := runLoadTest(profile)
+		suite.Close("")
+		os.Exit(status)
+	}
+	if err := addCucumberReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	status := godog.TestSuite{
 		Name:                 "elsa-test-suite",
@@ -37,10 +99,7 @@ func TestMain(m *testing.M) {
 		Options:              &opts,
 	}.Run()
 
-	// Optional: Call AfterSuiteHook if you have one defined and need to manage it this way
-	// This is a common pattern if AfterSuite is not directly supported by the Godog version's hooks.
-	// For this PoC, we'll call it directly.
-	step_definitions.AfterSuiteHook()
+	suite.Close(*reportDir)
 
 	if status > 0 {
 		fmt.Println("Godog tests failed!")
@@ -51,17 +110,87 @@ func TestMain(m *testing.M) {
 	os.Exit(status) // Ensure the exit code reflects the test suite status
 }
 
+// runLoadTest runs copies of the selected scenarios as scheduled by the profile. The number of starts is
+// rounded up to whole sets of the selected scenarios. The result is printed and written to the report
+// directory, the run passes if the SLAs are met (without SLAs if all scenarios passed).
+func runLoadTest(profile load.Profile) int {
+	if err := profile.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	slas, err := load.ParseSLAs(*loadSLA)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	features, err := godog.TestSuite{Options: &opts}.RetrieveFeatures()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Reading the features failed: %v\n", err)
+		return 1
+	}
+	scenarios := 0
+	for _, f := range features {
+		scenarios += len(f.Pickles)
+	}
+	if scenarios == 0 {
+		fmt.Fprintln(os.Stderr, "No scenarios selected for the load test")
+		return 1
+	}
+
+	// godog runs a feature file once, the copies get their own paths
+	copies := int(math.Ceil(float64(profile.Starts()) / float64(scenarios)))
+	fsys := fstest.MapFS{}
+	for i := 1; i <= copies; i++ {
+		for _, f := range features {
+			fsys[path.Join("load", fmt.Sprintf("%05d", i), strings.TrimPrefix(path.Clean(filepath.ToSlash(f.Uri)), "/"))] = &fstest.MapFile{Data: f.Content}
+		}
+	}
+	loadOpts := opts
+	loadOpts.FS = fsys
+	loadOpts.Paths = []string{"load"}
+	loadOpts.Concurrency = *loadConcurrency
+
+	starts := copies * scenarios
+	fmt.Printf("Load test: %d scenarios (%d times %d selected), rate %g/s, ramp %s\n", starts, copies, scenarios, profile.Rate, profile.Ramp)
+	recorder := load.NewRecorder()
+	start := time.Now()
+	suite.StartLoadTest(load.NewPacer(profile, start), recorder)
+	status := godog.TestSuite{
+		Name:                 "elsa-load-test",
+		TestSuiteInitializer: InitializeTestSuite,
+		ScenarioInitializer:  InitializeScenario,
+		Options:              &loadOpts,
+	}.Run()
+
+	result := recorder.Result(start, time.Now(), starts, slas)
+	result.WriteText(os.Stdout)
+	if *reportDir != "" {
+		path := filepath.Join(*reportDir, loadFile)
+		if err := result.WriteFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Writing the load test result failed: %v\n", err)
+		} else {
+			fmt.Printf("Load test result written to %s\n", path)
+		}
+	}
+	switch {
+	case !result.Passed():
+		fmt.Println("Load test failed: SLAs violated")
+		return 1
+	case len(slas) == 0 && status != 0:
+		fmt.Println("Load test failed: scenarios failed")
+		return status
+	}
+	fmt.Println("Load test passed!")
+	return 0
+}
+
 // InitializeTestSuite can be used for global suite setup if needed
 func InitializeTestSuite(ctx *godog.TestSuiteContext) {
 	// Example: if you need to run something once before all scenarios in all suites
 	// For this PoC, most setup is per-scenario or managed by hooks.go
 }
 
-// InitializeScenario registers step definitions.
+// InitializeScenario registers the hooks of the suite and all step groups of the library.
 func InitializeScenario(ctx *godog.ScenarioContext) {
-	// Initialize steps from all your step definition files
-	step_definitions.InitializeConfigSteps(ctx)
-	step_definitions.InitializeMessageSteps(ctx)
-	step_definitions.InitializeAPISteps(ctx)
-	step_definitions.InitializeHookSteps(ctx) // This includes BeforeScenario and the server start step
+	suite.Register(ctx, step_definitions.AllSteps...)
 }
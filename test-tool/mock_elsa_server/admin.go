@@ -0,0 +1,216 @@
+package mock_elsa_server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"test-tool/clock"
+	"test-tool/queue"
+	"time"
+)
+
+// InstructionUpdate sets fields of an instruction and appends statuses to its history, empty fields
+// keep their values. It is the body of PUT /_mock/instructions/{txID} of the control API.
+type InstructionUpdate struct {
+	MitiTXID              string   `json:"mitiTXID,omitempty"`
+	InstructingParty      string   `json:"instructingParty,omitempty"`
+	MovementType          string   `json:"movementType,omitempty"`
+	PaymentType           string   `json:"paymentType,omitempty"`
+	CancellationRequested *bool    `json:"cancellationRequested,omitempty"`
+	ISIN                  string   `json:"isin,omitempty"`
+	Quantity              string   `json:"quantity,omitempty"`
+	SettlementDate        string   `json:"settlementDate,omitempty"`
+	SafekeepingAccount    string   `json:"safekeepingAccount,omitempty"`
+	Statuses              []string `json:"statuses,omitempty"` // appended in order, the last one is the current status
+}
+
+// PutInstruction creates or updates the instruction with the client TXID, it returns a copy of the
+// instruction and whether it was created
+func (s *MockElsaAPIServer) PutInstruction(clientTXID string, u InstructionUpdate) (InstructionState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.instructions[clientTXID]
+	if !ok {
+		state = s.newInstruction(clientTXID)
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&state.MitiTXID, u.MitiTXID},
+		{&state.InstructingParty, u.InstructingParty},
+		{&state.MovementType, u.MovementType},
+		{&state.PaymentType, u.PaymentType},
+		{&state.ISIN, u.ISIN},
+		{&state.Quantity, u.Quantity},
+		{&state.SettlementDate, u.SettlementDate},
+		{&state.SafekeepingAccount, u.SafekeepingAccount},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	if u.CancellationRequested != nil {
+		state.CancellationRequested = *u.CancellationRequested
+	}
+	for _, status := range u.Statuses {
+		s.appendStatus(state, status, nil)
+	}
+	s.logf("Instruction %s put, history: %+v", clientTXID, state.StatusHistory)
+	return copyState(state), !ok
+}
+
+// Instruction returns a copy of the instruction with the client TXID
+func (s *MockElsaAPIServer) Instruction(clientTXID string) (InstructionState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, ok := s.instructions[clientTXID]
+	if !ok {
+		return InstructionState{}, false
+	}
+	return copyState(state), true
+}
+
+// DeleteInstruction removes the instruction with the client TXID and the messages of its statuses,
+// pending transitions and retries of it are cancelled. It reports whether there was one.
+func (s *MockElsaAPIServer) DeleteInstruction(clientTXID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.instructions[clientTXID]; !ok {
+		return false
+	}
+	delete(s.instructions, clientTXID)
+	for id := range s.messages {
+		if strings.HasPrefix(id, "mock-"+clientTXID+"-") {
+			delete(s.messages, id)
+		}
+	}
+	for id, t := range s.scheduled {
+		if t.TXID == clientTXID {
+			t.timer.Stop()
+			delete(s.scheduled, id)
+		}
+	}
+	s.stopRetry(clientTXID)
+	s.logf("Instruction %s deleted", clientTXID)
+	return true
+}
+
+func copyState(state *InstructionState) InstructionState {
+	c := *state
+	c.StatusHistory = append([]APIStatusEntry(nil), state.StatusHistory...)
+	return c
+}
+
+// ScheduledTransition is a status set on an instruction when it is due, see ScheduleStatus
+type ScheduledTransition struct {
+	ID     string    `json:"id"`
+	TXID   string    `json:"txID"`
+	Status string    `json:"status"`
+	Due    time.Time `json:"due"`
+
+	timer clock.Timer
+}
+
+// ScheduleStatus sets the status of the instruction with the client TXID after the delay, e.g. to
+// simulate a party answering late. The instruction is created if it does not exist when it is due.
+func (s *MockElsaAPIServer) ScheduleStatus(clientTXID, status string, after time.Duration) (ScheduledTransition, error) {
+	if clientTXID == "" || status == "" {
+		return ScheduledTransition{}, fmt.Errorf("transition needs a TXID and a status")
+	}
+	if after < 0 {
+		return ScheduledTransition{}, fmt.Errorf("delay of transition must not be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTransitionID++
+	t := &ScheduledTransition{ID: strconv.Itoa(s.lastTransitionID), TXID: clientTXID, Status: status, Due: s.clock.Now().Add(after).UTC()}
+	t.timer = s.clock.AfterFunc(after, func() {
+		s.mu.Lock()
+		if _, pending := s.scheduled[t.ID]; !pending {
+			s.mu.Unlock()
+			return // cancelled
+		}
+		delete(s.scheduled, t.ID)
+		s.mu.Unlock()
+		s.SetInstructionStatus(t.TXID, t.Status)
+	})
+	s.scheduled[t.ID] = t
+	return *t, nil
+}
+
+// CancelScheduled cancels the pending transition with the ID, it reports whether there was one
+func (s *MockElsaAPIServer) CancelScheduled(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.scheduled[id]
+	if ok {
+		t.timer.Stop()
+		delete(s.scheduled, id)
+	}
+	return ok
+}
+
+// Scheduled returns the pending transitions, the next due first
+func (s *MockElsaAPIServer) Scheduled() []ScheduledTransition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scheduledTransitions()
+}
+
+// scheduledTransitions returns the pending transitions, s.mu must be held
+func (s *MockElsaAPIServer) scheduledTransitions() []ScheduledTransition {
+	res := make([]ScheduledTransition, 0, len(s.scheduled))
+	for _, t := range s.scheduled {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Due.Before(res[j].Due) })
+	return res
+}
+
+// cancelScheduled cancels all pending transitions, s.mu must be held
+func (s *MockElsaAPIServer) cancelScheduled() {
+	for id, t := range s.scheduled {
+		t.timer.Stop()
+		delete(s.scheduled, id)
+	}
+}
+
+// State is a dump of the mock, GET /_mock/state of the control API
+type State struct {
+	Instructions []InstructionState    `json:"instructions"` // in order of creation
+	Transitions  []ScheduledTransition `json:"transitions"`
+	Faults       []Fault               `json:"faults"`
+	QueueFaults  []queue.Fault         `json:"queueFaults"`
+	Flow         bool                  `json:"flow"`   // statuses are derived from the messages of the queues
+	Replay       bool                  `json:"replay"` // a cassette is replayed
+	Clock        ClockState            `json:"clock"`
+	Retry        RetryPolicy           `json:"retry"`
+}
+
+// Dump returns the state of the mock
+func (s *MockElsaAPIServer) Dump() State {
+	faults, clockState := s.Faults(), s.Clock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := State{
+		Instructions: make([]InstructionState, 0, len(s.instructions)),
+		Transitions:  s.scheduledTransitions(),
+		Faults:       faults,
+		QueueFaults:  s.queueFaults.List(),
+		Flow:         s.watcher != nil,
+		Replay:       s.replay != nil,
+		Clock:        clockState,
+		Retry:        s.retryPolicy,
+	}
+	for _, state := range s.instructions {
+		st.Instructions = append(st.Instructions, copyState(state))
+	}
+	sort.Slice(st.Instructions, func(i, j int) bool {
+		a, _ := strconv.Atoi(st.Instructions[i].ID)
+		b, _ := strconv.Atoi(st.Instructions[j].ID)
+		return a < b
+	})
+	return st
+}
@@ -0,0 +1,107 @@
+package mock_elsa_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminInstructions(t *testing.T) {
+	srv := newAPIServer(t)
+	s := srv.Config.Handler.(*MockElsaAPIServer)
+
+	resp, body := request(t, http.MethodPut, srv.URL+"/_mock/instructions/TX9", `{"isin": "DE0001102580", "statuses": ["created", "sent_to_creation"]}`)
+	var state InstructionState
+	if err := json.Unmarshal(body, &state); resp.StatusCode != http.StatusCreated || err != nil {
+		t.Fatalf("create instruction: got %d %s", resp.StatusCode, body)
+	}
+	if state.MitiTXID != "miti-TX9" || state.ISIN != "DE0001102580" || len(state.StatusHistory) != 2 || state.StatusHistory[0].Name != StatusSentToCreation {
+		t.Errorf("created instruction: got %+v", state)
+	}
+	resp, body = request(t, http.MethodPut, srv.URL+"/_mock/instructions/TX9", `{"quantity": "100", "cancellationRequested": true}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update instruction: got %d %s", resp.StatusCode, body)
+	}
+	if state, _ := s.Instruction("TX9"); state.ISIN != "DE0001102580" || state.Quantity != "100" || !state.CancellationRequested || len(state.StatusHistory) != 2 {
+		t.Errorf("updated instruction: got %+v", state)
+	}
+	// the instruction is served by the ELSA API
+	if resp, body := get(t, srv.URL+"/instructions/TX9"); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /instructions/TX9: got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := request(t, http.MethodPut, srv.URL+"/_mock/instructions/TX9", `{"statuses": "created"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid update: got %d", resp.StatusCode)
+	}
+
+	var states []InstructionState
+	if _, body := get(t, srv.URL+"/_mock/instructions"); json.Unmarshal(body, &states) != nil || len(states) != 3 || states[2].TXID != "TX9" {
+		t.Errorf("list instructions: got %s", body)
+	}
+	if resp, _ := request(t, http.MethodDelete, srv.URL+"/_mock/instructions/TX9", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete instruction: got %d", resp.StatusCode)
+	}
+	for _, path := range []string{"/_mock/instructions/TX9", "/instructions/TX9"} {
+		if resp, _ := get(t, srv.URL+path); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s of deleted instruction: got %d", path, resp.StatusCode)
+		}
+	}
+	if resp, _ := request(t, http.MethodDelete, srv.URL+"/_mock/instructions/TX9", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete deleted instruction: got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminTransitionsAndState(t *testing.T) {
+	srv := newAPIServer(t)
+	s := srv.Config.Handler.(*MockElsaAPIServer)
+
+	resp, body := request(t, http.MethodPost, srv.URL+"/_mock/transitions", `{"txID": "TX2", "status": "sent_to_creation", "after": "50ms"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("schedule transition: got %d %s", resp.StatusCode, body)
+	}
+	later, err := s.ScheduleStatus("TX2", StatusCancelled, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled := s.Scheduled(); len(scheduled) != 2 || scheduled[1].ID != later.ID {
+		t.Errorf("pending transitions: got %+v", scheduled)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if state, _ := s.Instruction("TX2"); state.StatusHistory[0].Name == StatusSentToCreation {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled transition not applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp, _ := request(t, http.MethodDelete, srv.URL+"/_mock/transitions/"+later.ID, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("cancel transition: got %d", resp.StatusCode)
+	}
+	if scheduled := s.Scheduled(); len(scheduled) != 0 {
+		t.Errorf("pending transitions after cancel: got %+v", scheduled)
+	}
+	for _, body := range []string{`{"txID": "TX2"}`, `{"txID": "TX2", "status": "settled", "after": "soon"}`, `{"txID": "TX2", "status": "settled", "after": "-1s"}`} {
+		if resp, _ := request(t, http.MethodPost, srv.URL+"/_mock/transitions", body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("invalid transition %s: got %d", body, resp.StatusCode)
+		}
+	}
+
+	if _, err := s.ScheduleStatus("TX3", StatusCreated, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddFault(Fault{TXID: "TX1", Status: http.StatusInternalServerError}); err != nil {
+		t.Fatal(err)
+	}
+	var state State
+	if _, body := get(t, srv.URL+"/_mock/state"); json.Unmarshal(body, &state) != nil || len(state.Instructions) != 2 || len(state.Transitions) != 1 || len(state.Faults) != 1 {
+		t.Errorf("state: got %s", body)
+	}
+	if resp, _ := request(t, http.MethodPost, srv.URL+"/_mock/reset", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("reset: got %d", resp.StatusCode)
+	}
+	if state := s.Dump(); len(state.Instructions) != 0 || len(state.Transitions) != 0 || len(state.Faults) != 0 {
+		t.Errorf("state after reset: got %+v", state)
+	}
+}
@@ -0,0 +1,320 @@
+package mock_elsa_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPageSize is the page size of the list operations without size parameter, as declared by the spec
+const defaultPageSize = 20
+
+// collectionIDPrefix makes the ID of the collection of an instruction from its internal ID
+const collectionIDPrefix = "col-"
+
+// operations implements the operations of the spec, keyed by operation ID
+var operations = map[string]func(s *MockElsaAPIServer, w http.ResponseWriter, r *http.Request, params map[string]string){
+	"listInstructions":    (*MockElsaAPIServer).listInstructions,
+	"getInstruction":      (*MockElsaAPIServer).getInstruction,
+	"getInstructionById":  (*MockElsaAPIServer).getInstructionByID,
+	"getInstructionAudit": (*MockElsaAPIServer).getInstructionAudit,
+	"getMessage":          (*MockElsaAPIServer).getMessage,
+	"listCollections":     (*MockElsaAPIServer).listCollections,
+	"getCollection":       (*MockElsaAPIServer).getCollection,
+	"streamEvents": func(s *MockElsaAPIServer, w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		s.serveEvents(w, r)
+	},
+	"getSpec": func(_ *MockElsaAPIServer, w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	},
+}
+
+// instructionResource is an instruction as returned by the API
+type instructionResource struct {
+	ID                    string            `json:"id"`
+	Href                  string            `json:"href"`
+	InstructionType       string            `json:"instructionType"`
+	InstructingParty      string            `json:"instructingParty"`
+	ClientTxID            string            `json:"clientTxID"`
+	TxID                  string            `json:"txID"` // This is MitiTXID
+	MovementType          string            `json:"movementType"`
+	PaymentType           string            `json:"paymentType"`
+	CancellationRequested bool              `json:"cancellationRequested"`
+	Status                []APIStatusEntry  `json:"status"`
+	Links                 map[string]string `json:"links"`
+}
+
+type auditEntry struct {
+	Timestamp      string `json:"timestamp"`
+	Action         string `json:"action"`
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previousStatus,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+type auditTrail struct {
+	InstructionID string       `json:"instructionId"`
+	TxID          string       `json:"txID"`
+	Entries       []auditEntry `json:"entries"`
+}
+
+type collectionMessage struct {
+	ID        string `json:"id"`
+	Status    string `json:"status,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Href      string `json:"href"`
+}
+
+type collectionResource struct {
+	ID            string              `json:"id"`
+	Href          string              `json:"href"`
+	InstructionID string              `json:"instructionId"`
+	TxID          string              `json:"txID"`
+	Messages      []collectionMessage `json:"messages"`
+}
+
+type page struct {
+	Items interface{}       `json:"items"`
+	Page  int               `json:"page"`
+	Size  int               `json:"size"`
+	Total int               `json:"total"`
+	Links map[string]string `json:"links"`
+}
+
+// writeJSON writes v as JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response, details are pairs of additional fields and their values
+func writeError(w http.ResponseWriter, status int, message string, details ...string) {
+	body := map[string]string{"error": message}
+	for i := 0; i+1 < len(details); i += 2 {
+		body[details[i]] = details[i+1]
+	}
+	writeJSON(w, status, body)
+}
+
+// snapshot returns copies of the instructions with a status history in order of creation,
+// accepted by filter if it is not nil. It takes s.mu itself.
+func (s *MockElsaAPIServer) snapshot(filter func(*InstructionState) bool) ([]InstructionState, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var res []InstructionState
+	for _, stored := range s.instructions {
+		if len(stored.StatusHistory) == 0 || (filter != nil && !filter(stored)) {
+			continue
+		}
+		state := *stored
+		state.StatusHistory = append([]APIStatusEntry(nil), stored.StatusHistory...)
+		res = append(res, state)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, _ := strconv.Atoi(res[i].ID)
+		b, _ := strconv.Atoi(res[j].ID)
+		return a < b
+	})
+	return res, s.apiBaseURL
+}
+
+// byID returns a filter for the instruction with the internal ID
+func byID(id string) func(*InstructionState) bool {
+	return func(state *InstructionState) bool { return state.ID == id }
+}
+
+// byTXID returns a filter for the instruction with the client or market infrastructure TXID
+func byTXID(txID string) func(*InstructionState) bool {
+	return func(state *InstructionState) bool { return state.TXID == txID || state.MitiTXID == txID }
+}
+
+func instructionOf(state *InstructionState, apiBaseURL string) instructionResource {
+	self := fmt.Sprintf("%s/instructions/id/%s", apiBaseURL, state.ID)
+	return instructionResource{
+		ID:                    state.ID,
+		Href:                  self,
+		InstructionType:       "elsa_to_t2s",
+		InstructingParty:      valueOr(state.InstructingParty, defaultInstructingParty),
+		ClientTxID:            state.TXID,
+		TxID:                  state.MitiTXID,
+		MovementType:          valueOr(state.MovementType, defaultMovementType),
+		PaymentType:           valueOr(state.PaymentType, defaultPaymentType),
+		CancellationRequested: state.CancellationRequested,
+		Status:                state.StatusHistory,
+		Links: map[string]string{
+			"instruction": self,
+			"audit":       self + "/audit",
+			"collection":  fmt.Sprintf("%s/collections/id/%s%s", apiBaseURL, collectionIDPrefix, state.ID),
+		},
+	}
+}
+
+// auditOf derives the audit trail from the status history, oldest entry first
+func auditOf(state *InstructionState) auditTrail {
+	trail := auditTrail{InstructionID: state.ID, TxID: state.TXID, Entries: []auditEntry{}}
+	previous := ""
+	for i := len(state.StatusHistory) - 1; i >= 0; i-- {
+		st := state.StatusHistory[i]
+		entry := auditEntry{Timestamp: st.Timestamp, Action: "status_changed", Status: st.Name, PreviousStatus: previous, Message: st.Message}
+		if previous == "" {
+			entry.Action = "created"
+		}
+		trail.Entries = append(trail.Entries, entry)
+		if st.Name == StatusCancelled && state.CancellationRequested {
+			trail.Entries = append(trail.Entries, auditEntry{Timestamp: st.Timestamp, Action: "cancellation_requested", Status: st.Name})
+		}
+		previous = st.Name
+	}
+	return trail
+}
+
+// collectionOf lists the stored messages of the instruction, oldest first
+func (s *MockElsaAPIServer) collectionOf(state *InstructionState, apiBaseURL string) collectionResource {
+	id := collectionIDPrefix + state.ID
+	c := collectionResource{
+		ID:            id,
+		Href:          fmt.Sprintf("%s/collections/id/%s", apiBaseURL, id),
+		InstructionID: state.ID,
+		TxID:          state.TXID,
+		Messages:      []collectionMessage{},
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(state.StatusHistory) - 1; i >= 0; i-- {
+		st := state.StatusHistory[i]
+		messageID := st.Message[strings.LastIndex(st.Message, "/")+1:]
+		if _, ok := s.messages[messageID]; !ok {
+			continue
+		}
+		c.Messages = append(c.Messages, collectionMessage{ID: messageID, Status: st.Name, Timestamp: st.Timestamp, Href: st.Message})
+	}
+	return c
+}
+
+// paging returns the requested page and size, the parameters were checked against the spec
+func paging(query url.Values) (int, int) {
+	pageNo, size := 1, defaultPageSize
+	if n, err := strconv.Atoi(query.Get("page")); err == nil {
+		pageNo = n
+	}
+	if n, err := strconv.Atoi(query.Get("size")); err == nil {
+		size = n
+	}
+	return pageNo, size
+}
+
+// pageOf cuts the page out of n entries and links the neighbouring pages
+func pageOf(r *http.Request, apiBaseURL string, n int) (from, to int, p page) {
+	pageNo, size := paging(r.URL.Query())
+	link := func(no int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(no))
+		q.Set("size", strconv.Itoa(size))
+		return apiBaseURL + r.URL.Path + "?" + q.Encode()
+	}
+	p = page{Page: pageNo, Size: size, Total: n, Links: map[string]string{"self": link(pageNo)}}
+	if pageNo > 1 {
+		p.Links["prev"] = link(pageNo - 1)
+	}
+	if pageNo*size < n {
+		p.Links["next"] = link(pageNo + 1)
+	}
+	from = min((pageNo-1)*size, n)
+	return from, min(from+size, n), p
+}
+
+func (s *MockElsaAPIServer) listInstructions(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	q := r.URL.Query()
+	states, apiBaseURL := s.snapshot(func(state *InstructionState) bool {
+		return (q.Get("txID") == "" || byTXID(q.Get("txID"))(state)) &&
+			(q.Get("status") == "" || state.StatusHistory[0].Name == q.Get("status")) &&
+			(q.Get("instructingParty") == "" || valueOr(state.InstructingParty, defaultInstructingParty) == q.Get("instructingParty")) &&
+			(q.Get("movementType") == "" || valueOr(state.MovementType, defaultMovementType) == q.Get("movementType")) &&
+			(q.Get("paymentType") == "" || valueOr(state.PaymentType, defaultPaymentType) == q.Get("paymentType"))
+	})
+	from, to, p := pageOf(r, apiBaseURL, len(states))
+	items := []instructionResource{}
+	for i := from; i < to; i++ {
+		items = append(items, instructionOf(&states[i], apiBaseURL))
+	}
+	p.Items = items
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *MockElsaAPIServer) getInstruction(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	clientTXID := params["txID"]
+	states, apiBaseURL := s.snapshot(func(state *InstructionState) bool { return state.TXID == clientTXID })
+	if len(states) == 0 {
+		s.logf("Instruction %s not found or no status history", clientTXID)
+		writeError(w, http.StatusNotFound, "Instruction not found", "transactionId", clientTXID)
+		return
+	}
+	writeJSON(w, http.StatusOK, instructionOf(&states[0], apiBaseURL))
+}
+
+func (s *MockElsaAPIServer) getInstructionByID(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	states, apiBaseURL := s.snapshot(byID(params["id"]))
+	if len(states) == 0 {
+		writeError(w, http.StatusNotFound, "Instruction not found", "id", params["id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, instructionOf(&states[0], apiBaseURL))
+}
+
+func (s *MockElsaAPIServer) getInstructionAudit(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	states, _ := s.snapshot(byID(params["id"]))
+	if len(states) == 0 {
+		writeError(w, http.StatusNotFound, "Instruction not found", "id", params["id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, auditOf(&states[0]))
+}
+
+func (s *MockElsaAPIServer) getMessage(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	messageID := params["id"]
+	s.mu.RLock()
+	message, exists := s.messages[messageID]
+	s.mu.RUnlock()
+	if !exists {
+		s.logf("Message %s not found", messageID)
+		writeError(w, http.StatusNotFound, "Message not found", "messageId", messageID)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(message)
+}
+
+func (s *MockElsaAPIServer) listCollections(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var filter func(*InstructionState) bool
+	if txID := r.URL.Query().Get("txID"); txID != "" {
+		filter = byTXID(txID)
+	}
+	states, apiBaseURL := s.snapshot(filter)
+	from, to, p := pageOf(r, apiBaseURL, len(states))
+	items := []collectionResource{}
+	for i := from; i < to; i++ {
+		items = append(items, s.collectionOf(&states[i], apiBaseURL))
+	}
+	p.Items = items
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *MockElsaAPIServer) getCollection(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id, ok := strings.CutPrefix(params["id"], collectionIDPrefix)
+	var states []InstructionState
+	apiBaseURL := ""
+	if ok {
+		states, apiBaseURL = s.snapshot(byID(id))
+	}
+	if len(states) == 0 {
+		writeError(w, http.StatusNotFound, "Collection not found", "id", params["id"])
+		return
+	}
+	writeJSON(w, http.StatusOK, s.collectionOf(&states[0], apiBaseURL))
+}
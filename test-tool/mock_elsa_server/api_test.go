@@ -0,0 +1,204 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpecOperationsAreImplemented(t *testing.T) {
+	ids := APISpec().Operations()
+	for _, id := range ids {
+		if _, ok := operations[id]; !ok {
+			t.Errorf("operation %s of the spec is not implemented", id)
+		}
+	}
+	if len(operations) != len(ids) {
+		t.Errorf("%d operations implemented, the spec has %d", len(operations), len(ids))
+	}
+}
+
+// newAPIServer serves two instructions: TX1 rejected by CREATION, TX2 only created
+func newAPIServer(t *testing.T) *httptest.Server {
+	s, _ := newFlowServer(t)
+	srv := httptest.NewServer(s)
+	s.SetAPIBaseURL(srv.URL) // before the statuses are set, their message links point to it
+	t.Cleanup(srv.Close)
+
+	for _, m := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ProcessMessage(context.Background(), PartyCreation, []byte(rejected)); err != nil {
+		t.Fatal(err)
+	}
+	s.SetInstructionStatus("TX2", StatusCreated)
+	return srv
+}
+
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	return request(t, http.MethodGet, url, "")
+}
+
+func request(t *testing.T, method, url, body string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+// TestAPIConformsToSpec is the contract test of the mock: all responses match the spec
+func TestAPIConformsToSpec(t *testing.T) {
+	srv := newAPIServer(t)
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/instructions", http.StatusOK},
+		{"/instructions?status=created&size=1&page=1", http.StatusOK},
+		{"/instructions?size=0", http.StatusBadRequest},
+		{"/instructions?page=x", http.StatusBadRequest},
+		{"/instructions?movementType=XXXX", http.StatusBadRequest},
+		{"/instructions/TX1", http.StatusOK},
+		{"/instructions/UNKNOWN", http.StatusNotFound},
+		{"/instructions/id/1", http.StatusOK},
+		{"/instructions/id/99", http.StatusNotFound},
+		{"/instructions/id/1/audit", http.StatusOK},
+		{"/messages/id/mock-TX1-created", http.StatusOK},
+		{"/messages/id/mock-TX2-created", http.StatusNotFound},
+		{"/collections", http.StatusOK},
+		{"/collections/id/col-1", http.StatusOK},
+		{"/collections/id/1", http.StatusNotFound},
+		{"/openapi.json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, srv.URL+tt.path)
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: got status %d, want %d: %s", tt.path, resp.StatusCode, tt.status, body)
+			continue
+		}
+		path, _, _ := strings.Cut(tt.path, "?")
+		if err := APISpec().ValidateResponse(http.MethodGet, path, resp.StatusCode, resp.Header.Get("Content-Type"), body); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	srv := newAPIServer(t)
+	if resp, _ := get(t, srv.URL+"/unknown"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown path: got %d, want 404", resp.StatusCode)
+	}
+	resp, err := http.Post(srv.URL+"/instructions/TX1", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestListInstructions(t *testing.T) {
+	srv := newAPIServer(t)
+	list := func(query string) (ids []string, p page) {
+		_, body := get(t, srv.URL+"/instructions?"+query)
+		var res struct {
+			page
+			Items []instructionResource `json:"items"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range res.Items {
+			ids = append(ids, item.ClientTxID)
+		}
+		return ids, res.page
+	}
+
+	if ids, p := list(""); strings.Join(ids, ",") != "TX1,TX2" || p.Total != 2 || p.Links["next"] != "" {
+		t.Errorf("all instructions: got %v, %+v", ids, p)
+	}
+	if ids, _ := list("status=cancelled"); strings.Join(ids, ",") != "TX1" {
+		t.Errorf("status filter: got %v", ids)
+	}
+	if ids, _ := list("txID=miti-TX2"); strings.Join(ids, ",") != "TX2" {
+		t.Errorf("txID filter: got %v", ids)
+	}
+	if ids, _ := list("paymentType=FREE&movementType=DELI"); strings.Join(ids, ",") != "TX1" {
+		t.Errorf("type filters: got %v", ids)
+	}
+	ids, p := list("size=1&page=2")
+	if strings.Join(ids, ",") != "TX2" || p.Total != 2 || p.Links["prev"] == "" || p.Links["next"] != "" {
+		t.Errorf("second page: got %v, %+v", ids, p)
+	}
+	if ids, p := list("page=3"); len(ids) != 0 || p.Total != 2 {
+		t.Errorf("page after the last: got %v, %+v", ids, p)
+	}
+}
+
+func TestAuditAndCollection(t *testing.T) {
+	srv := newAPIServer(t)
+	_, body := get(t, srv.URL+"/instructions/id/1/audit")
+	var trail auditTrail
+	if err := json.Unmarshal(body, &trail); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range trail.Entries {
+		actions = append(actions, e.Action+":"+e.Status)
+	}
+	want := "created:created,status_changed:accepted_by_t2s,status_changed:sent_to_creation," +
+		"status_changed:rejected_by_creation,status_changed:cancelled,cancellation_requested:cancelled"
+	if got := strings.Join(actions, ","); got != want {
+		t.Errorf("audit trail:\n got %s\nwant %s", got, want)
+	}
+
+	_, body = get(t, srv.URL+"/collections/id/col-1")
+	var c collectionResource
+	if err := json.Unmarshal(body, &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Messages) != len(trail.Entries)-1 || c.Messages[0].ID != "mock-TX1-created" {
+		t.Errorf("collection: got %+v", c.Messages)
+	}
+	if resp, _ := get(t, c.Messages[0].Href); resp.StatusCode != http.StatusOK {
+		t.Errorf("message link of the collection: got %d", resp.StatusCode)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := APISpec()
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/instructions/TX1", http.StatusInternalServerError, `{"error": "boom"}`}, // status not declared
+		{"/instructions/TX1", http.StatusOK, `{"id": "1"}`},                        // required properties missing
+		{"/instructions/id/1/audit", http.StatusOK, `{"instructionId": "1", "txID": "TX1", "entries": [{"timestamp": "t", "action": "deleted"}]}`},
+		{"/instructions", http.StatusOK, `{"items": [], "page": "1", "size": 20, "total": 0, "links": {"self": "x"}}`},
+		{"/messages/id/m1", http.StatusOK, `<Document>`},
+		{"/nothing", http.StatusOK, `{}`},
+	}
+	for _, tt := range tests {
+		if err := spec.ValidateResponse(http.MethodGet, tt.path, tt.status, "", []byte(tt.body)); err == nil {
+			t.Errorf("%s %d %s: expected a contract violation", tt.path, tt.status, tt.body)
+		}
+	}
+}
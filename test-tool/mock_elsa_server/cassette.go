@@ -0,0 +1,346 @@
+package mock_elsa_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// A cassette holds request/response pairs of the ELSA API recorded by the RecordingProxy. Values which
+// differ from run to run are templates: TXIDs and other path parameters are {{.TXID}}, {{.ID}} etc.,
+// timestamps are {{at "1.5s"}} (the offset to the start of the recording) and the URL of the recorded
+// API is {{.BaseURL}}.
+type Cassette struct {
+	Recorded     time.Time         `json:"recorded"`     // start of the recording, timestamps are relative to it
+	Variables    map[string]string `json:"variables"`    // recorded values of the variables, used if a replay does not bind them
+	Interactions []Interaction     `json:"interactions"` // in the order they were recorded
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"` // path and sorted query, templated
+}
+
+type RecordedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"` // templated
+}
+
+// minVariableLength is the length a value needs to become a variable, shorter ones (e.g. small
+// internal IDs) would replace unrelated parts of the responses and are recorded as they are
+const minVariableLength = 3
+
+// timestampPattern matches the RFC 3339 timestamps of the API
+var timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+// variablePattern matches the variables of a templated URL
+var variablePattern = regexp.MustCompile(`\{\{\.([A-Za-z0-9_]+)\}\}`)
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette, the file is replaced atomically
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// requestURL returns path and query of the request, the query parameters sorted
+func requestURL(u *url.URL) string {
+	if q := u.Query(); len(q) > 0 {
+		return u.Path + "?" + q.Encode()
+	}
+	return u.Path
+}
+
+// templater turns recorded values into templates, it collects the variables while recording
+type templater struct {
+	recorded  time.Time
+	baseURL   string
+	variables map[string]string // name -> recorded value
+}
+
+// bind makes the path parameters of the request and a txID query parameter variables and returns the
+// templated URL. The variable of a parameter is named after it, e.g. TXID for txID, further values of
+// the same parameter get a suffix (TXID_2).
+func (t *templater) bind(method string, u *url.URL) string {
+	params := make(map[string]string)
+	if _, pathParams, err := apiSpec.Route(method, u.Path); err == nil {
+		params = pathParams
+	}
+	if txID := u.Query().Get("txID"); txID != "" {
+		params["txID"] = txID
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.variable(strings.ToUpper(name), params[name])
+	}
+	return t.apply(requestURL(u))
+}
+
+// variable registers the value under the name unless it is known already
+func (t *templater) variable(name, value string) {
+	if len(value) < minVariableLength {
+		return
+	}
+	for _, known := range t.variables {
+		if known == value {
+			return
+		}
+	}
+	key := name
+	for i := 2; ; i++ {
+		if _, taken := t.variables[key]; !taken {
+			break
+		}
+		key = name + "_" + strconv.Itoa(i)
+	}
+	t.variables[key] = value
+}
+
+// apply replaces the base URL, the values of the variables and the timestamps in text by templates
+func (t *templater) apply(text string) string {
+	text = strings.ReplaceAll(text, "{{", `{{"{{"}}`)
+	if t.baseURL != "" {
+		text = strings.ReplaceAll(text, t.baseURL, "{{.BaseURL}}")
+	}
+	text = timestampPattern.ReplaceAllStringFunc(text, func(ts string) string {
+		parsed, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return ts
+		}
+		return fmt.Sprintf(`{{at %q}}`, parsed.Sub(t.recorded).String())
+	})
+
+	// longer values first, a TXID may be part of another one
+	names := make([]string, 0, len(t.variables))
+	for name := range t.variables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := len(t.variables[names[i]]), len(t.variables[names[j]]); a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		text = replaceWord(text, t.variables[name], "{{."+name+"}}")
+	}
+	return text
+}
+
+// replaceWord replaces value where it is not part of a longer word, e.g. TX1 in miti-TX1 but not in TX10
+func replaceWord(text, value, replacement string) string {
+	pattern := regexp.MustCompile(`(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(value) + `($|[^A-Za-z0-9_])`)
+	for {
+		replaced := pattern.ReplaceAllString(text, "${1}"+strings.ReplaceAll(replacement, "$", "$$")+"${2}")
+		if replaced == text { // neighbouring matches share a delimiter, repeat until all are replaced
+			return text
+		}
+		text = replaced
+	}
+}
+
+// replay serves the interactions of a cassette. Interactions with the same templated request are
+// replayed in the recorded order, the last one is repeated. Variables are bound by the requests,
+// e.g. the TXID of GET /instructions/{txID}, unbound ones have their recorded values.
+type replay struct {
+	mu        sync.Mutex
+	cassette  *Cassette
+	start     time.Time // timestamps are rendered relative to it
+	variables map[string]string
+	next      map[string]int // position of the next interaction per templated request
+	requests  []*requestMatcher
+}
+
+type requestMatcher struct {
+	key          string // method and templated URL
+	pattern      *regexp.Regexp
+	names        []string // variables bound by the pattern
+	interactions []*Interaction
+}
+
+func newReplay(c *Cassette, start time.Time) (*replay, error) {
+	r := &replay{cassette: c, start: start, variables: make(map[string]string), next: make(map[string]int)}
+	byKey := make(map[string]*requestMatcher)
+	for i := range c.Interactions {
+		in := &c.Interactions[i]
+		key := in.Request.Method + " " + in.Request.URL
+		m, ok := byKey[key]
+		if !ok {
+			pattern, names, err := urlPattern(in.Request.URL)
+			if err != nil {
+				return nil, err
+			}
+			m = &requestMatcher{key: key, pattern: pattern, names: names}
+			byKey[key] = m
+			r.requests = append(r.requests, m)
+		}
+		m.interactions = append(m.interactions, in)
+	}
+	return r, nil
+}
+
+// urlPattern turns a templated URL into a regular expression, the variables match one path segment or query value
+func urlPattern(templated string) (*regexp.Regexp, []string, error) {
+	var (
+		pattern strings.Builder
+		names   []string
+		last    int
+	)
+	pattern.WriteString("^")
+	for _, loc := range variablePattern.FindAllStringSubmatchIndex(templated, -1) {
+		pattern.WriteString(regexp.QuoteMeta(templated[last:loc[0]]))
+		names = append(names, templated[loc[2]:loc[3]])
+		pattern.WriteString(`([^/?&=]+)`)
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(templated[last:]))
+	pattern.WriteString("$")
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid recorded request %s: %w", templated, err)
+	}
+	return re, names, nil
+}
+
+// serve writes the response recorded for the request, it returns false if there is none
+func (r *replay) serve(w http.ResponseWriter, req *http.Request, apiBaseURL string) bool {
+	r.mu.Lock()
+	target := req.Method + " " + requestURL(req.URL)
+	var (
+		in    *Interaction
+		bound map[string]string
+	)
+	for _, m := range r.requests {
+		if !strings.HasPrefix(m.key, req.Method+" ") {
+			continue
+		}
+		match := m.pattern.FindStringSubmatch(strings.TrimPrefix(target, req.Method+" "))
+		if match == nil {
+			continue
+		}
+		bound = make(map[string]string)
+		for i, name := range m.names {
+			bound[name] = match[i+1]
+		}
+		if !r.consistent(bound) {
+			continue
+		}
+		n := r.next[m.key]
+		in = m.interactions[min(n, len(m.interactions)-1)]
+		r.next[m.key] = n + 1
+		break
+	}
+	if in == nil {
+		r.mu.Unlock()
+		return false
+	}
+	for name, value := range bound {
+		r.variables[name] = value
+	}
+	data := map[string]string{"BaseURL": apiBaseURL}
+	for name, value := range r.cassette.Variables {
+		data[name] = value
+	}
+	for name, value := range r.variables {
+		data[name] = value
+	}
+	r.mu.Unlock()
+
+	body, err := r.render(in.Response.Body, data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	if in.Response.ContentType != "" {
+		w.Header().Set("Content-Type", in.Response.ContentType)
+	}
+	w.WriteHeader(in.Response.Status)
+	w.Write(body)
+	return true
+}
+
+// consistent reports whether the variables bound by a request agree with the ones bound before, r.mu must be held
+func (r *replay) consistent(bound map[string]string) bool {
+	for name, value := range bound {
+		if known, ok := r.variables[name]; ok && known != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *replay) render(text string, data map[string]string) ([]byte, error) {
+	tmpl, err := template.New("response").Option("missingkey=error").Funcs(template.FuncMap{
+		"at": func(offset string) (string, error) {
+			d, err := time.ParseDuration(offset)
+			if err != nil {
+				return "", err
+			}
+			return r.start.Add(d).UTC().Format(time.RFC3339Nano), nil
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render recorded response: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ReplayCassette makes the server answer requests with the responses of the cassette, timestamps are
+// rendered relative to start. Requests the cassette has no response for are served from the state of
+// the mock as before. A nil cassette ends the replay.
+func (s *MockElsaAPIServer) ReplayCassette(c *Cassette, start time.Time) error {
+	var r *replay
+	if c != nil {
+		var err error
+		if r, err = newReplay(c, start); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replay = r
+	return nil
+}
@@ -0,0 +1,130 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordFlow records polls of TX1 before it exists, after the client copy and after the acceptance of T2S
+func recordFlow(t *testing.T) *Cassette {
+	s, _ := newFlowServer(t)
+	elsa := httptest.NewServer(s)
+	defer elsa.Close()
+	s.SetAPIBaseURL(elsa.URL)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	proxy, err := NewRecordingProxy(elsa.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	get(t, srv.URL+"/instructions/TX1")
+	for _, m := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+		get(t, srv.URL+"/instructions/TX1")
+	}
+	get(t, srv.URL+"/instructions?txID=TX1")
+
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRecordingProxy(t *testing.T) {
+	c := recordFlow(t)
+	if len(c.Interactions) != 4 {
+		t.Fatalf("got %d interactions, want 4", len(c.Interactions))
+	}
+	if c.Variables["TXID"] != "TX1" {
+		t.Errorf("variables %v, want TXID TX1", c.Variables)
+	}
+	for i, want := range []string{"/instructions/{{.TXID}}", "/instructions/{{.TXID}}", "/instructions/{{.TXID}}", "/instructions?txID={{.TXID}}"} {
+		if got := c.Interactions[i].Request.URL; got != want {
+			t.Errorf("interaction %d: URL %s, want %s", i, got, want)
+		}
+	}
+	body := c.Interactions[2].Response.Body
+	for _, want := range []string{`"clientTxID":"{{.TXID}}"`, `{{.BaseURL}}/messages/id/mock-{{.TXID}}-created`, `"timestamp":"{{at `} {
+		if !strings.Contains(body, want) {
+			t.Errorf("recorded body does not contain %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "TX1") || strings.Contains(body, "127.0.0.1") {
+		t.Errorf("recorded body has untemplated values: %s", body)
+	}
+}
+
+func TestReplayCassette(t *testing.T) {
+	c := recordFlow(t)
+	s := NewMockElsaAPIServer()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	s.SetAPIBaseURL(srv.URL)
+	start := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := s.ReplayCassette(c, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// the polls of another TXID are answered in the recorded order, the last response is repeated
+	var statuses []string
+	for i := 0; i < 4; i++ {
+		resp, body := get(t, srv.URL+"/instructions/REPLAY1")
+		if resp.StatusCode != http.StatusOK {
+			statuses = append(statuses, "404")
+			continue
+		}
+		var inst instructionResource
+		if err := json.Unmarshal(body, &inst); err != nil {
+			t.Fatal(err)
+		}
+		if inst.ClientTxID != "REPLAY1" || !strings.HasPrefix(inst.Status[0].Message, srv.URL+"/messages/id/mock-REPLAY1-") {
+			t.Errorf("values of the replay not substituted: %s", body)
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, inst.Status[0].Timestamp); err != nil || ts.Before(start) || ts.After(start.Add(time.Minute)) {
+			t.Errorf("timestamp %s is not relative to the start of the replay", inst.Status[0].Timestamp)
+		}
+		statuses = append(statuses, inst.Status[0].Name)
+	}
+	if got, want := strings.Join(statuses, ","), "404,created,sent_to_creation,sent_to_creation"; got != want {
+		t.Errorf("replayed statuses %s, want %s", got, want)
+	}
+
+	// the query is matched with the bound TXID, others are served by the mock itself
+	if resp, body := get(t, srv.URL+"/instructions?txID=REPLAY1"); resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"clientTxID":"REPLAY1"`) {
+		t.Errorf("list: got %d %s", resp.StatusCode, body)
+	}
+	if resp, body := get(t, srv.URL+"/instructions?txID=OTHER1"); resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"total":0`) {
+		t.Errorf("unrecorded request: got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestTemplater(t *testing.T) {
+	recorded := time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC)
+	tm := &templater{recorded: recorded, baseURL: "http://elsa:8080", variables: map[string]string{"TXID": "TX1", "TXID_2": "TX10"}}
+	got := tm.apply(`{"a":"TX1","b":"TX10","c":"miti-TX1","d":"TX1 TX1","t":"2025-04-15T10:00:01.5Z","u":"http://elsa:8080/x","e":"{{x}}"}`)
+	want := `{"a":"{{.TXID}}","b":"{{.TXID_2}}","c":"miti-{{.TXID}}","d":"{{.TXID}} {{.TXID}}","t":"{{at "1.5s"}}","u":"{{.BaseURL}}/x","e":"{{"{{"}}x}}"}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	r := &replay{start: recorded.Add(time.Hour)}
+	out, err := r.render(got, map[string]string{"TXID": "A1", "TXID_2": "A10", "BaseURL": "http://mock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":"A1","b":"A10","c":"miti-A1","d":"A1 A1","t":"2025-04-15T11:00:01.5Z","u":"http://mock/x","e":"{{x}}"}`; string(out) != want {
+		t.Errorf("got  %s\nwant %s", out, want)
+	}
+}
@@ -0,0 +1,228 @@
+package mock_elsa_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"test-tool/queue"
+	"time"
+)
+
+// controlPrefix is the path prefix of the control API of the mock, it is not part of the ELSA API
+const controlPrefix = "/_mock"
+
+// newControlMux returns the handler of the control API:
+//
+//	GET    /_mock/state                 dump of the instructions, pending transitions and fault rules
+//	POST   /_mock/reset                 clear the state, a running business flow keeps running
+//	GET    /_mock/instructions          the instructions in order of creation
+//	GET    /_mock/instructions/{txID}   an instruction by client TXID
+//	PUT    /_mock/instructions/{txID}   create or update it, e.g. {"isin": "DE0001", "statuses": ["created"]}
+//	DELETE /_mock/instructions/{txID}   delete it
+//	GET    /_mock/transitions           the pending transitions
+//	POST   /_mock/transitions           schedule one, e.g. {"txID": "TX1", "status": "settled", "after": "30s"}
+//	DELETE /_mock/transitions/{id}      cancel one
+//	GET    /_mock/faults             the fault rules of the API
+//	POST   /_mock/faults             add a rule, e.g. {"txID": "TX1", "status": 500, "times": 2}
+//	DELETE /_mock/faults             remove all rules of the API and the queues
+//	DELETE /_mock/faults/{id}        remove a rule
+//	GET    /_mock/queue-faults       the fault rules of the queues
+//	POST   /_mock/queue-faults       add a rule, e.g. {"kind": "duplicate", "correlationId": "TX1"}
+//	DELETE /_mock/queue-faults/{id}  remove a rule
+//	GET    /_mock/clock              the time of the mock
+//	PUT    /_mock/clock              control it, e.g. {"now": "2026-03-02T09:00:00Z"}, the current time if empty
+//	POST   /_mock/clock/advance      advance the controlled clock, e.g. {"by": "30m"}
+//	GET    /_mock/retry              the retry policy
+//	PUT    /_mock/retry              set it, e.g. {"timeout": "30m", "maxRetries": 3}
+func (s *MockElsaAPIServer) newControlMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+controlPrefix+"/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Dump())
+	})
+	mux.HandleFunc("POST "+controlPrefix+"/reset", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		queues, flow := s.flowQueues, s.watcher != nil
+		s.mu.RUnlock()
+		s.ResetState()
+		if flow {
+			if err := s.StartFlow(queues); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to restart the business flow: "+err.Error())
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/instructions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Dump().Instructions)
+	})
+	mux.HandleFunc("GET "+controlPrefix+"/instructions/{txID}", func(w http.ResponseWriter, r *http.Request) {
+		state, ok := s.Instruction(r.PathValue("txID"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "Instruction not found", "txID", r.PathValue("txID"))
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	})
+	mux.HandleFunc("PUT "+controlPrefix+"/instructions/{txID}", func(w http.ResponseWriter, r *http.Request) {
+		var u InstructionUpdate
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid instruction: "+err.Error())
+			return
+		}
+		state, created := s.PutInstruction(r.PathValue("txID"), u)
+		if created {
+			writeJSON(w, http.StatusCreated, state)
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	})
+	mux.HandleFunc("DELETE "+controlPrefix+"/instructions/{txID}", func(w http.ResponseWriter, r *http.Request) {
+		if !s.DeleteInstruction(r.PathValue("txID")) {
+			writeError(w, http.StatusNotFound, "Instruction not found", "txID", r.PathValue("txID"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/transitions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Scheduled())
+	})
+	mux.HandleFunc("POST "+controlPrefix+"/transitions", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TXID   string `json:"txID"`
+			Status string `json:"status"`
+			After  string `json:"after"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid transition: "+err.Error())
+			return
+		}
+		var after time.Duration
+		if req.After != "" {
+			d, err := time.ParseDuration(req.After)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid delay %q of transition", req.After))
+				return
+			}
+			after = d
+		}
+		scheduled, err := s.ScheduleStatus(req.TXID, req.Status, after)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, scheduled)
+	})
+	mux.HandleFunc("DELETE "+controlPrefix+"/transitions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !s.CancelScheduled(r.PathValue("id")) {
+			writeError(w, http.StatusNotFound, "Transition not found", "id", r.PathValue("id"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/faults", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Faults())
+	})
+	mux.HandleFunc("POST "+controlPrefix+"/faults", func(w http.ResponseWriter, r *http.Request) {
+		var fault Fault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid fault: "+err.Error())
+			return
+		}
+		added, err := s.AddFault(fault)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, added)
+	})
+	mux.HandleFunc("DELETE "+controlPrefix+"/faults", func(w http.ResponseWriter, r *http.Request) {
+		s.ClearFaults()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE "+controlPrefix+"/faults/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !s.RemoveFault(r.PathValue("id")) {
+			writeError(w, http.StatusNotFound, "Fault not found", "id", r.PathValue("id"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/queue-faults", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.queueFaults.List())
+	})
+	mux.HandleFunc("POST "+controlPrefix+"/queue-faults", func(w http.ResponseWriter, r *http.Request) {
+		var fault queue.Fault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid queue fault: "+err.Error())
+			return
+		}
+		added, err := s.queueFaults.Add(fault)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, added)
+	})
+	mux.HandleFunc("DELETE "+controlPrefix+"/queue-faults/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !s.queueFaults.Remove(r.PathValue("id")) {
+			writeError(w, http.StatusNotFound, "Queue fault not found", "id", r.PathValue("id"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/clock", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Clock())
+	})
+	mux.HandleFunc("PUT "+controlPrefix+"/clock", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Now time.Time `json:"now"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "Invalid clock: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ControlClock(req.Now))
+	})
+	mux.HandleFunc("POST "+controlPrefix+"/clock/advance", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			By string `json:"by"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid advance: "+err.Error())
+			return
+		}
+		d, err := time.ParseDuration(req.By)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid duration %q to advance the clock by", req.By))
+			return
+		}
+		state, err := s.AdvanceClock(d)
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	})
+
+	mux.HandleFunc("GET "+controlPrefix+"/retry", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.RetryPolicy())
+	})
+	mux.HandleFunc("PUT "+controlPrefix+"/retry", func(w http.ResponseWriter, r *http.Request) {
+		var p RetryPolicy
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid retry policy: "+err.Error())
+			return
+		}
+		if err := s.SetRetryPolicy(p); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+	return mux
+}
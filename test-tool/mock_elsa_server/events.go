@@ -0,0 +1,96 @@
+package mock_elsa_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// eventBuffer is the number of events a slow subscriber may lag behind before events are dropped,
+// subscribers only use the events as trigger to read the instruction again.
+const eventBuffer = 16
+
+// StatusEvent is pushed to the subscribers of GET /events when an instruction gets a new status
+type StatusEvent struct {
+	TXID      string `json:"txID"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Subscribe returns a channel receiving the status events of all instructions. The channel is
+// closed by the returned cancel function or CloseEvents.
+func (s *MockElsaAPIServer) Subscribe() (<-chan StatusEvent, func()) {
+	ch := make(chan StatusEvent, eventBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// CloseEvents ends all subscriptions, e.g. before the HTTP server is shut down
+func (s *MockElsaAPIServer) CloseEvents() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the event to all subscribers without blocking, s.mu must be held
+func (s *MockElsaAPIServer) publish(event StatusEvent) {
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// serveEvents streams the status events as server-sent events, optionally filtered by ?txID=
+func (s *MockElsaAPIServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error": "Streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+	txID := r.URL.Query().Get("txID")
+	events, cancel := s.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if txID != "" && event.TXID != txID {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package mock_elsa_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"test-tool/queue"
+	"time"
+)
+
+// Fault is a rule making the mock answer API requests like a failing ELSA: slowly, with an error
+// status or with a broken body. A request matches if it matches all fields which are set.
+type Fault struct {
+	ID        string        `json:"id,omitempty"`        // assigned by AddFault
+	TXID      string        `json:"txID,omitempty"`      // client or market infrastructure TXID of the requested instruction
+	Path      string        `json:"path,omitempty"`      // pattern of the request path as for path.Match, e.g. /instructions/*
+	Latency   time.Duration `json:"-"`                   // the response is delayed, "latency" in JSON, e.g. "2s"
+	Status    int           `json:"status,omitempty"`    // error status returned instead of the response
+	Malformed bool          `json:"malformed,omitempty"` // the body of the response is cut off, not for the event stream
+	Times     int           `json:"times,omitempty"`     // number of requests the fault applies to, all if 0
+}
+
+// MarshalJSON writes the latency as duration string like "1.5s"
+func (f Fault) MarshalJSON() ([]byte, error) {
+	type plain Fault // without the methods, no recursion
+	out := struct {
+		plain
+		Latency string `json:"latency,omitempty"`
+	}{plain: plain(f)}
+	if f.Latency > 0 {
+		out.Latency = f.Latency.String()
+	}
+	return json.Marshal(out)
+}
+
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	type plain Fault
+	in := struct {
+		*plain
+		Latency string `json:"latency,omitempty"`
+	}{plain: (*plain)(f)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Latency != "" {
+		d, err := time.ParseDuration(in.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid latency of fault: %w", err)
+		}
+		f.Latency = d
+	}
+	return nil
+}
+
+func (f *Fault) matches(requestPath string, txIDs []string) bool {
+	if f.Path != "" {
+		if ok, _ := path.Match(f.Path, requestPath); !ok {
+			return false
+		}
+	}
+	if f.TXID == "" {
+		return true
+	}
+	for _, txID := range txIDs {
+		if txID == f.TXID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFault adds the rule and returns it with its ID. The first matching rule applies to a request,
+// rules with Times are removed once they have been applied that often.
+func (s *MockElsaAPIServer) AddFault(fault Fault) (Fault, error) {
+	if fault.Latency <= 0 && fault.Status == 0 && !fault.Malformed {
+		return fault, fmt.Errorf("fault needs a latency, a status or malformed responses")
+	}
+	if fault.Status != 0 && (fault.Status < 100 || fault.Status > 599) {
+		return fault, fmt.Errorf("invalid status %d of fault", fault.Status)
+	}
+	if _, err := path.Match(fault.Path, ""); err != nil {
+		return fault, fmt.Errorf("invalid path pattern %q of fault: %w", fault.Path, err)
+	}
+	if fault.Times < 0 {
+		return fault, fmt.Errorf("times of fault must not be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFaultID++
+	fault.ID = strconv.Itoa(s.lastFaultID)
+	s.faults = append(s.faults, &fault)
+	return fault, nil
+}
+
+// RemoveFault removes the rule with the ID, it reports whether there was one
+func (s *MockElsaAPIServer) RemoveFault(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, fault := range s.faults {
+		if fault.ID == id {
+			s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearFaults removes all rules of the API and the queues
+func (s *MockElsaAPIServer) ClearFaults() {
+	s.mu.Lock()
+	s.faults = nil
+	s.mu.Unlock()
+	s.queueFaults.Clear()
+}
+
+// Faults returns the rules of the API in the order they are checked
+func (s *MockElsaAPIServer) Faults() []Fault {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]Fault, len(s.faults))
+	for i, fault := range s.faults {
+		res[i] = *fault
+	}
+	return res
+}
+
+// QueueFaults returns the fault rules of the queues, they apply to the queues wrapped by them (see
+// queue.Faults.Wrap) and are managed by the control API like the faults of the API.
+func (s *MockElsaAPIServer) QueueFaults() *queue.Faults {
+	return s.queueFaults
+}
+
+// takeFault returns the rule applying to the request and counts it down, nil if there is none
+func (s *MockElsaAPIServer) takeFault(r *http.Request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.faults) == 0 {
+		return nil
+	}
+	txIDs := s.requestTXIDs(r)
+	for i, fault := range s.faults {
+		if !fault.matches(r.URL.Path, txIDs) {
+			continue
+		}
+		applied := *fault
+		if fault.Times > 0 {
+			if fault.Times--; fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return &applied
+	}
+	return nil
+}
+
+// requestTXIDs returns the TXIDs in path and query of the request and both TXIDs of the instruction
+// they refer to, also if it is requested by its internal or collection ID. s.mu must be held.
+func (s *MockElsaAPIServer) requestTXIDs(r *http.Request) []string {
+	var txIDs []string
+	if txID := r.URL.Query().Get("txID"); txID != "" {
+		txIDs = append(txIDs, txID)
+	}
+	op, params, err := apiSpec.Route(r.Method, r.URL.Path)
+	if err != nil {
+		return txIDs
+	}
+	id := ""
+	switch op.OperationID {
+	case "getInstruction":
+		txIDs = append(txIDs, params["txID"])
+	case "getInstructionById", "getInstructionAudit":
+		id = params["id"]
+	case "getCollection":
+		id = strings.TrimPrefix(params["id"], collectionIDPrefix)
+	}
+	for _, state := range s.instructions {
+		for _, txID := range txIDs {
+			if txID == state.TXID || txID == state.MitiTXID {
+				id = state.ID
+			}
+		}
+		if id != "" && state.ID == id {
+			return append(txIDs, state.TXID, state.MitiTXID)
+		}
+	}
+	return txIDs
+}
+
+// serveFault answers the request as the fault makes it
+func (s *MockElsaAPIServer) serveFault(w http.ResponseWriter, r *http.Request, fault *Fault) {
+	s.logf("Fault %s applied to %s %s", fault.ID, r.Method, r.URL.Path)
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch {
+	case fault.Status != 0:
+		writeError(w, fault.Status, "Injected fault", "fault", fault.ID)
+	case fault.Malformed && r.URL.Path != "/events":
+		buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		s.serveAPI(buf, r)
+		body := buf.body.Bytes()
+		w.WriteHeader(buf.status)
+		w.Write(body[:len(body)/2])
+	default:
+		s.serveAPI(w, r)
+	}
+}
+
+// bufferedResponse keeps the response of a handler, so it can be changed before it is sent
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
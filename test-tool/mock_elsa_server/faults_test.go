@@ -0,0 +1,100 @@
+package mock_elsa_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	srv := newAPIServer(t)
+	s := srv.Config.Handler.(*MockElsaAPIServer)
+	add := func(fault Fault) {
+		if _, err := s.AddFault(fault); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the rule of TX1 also applies to its internal ID and market infrastructure TXID, TX2 is not affected
+	add(Fault{TXID: "TX1", Status: http.StatusServiceUnavailable, Times: 3})
+	for _, path := range []string{"/instructions/TX1", "/instructions/id/1/audit", "/instructions?txID=MITI1", "/instructions/TX2"} {
+		resp, body := get(t, srv.URL+path)
+		want := http.StatusServiceUnavailable
+		if strings.HasSuffix(path, "TX2") {
+			want = http.StatusOK
+		}
+		if resp.StatusCode != want {
+			t.Errorf("GET %s: got %d, want %d: %s", path, resp.StatusCode, want, body)
+		}
+	}
+	if resp, _ := get(t, srv.URL+"/instructions/TX1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("fault applied more than 3 times: got %d", resp.StatusCode)
+	}
+
+	add(Fault{Path: "/instructions/*", Malformed: true, Times: 1})
+	_, body := get(t, srv.URL+"/instructions/TX2")
+	if json.Valid(body) || !strings.HasPrefix(string(body), `{"id":"2"`) {
+		t.Errorf("malformed: got %s", body)
+	}
+
+	add(Fault{Path: "/collections", Latency: 200 * time.Millisecond, Times: 1})
+	start := time.Now()
+	if resp, _ := get(t, srv.URL+"/collections"); resp.StatusCode != http.StatusOK || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("latency: got %d after %s", resp.StatusCode, time.Since(start))
+	}
+	if faults := s.Faults(); len(faults) != 0 {
+		t.Errorf("faults not removed after being applied: %+v", faults)
+	}
+
+	for _, fault := range []Fault{{Path: "/x"}, {Status: 42}, {Path: "[", Status: 500}} {
+		if _, err := s.AddFault(fault); err == nil {
+			t.Errorf("invalid fault %+v accepted", fault)
+		}
+	}
+}
+
+func TestControlAPI(t *testing.T) {
+	srv := newAPIServer(t)
+	s := srv.Config.Handler.(*MockElsaAPIServer)
+	do := func(method, path, body string) (*http.Response, string) {
+		resp, b := request(t, method, srv.URL+path, body)
+		return resp, string(b)
+	}
+
+	resp, body := do(http.MethodPost, "/_mock/faults", `{"txID": "TX2", "latency": "10ms", "status": 500}`)
+	var fault Fault
+	if err := json.Unmarshal([]byte(body), &fault); resp.StatusCode != http.StatusCreated || err != nil || fault.Latency != 10*time.Millisecond {
+		t.Fatalf("add fault: got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := get(t, srv.URL+"/instructions/TX2"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("fault added by the control API not applied: got %d", resp.StatusCode)
+	}
+	if _, body := do(http.MethodGet, "/_mock/faults", ""); !strings.Contains(body, `"latency":"10ms"`) {
+		t.Errorf("list faults: got %s", body)
+	}
+	if resp, _ := do(http.MethodDelete, "/_mock/faults/"+fault.ID, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("remove fault: got %d", resp.StatusCode)
+	}
+	if resp, _ := do(http.MethodDelete, "/_mock/faults/"+fault.ID, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("remove removed fault: got %d", resp.StatusCode)
+	}
+	if resp, body := do(http.MethodPost, "/_mock/faults", `{"txID": "TX2"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("add fault without effect: got %d %s", resp.StatusCode, body)
+	}
+
+	if resp, body := do(http.MethodPost, "/_mock/queue-faults", `{"kind": "delay", "delay": "1s", "correlationId": "TX1"}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("add queue fault: got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := do(http.MethodPost, "/_mock/queue-faults", `{"kind": "explode"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("add unknown queue fault: got %d", resp.StatusCode)
+	}
+	if faults := s.QueueFaults().List(); len(faults) != 1 || faults[0].Delay != time.Second {
+		t.Errorf("queue faults: got %+v", faults)
+	}
+	do(http.MethodDelete, "/_mock/faults", "")
+	if len(s.QueueFaults().List()) != 0 {
+		t.Error("queue faults not cleared")
+	}
+}
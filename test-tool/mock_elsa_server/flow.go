@@ -0,0 +1,241 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"test-tool/queue"
+	"text/template"
+)
+
+// Party is the primary system a message is exchanged with
+type Party string
+
+const (
+	PartyT2S      Party = "T2S"
+	PartyCreation Party = "CREATION"
+)
+
+// Instruction statuses set by the state machine, in the order of the business flow (see requirement.md)
+const (
+	StatusCreated             = "created"                // client copy received from T2S
+	StatusAcceptedByT2S       = "accepted_by_t2s"        // T2S accepted the client copy
+	StatusRejectedByT2S       = "rejected_by_t2s"        // T2S rejected the client copy, end of flow
+	StatusSentToCreation      = "sent_to_creation"       // request sent to CREATION on behalf of the client
+	StatusAcceptedByCreation  = "accepted_by_creation"   // CREATION accepted the request
+	StatusRejectedByCreation  = "rejected_by_creation"   // CREATION rejected the request
+	StatusCancelled           = "cancelled"              // cancellation sent to T2S, end of flow
+	StatusMatchingSentToT2S   = "matching_sent_to_t2s"   // status and matching message sent to T2S
+	StatusMatched             = "matched"                // MATCH received from T2S
+	StatusMatchSentToCreation = "match_sent_to_creation" // MATCH forwarded to CREATION
+	StatusSettledByCreation   = "settled_by_creation"    // CREATION confirmed the settlement
+	StatusReleased            = "released"               // release sent to T2S
+	StatusSettled             = "settled"                // settled copy received from T2S, end of flow
+	StatusTimedOut            = "timed_out"              // no answer to the messages of a status after the retries, end of flow
+)
+
+// outboundMessage is a message ELSA sends as reaction to an inbound one
+type outboundMessage struct {
+	to       Party
+	name     string // file name suffix and template name
+	template *template.Template
+}
+
+// transition moves an instruction from status 'from' when 'kind' is received from 'party'.
+// All 'statuses' are appended to the history in order and the 'outbound' messages are sent.
+type transition struct {
+	from     string
+	party    Party
+	kind     MessageKind
+	statuses []string
+	outbound []outboundMessage
+}
+
+var transitions = []transition{
+	{"", PartyT2S, KindClientCopy, []string{StatusCreated}, nil},
+	{StatusCreated, PartyT2S, KindAccepted, []string{StatusAcceptedByT2S, StatusSentToCreation},
+		[]outboundMessage{{PartyCreation, "creation_request", creationRequestTmpl}}},
+	{StatusCreated, PartyT2S, KindRejected, []string{StatusRejectedByT2S}, nil},
+	{StatusSentToCreation, PartyCreation, KindAccepted, []string{StatusAcceptedByCreation, StatusMatchingSentToT2S},
+		[]outboundMessage{{PartyT2S, "status", t2sStatusTmpl}, {PartyT2S, "matching", t2sMatchingTmpl}}},
+	{StatusSentToCreation, PartyCreation, KindRejected, []string{StatusRejectedByCreation, StatusCancelled},
+		[]outboundMessage{{PartyT2S, "cancellation", t2sCancellationTmpl}}},
+	{StatusMatchingSentToT2S, PartyT2S, KindMatched, []string{StatusMatched, StatusMatchSentToCreation},
+		[]outboundMessage{{PartyCreation, "match", creationMatchTmpl}}},
+	{StatusMatchSentToCreation, PartyCreation, KindSettled, []string{StatusSettledByCreation, StatusReleased},
+		[]outboundMessage{{PartyT2S, "release", t2sReleaseTmpl}}},
+	{StatusReleased, PartyT2S, KindSettled, []string{StatusSettled}, nil},
+}
+
+// FlowQueues holds the queues the state machine reads from and writes to
+type FlowQueues struct {
+	T2SInbound       []queue.Queue // T2S -> ELSA, client copies and T2S status messages
+	CreationInbound  queue.Queue   // CREATION -> ELSA
+	T2SOutbound      queue.Queue   // ELSA -> T2S
+	CreationOutbound queue.Queue   // ELSA -> CREATION
+}
+
+// ProcessMessage applies a message received from party to the instruction it refers to.
+// The statuses and instruction fields are derived from the message content, the reaction of
+// ELSA is written to the outbound queue of the addressed party. The instruction is updated before the
+// reaction is sent, the sending happens without holding the lock of the mock so slow queues do not block
+// its API. A reaction which cannot be sent is resent by the ReTry service, if a RetryPolicy is set.
+func (s *MockElsaAPIServer) ProcessMessage(ctx context.Context, party Party, data []byte) error {
+	msg, err := ParseMessage(data)
+	if err != nil {
+		return err
+	}
+	deliveries, err := s.apply(party, msg, data)
+	if err != nil {
+		return err
+	}
+	if err := s.deliver(ctx, deliveries); err != nil {
+		return fmt.Errorf("instruction %s: %w", msg.TXID, err)
+	}
+	return nil
+}
+
+// apply moves the instruction of the message on and returns the rendered reaction of ELSA.
+// The instruction is left unchanged if the reaction cannot be rendered.
+func (s *MockElsaAPIServer) apply(party Party, msg *ParsedMessage, data []byte) ([]*delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.instructions[msg.TXID]
+	current := ""
+	if state != nil && len(state.StatusHistory) > 0 {
+		current = state.StatusHistory[0].Name
+	}
+
+	ti := findTransition(current, party, msg.Kind)
+	if ti < 0 {
+		return nil, fmt.Errorf("instruction %s: unexpected %s message from %s in status %q", msg.TXID, msg.Kind, party, current)
+	}
+	t := &transitions[ti]
+
+	// The messages are rendered from an updated copy of the instruction, it is only taken over when all
+	// messages were rendered
+	updated := s.nextInstruction(msg.TXID)
+	if state != nil {
+		copied := *state
+		updated = &copied
+	}
+	updated.update(msg)
+
+	// The first status is linked to the received message, the following ones to the last message ELSA sends
+	message := data
+	var deliveries []*delivery
+	for _, out := range t.outbound {
+		d, err := s.render(updated, out)
+		if err != nil {
+			return nil, fmt.Errorf("instruction %s: %w", msg.TXID, err)
+		}
+		deliveries = append(deliveries, d)
+		message = d.msg.Body
+	}
+	if state == nil {
+		state = s.addInstruction(updated)
+	} else {
+		*state = *updated
+	}
+	for i, status := range t.statuses {
+		if i == 0 {
+			s.appendStatus(state, status, data)
+		} else {
+			s.appendStatus(state, status, message)
+		}
+		if status == StatusCancelled {
+			state.CancellationRequested = true
+		}
+	}
+	s.awaitRetry(state, t)
+	s.coverage[ti]++
+	s.logf("%s %s message for %s moved it from %q to %q", party, msg.Kind, msg.TXID, current, state.StatusHistory[0].Name)
+	return deliveries, nil
+}
+
+// findTransition returns the index of the transition in the model, -1 if there is none
+func findTransition(from string, party Party, kind MessageKind) int {
+	for i, t := range transitions {
+		if t.from == from && t.party == party && t.kind == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// update takes over the fields carried by the message, values not present in it are kept
+func (st *InstructionState) update(msg *ParsedMessage) {
+	if msg.MitiTXID != "" {
+		st.MitiTXID = msg.MitiTXID
+	}
+	if msg.InstructingParty != "" {
+		st.InstructingParty = msg.InstructingParty
+	}
+	if msg.MovementType != "" {
+		st.MovementType = msg.MovementType
+	}
+	if msg.PaymentType != "" {
+		st.PaymentType = msg.PaymentType
+	}
+	if msg.ISIN != "" {
+		st.ISIN = msg.ISIN
+	}
+	if msg.Quantity != "" {
+		st.Quantity = msg.Quantity
+	}
+	if msg.SettlementDate != "" {
+		st.SettlementDate = msg.SettlementDate
+	}
+	if msg.SafekeepingAccount != "" {
+		st.SafekeepingAccount = msg.SafekeepingAccount
+	}
+}
+
+// delivery is a rendered outbound message together with the queue it is sent to
+type delivery struct {
+	out   outboundMessage
+	queue queue.Queue
+	msg   *queue.Message
+}
+
+// render renders the outbound message for the queue of the addressed party, the message is correlated
+// by the client TXID. s.mu must be held.
+func (s *MockElsaAPIServer) render(state *InstructionState, out outboundMessage) (*delivery, error) {
+	q := s.flowQueues.T2SOutbound
+	if out.to == PartyCreation {
+		q = s.flowQueues.CreationOutbound
+	}
+	if q == nil {
+		return nil, fmt.Errorf("no outbound queue configured for %s", out.to)
+	}
+
+	var buf strings.Builder
+	if err := out.template.Execute(&buf, state); err != nil {
+		return nil, fmt.Errorf("failed to render %s message: %w", out.name, err)
+	}
+	return &delivery{
+		out:   out,
+		queue: q,
+		msg: &queue.Message{
+			ID:            fmt.Sprintf("%s_%s", state.TXID, out.name),
+			CorrelationID: state.TXID,
+			Body:          []byte(buf.String()),
+		},
+	}, nil
+}
+
+// deliver puts the rendered messages to their queues, s.mu must not be held. All messages are tried,
+// the errors of those which could not be sent are returned.
+func (s *MockElsaAPIServer) deliver(ctx context.Context, deliveries []*delivery) error {
+	var errs []error
+	for _, d := range deliveries {
+		if err := d.queue.Put(ctx, d.msg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send %s message: %w", d.out.name, err))
+			continue
+		}
+		s.logf("ELSA sent %s message to %s: %s", d.out.name, d.out.to, d.queue.Name())
+	}
+	return errors.Join(errs...)
+}
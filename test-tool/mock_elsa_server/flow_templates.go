@@ -0,0 +1,130 @@
+package mock_elsa_server
+
+import (
+	"text/template"
+	"time"
+)
+
+// Messages ELSA sends in the business flow. They are valid against the ISO schemas but carry only
+// the mandatory elements, the data is taken from the InstructionState. Details the client copy did not
+// provide are filled with placeholders.
+
+var templateFuncs = template.FuncMap{
+	"today": func() string { return time.Now().Format("2006-01-02") },
+}
+
+func newTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(templateFuncs).Parse(text))
+}
+
+// instructionBody is the body of a sese.023 for the instruction, below SctiesSttlmTxInstr/TxId
+const instructionBody = `
+		<SttlmTpAndAddtlParams>
+			<SctiesMvmntTp>{{or .MovementType "RECE"}}</SctiesMvmntTp>
+			<Pmt>{{or .PaymentType "APMT"}}</Pmt>
+		</SttlmTpAndAddtlParams>
+		<TradDtls>
+			<SttlmDt>
+				<Dt>
+					<Dt>{{or .SettlementDate today}}</Dt>
+				</Dt>
+			</SttlmDt>
+		</TradDtls>
+		<FinInstrmId>
+			<ISIN>{{or .ISIN "XS0000000000"}}</ISIN>
+		</FinInstrmId>
+		<QtyAndAcctDtls>
+			<SttlmQty>
+				<Qty>
+					<Unit>{{or .Quantity "1"}}</Unit>
+				</Qty>
+			</SttlmQty>
+			<SfkpgAcct>
+				<Id>{{or .SafekeepingAccount "MOCKACCT"}}</Id>
+			</SfkpgAcct>
+		</QtyAndAcctDtls>
+		<SttlmParams>
+			<SctiesTxTp>
+				<Cd>TRAD</Cd>
+			</SctiesTxTp>
+		</SttlmParams>`
+
+// creationRequestTmpl is the request ELSA sends to CREATION on behalf of the client (sese.023)
+var creationRequestTmpl = newTemplate("creation_request", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.023.001.10">
+	<SctiesSttlmTxInstr>
+		<TxId>{{.TXID}}</TxId>`+instructionBody+`
+	</SctiesSttlmTxInstr>
+</Document>
+`)
+
+// t2sStatusTmpl informs T2S (and the client) that CREATION accepted the request (sese.024)
+var t2sStatusTmpl = newTemplate("status", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10">
+	<SctiesSttlmTxStsAdvc>
+		<TxId>
+			<AcctOwnrTxId>{{.TXID}}</AcctOwnrTxId>
+			<MktInfrstrctrTxId>{{.MitiTXID}}</MktInfrstrctrTxId>
+		</TxId>
+		<PrcgSts>
+			<AckdAccptd>
+				<NoSpcfdRsn>NORE</NoSpcfdRsn>
+			</AckdAccptd>
+		</PrcgSts>
+	</SctiesSttlmTxStsAdvc>
+</Document>
+`)
+
+// t2sMatchingTmpl is the matching instruction ELSA sends to T2S (sese.023)
+var t2sMatchingTmpl = newTemplate("matching", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.023.001.10">
+	<SctiesSttlmTxInstr>
+		<TxId>{{.MitiTXID}}</TxId>`+instructionBody+`
+	</SctiesSttlmTxInstr>
+</Document>
+`)
+
+// t2sCancellationTmpl cancels the client copy in T2S after CREATION rejected the request (sese.020)
+var t2sCancellationTmpl = newTemplate("cancellation", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.020.001.06">
+	<SctiesTxCxlReq>
+		<AcctOwnrTxId>
+			<SctiesSttlmTxId>
+				<TxId>{{.TXID}}</TxId>
+				<SctiesMvmntTp>{{or .MovementType "RECE"}}</SctiesMvmntTp>
+				<Pmt>{{or .PaymentType "APMT"}}</Pmt>
+			</SctiesSttlmTxId>
+		</AcctOwnrTxId>
+		<MktInfrstrctrTxId>{{.MitiTXID}}</MktInfrstrctrTxId>
+		<SfkpgAcct>
+			<Id>{{or .SafekeepingAccount "MOCKACCT"}}</Id>
+		</SfkpgAcct>
+	</SctiesTxCxlReq>
+</Document>
+`)
+
+// creationMatchTmpl forwards the MATCH of T2S to CREATION (sese.024)
+var creationMatchTmpl = newTemplate("match", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10">
+	<SctiesSttlmTxStsAdvc>
+		<TxId>
+			<AcctOwnrTxId>{{.TXID}}</AcctOwnrTxId>
+			<MktInfrstrctrTxId>{{.MitiTXID}}</MktInfrstrctrTxId>
+		</TxId>
+		<MtchgSts>
+			<Mtchd/>
+		</MtchgSts>
+	</SctiesSttlmTxStsAdvc>
+</Document>
+`)
+
+// t2sReleaseTmpl releases the client copy in T2S after CREATION settled (sese.030)
+var t2sReleaseTmpl = newTemplate("release", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.030.001.09">
+	<SctiesSttlmCondsModReq>
+		<ReqDtls>
+			<Ref>
+				<AcctOwnrTxId>{{.TXID}}</AcctOwnrTxId>
+				<MktInfrstrctrTxId>{{.MitiTXID}}</MktInfrstrctrTxId>
+			</Ref>
+			<HldInd>
+				<Ind>false</Ind>
+			</HldInd>
+		</ReqDtls>
+	</SctiesSttlmCondsModReq>
+</Document>
+`)
@@ -0,0 +1,390 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"test-tool/queue"
+	"testing"
+	"time"
+)
+
+const (
+	clientCopy = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.023.001.09">
+	<SctiesSttlmTxInstr>
+		<TxId>TX1</TxId>
+		<SttlmTpAndAddtlParams><SctiesMvmntTp>DELI</SctiesMvmntTp><Pmt>FREE</Pmt></SttlmTpAndAddtlParams>
+	</SctiesSttlmTxInstr>
+</Document>`
+	accepted = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10"><SctiesSttlmTxStsAdvc>
+	<TxId><AcctOwnrTxId>TX1</AcctOwnrTxId><MktInfrstrctrTxId>MITI1</MktInfrstrctrTxId></TxId>
+	<PrcgSts><AckdAccptd><NoSpcfdRsn>NORE</NoSpcfdRsn></AckdAccptd></PrcgSts>
+</SctiesSttlmTxStsAdvc></Document>`
+	rejected = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10"><SctiesSttlmTxStsAdvc>
+	<TxId><AcctOwnrTxId>TX1</AcctOwnrTxId></TxId>
+	<PrcgSts><Rjctd><Rsn><Cd><Cd>OTHR</Cd></Cd></Rsn></Rjctd></PrcgSts>
+</SctiesSttlmTxStsAdvc></Document>`
+	matched = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.024.001.10"><SctiesSttlmTxStsAdvc>
+	<TxId><AcctOwnrTxId>TX1</AcctOwnrTxId></TxId>
+	<MtchgSts><Mtchd><NoSpcfdRsn>NORE</NoSpcfdRsn></Mtchd></MtchgSts>
+</SctiesSttlmTxStsAdvc></Document>`
+	settled = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.025.001.09"><SctiesSttlmTxConf>
+	<TxIdDtls><AcctOwnrTxId>TX1</AcctOwnrTxId></TxIdDtls>
+</SctiesSttlmTxConf></Document>`
+)
+
+type step struct {
+	party    Party
+	message  string
+	status   string
+	outbound []string // messages expected in the outbound queues after the step, as '<queue>/<message ID>'
+}
+
+func newFlowServer(t *testing.T) (*MockElsaAPIServer, FlowQueues) {
+	b := queue.NewMemoryBroker()
+	open := func(name string) queue.Queue {
+		q, err := b.Queue(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	queues := FlowQueues{
+		T2SInbound:       []queue.Queue{open("receiver_t2s")},
+		CreationInbound:  open("receiver_creation"),
+		T2SOutbound:      open("sender_t2s"),
+		CreationOutbound: open("sender_creation"),
+	}
+	s := NewMockElsaAPIServer()
+	s.flowQueues = queues
+	return s, queues
+}
+
+// hasMessage reports whether the queue holds a message with the given ID
+func hasMessage(t *testing.T, q queue.Queue, id string) bool {
+	msgs, err := q.Browse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if msg.ID == id {
+			return msg.CorrelationID == "TX1"
+		}
+	}
+	return false
+}
+
+func TestProcessMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"settled", []step{
+			{PartyT2S, clientCopy, StatusCreated, nil},
+			{PartyT2S, accepted, StatusSentToCreation, []string{"sender_creation/TX1_creation_request"}},
+			{PartyCreation, accepted, StatusMatchingSentToT2S, []string{"sender_t2s/TX1_status", "sender_t2s/TX1_matching"}},
+			{PartyT2S, matched, StatusMatchSentToCreation, []string{"sender_creation/TX1_match"}},
+			{PartyCreation, settled, StatusReleased, []string{"sender_t2s/TX1_release"}},
+			{PartyT2S, settled, StatusSettled, nil},
+		}},
+		{"rejected by T2S", []step{
+			{PartyT2S, clientCopy, StatusCreated, nil},
+			{PartyT2S, rejected, StatusRejectedByT2S, nil},
+		}},
+		{"rejected by CREATION", []step{
+			{PartyT2S, clientCopy, StatusCreated, nil},
+			{PartyT2S, accepted, StatusSentToCreation, nil},
+			{PartyCreation, rejected, StatusCancelled, []string{"sender_t2s/TX1_cancellation"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, queues := newFlowServer(t)
+			outbound := map[string]queue.Queue{"sender_t2s": queues.T2SOutbound, "sender_creation": queues.CreationOutbound}
+			for i, st := range tt.steps {
+				if err := s.ProcessMessage(context.Background(), st.party, []byte(st.message)); err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if got := s.instructions["TX1"].StatusHistory[0].Name; got != st.status {
+					t.Errorf("step %d: status %q, want %q", i, got, st.status)
+				}
+				for _, out := range st.outbound {
+					name, id, _ := strings.Cut(out, "/")
+					if !hasMessage(t, outbound[name], id) {
+						t.Errorf("step %d: outbound message %s missing", i, out)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessMessageDerivesFields(t *testing.T) {
+	s, _ := newFlowServer(t)
+	for _, m := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := s.instructions["TX1"]
+	if state.MovementType != "DELI" || state.PaymentType != "FREE" || state.MitiTXID != "MITI1" {
+		t.Errorf("unexpected instruction fields %+v", state)
+	}
+}
+
+func TestParseSettlementConfirmationV09(t *testing.T) {
+	msg, err := ParseMessage([]byte(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.025.001.09"><SctiesSttlmTxConf>
+	<TxIdDtls><AcctOwnrTxId>TX1</AcctOwnrTxId><MktInfrstrctrTxId>MITI1</MktInfrstrctrTxId>
+	<SctiesMvmntTp>RECE</SctiesMvmntTp><Pmt>APMT</Pmt></TxIdDtls>
+</SctiesSttlmTxConf></Document>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Kind != KindSettled || msg.TXID != "TX1" || msg.MitiTXID != "MITI1" || msg.MovementType != "RECE" || msg.PaymentType != "APMT" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	data, err := os.ReadFile("../../elsa-xml/testdata/T2S/sese.023_t2s_ok.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ParseMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParsedMessage{Kind: KindClientCopy, MessageType: "sese.023.001.09", TXID: "SA0A2876F1MN2SSH", InstructingParty: "DAKVDEFFLIO",
+		MovementType: msg.MovementType, PaymentType: msg.PaymentType,
+		ISIN: "AT0000A28768", Quantity: "500", SettlementDate: "2020-12-16", SafekeepingAccount: "DAKV1099000"}
+	if *msg != want {
+		t.Errorf("client copy of T2S: got %+v", msg)
+	}
+
+	if msg, err := ParseMessage([]byte(rejected)); err != nil || msg.Kind != KindRejected || msg.ReasonCode != "OTHR" {
+		t.Errorf("rejection: got %+v, %v", msg, err)
+	}
+	if _, err := ParseMessage([]byte(`<Document><SctiesSttlmTxAllgmtNtfctn/></Document>`)); err == nil || !strings.Contains(err.Error(), "/Document/SctiesSttlmTxAllgmtNtfctn") {
+		t.Errorf("unsupported message: got %v", err)
+	}
+}
+
+func TestStatusMessagesAreServed(t *testing.T) {
+	s, _ := newFlowServer(t)
+	for _, m := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// newest first: sent_to_creation links the request to CREATION, accepted_by_t2s the acceptance of T2S
+	history := s.instructions["TX1"].StatusHistory
+	for i, want := range []string{"<TxId>TX1</TxId>", "<AckdAccptd>"} {
+		path := strings.TrimPrefix(history[i].Message, "http://localhost:8080")
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("%s: got %d %q, want message containing %s", history[i].Name, resp.StatusCode, body, want)
+		}
+	}
+
+	s.SetInstructionStatus("TX2", StatusCreated)
+	path := strings.TrimPrefix(s.instructions["TX2"].StatusHistory[0].Message, "http://localhost:8080")
+	if resp, err := http.Get(srv.URL + path); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status without message: got %v, %v, want 404", resp, err)
+	}
+}
+
+func TestProcessMessageOutOfSequence(t *testing.T) {
+	s, _ := newFlowServer(t)
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(matched)); err == nil {
+		t.Fatal("expected an error for a MATCH without client copy")
+	}
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(clientCopy)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ProcessMessage(context.Background(), PartyCreation, []byte(accepted)); err == nil {
+		t.Fatal("expected an error for a CREATION acceptance before the request was sent")
+	}
+}
+
+func TestProcessMessageSendFails(t *testing.T) {
+	s, queues := newFlowServer(t)
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(clientCopy)); err != nil {
+		t.Fatal(err)
+	}
+	s.flowQueues.CreationOutbound = nil
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(accepted)); err == nil {
+		t.Fatal("expected an error without queue to CREATION")
+	}
+	if state, _ := s.Instruction("TX1"); len(state.StatusHistory) != 1 || state.StatusHistory[0].Name != StatusCreated || state.MitiTXID != "miti-TX1" {
+		t.Errorf("instruction changed by the failed message: got %+v", state)
+	}
+
+	// the message is processed again once it can be sent
+	s.flowQueues = queues
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(accepted)); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := s.Instruction("TX1"); state.StatusHistory[0].Name != StatusSentToCreation || state.MitiTXID != "MITI1" {
+		t.Errorf("after the retry: got %+v", state)
+	}
+}
+
+// blockingQueue is a queue whose Put blocks until its context ends
+type blockingQueue struct {
+	queue.Queue
+	putting chan struct{}
+}
+
+func (q *blockingQueue) Put(ctx context.Context, msg *queue.Message) error {
+	close(q.putting)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestProcessMessageSendsWithoutLock(t *testing.T) {
+	s, queues := newFlowServer(t)
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(clientCopy)); err != nil {
+		t.Fatal(err)
+	}
+	blocking := &blockingQueue{Queue: queues.CreationOutbound, putting: make(chan struct{})}
+	s.flowQueues.CreationOutbound = blocking
+
+	ctx, cancel := context.WithCancel(context.Background())
+	processed := make(chan error)
+	go func() { processed <- s.ProcessMessage(ctx, PartyT2S, []byte(accepted)) }()
+	<-blocking.putting
+
+	// the instruction is updated and readable while the message is being sent
+	if state, _ := s.Instruction("TX1"); state.StatusHistory[0].Name != StatusSentToCreation {
+		t.Errorf("got status %s while sending", state.StatusHistory[0].Name)
+	}
+	cancel()
+	select {
+	case err := <-processed:
+		if err == nil || !strings.Contains(err.Error(), "failed to send creation_request message") {
+			t.Errorf("got %v, want the failed send", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the send was not cancelled")
+	}
+}
+
+func TestStartFlowWatchesInboundQueues(t *testing.T) {
+	s, queues := newFlowServer(t)
+	if err := s.StartFlow(queues); err != nil {
+		t.Fatal(err)
+	}
+	defer s.StopFlow()
+
+	msg := &queue.Message{CorrelationID: "TX1", Body: []byte(clientCopy)}
+	if err := queues.T2SInbound[0].Put(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		state := s.instructions["TX1"]
+		s.mu.RUnlock()
+		if state != nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("client copy was not picked up from the inbound queue")
+}
+
+func TestPaths(t *testing.T) {
+	var got []string
+	for _, p := range Paths() {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"timeout in created",
+		"timeout in sent_to_creation",
+		"timeout in matching_sent_to_t2s",
+		"timeout in match_sent_to_creation",
+		"timeout in released",
+		"settled",
+		"cancelled",
+		"rejected_by_t2s",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got paths\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestIsFinalStatus(t *testing.T) {
+	for status, want := range map[string]bool{
+		StatusSettled:             true,
+		StatusCancelled:           true,
+		StatusRejectedByT2S:       true,
+		StatusCreated:             false,
+		StatusRejectedByCreation:  false, // intermediate, followed by cancelled in the same transition
+		StatusMatchSentToCreation: false,
+		"unknown":                 false,
+	} {
+		if got := IsFinalStatus(status); got != want {
+			t.Errorf("IsFinalStatus(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestStatusEvents(t *testing.T) {
+	s, _ := newFlowServer(t)
+	events, cancel := s.Subscribe()
+	defer cancel()
+
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(clientCopy)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if e.TXID != "TX1" || e.Status != StatusCreated {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no status event")
+	}
+
+	s.CloseEvents()
+	if _, ok := <-events; ok {
+		t.Error("events not closed")
+	}
+	cancel() // closing twice must not panic
+}
+
+func TestCoverage(t *testing.T) {
+	s, _ := newFlowServer(t)
+	for _, m := range []string{clientCopy, rejected} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.ResetState()
+	if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(clientCopy)); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, c := range s.Coverage() {
+		counts[c.Transition.String()] = c.Count
+	}
+	if n := counts["(new) --T2S clientCopy--> created"]; n != 2 {
+		t.Errorf("client copy transition taken %d times, want 2 (coverage must survive ResetState)", n)
+	}
+	if n := counts["created --T2S rejected--> rejected_by_t2s"]; n != 1 {
+		t.Errorf("rejection transition taken %d times, want 1", n)
+	}
+}
@@ -0,0 +1,227 @@
+package mock_elsa_server
+
+import (
+	"bytes"
+	"elsa-xml/pkg/extractor"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MessageKind is the business meaning of a message received by the mock
+type MessageKind string
+
+const (
+	KindClientCopy   MessageKind = "clientCopy"   // sese.023
+	KindAccepted     MessageKind = "accepted"     // sese.024 with PrcgSts/AckdAccptd
+	KindRejected     MessageKind = "rejected"     // sese.024 with PrcgSts/Rjctd
+	KindMatched      MessageKind = "matched"      // sese.024 with MtchgSts/Mtchd
+	KindSettled      MessageKind = "settled"      // sese.025
+	KindCancellation MessageKind = "cancellation" // sese.020
+)
+
+// ParsedMessage holds the values the state machine needs from a message
+type ParsedMessage struct {
+	Kind             MessageKind
+	MessageType      string // e.g. sese.024.001.10, taken from the Document namespace
+	TXID             string // AcctOwnrTxId / TxId
+	MitiTXID         string // MktInfrstrctrTxId
+	InstructingParty string
+	MovementType     string
+	PaymentType      string
+	ReasonCode       string
+
+	// instruction details of the client copy
+	ISIN               string
+	Quantity           string
+	SettlementDate     string
+	SafekeepingAccount string
+}
+
+// xmlValue is one element of a message, identified by the local names of all its ancestors
+type xmlValue struct {
+	path  string // e.g. /Document/SctiesSttlmTxStsAdvc/TxId/AcctOwnrTxId
+	value string
+}
+
+type xmlValues []xmlValue
+
+// isoNamespace is the prefix of the namespaces of the ISO 20022 documents
+const isoNamespace = "urn:iso:std:iso:20022:tech:xsd:"
+
+// messageTypes maps the message element below Document to the kind of the message and its extractor
+// message type, the second one for the T2S wrapped format
+var messageTypes = map[string]struct {
+	kind     MessageKind
+	iso, t2s string
+}{
+	"SctiesSttlmTxInstr":   {KindClientCopy, extractor.MsgTypeSese023, extractor.MsgTypeSese023Plus},
+	"SctiesSttlmTxStsAdvc": {"", extractor.MsgTypeSese024, ""},
+	"SctiesSttlmTxConf":    {KindSettled, extractor.MsgTypeSese025, ""},
+	"SctiesTxCxlReq":       {KindCancellation, extractor.MsgTypeSese020, extractor.MsgTypeSese020Plus},
+}
+
+// ParseMessage derives kind and fields from the message content, the values are read by the extractor of
+// elsa-xml. The pure ISO format and the T2S wrapped format (CST2SMsg) are handled alike.
+func ParseMessage(data []byte) (*ParsedMessage, error) {
+	root, err := readRoot(data)
+	if err != nil {
+		return nil, err
+	}
+	types, ok := messageTypes[root.message]
+	msgType := types.iso
+	if root.wrapped {
+		msgType = types.t2s
+	}
+	if !ok || msgType == "" {
+		return nil, fmt.Errorf("unsupported message with root element %q", root.path())
+	}
+	res, err := extractor.Extract(data, msgType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	msg := &ParsedMessage{
+		Kind:             types.kind,
+		MessageType:      strings.TrimPrefix(root.namespace, isoNamespace),
+		TXID:             res.Value(extractor.TxIDKey),
+		MitiTXID:         res.Value(extractor.MktInfrstrctrTxIDKey),
+		InstructingParty: res.Value(extractor.InstructingPartyKey),
+		MovementType:     res.Value(extractor.MovementTypeKey),
+		PaymentType:      res.Value(extractor.PaymentTypeKey),
+	}
+	switch msgType {
+	case extractor.MsgTypeSese023, extractor.MsgTypeSese023Plus:
+		msg.ISIN = res.Value(extractor.ISINKey)
+		msg.Quantity = res.Value(extractor.QuantityKey)
+		msg.SettlementDate = res.Value(extractor.SettlementDateKey)
+		msg.SafekeepingAccount = res.Value(extractor.SafekeepingAccountKey)
+	case extractor.MsgTypeSese024:
+		switch {
+		case res.Value(extractor.ProcessingStatusKey) == "Rjctd":
+			msg.Kind = KindRejected
+		case res.Value(extractor.MatchingStatusKey) == "Mtchd":
+			msg.Kind = KindMatched
+		case res.Value(extractor.ProcessingStatusKey) == "AckdAccptd":
+			msg.Kind = KindAccepted
+		default:
+			return nil, fmt.Errorf("sese.024 for %s carries neither an acceptance, a rejection nor a match", msg.TXID)
+		}
+		if msg.Kind != KindMatched {
+			msg.ReasonCode = res.Value(extractor.StatusReasonKey)
+		}
+	}
+
+	if msg.TXID == "" {
+		return nil, fmt.Errorf("%s message without transaction id", msg.Kind)
+	}
+	return msg, nil
+}
+
+// FindValue returns the value of the first element of the message whose path of local names
+// ends with suffix, e.g. "SttlmTpAndAddtlParams/SctiesMvmntTp".
+func FindValue(data []byte, suffix string) (string, bool, error) {
+	values, _, err := readValues(data)
+	if err != nil {
+		return "", false, err
+	}
+	for _, e := range values {
+		if strings.HasSuffix(e.path, "/"+strings.Trim(suffix, "/")) {
+			return e.value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// readValues flattens the message into the list of its leaf elements and
+// returns the namespace of the ISO Document element
+func readValues(data []byte) (xmlValues, string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		values       xmlValues
+		stack        []string
+		text         strings.Builder
+		docNamespace string
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse message: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			text.Reset()
+			if t.Name.Local == "Document" && docNamespace == "" {
+				docNamespace = t.Name.Space
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			values = append(values, xmlValue{
+				path:  "/" + strings.Join(stack, "/"),
+				value: strings.TrimSpace(text.String()),
+			})
+			text.Reset()
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(values) == 0 {
+		return nil, "", fmt.Errorf("empty message")
+	}
+	return values, docNamespace, nil
+}
+
+// messageRoot locates the message element of a message
+type messageRoot struct {
+	elements  []string // local names of the elements from the root to the message element
+	namespace string   // namespace of the ISO Document element
+	message   string   // local name of the message element below Document, e.g. SctiesSttlmTxStsAdvc
+	wrapped   bool     // the document is wrapped in the T2S format (CST2SMsg)
+}
+
+// path returns the path of the message element, of the root element if there is none
+func (r *messageRoot) path() string {
+	return "/" + strings.Join(r.elements, "/")
+}
+
+// readRoot reads the message up to its message element
+func readRoot(data []byte) (*messageRoot, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	root := &messageRoot{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse message: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			root.elements = append(root.elements, t.Name.Local)
+			switch {
+			case len(root.elements) == 1:
+				root.wrapped = t.Name.Local == "CST2SMsg"
+			case root.elements[len(root.elements)-2] == "Document":
+				root.message = t.Name.Local
+				return root, nil
+			}
+			if t.Name.Local == "Document" {
+				root.namespace = t.Name.Space
+			}
+		case xml.EndElement:
+			if len(root.elements) > 1 {
+				root.elements = root.elements[:len(root.elements)-1]
+			}
+		}
+	}
+	if len(root.elements) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
+	return root, nil
+}
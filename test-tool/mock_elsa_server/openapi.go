@@ -0,0 +1,365 @@
+package mock_elsa_server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openAPISpec is the spec of the ELSA REST API. The mock routes its requests by it and responses
+// of the mock and of the real ELSA are checked against it (see Spec.ValidateResponse).
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Spec is the part of an OpenAPI 3 spec needed for routing and contract checks
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"` // path template -> lower case method -> operation
+	Components struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		Responses  map[string]*Response  `json:"responses"`
+		Schemas    map[string]*Schema    `json:"schemas"`
+	} `json:"components"`
+
+	routes []*route
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Schema is the subset of JSON schema the spec uses
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// route is an operation with its path template split into segments, "{name}" segments are parameters
+type route struct {
+	method    string
+	template  string
+	segments  []string
+	operation *Operation
+}
+
+// Errors of Spec.Route
+var (
+	ErrNoRoute          = errors.New("no such resource")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// LoadSpec parses an OpenAPI spec in JSON format
+func LoadSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+	for template, methods := range spec.Paths {
+		for method, op := range methods {
+			for i, p := range op.Parameters {
+				if p.Ref == "" {
+					continue
+				}
+				resolved, ok := spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown parameter %s", method, template, p.Ref)
+				}
+				op.Parameters[i] = resolved
+			}
+			for code, resp := range op.Responses {
+				if resp.Ref == "" {
+					continue
+				}
+				resolved, ok := spec.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown response %s", method, template, resp.Ref)
+				}
+				op.Responses[code] = resolved
+			}
+			spec.routes = append(spec.routes, &route{
+				method:    strings.ToUpper(method),
+				template:  template,
+				segments:  strings.Split(strings.Trim(template, "/"), "/"),
+				operation: op,
+			})
+		}
+	}
+	// literal segments win over parameters, e.g. /instructions/id/{id} over /instructions/{txID}/...
+	sort.Slice(spec.routes, func(i, j int) bool {
+		return spec.routes[i].literals() > spec.routes[j].literals() ||
+			spec.routes[i].literals() == spec.routes[j].literals() && spec.routes[i].template < spec.routes[j].template
+	})
+	return &spec, nil
+}
+
+func (r *route) literals() int {
+	n := 0
+	for _, s := range r.segments {
+		if !strings.HasPrefix(s, "{") {
+			n++
+		}
+	}
+	return n
+}
+
+// match returns the path parameters if the path matches the template of the route
+func (r *route) match(path string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, s := range r.segments {
+		if strings.HasPrefix(s, "{") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[strings.Trim(s, "{}")] = value
+		} else if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Route returns the operation of the request and its path parameters. ErrNoRoute is returned for
+// an unknown path, ErrMethodNotAllowed if the path has no operation for the method.
+func (sp *Spec) Route(method, path string) (*Operation, map[string]string, error) {
+	err := ErrNoRoute
+	for _, r := range sp.routes {
+		params, ok := r.match(path)
+		if !ok {
+			continue
+		}
+		if r.method == method {
+			return r.operation, params, nil
+		}
+		err = ErrMethodNotAllowed
+	}
+	return nil, nil, err
+}
+
+// Operations returns the IDs of all operations of the spec
+func (sp *Spec) Operations() []string {
+	var ids []string
+	for _, r := range sp.routes {
+		ids = append(ids, r.operation.OperationID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CheckQuery checks the query parameters of a request against the parameters of the operation,
+// parameters the operation does not declare are ignored.
+func (sp *Spec) CheckQuery(op *Operation, query url.Values) error {
+	for _, p := range op.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		value := query.Get(p.Name)
+		if value == "" {
+			if p.Required {
+				return fmt.Errorf("missing parameter %s", p.Name)
+			}
+			continue
+		}
+		var v interface{} = value
+		if s := sp.resolve(p.Schema); s != nil && s.Type == "integer" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %q is no integer", p.Name, value)
+			}
+			v = float64(n)
+		}
+		if err := sp.validate(p.Schema, v, p.Name); err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateResponse checks a response of the ELSA API against the spec: the status code must be
+// declared for the operation and a JSON body must match the schema of the response, an XML body
+// must be well-formed.
+func (sp *Spec) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	op, _, err := sp.Route(method, path)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not declared for %s", method, path, status, op.OperationID)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = sniffMediaType(body)
+	}
+	content, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s: content type %s is not declared for status %d of %s", method, path, mediaType, status, op.OperationID)
+	}
+
+	switch mediaType {
+	case "application/json":
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return fmt.Errorf("%s %s: invalid JSON: %w", method, path, err)
+		}
+		if err := sp.validate(content.Schema, v, "$"); err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+	case "application/xml":
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("%s %s: invalid XML: %w", method, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// sniffMediaType guesses the media type of a body whose content type is unknown, e.g. a recorded response
+func sniffMediaType(body []byte) string {
+	switch b := bytes.TrimSpace(body); {
+	case bytes.HasPrefix(b, []byte("{")), bytes.HasPrefix(b, []byte("[")):
+		return "application/json"
+	case bytes.HasPrefix(b, []byte("<")):
+		return "application/xml"
+	}
+	return "text/plain"
+}
+
+func (sp *Spec) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = sp.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validate checks a value decoded by encoding/json against the schema, path names the value in errors
+func (sp *Spec) validate(schema *Schema, v interface{}, path string) error {
+	s := sp.resolve(schema)
+	if s == nil {
+		return nil
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is none of %v", path, v, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", path, v)
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected a number, got %T", path, v)
+		}
+		if s.Type == "integer" && n != float64(int64(n)) {
+			return fmt.Errorf("%s: %v is no integer", path, n)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, n, *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return fmt.Errorf("%s: %v is greater than %v", path, n, *s.Maximum)
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", path, v)
+		}
+		for i, item := range items {
+			if err := sp.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", path, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing property %s", path, name)
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if err := sp.validate(prop, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apiSpec is the parsed embedded spec
+var apiSpec = mustLoadSpec()
+
+func mustLoadSpec() *Spec {
+	spec, err := LoadSpec(openAPISpec)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// APISpec returns the spec of the ELSA REST API the mock implements
+func APISpec() *Spec {
+	return apiSpec
+}
@@ -0,0 +1,161 @@
+package mock_elsa_server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Transition is a step of the business flow model: when an instruction in status From receives
+// a message of Kind from Party, the Statuses are appended to its history.
+type Transition struct {
+	From     string
+	Party    Party
+	Kind     MessageKind
+	Statuses []string
+}
+
+// To returns the status the instruction is in after the transition
+func (t Transition) To() string {
+	return t.Statuses[len(t.Statuses)-1]
+}
+
+func (t Transition) String() string {
+	from := t.From
+	if from == "" {
+		from = "(new)"
+	}
+	return fmt.Sprintf("%s --%s %s--> %s", from, t.Party, t.Kind, strings.Join(t.Statuses, ", "))
+}
+
+// Transitions returns the business flow model the state machine follows
+func Transitions() []Transition {
+	res := make([]Transition, len(transitions))
+	for i, t := range transitions {
+		res[i] = Transition{From: t.from, Party: t.party, Kind: t.kind, Statuses: append([]string(nil), t.statuses...)}
+	}
+	return res
+}
+
+// FlowPath is a way through the business flow. A path either ends in a final status or, if Timeout
+// is set, in a status where the instruction waits for a party which does not answer.
+type FlowPath struct {
+	Transitions []Transition
+	Timeout     bool
+}
+
+// End returns the status the path ends in
+func (p FlowPath) End() string {
+	return p.Transitions[len(p.Transitions)-1].To()
+}
+
+// Statuses returns the full status history of the path, oldest first
+func (p FlowPath) Statuses() []string {
+	var res []string
+	for _, t := range p.Transitions {
+		res = append(res, t.Statuses...)
+	}
+	return res
+}
+
+func (p FlowPath) String() string {
+	if p.Timeout {
+		return "timeout in " + p.End()
+	}
+	return p.End()
+}
+
+// Paths enumerates all paths through the business flow: one per final status reachable by the
+// accept/reject branches and one timeout path per status in which ELSA waits for T2S or CREATION.
+func Paths() []FlowPath {
+	model := Transitions()
+	var paths []FlowPath
+	var walk func(prefix []Transition, status string)
+	walk = func(prefix []Transition, status string) {
+		var next []Transition
+		for _, t := range model {
+			if t.From == status {
+				next = append(next, t)
+			}
+		}
+		if status != "" {
+			path := FlowPath{Transitions: append([]Transition(nil), prefix...), Timeout: len(next) > 0}
+			paths = append(paths, path)
+		}
+		for _, t := range next {
+			if visited(prefix, t.To()) {
+				continue // the model has no cycles, guard against endless paths anyway
+			}
+			walk(append(prefix, t), t.To())
+		}
+	}
+	walk(nil, "")
+	return paths
+}
+
+func visited(prefix []Transition, status string) bool {
+	for _, t := range prefix {
+		for _, s := range t.Statuses {
+			if s == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TransitionCoverage is how often a transition of the model was exercised
+type TransitionCoverage struct {
+	Transition Transition
+	Count      int
+}
+
+// Coverage returns how often each transition of the model was exercised since the server was created,
+// ResetState does not clear it so it covers a whole test run.
+func (s *MockElsaAPIServer) Coverage() []TransitionCoverage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	model := Transitions()
+	res := make([]TransitionCoverage, len(model))
+	for i, t := range model {
+		res[i] = TransitionCoverage{Transition: t, Count: s.coverage[i]}
+	}
+	return res
+}
+
+// WriteCoverageReport writes the exercised transitions of the model as a table
+func WriteCoverageReport(w io.Writer, coverage []TransitionCoverage) error {
+	covered := 0
+	for _, c := range coverage {
+		if c.Count > 0 {
+			covered++
+		}
+	}
+	if _, err := fmt.Fprintf(w, "Business flow coverage: %d of %d transitions exercised\n", covered, len(coverage)); err != nil {
+		return err
+	}
+	for _, c := range coverage {
+		mark := "x"
+		if c.Count == 0 {
+			mark = " "
+		}
+		if _, err := fmt.Fprintf(w, "  [%s] %5d  %s\n", mark, c.Count, c.Transition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsFinalStatus reports whether the business flow ends in the status, no message moves an instruction out of it
+func IsFinalStatus(status string) bool {
+	reached := false
+	for _, t := range transitions {
+		if t.from == status {
+			return false
+		}
+		if t.statuses[len(t.statuses)-1] == status {
+			reached = true
+		}
+	}
+	return reached
+}
@@ -0,0 +1,96 @@
+package mock_elsa_server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingProxy is a reverse proxy in front of the ELSA API which records the request/response pairs
+// into a cassette, the MockElsaAPIServer replays them (see ReplayCassette). The event stream is passed
+// through without recording.
+type RecordingProxy struct {
+	proxy *httputil.ReverseProxy
+	path  string // cassette file, written after every recorded interaction
+
+	mu        sync.Mutex
+	cassette  *Cassette
+	templater *templater
+}
+
+// NewRecordingProxy returns a proxy to the ELSA API at target recording into the cassette file at path
+func NewRecordingProxy(target, path string) (*RecordingProxy, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid ELSA API URL %q", target)
+	}
+	now := time.Now().UTC()
+	p := &RecordingProxy{
+		path:      path,
+		cassette:  &Cassette{Recorded: now, Variables: make(map[string]string)},
+		templater: &templater{recorded: now, baseURL: strings.TrimSuffix(target, "/"), variables: make(map[string]string)},
+	}
+	p.proxy = httputil.NewSingleHostReverseProxy(u)
+	p.proxy.FlushInterval = -1 // server-sent events are passed on at once
+	p.proxy.ModifyResponse = p.record
+	return p, nil
+}
+
+func (p *RecordingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.proxy.ServeHTTP(w, r)
+}
+
+// record adds the response and its request to the cassette
+func (p *RecordingProxy) record(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/event-stream" {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s: %w", resp.Request.URL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	templatedURL := p.templater.bind(resp.Request.Method, resp.Request.URL) // before the body, it binds the variables
+	in := Interaction{
+		Request: RecordedRequest{Method: resp.Request.Method, URL: templatedURL},
+		Response: RecordedResponse{
+			Status:      resp.StatusCode,
+			ContentType: contentType,
+			Body:        p.templater.apply(string(body)),
+		},
+	}
+	p.cassette.Interactions = append(p.cassette.Interactions, in)
+	for name, value := range p.templater.variables {
+		p.cassette.Variables[name] = value
+	}
+	fmt.Printf("Proxy: recorded %s %s -> %d\n", in.Request.Method, in.Request.URL, in.Response.Status)
+	if p.path == "" {
+		return nil
+	}
+	return p.cassette.Save(p.path)
+}
+
+// Cassette returns a copy of the recorded cassette
+func (p *RecordingProxy) Cassette() *Cassette {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := *p.cassette
+	c.Interactions = append([]Interaction(nil), p.cassette.Interactions...)
+	c.Variables = make(map[string]string, len(p.cassette.Variables))
+	for name, value := range p.cassette.Variables {
+		c.Variables[name] = value
+	}
+	return &c
+}
@@ -0,0 +1,217 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"test-tool/clock"
+	"time"
+)
+
+// RetryPolicy is how the ReTry service of ELSA resends unanswered messages: when ELSA sent messages in a
+// status and the answer does not arrive within Timeout, the messages are sent again, at most MaxRetries
+// times. Without answer to the last retry the instruction gets StatusTimedOut. A zero Timeout disables
+// the retries, it is the default.
+type RetryPolicy struct {
+	Timeout    time.Duration `json:"-"`          // "timeout" in JSON, e.g. "30m"
+	MaxRetries int           `json:"maxRetries"` // 0 sets StatusTimedOut after the first timeout
+}
+
+// MarshalJSON writes the timeout as duration string like "30m0s"
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	type plain RetryPolicy
+	out := struct {
+		plain
+		Timeout string `json:"timeout,omitempty"`
+	}{plain: plain(p)}
+	if p.Timeout > 0 {
+		out.Timeout = p.Timeout.String()
+	}
+	return json.Marshal(out)
+}
+
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	type plain RetryPolicy
+	in := struct {
+		*plain
+		Timeout string `json:"timeout,omitempty"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Timeout != "" {
+		d, err := time.ParseDuration(in.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", in.Timeout, err)
+		}
+		p.Timeout = d
+	}
+	return nil
+}
+
+// SetRetryPolicy sets how unanswered messages are retried from now on, see RetryPolicy
+func (s *MockElsaAPIServer) SetRetryPolicy(p RetryPolicy) error {
+	if p.Timeout < 0 || p.MaxRetries < 0 {
+		return fmt.Errorf("retry timeout and number of retries must not be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retryPolicy = p
+	s.logf("retry policy %+v", p)
+	return nil
+}
+
+// RetryPolicy returns the policy set by SetRetryPolicy
+func (s *MockElsaAPIServer) RetryPolicy() RetryPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retryPolicy
+}
+
+// ClockState is the clock of the mock, GET /_mock/clock of the control API
+type ClockState struct {
+	Now    time.Time `json:"now"`
+	Manual bool      `json:"manual"` // the clock only moves when advanced, see ControlClock
+}
+
+// SetClock sets the clock of the status timestamps, scheduled transitions and retries. Pending
+// transitions and retries keep the clock they were started with.
+func (s *MockElsaAPIServer) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
+// Clock returns the state of the clock of the mock
+func (s *MockElsaAPIServer) Clock() ClockState {
+	s.mu.RLock()
+	c := s.clock
+	s.mu.RUnlock()
+	_, manual := c.(*clock.Manual)
+	return ClockState{Now: c.Now().UTC(), Manual: manual}
+}
+
+// ControlClock replaces the clock of the mock by a manual one standing at start, the current time of the
+// mock if start is zero. Timeouts and retries then only happen when the clock is advanced, see AdvanceClock.
+func (s *MockElsaAPIServer) ControlClock(start time.Time) ClockState {
+	s.mu.Lock()
+	if start.IsZero() {
+		start = s.clock.Now()
+	}
+	s.clock = clock.NewManual(start)
+	s.mu.Unlock()
+	s.logf("clock controlled, standing at %s", start.UTC().Format(time.RFC3339))
+	return s.Clock()
+}
+
+// AdvanceClock moves the manual clock of the mock forward, the transitions and retries due meanwhile
+// happen before it returns
+func (s *MockElsaAPIServer) AdvanceClock(d time.Duration) (ClockState, error) {
+	if d < 0 {
+		return ClockState{}, fmt.Errorf("the clock cannot go back")
+	}
+	s.mu.RLock()
+	manual, ok := s.clock.(*clock.Manual)
+	s.mu.RUnlock()
+	if !ok {
+		return ClockState{}, fmt.Errorf("the clock of the mock is not controlled, it follows the wall clock")
+	}
+	calls := manual.Advance(d)
+	s.logf("clock advanced by %s, %d timers fired", d, calls)
+	return s.Clock(), nil
+}
+
+// pendingRetry waits for the answer to the messages an instruction was sent in a status
+type pendingRetry struct {
+	status   string
+	outbound []outboundMessage
+	attempt  int // number of retries sent so far
+	timer    clock.Timer
+}
+
+// awaitRetry starts waiting for the answer to the outbound messages of transition t, a pending retry of the
+// instruction is cancelled. s.mu must be held.
+func (s *MockElsaAPIServer) awaitRetry(state *InstructionState, t *transition) {
+	s.stopRetry(state.TXID)
+	status := state.StatusHistory[0].Name
+	if s.retryPolicy.Timeout <= 0 || len(t.outbound) == 0 || !awaitsAnswer(status) {
+		return
+	}
+	s.armRetry(state.TXID, &pendingRetry{status: status, outbound: t.outbound})
+}
+
+// armRetry starts the timer of a retry, s.mu must be held
+func (s *MockElsaAPIServer) armRetry(txID string, r *pendingRetry) {
+	r.timer = s.clock.AfterFunc(s.retryPolicy.Timeout, func() { s.retry(txID, r) })
+	s.retries[txID] = r
+}
+
+// retry resends the messages of an unanswered status or gives up on the instruction. The messages are
+// sent after s.mu is released, a slow queue must not block the API and the manual clock.
+func (s *MockElsaAPIServer) retry(txID string, r *pendingRetry) {
+	ctx, deliveries := s.nextRetry(txID, r)
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := s.deliver(ctx, deliveries); err != nil {
+		s.errorf("retry of %s failed: %v", txID, err)
+	}
+}
+
+// nextRetry counts the retry and renders its messages, they are sent with the returned context of the
+// business flow. The next retry is armed, after the last one the instruction times out.
+func (s *MockElsaAPIServer) nextRetry(txID string, r *pendingRetry) (context.Context, []*delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.retries[txID] != r {
+		return nil, nil // answered or cancelled meanwhile
+	}
+	delete(s.retries, txID)
+	state := s.instructions[txID]
+	if state == nil || len(state.StatusHistory) == 0 || state.StatusHistory[0].Name != r.status {
+		return nil, nil
+	}
+	if r.attempt >= s.retryPolicy.MaxRetries {
+		s.appendStatus(state, StatusTimedOut, nil)
+		s.logf("no answer for %s in status %q after %d retries, it timed out", txID, r.status, r.attempt)
+		return nil, nil
+	}
+	r.attempt++
+	var deliveries []*delivery
+	for _, out := range r.outbound {
+		out.name = fmt.Sprintf("%s_retry%d", out.name, r.attempt)
+		d, err := s.render(state, out)
+		if err != nil {
+			s.errorf("retry of %s failed: %v", txID, err)
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	state.RetryCount++
+	s.logf("ReTry %d of %d for %s in status %q", r.attempt, s.retryPolicy.MaxRetries, txID, r.status)
+	s.armRetry(txID, r)
+
+	ctx := context.Background()
+	if s.watcher != nil {
+		ctx = s.watcher.ctx
+	}
+	return ctx, deliveries
+}
+
+// stopRetry cancels the pending retry of the instruction, s.mu must be held
+func (s *MockElsaAPIServer) stopRetry(txID string) {
+	if r, ok := s.retries[txID]; ok {
+		r.timer.Stop()
+		delete(s.retries, txID)
+	}
+}
+
+// awaitsAnswer reports whether the business flow continues from the status with a message of a party
+func awaitsAnswer(status string) bool {
+	for _, t := range transitions {
+		if t.from == status && t.from != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"test-tool/clock"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	s, queues := newFlowServer(t)
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	manual := clock.NewManual(start)
+	s.SetClock(manual)
+	if err := s.SetRetryPolicy(RetryPolicy{Timeout: 30 * time.Minute, MaxRetries: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if manual.Pending() != 1 {
+		t.Fatalf("expected the retry of %s to be pending, got %d timers", StatusSentToCreation, manual.Pending())
+	}
+
+	manual.Advance(29 * time.Minute)
+	if hasMessage(t, queues.CreationOutbound, "TX1_creation_request_retry1") {
+		t.Error("retry sent before the timeout")
+	}
+	manual.Advance(time.Minute)
+	if !hasMessage(t, queues.CreationOutbound, "TX1_creation_request_retry1") {
+		t.Error("creation request not retried after the timeout")
+	}
+	manual.Advance(time.Hour)
+	state, _ := s.Instruction("TX1")
+	if !hasMessage(t, queues.CreationOutbound, "TX1_creation_request_retry2") || state.RetryCount != 2 || state.StatusHistory[0].Name != StatusTimedOut {
+		t.Errorf("after the retries: got %+v", state)
+	}
+	if state.StatusHistory[0].Timestamp != "2026-03-02T10:30:00Z" {
+		t.Errorf("timeout at the time of the clock: got %s", state.StatusHistory[0].Timestamp)
+	}
+
+	// an answer within the timeout cancels the retry
+	s.ResetState()
+	s.flowQueues = queues
+	for _, msg := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manual.Advance(10 * time.Minute)
+	if err := s.ProcessMessage(context.Background(), PartyCreation, []byte(accepted)); err != nil {
+		t.Fatal(err)
+	}
+	manual.Advance(25 * time.Minute)
+	if state, _ := s.Instruction("TX1"); state.RetryCount != 0 || state.StatusHistory[0].Name != StatusMatchingSentToT2S {
+		t.Errorf("answered instruction: got %+v", state)
+	}
+}
+
+func TestAdminClock(t *testing.T) {
+	srv := newAPIServer(t)
+	s := srv.Config.Handler.(*MockElsaAPIServer)
+
+	if resp, _ := request(t, http.MethodPost, srv.URL+"/_mock/clock/advance", `{"by": "1h"}`); resp.StatusCode != http.StatusConflict {
+		t.Errorf("advance the wall clock: got %d", resp.StatusCode)
+	}
+	resp, body := request(t, http.MethodPut, srv.URL+"/_mock/clock", `{"now": "2026-03-02T09:00:00Z"}`)
+	var state ClockState
+	if err := json.Unmarshal(body, &state); resp.StatusCode != http.StatusOK || err != nil || !state.Manual {
+		t.Fatalf("control the clock: got %d %s", resp.StatusCode, body)
+	}
+	if _, err := s.ScheduleStatus("TX2", StatusSettled, 90*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	resp, body = request(t, http.MethodPost, srv.URL+"/_mock/clock/advance", `{"by": "90m"}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "2026-03-02T10:30:00Z") {
+		t.Errorf("advance the clock: got %d %s", resp.StatusCode, body)
+	}
+	if state, ok := s.Instruction("TX2"); !ok || state.StatusHistory[0].Name != StatusSettled {
+		t.Errorf("transition due within the advance: got %+v", state)
+	}
+	for _, body := range []string{`{"by": "soon"}`, `{"by": "-1m"}`} {
+		if resp, _ := request(t, http.MethodPost, srv.URL+"/_mock/clock/advance", body); resp.StatusCode == http.StatusOK {
+			t.Errorf("invalid advance %s: got %d", body, resp.StatusCode)
+		}
+	}
+
+	if resp, body := request(t, http.MethodPut, srv.URL+"/_mock/retry", `{"timeout": "30m", "maxRetries": 3}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("set retry policy: got %d %s", resp.StatusCode, body)
+	}
+	if p := s.RetryPolicy(); p.Timeout != 30*time.Minute || p.MaxRetries != 3 {
+		t.Errorf("retry policy: got %+v", p)
+	}
+	if _, body := get(t, srv.URL+"/_mock/state"); !strings.Contains(string(body), `"retry":{"maxRetries":3,"timeout":"30m0s"}`) {
+		t.Errorf("state: got %s", body)
+	}
+	if resp, _ := request(t, http.MethodPut, srv.URL+"/_mock/retry", `{"maxRetries": -1}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid retry policy: got %d", resp.StatusCode)
+	}
+}
+
+func TestRetrySendsWithoutLock(t *testing.T) {
+	s, queues := newFlowServer(t)
+	manual := clock.NewManual(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	s.SetClock(manual)
+	if err := s.SetRetryPolicy(RetryPolicy{Timeout: 30 * time.Minute, MaxRetries: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StartFlow(queues); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{clientCopy, accepted} {
+		if err := s.ProcessMessage(context.Background(), PartyT2S, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocking := &blockingQueue{Queue: queues.CreationOutbound, putting: make(chan struct{})}
+	s.mu.Lock()
+	s.flowQueues.CreationOutbound = blocking
+	s.mu.Unlock()
+
+	advanced := make(chan struct{})
+	go func() {
+		manual.Advance(30 * time.Minute)
+		close(advanced)
+	}()
+	<-blocking.putting
+
+	// the retry is counted and the instruction readable while the message is being sent
+	if state, _ := s.Instruction("TX1"); state.RetryCount != 1 {
+		t.Errorf("got %d retries while sending", state.RetryCount)
+	}
+	s.StopFlow() // cancels the send
+	select {
+	case <-advanced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the send of the retry was not cancelled")
+	}
+}
@@ -1,21 +1,38 @@
 package mock_elsa_server
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"test-tool/clock"
+	"test-tool/queue"
 	"time"
 	// "elsa-test-tool/step_definitions" // Avoid circular dependency if not strictly needed for types here
 )
 
 // InstructionState holds the current status of a transaction
 type InstructionState struct {
-	TXID          string           `json:"txID"`          // Client's TXID
-	MitiTXID      string           `json:"mitiTXID"`      // Miti TXID (used in API response as 'txID')
-	StatusHistory []APIStatusEntry `json:"statusHistory"` // Chronological, newest first
-	// Add any other relevant fields ELSA might track
+	ID                    string           `json:"id"`            // Internal ID, assigned in order of creation
+	TXID                  string           `json:"txID"`          // Client's TXID
+	MitiTXID              string           `json:"mitiTXID"`      // Miti TXID (used in API response as 'txID')
+	StatusHistory         []APIStatusEntry `json:"statusHistory"` // Chronological, newest first
+	InstructingParty      string           `json:"instructingParty"`
+	MovementType          string           `json:"movementType"`
+	PaymentType           string           `json:"paymentType"`
+	CancellationRequested bool             `json:"cancellationRequested"`
+
+	// instruction details of the client copy, used for the messages ELSA sends
+	ISIN               string `json:"isin"`
+	Quantity           string `json:"quantity"`
+	SettlementDate     string `json:"settlementDate"`
+	SafekeepingAccount string `json:"safekeepingAccount"`
+
+	RetryCount int `json:"retryCount"` // messages resent by the ReTry service, see SetRetryPolicy
 }
 
 // APIStatusEntry matches the structure in the API response
@@ -25,27 +42,117 @@ type APIStatusEntry struct {
 	Message   string `json:"message"` // Link to message API
 }
 
+// Values reported for instructions whose messages do not carry them (e.g. set by SetInstructionStatus)
+const (
+	defaultInstructingParty = "MOCKT2SXXX"
+	defaultMovementType     = "RECE"
+	defaultPaymentType      = "APMT"
+)
+
+// defaultAPIBaseURL is the URL used in links until SetAPIBaseURL is called
+const defaultAPIBaseURL = "http://localhost:8080"
+
 // MockElsaAPIServer simulates the ELSA REST API
 type MockElsaAPIServer struct {
-	mu sync.RWMutex
-	// instructions map[string]*InstructionState // Keyed by ClientTXID
-	instructions map[string][]APIStatusEntry // Keyed by ClientTXID, stores status history
-	apiBaseURL   string                      // Not strictly needed for handler if paths are fixed, but good for context
+	mu           sync.RWMutex
+	instructions map[string]*InstructionState  // Keyed by ClientTXID
+	messages     map[string][]byte             // Messages linked from the status history, keyed by message ID
+	coverage     map[int]int                   // Number of times each transition was taken, keyed by index in the model
+	subscribers  map[chan StatusEvent]struct{} // Subscribers of the status events, see Subscribe
+	apiBaseURL   string                        // Base URL of the links in the responses, see SetAPIBaseURL
+	lastID       int                           // Internal ID of the instruction created last
+	replay       *replay                       // Recorded responses served before the own state, see ReplayCassette
+	faults       []*Fault                      // Fault rules of the API, see AddFault
+	lastFaultID  int                           // ID of the fault rule added last
+	queueFaults  *queue.Faults                 // Fault rules of the queues, see QueueFaults
+	control      http.Handler                  // Control API of the mock below controlPrefix
+	clock        clock.Clock                   // Time of the statuses, transitions and retries, see SetClock
+	retryPolicy  RetryPolicy                   // see SetRetryPolicy
+	retries      map[string]*pendingRetry      // Unanswered messages by client TXID
+
+	scheduled        map[string]*ScheduledTransition // Pending transitions by ID, see ScheduleStatus
+	lastTransitionID int                             // ID of the transition scheduled last
+
+	// state-machine mode, see StartFlow
+	watcher    *flowWatcher
+	flowQueues FlowQueues
+
+	logger atomic.Pointer[Logger] // see SetLogger, also used while s.mu is held
+}
+
+// Logger receives the diagnostics of the mock, failed is set for errors
+type Logger func(failed bool, msg string)
+
+// consoleLogger prints the diagnostics to stdout and the errors to stderr, it is the default Logger
+func consoleLogger(failed bool, msg string) {
+	if failed {
+		fmt.Fprintf(os.Stderr, "MockServer: %s\n", msg)
+		return
+	}
+	fmt.Printf("MockServer: %s\n", msg)
 }
 
 // NewMockElsaAPIServer creates a new mock server instance
 func NewMockElsaAPIServer() *MockElsaAPIServer {
-	return &MockElsaAPIServer{
-		instructions: make(map[string][]APIStatusEntry),
+	s := &MockElsaAPIServer{
+		instructions: make(map[string]*InstructionState),
+		messages:     make(map[string][]byte),
+		coverage:     make(map[int]int),
+		subscribers:  make(map[chan StatusEvent]struct{}),
+		apiBaseURL:   defaultAPIBaseURL,
+		queueFaults:  queue.NewFaults(),
+		scheduled:    make(map[string]*ScheduledTransition),
+		clock:        clock.Real{},
+		retries:      make(map[string]*pendingRetry),
 	}
+	s.control = s.newControlMux()
+	s.SetLogger(consoleLogger)
+	return s
 }
 
-// ResetState clears all stored instruction states.
+// SetLogger sets the receiver of the diagnostics, e.g. to keep them with the scenario using the mock.
+// A nil logger discards them.
+func (s *MockElsaAPIServer) SetLogger(l Logger) {
+	if l == nil {
+		l = func(bool, string) {}
+	}
+	s.logger.Store(&l)
+}
+
+// logf passes a diagnostic to the logger
+func (s *MockElsaAPIServer) logf(format string, args ...any) {
+	(*s.logger.Load())(false, fmt.Sprintf(format, args...))
+}
+
+// errorf passes an error to the logger
+func (s *MockElsaAPIServer) errorf(format string, args ...any) {
+	(*s.logger.Load())(true, fmt.Sprintf(format, args...))
+}
+
+// SetAPIBaseURL sets the URL the server is reachable at, the links in the responses point to it.
+func (s *MockElsaAPIServer) SetAPIBaseURL(apiBaseURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiBaseURL = strings.TrimSuffix(apiBaseURL, "/")
+}
+
+// ResetState clears all stored instruction states, pending transitions and retries and fault rules and
+// leaves state-machine mode. The clock and the retry policy are kept.
 func (s *MockElsaAPIServer) ResetState() {
+	s.StopFlow()
+	s.ClearFaults()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.instructions = make(map[string][]APIStatusEntry)
-	fmt.Println("MockElsaAPIServer state reset.")
+	s.instructions = make(map[string]*InstructionState)
+	s.messages = make(map[string][]byte)
+	s.lastID = 0
+	s.cancelScheduled()
+	for txID := range s.retries {
+		s.stopRetry(txID)
+	}
+	s.flowQueues = FlowQueues{}
+	s.logf("state reset")
 }
 
 // SetInstructionStatus sets or updates the status of an instruction.
@@ -54,75 +161,98 @@ func (s *MockElsaAPIServer) SetInstructionStatus(clientTXID string, newStatusNam
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
-	messageLink := fmt.Sprintf("http://localhost:8080/messages/id/mock-%s-%s", clientTXID, newStatusName) // Example message link
+	state, ok := s.instructions[clientTXID]
+	if !ok {
+		state = s.newInstruction(clientTXID)
+	}
+	s.appendStatus(state, newStatusName, nil)
+	s.logf("Set status for %s to %s. History: %+v", clientTXID, newStatusName, state.StatusHistory)
+}
+
+// newInstruction creates the instruction with the next internal ID, s.mu must be held
+func (s *MockElsaAPIServer) newInstruction(clientTXID string) *InstructionState {
+	return s.addInstruction(s.nextInstruction(clientTXID))
+}
+
+// nextInstruction returns the instruction newInstruction creates next without adding it, s.mu must be held
+func (s *MockElsaAPIServer) nextInstruction(clientTXID string) *InstructionState {
+	return &InstructionState{ID: strconv.Itoa(s.lastID + 1), TXID: clientTXID, MitiTXID: "miti-" + clientTXID}
+}
+
+// addInstruction adds an instruction returned by nextInstruction, s.mu must be held
+func (s *MockElsaAPIServer) addInstruction(state *InstructionState) *InstructionState {
+	s.lastID++
+	s.instructions[state.TXID] = state
+	return state
+}
+
+// appendStatus prepends a new status to maintain newest-first order, s.mu must be held.
+// The message which caused the status is served under the message link, without message the link is not found.
+func (s *MockElsaAPIServer) appendStatus(state *InstructionState, newStatusName string, message []byte) {
+	timestamp := s.clock.Now().UTC().Format(time.RFC3339Nano)
+	messageID := fmt.Sprintf("mock-%s-%s", state.TXID, newStatusName)
+	messageLink := s.apiBaseURL + "/messages/id/" + messageID
+	if message != nil {
+		s.messages[messageID] = message
+	}
 
 	newEntry := APIStatusEntry{
 		Name:      newStatusName,
 		Timestamp: timestamp,
 		Message:   messageLink,
 	}
-
-	// Prepend new status to maintain newest-first order
-	s.instructions[clientTXID] = append([]APIStatusEntry{newEntry}, s.instructions[clientTXID]...)
-	fmt.Printf("MockServer: Set status for %s to %s. History: %+v", clientTXID, newStatusName, s.instructions[clientTXID])
+	state.StatusHistory = append([]APIStatusEntry{newEntry}, state.StatusHistory...)
+	s.publish(StatusEvent{TXID: state.TXID, Status: newStatusName, Timestamp: timestamp})
 }
 
-// ServeHTTP handles incoming HTTP requests
+// ServeHTTP handles incoming HTTP requests. Requests below controlPrefix go to the control API of the
+// mock, the others are answered as the matching fault rule makes it (see AddFault) or served by serveAPI.
 func (s *MockElsaAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("MockServer: Received request: %s %s", r.Method, r.URL.Path)
-	s.mu.RLock() // Use RLock for read-heavy operations initially
-
-	// Example: /instructions/{TXID}
-	if strings.HasPrefix(r.URL.Path, "/instructions/") && r.Method == http.MethodGet {
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/instructions/"), "/")
-		clientTXID := parts[0]
-
-		statusHistory, exists := s.instructions[clientTXID]
-		s.mu.RUnlock() // Unlock before writing response or further processing
-
-		if !exists || len(statusHistory) == 0 {
-			fmt.Printf("MockServer: Instruction %s not found or no status history", clientTXID)
-			http.Error(w, fmt.Sprintf(`{"error": "Instruction not found", "transactionId": "%s"}`, clientTXID), http.StatusNotFound)
-			return
-		}
-
-		// Construct the API response based on your provided structure
-		// The 'txID' in the main response body is the MitiTXID
-		mitiTXID := "miti-" + clientTXID // Construct MitiTXID
-
-		apiResponse := struct { // Using an anonymous struct for the response shape
-			Href                  string            `json:"href"`
-			InstructionType       string            `json:"instructionType"`
-			InstructingParty      string            `json:"instructingParty"`
-			TxID                  string            `json:"txID"` // This is MitiTXID
-			MovementType          string            `json:"movementType"`
-			PaymentType           string            `json:"paymentType"`
-			CancellationRequested bool              `json:"cancellationRequested"`
-			Status                []APIStatusEntry  `json:"status"`
-			Links                 map[string]string `json:"links"`
-		}{
-			Href:                  fmt.Sprintf("http://localhost:8080/instructions/id/%s/audit", clientTXID), // Example
-			InstructionType:       "elsa_to_t2s",                                                             // Example
-			InstructingParty:      "MOCKT2SXXX",                                                              // Example
-			TxID:                  mitiTXID,
-			MovementType:          "RECE", // Example
-			PaymentType:           "APMT", // Example
-			CancellationRequested: false,  // Example
-			Status:                statusHistory,
-			Links: map[string]string{
-				"instruction": fmt.Sprintf("http://localhost:8080/instructions/id/%s", clientTXID),
-				"collection":  fmt.Sprintf("http://localhost:8080/collections/id/mock-%s", clientTXID),
-			},
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(apiResponse)
-		fmt.Printf("MockServer: Responded for %s with status history: %+v", clientTXID, statusHistory)
+	if strings.HasPrefix(r.URL.Path, controlPrefix+"/") {
+		s.control.ServeHTTP(w, r)
+		return
+	}
+	if fault := s.takeFault(r); fault != nil {
+		s.serveFault(w, r, fault)
+		return
+	}
+	s.serveAPI(w, r)
+}
+
+// serveAPI answers with a recorded response (see ReplayCassette) or routes the request by the OpenAPI
+// spec of the ELSA API (openapi.json)
+func (s *MockElsaAPIServer) serveAPI(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	replay, apiBaseURL := s.replay, s.apiBaseURL
+	s.mu.RUnlock()
+	if replay != nil && replay.serve(w, r, apiBaseURL) {
 		return
-	} else {
-		s.mu.RUnlock() // Ensure RUnlock is called if not handled by specific path
 	}
 
-	http.NotFound(w, r)
+	op, params, err := apiSpec.Route(r.Method, r.URL.Path)
+	switch {
+	case errors.Is(err, ErrMethodNotAllowed):
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	case err != nil:
+		writeError(w, http.StatusNotFound, "Not found", "path", r.URL.Path)
+		return
+	}
+	if err := apiSpec.CheckQuery(op, r.URL.Query()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	handler, ok := operations[op.OperationID]
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Operation not implemented by the mock", "operationId", op.OperationID)
+		return
+	}
+	handler(s, w, r, params)
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
 }
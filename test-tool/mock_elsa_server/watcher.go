@@ -0,0 +1,134 @@
+package mock_elsa_server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"test-tool/queue"
+	"time"
+)
+
+// flowPollInterval is how often the inbound queues are read for new messages
+const flowPollInterval = 100 * time.Millisecond
+
+// inboundQueue is a queue ELSA reads together with the party sending to it
+type inboundQueue struct {
+	queue queue.Queue
+	party Party
+}
+
+// flowWatcher feeds the messages of the inbound queues to the state machine.
+// Cancelling ctx stops it, also while it reads or sends a message.
+type flowWatcher struct {
+	inbound []inboundQueue
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// StartFlow switches the mock into state-machine mode: messages put to the inbound queues
+// drive the instruction statuses and ELSA's reactions are put to the outbound queues.
+// A running flow is restarted with the given queues.
+func (s *MockElsaAPIServer) StartFlow(queues FlowQueues) error {
+	var inbound []inboundQueue
+	parties := make(map[string]Party) // queue name -> sending party
+	add := func(q queue.Queue, party Party) error {
+		if q == nil {
+			return nil
+		}
+		if p, ok := parties[q.Name()]; ok {
+			if p != party {
+				return fmt.Errorf("queue %s is configured as inbound queue for %s and %s", q.Name(), p, party)
+			}
+			return nil // e.g. client copies and T2S status messages share a queue
+		}
+		parties[q.Name()] = party
+		inbound = append(inbound, inboundQueue{q, party})
+		return nil
+	}
+	for _, q := range queues.T2SInbound {
+		if err := add(q, PartyT2S); err != nil {
+			return err
+		}
+	}
+	if err := add(queues.CreationInbound, PartyCreation); err != nil {
+		return err
+	}
+	if len(inbound) == 0 {
+		return fmt.Errorf("no inbound queue configured")
+	}
+
+	s.StopFlow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &flowWatcher{
+		inbound: inbound,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.flowQueues = queues
+	s.watcher = w
+	s.mu.Unlock()
+
+	go w.run(s)
+	s.logf("business flow started, reading %d inbound queues", len(inbound))
+	return nil
+}
+
+// StopFlow leaves state-machine mode, statuses are then only changed by SetInstructionStatus.
+func (s *MockElsaAPIServer) StopFlow() {
+	s.mu.Lock()
+	w := s.watcher
+	s.watcher = nil
+	s.mu.Unlock()
+
+	if w != nil {
+		w.cancel()
+		<-w.done
+		s.logf("business flow stopped")
+	}
+}
+
+// FlowEnabled reports whether the mock runs in state-machine mode.
+func (s *MockElsaAPIServer) FlowEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.watcher != nil
+}
+
+func (w *flowWatcher) run(s *MockElsaAPIServer) {
+	defer close(w.done)
+	ticker := time.NewTicker(flowPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.consume(s)
+		}
+	}
+}
+
+// consume gets all messages of the inbound queues and hands them to the state machine
+func (w *flowWatcher) consume(s *MockElsaAPIServer) {
+	for _, in := range w.inbound {
+		for w.ctx.Err() == nil {
+			msg, err := in.queue.Get(w.ctx, queue.Selector{})
+			if errors.Is(err, queue.ErrEmpty) {
+				break
+			}
+			if err != nil {
+				if w.ctx.Err() == nil {
+					s.errorf("reading %s failed: %v", in.queue.Name(), err)
+				}
+				break
+			}
+			if err := s.ProcessMessage(w.ctx, in.party, msg.Body); err != nil {
+				s.errorf("message %s of %s not processed: %v", msg.ID, in.queue.Name(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package postman converts Postman collections (format v2.1) into Gherkin features for the HTTP steps
+// of the test tool, so the existing Postman tests of ELSA can be migrated.
+//
+// Every folder on the top level of the collection becomes a scenario with the requests of the folder
+// and its sub-folders in order, a request on the top level becomes a scenario of its own. The variables
+// of the environment and the collection are values of the scenario in the Background. Requests, headers,
+// bodies and the common pm.* assertions of the test scripts are translated into steps. Whatever cannot
+// be translated, e.g. JavaScript logic or file uploads, is kept as MANUAL comment at its place, the
+// scenario is tagged @manual and the item is reported as Issue.
+package postman
+
+//go:generate go run ../cmd/postman2feature -collection ../testdata/postman/elsa_api.postman_collection.json -environment ../testdata/postman/elsa_local.postman_environment.json -o ../features/postman/elsa_api.feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Collection is a Postman collection of format v2.1
+type Collection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []Item     `json:"item"`
+	Variable []Variable `json:"variable"`
+	Event    []Event    `json:"event"`
+	Auth     *Auth      `json:"auth"`
+}
+
+// Item is a folder (with items) or a request
+type Item struct {
+	Name    string   `json:"name"`
+	Item    []Item   `json:"item"`
+	Request *Request `json:"request"`
+	Event   []Event  `json:"event"`
+	Auth    *Auth    `json:"auth"` // of the requests of a folder
+}
+
+// Request is the HTTP request of an item
+type Request struct {
+	Method string     `json:"method"`
+	Header []KeyValue `json:"header"`
+	URL    URL        `json:"url"`
+	Body   *Body      `json:"body"`
+	Auth   *Auth      `json:"auth"`
+}
+
+// URL is the raw URL of a request, Postman writes it as string or as object
+type URL struct {
+	Raw string
+}
+
+// UnmarshalJSON accepts both forms of the URL, an object without raw URL is assembled from its parts
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Raw); err == nil {
+		return nil
+	}
+	var parts struct {
+		Raw      string      `json:"raw"`
+		Protocol string      `json:"protocol"`
+		Host     stringParts `json:"host"`
+		Path     stringParts `json:"path"`
+		Query    []KeyValue  `json:"query"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if parts.Raw != "" {
+		u.Raw = parts.Raw
+		return nil
+	}
+	var b strings.Builder
+	if parts.Protocol != "" {
+		b.WriteString(parts.Protocol + "://")
+	}
+	b.WriteString(strings.Join(parts.Host, "."))
+	if len(parts.Path) > 0 {
+		b.WriteString("/" + strings.Join(parts.Path, "/"))
+	}
+	sep := "?"
+	for _, q := range parts.Query {
+		if !q.Disabled {
+			b.WriteString(sep + q.Key + "=" + q.Value)
+			sep = "&"
+		}
+	}
+	u.Raw = b.String()
+	return nil
+}
+
+// stringParts is a list of URL parts, Postman writes a single part as string
+type stringParts []string
+
+func (p *stringParts) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = strings.Split(s, ".")
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(p))
+}
+
+// Body is the body of a request
+type Body struct {
+	Mode       string     `json:"mode"` // raw, urlencoded, formdata, file or graphql
+	Raw        string     `json:"raw"`
+	URLEncoded []KeyValue `json:"urlencoded"`
+	Options    struct {
+		Raw struct {
+			Language string `json:"language"` // json, xml, text, ...
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+// KeyValue is a header, query parameter, form field or auth attribute
+type KeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Auth is the authorization of a request, inherited from the folder or collection if not set
+type Auth struct {
+	Type   string     `json:"type"` // noauth, bearer, basic, apikey, oauth2, ...
+	Bearer []KeyValue `json:"bearer"`
+	Basic  []KeyValue `json:"basic"`
+	APIKey []KeyValue `json:"apikey"`
+}
+
+// attribute returns the value of the auth attribute
+func attribute(attrs []KeyValue, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Event is a script run before (prerequest) or after (test) a request
+type Event struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec stringLines `json:"exec"`
+	} `json:"script"`
+}
+
+// stringLines are the lines of a script, Postman writes a single line as string
+type stringLines []string
+
+func (l *stringLines) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = strings.Split(s, "\n")
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// Variable is a variable of a collection or environment
+type Variable struct {
+	Key      string `json:"key"`
+	Value    value  `json:"value"`
+	Type     string `json:"type"` // default, secret, ...
+	Enabled  *bool  `json:"enabled"`
+	Disabled bool   `json:"disabled"`
+}
+
+// active reports whether the variable is used
+func (v Variable) active() bool {
+	return !v.Disabled && (v.Enabled == nil || *v.Enabled)
+}
+
+// value is a variable value, values which are no strings are kept as JSON text
+type value string
+
+func (v *value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = value(s)
+		return nil
+	}
+	*v = value(data)
+	return nil
+}
+
+// Environment is a Postman environment
+type Environment struct {
+	Name   string     `json:"name"`
+	Values []Variable `json:"values"`
+}
+
+// LoadCollection reads a collection file, only format v2.1 is supported
+func LoadCollection(path string) (*Collection, error) {
+	var c Collection
+	if err := load(path, &c); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(c.Info.Schema, "v2.1") {
+		return nil, fmt.Errorf("collection %s has schema %q, only v2.1 collections are supported (export the collection as v2.1)", path, c.Info.Schema)
+	}
+	return &c, nil
+}
+
+// LoadEnvironment reads an environment file
+func LoadEnvironment(path string) (*Environment, error) {
+	var env Environment
+	if err := load(path, &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeForm returns the fields of an urlencoded body
+func encodeForm(fields []KeyValue) string {
+	var parts []string
+	for _, f := range fields {
+		if !f.Disabled {
+			parts = append(parts, url.QueryEscape(f.Key)+"="+url.QueryEscape(f.Value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
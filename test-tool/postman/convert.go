@@ -0,0 +1,352 @@
+package postman
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options control the generated feature
+type Options struct {
+	Config          string // configuration file of the Background, e.g. elsa_services.json
+	BaseURLVariable string // variable holding the ELSA API base URL, it is set from the configuration
+}
+
+// DefaultOptions are used for the checked in example feature
+var DefaultOptions = Options{
+	Config:          "elsa_services.json",
+	BaseURLVariable: "baseUrl",
+}
+
+// Issue is an item of the collection which needs manual work
+type Issue struct {
+	Item   string // folder and request names, empty for the collection or environment
+	Reason string
+	Source string // the untranslated script line or setting
+}
+
+func (i Issue) String() string {
+	item := i.Item
+	if item == "" {
+		item = "(collection)"
+	}
+	return fmt.Sprintf("%s: %s: %s", item, i.Reason, i.Source)
+}
+
+// step is a line of a scenario, keyword is Given, When or Then, a comment has the keyword #
+type step struct {
+	keyword string
+	text    string
+	table   [][]string
+	doc     string // DocString
+}
+
+// scenario is a top level item of the collection
+type scenario struct {
+	name   string
+	steps  []step
+	manual bool
+}
+
+// converter holds the state of the conversion of a collection
+type converter struct {
+	opts    Options
+	known   map[string]bool // variables with a value at the current step, see variables
+	item    string          // item converted currently, for the issues
+	current *scenario
+	issues  []Issue
+}
+
+// variablePattern matches a Postman variable like {{baseUrl}} or {{$guid}}
+var variablePattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// dynamicVariables are the Postman dynamic variables with an equivalent fixture helper
+var dynamicVariables = map[string]string{
+	"$isoTimestamp": "{{now}}",
+}
+
+// Convert writes the feature of the collection, the environment is optional. It returns the items which
+// were not translated, they are marked MANUAL in the feature.
+func Convert(w io.Writer, c *Collection, env *Environment, opts Options) ([]Issue, error) {
+	cv := &converter{opts: opts, known: make(map[string]bool)}
+	background := cv.background(c, env)
+	backgroundKnown := cv.known
+
+	var scenarios []*scenario
+	for _, item := range c.Item {
+		cv.known = make(map[string]bool, len(backgroundKnown))
+		for name := range backgroundKnown {
+			cv.known[name] = true
+		}
+		cv.current = &scenario{name: item.Name}
+		cv.convertItem(item, nil, []*Auth{c.Auth}, [][]Event{c.Event})
+		scenarios = append(scenarios, cv.current)
+	}
+	if len(scenarios) == 0 {
+		return cv.issues, fmt.Errorf("collection %q has no requests", c.Info.Name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Code generated by postman2feature from the Postman collection %q.\n", c.Info.Name)
+	b.WriteString("# Review the MANUAL comments, the scenarios with untranslated items are tagged @manual.\n")
+	b.WriteString("@postman\n")
+	fmt.Fprintf(&b, "Feature: %s\n\n", c.Info.Name)
+	b.WriteString("  Background:\n")
+	writeSteps(&b, background)
+	for _, sc := range scenarios {
+		b.WriteString("\n")
+		if sc.manual {
+			b.WriteString("  @manual\n")
+		}
+		fmt.Fprintf(&b, "  Scenario: %s\n", sc.name)
+		writeSteps(&b, sc.steps)
+	}
+	_, err := io.WriteString(w, b.String())
+	return cv.issues, err
+}
+
+// background sets the configuration and the variables of the environment and the collection, the
+// collection variables take precedence like in Postman
+func (cv *converter) background(c *Collection, env *Environment) []step {
+	steps := []step{{keyword: "Given", text: fmt.Sprintf("the system is configured from %q", cv.opts.Config)}}
+	if cv.opts.BaseURLVariable != "" {
+		steps = append(steps, step{keyword: "Given", text: fmt.Sprintf("the value %q is the ELSA API base URL", cv.opts.BaseURLVariable)})
+		cv.known[cv.opts.BaseURLVariable] = true
+	}
+
+	values := make(map[string]string)
+	var vars []Variable
+	if env != nil {
+		vars = append(vars, env.Values...)
+	}
+	vars = append(vars, c.Variable...)
+	for _, v := range vars {
+		if !v.active() || v.Key == cv.opts.BaseURLVariable {
+			continue
+		}
+		if v.Type == "secret" {
+			cv.issues = append(cv.issues, Issue{Reason: "secret variable not written to the feature, provide its value", Source: v.Key})
+			continue
+		}
+		values[v.Key] = string(v.Value)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+		cv.known[name] = true
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		table := [][]string{{"Name", "Value"}}
+		for _, name := range names {
+			table = append(table, []string{name, cv.variables(values[name], "variable "+name)})
+		}
+		steps = append(steps, step{keyword: "Given", text: "the values are:", table: table})
+	}
+	return steps
+}
+
+// convertItem appends the steps of the request or of the requests of the folder to the current scenario,
+// auths and events are the ones of the enclosing folders, outermost first
+func (cv *converter) convertItem(item Item, path []string, auths []*Auth, events [][]Event) {
+	path = append(path, item.Name)
+	auths = append(auths, item.Auth)
+	events = append(events, item.Event)
+	if item.Request == nil {
+		for _, child := range item.Item {
+			cv.convertItem(child, path, auths, events)
+		}
+		return
+	}
+	cv.item = strings.Join(path, " / ")
+	name := strings.Join(path[1:], " / ") // below the folder of the scenario
+	if name == "" {
+		name = item.Name
+	}
+	cv.add(step{keyword: "#", text: strings.ToUpper(item.Request.Method) + " " + name})
+
+	sc := &script{cv: cv, aliases: make(map[string]bool)}
+	for _, evs := range events {
+		for _, ev := range evs {
+			if ev.Listen == "prerequest" {
+				cv.add(sc.translate(ev.Script.Exec, ev.Listen)...)
+			}
+		}
+	}
+	cv.request(item.Request, auths)
+	for _, evs := range events {
+		for _, ev := range evs {
+			if ev.Listen == "test" {
+				cv.add(sc.translate(ev.Script.Exec, ev.Listen)...)
+			}
+		}
+	}
+}
+
+// request adds the steps sending the request
+func (cv *converter) request(req *Request, auths []*Auth) {
+	headers := [][]string{{"Header", "Value"}}
+	hasHeader := func(name string) bool {
+		for _, h := range headers[1:] {
+			if strings.EqualFold(h[0], name) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, h := range req.Header {
+		if !h.Disabled {
+			headers = append(headers, []string{h.Key, cv.variables(h.Value, "header "+h.Key)})
+		}
+	}
+
+	auth := req.Auth
+	for i := len(auths) - 1; auth == nil && i >= 0; i-- {
+		auth = auths[i]
+	}
+	if auth != nil {
+		switch auth.Type {
+		case "", "noauth":
+		case "bearer":
+			headers = append(headers, []string{"Authorization", "Bearer " + cv.variables(attribute(auth.Bearer, "token"), "bearer token")})
+		case "basic":
+			user, password := attribute(auth.Basic, "username"), attribute(auth.Basic, "password")
+			if strings.Contains(user+password, "{{") {
+				cv.add(cv.manual("basic auth with variables", user+":"+password))
+				break
+			}
+			headers = append(headers, []string{"Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))})
+		case "apikey":
+			if attribute(auth.APIKey, "in") == "query" {
+				cv.add(cv.manual("API key in the query", attribute(auth.APIKey, "key")))
+				break
+			}
+			headers = append(headers, []string{attribute(auth.APIKey, "key"), cv.variables(attribute(auth.APIKey, "value"), "API key")})
+		default:
+			cv.add(cv.manual("unsupported auth", auth.Type))
+		}
+	}
+
+	var body string
+	if req.Body != nil {
+		switch req.Body.Mode {
+		case "", "none":
+		case "raw":
+			body = cv.variables(req.Body.Raw, "body")
+			if lang := req.Body.Options.Raw.Language; !hasHeader("Content-Type") && (lang == "json" || lang == "xml") {
+				headers = append(headers, []string{"Content-Type", "application/" + lang}) // set by Postman
+			}
+		case "urlencoded":
+			form := encodeForm(req.Body.URLEncoded)
+			if strings.Contains(form, "%7B%7B") {
+				cv.add(cv.manual("urlencoded body with variables", form))
+				break
+			}
+			body = form
+			if !hasHeader("Content-Type") {
+				headers = append(headers, []string{"Content-Type", "application/x-www-form-urlencoded"})
+			}
+		default:
+			cv.add(cv.manual("unsupported body mode", req.Body.Mode))
+		}
+	}
+
+	if len(headers) > 1 {
+		cv.add(step{keyword: "Given", text: "the request headers are:", table: headers})
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	target := cv.variables(req.URL.Raw, "URL")
+	if body != "" {
+		cv.add(step{keyword: "When", text: fmt.Sprintf(`I send a %s request to "%s" with body:`, method, target), doc: body})
+		return
+	}
+	cv.add(step{keyword: "When", text: fmt.Sprintf(`I send a %s request to "%s"`, method, target)})
+}
+
+// variables replaces the Postman variables in text by references to the values of the scenario,
+// variables without value at this point and dynamic variables without equivalent are reported
+func (cv *converter) variables(text, where string) string {
+	return variablePattern.ReplaceAllStringFunc(text, func(m string) string {
+		name := strings.TrimSpace(m[2 : len(m)-2])
+		if strings.HasPrefix(name, "$") {
+			if helper, ok := dynamicVariables[name]; ok {
+				return helper
+			}
+			cv.add(cv.manual("dynamic variable in "+where, m))
+			return m
+		}
+		if !cv.known[name] {
+			cv.add(cv.manual("variable in "+where+" is not set before", m))
+		}
+		return fmt.Sprintf("{{ref %q}}", name)
+	})
+}
+
+// manual records an issue of the current item, it returns the comment marking it in the scenario
+func (cv *converter) manual(reason, source string) step {
+	cv.issues = append(cv.issues, Issue{Item: cv.item, Reason: reason, Source: source})
+	if cv.current != nil {
+		cv.current.manual = true
+	}
+	return step{keyword: "#", text: fmt.Sprintf("MANUAL: %s: %s", reason, source)}
+}
+
+func (cv *converter) add(steps ...step) {
+	if cv.current != nil {
+		cv.current.steps = append(cv.current.steps, steps...)
+	}
+}
+
+// writeSteps writes the steps, a keyword repeating the one of the previous step is written as And
+func writeSteps(b *strings.Builder, steps []step) {
+	previous := ""
+	for _, s := range steps {
+		if s.keyword == "#" {
+			fmt.Fprintf(b, "    # %s\n", s.text)
+			continue
+		}
+		keyword := s.keyword
+		if keyword == previous {
+			keyword = "And"
+		}
+		previous = s.keyword
+		fmt.Fprintf(b, "    %s %s\n", keyword, s.text)
+		if s.table != nil {
+			writeTable(b, "      ", s.table)
+		}
+		if s.doc != "" {
+			b.WriteString(`      """` + "\n")
+			for _, line := range strings.Split(strings.TrimRight(s.doc, "\n"), "\n") {
+				b.WriteString("      " + line + "\n")
+			}
+			b.WriteString(`      """` + "\n")
+		}
+	}
+}
+
+// writeTable writes the rows as Gherkin table with aligned columns, pipes in cells are escaped
+func writeTable(b *strings.Builder, indent string, rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(escapeCell(cell)))
+		}
+	}
+	for _, row := range rows {
+		b.WriteString(indent + "|")
+		for i, cell := range row {
+			fmt.Fprintf(b, " %-*s |", widths[i], escapeCell(cell))
+		}
+		b.WriteString("\n")
+	}
+}
+
+func escapeCell(cell string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", `\n`).Replace(cell)
+}
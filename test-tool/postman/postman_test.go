@@ -0,0 +1,138 @@
+package postman
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+const generatedFeature = "../features/postman/elsa_api.feature"
+
+func TestGeneratedFeatureIsUpToDate(t *testing.T) {
+	c, err := LoadCollection("../testdata/postman/elsa_api.postman_collection.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := LoadEnvironment("../testdata/postman/elsa_local.postman_environment.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	issues, err := Convert(&b, c, env, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Item != "Instruction lifecycle / Get audit trail" {
+		t.Errorf("issues: got %v", issues)
+	}
+	data, err := os.ReadFile(generatedFeature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != b.String() {
+		t.Errorf("%s is outdated, run go generate ./postman", generatedFeature)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	collection := `{
+	  "info": {"name": "Conversions", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+	  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+	  "variable": [{"key": "page", "value": 1}, {"key": "off", "value": "x", "disabled": true}],
+	  "item": [
+	    {"name": "Folder", "item": [
+	      {"name": "Sub", "auth": {"type": "basic", "basic": [{"key": "username", "value": "u"}, {"key": "password", "value": "p"}]}, "item": [
+	        {"name": "Search", "request": {"method": "GET",
+	          "url": {"protocol": "https", "host": ["elsa", "example"], "path": ["instructions"], "query": [{"key": "page", "value": "{{page}}"}, {"key": "size", "value": "5", "disabled": true}]}},
+	         "event": [{"listen": "prerequest", "script": {"exec": "pm.environment.set('status', 'created');\nlet n = Math.random();"}},
+	                   {"listen": "test", "script": {"exec": [
+	                     "pm.test('one line', function () { pm.response.to.be.ok; });",
+	                     "const body = pm.response.json()",
+	                     "pm.expect(body['items'][0].status).to.equal(pm.variables.get('status'))",
+	                     "pm.expect(body).to.have.property('total.count', 2);",
+	                     "pm.expect(body.ok).to.be.true;",
+	                     "pm.response.to.have.header('X-Request-Id');",
+	                     "pm.expect(pm.response.code).to.eql(200);",
+	                     "postman.setNextRequest('Search');"
+	                   ]}}]}
+	      ]},
+	      {"name": "Upload", "request": {"method": "POST", "url": "{{baseUrl}}/upload?at={{$isoTimestamp}}&id={{$guid}}",
+	        "body": {"mode": "formdata", "formdata": [{"key": "file", "type": "file"}]}}}
+	    ]},
+	    {"name": "Form", "request": {"method": "post", "auth": {"type": "noauth"}, "url": "{{baseUrl}}/form/{{missing}}",
+	      "body": {"mode": "urlencoded", "urlencoded": [{"key": "a b", "value": "c&d"}]}}}
+	  ]
+	}`
+	env := `{"name": "Test", "values": [{"key": "token", "value": "secret", "type": "secret", "enabled": true}, {"key": "baseUrl", "value": "http://x"}]}`
+
+	var c Collection
+	var e Environment
+	if err := json.Unmarshal([]byte(collection), &c); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(env), &e); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	issues, err := Convert(&b, &c, &e, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"      | page | 1     |\n",
+		"      | Authorization | Basic dTpw |\n", // the auth of the sub-folder overrides the one of the collection
+		`When I send a GET request to "https://elsa.example/instructions?page={{ref "page"}}"`,
+		"      | status | created |\n",
+		"    # one line\n    Then the response status should be 200\n",
+		`And the response JSON "items[0].status" should be "{{ref "status"}}"`,
+		`And the response JSON "['total.count']" should be "2"`,
+		`And the response JSON "ok" should be "true"`,
+		`And the response should have the header "X-Request-Id"`,
+		"    # MANUAL: test script: postman.setNextRequest('Search');\n",
+		"    # POST Upload\n",
+		`| Authorization | Bearer {{ref "token"}} |`,
+		`When I send a POST request to "{{ref "baseUrl"}}/upload?at={{now}}&id={{$guid}}"`,
+		"  Scenario: Form\n    # POST Form\n",
+		`When I send a POST request to "{{ref "baseUrl"}}/form/{{ref "missing"}}" with body:`,
+		"      a+b=c%26d\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "| off ") || strings.Contains(out, "secret") || strings.Contains(out, "size=5") {
+		t.Errorf("disabled or secret values written:\n%s", out)
+	}
+	if strings.Count(out, "  @manual\n") != 2 {
+		t.Errorf("both scenarios should be tagged @manual:\n%s", out)
+	}
+
+	var reasons []string
+	for _, issue := range issues {
+		reasons = append(reasons, issue.Reason)
+	}
+	want := []string{
+		"secret variable not written to the feature, provide its value",
+		"prerequest script",
+		"test script",
+		"variable in bearer token is not set before",
+		"unsupported body mode",
+		"dynamic variable in URL",
+		"variable in URL is not set before",
+	}
+	if strings.Join(reasons, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues: got\n%s\nwant\n%s", strings.Join(reasons, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadCollectionRejectsOtherVersions(t *testing.T) {
+	path := t.TempDir() + "/v2.0.json"
+	if err := os.WriteFile(path, []byte(`{"info": {"name": "old", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCollection(path); err == nil || !strings.Contains(err.Error(), "v2.1") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package postman
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Translation of the statements of the pre-request and test scripts. Only single line statements of the
+// pm.* API with a direct equivalent step are translated, everything else is left for manual work.
+
+var (
+	testStart     = regexp.MustCompile(`^pm\.test\(\s*("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')\s*,\s*(?:function\s*\(\s*\)|\(\s*\)\s*=>)\s*\{?(.*)$`)
+	responseAlias = regexp.MustCompile(`^(?:var|let|const)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:pm\.response\.json\(\)|JSON\.parse\(responseBody\))$`)
+	expectation   = regexp.MustCompile(`^pm\.expect\((.+)\)\.to\.([A-Za-z.]+?)(?:\((.*)\))?$`)
+	responseCheck = regexp.MustCompile(`^pm\.response\.to\.([A-Za-z.]+?)(?:\((.*)\))?$`)
+	setVariable   = regexp.MustCompile(`^pm\.(?:environment|collectionVariables|globals|variables)\.set\((.*)\)$`)
+	getVariable   = regexp.MustCompile(`^pm\.(?:environment|collectionVariables|globals|variables)\.get\((.*)\)$`)
+	headerGet     = regexp.MustCompile(`^pm\.response\.headers\.get\((.*)\)$`)
+	jsonAccess    = regexp.MustCompile(`^(?:\.([A-Za-z_$][\w$]*)|\[(\d+)\]|\[("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')\])`)
+	identifier    = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+)
+
+// script translates the statements of the scripts of one request
+type script struct {
+	cv      *converter
+	aliases map[string]bool // variables holding the parsed response body
+}
+
+// translate returns the steps of the script lines, the listen type is prerequest or test
+func (sc *script) translate(lines []string, listen string) []step {
+	var steps []step
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if m := testStart.FindStringSubmatch(line); m != nil {
+			name, _ := stringLiteral(m[1])
+			steps = append(steps, step{keyword: "#", text: name})
+			line = strings.TrimSpace(m[2])
+		}
+		line = statement(line)
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "console.log(") {
+			continue
+		}
+		var translated []step
+		var ok bool
+		if listen == "test" {
+			translated, ok = sc.test(line)
+		} else {
+			translated, ok = sc.prerequest(line)
+		}
+		if !ok {
+			steps = append(steps, sc.cv.manual(fmt.Sprintf("%s script", listen), strings.TrimSpace(raw)))
+			continue
+		}
+		steps = append(steps, translated...)
+	}
+	return steps
+}
+
+// prerequest translates a statement of a pre-request script, only variables set to literals are supported
+func (sc *script) prerequest(line string) ([]step, bool) {
+	if m := setVariable.FindStringSubmatch(line); m != nil {
+		args := splitArgs(m[1])
+		if len(args) != 2 {
+			return nil, false
+		}
+		name, ok := stringLiteral(args[0])
+		value, ok2 := sc.literal(args[1])
+		if !ok || !ok2 {
+			return nil, false
+		}
+		sc.cv.known[name] = true
+		return []step{{keyword: "Given", text: "the values are:", table: [][]string{{"Name", "Value"}, {name, value}}}}, true
+	}
+	return nil, false
+}
+
+// test translates a statement of a test script into steps checking the last response
+func (sc *script) test(line string) ([]step, bool) {
+	then := func(format string, args ...interface{}) ([]step, bool) {
+		return []step{{keyword: "Then", text: fmt.Sprintf(format, args...)}}, true
+	}
+	if m := responseAlias.FindStringSubmatch(line); m != nil {
+		sc.aliases[m[1]] = true
+		return nil, true
+	}
+	if m := setVariable.FindStringSubmatch(line); m != nil {
+		args := splitArgs(m[1])
+		if len(args) != 2 {
+			return nil, false
+		}
+		name, ok := stringLiteral(args[0])
+		if !ok {
+			return nil, false
+		}
+		if path, ok := sc.jsonPath(args[1]); ok {
+			sc.cv.known[name] = true
+			return then(`the response JSON "%s" is saved as "%s"`, path, name)
+		}
+		if steps, ok := sc.prerequest(line); ok {
+			return steps, true
+		}
+		return nil, false
+	}
+	if m := responseCheck.FindStringSubmatch(line); m != nil {
+		args := splitArgs(m[2])
+		switch {
+		case m[1] == "have.status" && len(args) == 1 && isInt(args[0]):
+			return then("the response status should be %s", args[0])
+		case m[1] == "be.ok" && len(args) == 0:
+			return then("the response status should be 200")
+		case m[1] == "have.header" && len(args) == 1:
+			if name, ok := stringLiteral(args[0]); ok {
+				return then(`the response should have the header "%s"`, name)
+			}
+		case m[1] == "have.header" && len(args) == 2:
+			name, ok := stringLiteral(args[0])
+			value, ok2 := sc.literal(args[1])
+			if ok && ok2 {
+				return then(`the response header "%s" should be "%s"`, name, value)
+			}
+		}
+		return nil, false
+	}
+	m := expectation.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	subject, assertion, args := strings.TrimSpace(m[1]), m[2], splitArgs(m[3])
+	switch subject {
+	case "pm.response.code":
+		if isEqual(assertion) && len(args) == 1 && isInt(args[0]) {
+			return then("the response status should be %s", args[0])
+		}
+		return nil, false
+	case "pm.response.responseTime":
+		if (assertion == "be.below" || assertion == "be.lessThan") && len(args) == 1 && isInt(args[0]) {
+			return then("the response time should be below %s ms", args[0])
+		}
+		return nil, false
+	case "pm.response.text()":
+		if value, ok := sc.singleLiteral(args); ok && (assertion == "include" || assertion == "contain" || assertion == "have.string") {
+			return then(`the response body should contain "%s"`, value)
+		}
+		return nil, false
+	}
+	if h := headerGet.FindStringSubmatch(subject); h != nil {
+		name, ok := stringLiteral(h[1])
+		value, ok2 := sc.singleLiteral(args)
+		switch {
+		case !ok || !ok2:
+		case isEqual(assertion):
+			return then(`the response header "%s" should be "%s"`, name, value)
+		case assertion == "include" || assertion == "contain":
+			return then(`the response header "%s" should contain "%s"`, name, value)
+		}
+		return nil, false
+	}
+
+	path, ok := sc.jsonPath(subject)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case isEqual(assertion):
+		if value, ok := sc.singleLiteral(args); ok {
+			return then(`the response JSON "%s" should be "%s"`, path, value)
+		}
+	case assertion == "be.true" || assertion == "be.false" || assertion == "be.null":
+		return then(`the response JSON "%s" should be "%s"`, path, strings.TrimPrefix(assertion, "be."))
+	case assertion == "exist" || assertion == "not.be.undefined" || assertion == "be.not.undefined":
+		return then(`the response JSON "%s" should exist`, path)
+	case (assertion == "have.lengthOf" || assertion == "have.length") && len(args) == 1 && isInt(args[0]):
+		return then(`the response JSON "%s" should have %s items`, path, args[0])
+	case assertion == "have.property" && (len(args) == 1 || len(args) == 2):
+		key, ok := stringLiteral(args[0])
+		if !ok {
+			return nil, false
+		}
+		path = joinPath(path, key)
+		if len(args) == 1 {
+			return then(`the response JSON "%s" should exist`, path)
+		}
+		if value, ok := sc.literal(args[1]); ok {
+			return then(`the response JSON "%s" should be "%s"`, path, value)
+		}
+	}
+	return nil, false
+}
+
+// jsonPath returns the path of the step for an access of the parsed response body, e.g. jsonData.status[0].name
+func (sc *script) jsonPath(expr string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	var rest string
+	switch {
+	case strings.HasPrefix(expr, "pm.response.json()"):
+		rest = strings.TrimPrefix(expr, "pm.response.json()")
+	default:
+		end := strings.IndexAny(expr, ".[")
+		if end < 0 {
+			end = len(expr)
+		}
+		if !sc.aliases[expr[:end]] {
+			return "", false
+		}
+		rest = expr[end:]
+	}
+	path := ""
+	for rest != "" {
+		m := jsonAccess.FindStringSubmatch(rest)
+		if m == nil {
+			return "", false
+		}
+		rest = rest[len(m[0]):]
+		switch {
+		case m[1] != "":
+			path = joinPath(path, m[1])
+		case m[2] != "":
+			path += "[" + m[2] + "]"
+		default:
+			key, ok := stringLiteral(m[3])
+			if !ok {
+				return "", false
+			}
+			path = joinPath(path, key)
+		}
+	}
+	return path, true
+}
+
+// joinPath appends a key to a path of the response JSON, keys which are no identifiers are quoted with
+// single quotes, the path is a quoted argument of the steps
+func joinPath(path, key string) string {
+	if !identifier.MatchString(key) {
+		return path + "['" + key + "']"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// literal returns the text of a literal value or of a variable reference of the scripts, a variable is
+// referred to like in the translated URLs
+func (sc *script) literal(expr string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	if s, ok := stringLiteral(expr); ok {
+		return sc.cv.variables(s, "script value"), true
+	}
+	if m := getVariable.FindStringSubmatch(expr); m != nil {
+		name, ok := stringLiteral(m[1])
+		if !ok {
+			return "", false
+		}
+		return sc.cv.variables("{{"+name+"}}", "script value"), true
+	}
+	switch expr {
+	case "true", "false", "null":
+		return expr, true
+	}
+	if _, err := strconv.ParseFloat(expr, 64); err == nil {
+		return expr, true
+	}
+	return "", false
+}
+
+func (sc *script) singleLiteral(args []string) (string, bool) {
+	if len(args) != 1 {
+		return "", false
+	}
+	return sc.literal(args[0])
+}
+
+// statement returns the statement of a script line without the semicolon and the closing brackets of an
+// enclosing pm.test, a line only closing brackets is empty
+func statement(line string) string {
+	line = strings.TrimRight(strings.TrimSpace(line), "; ")
+	if strings.Trim(line, "}); ") == "" {
+		return ""
+	}
+	for strings.HasSuffix(line, "})") && strings.Count(line, ")") > strings.Count(line, "(") {
+		line = strings.TrimRight(strings.TrimSuffix(line, "})"), "; ")
+	}
+	return line
+}
+
+// isEqual reports whether the assertion of the chai chain is an equality
+func isEqual(assertion string) bool {
+	switch assertion {
+	case "eql", "equal", "eq", "equals", "be.equal", "be.eql", "deep.equal", "deep.eql":
+		return true
+	}
+	return false
+}
+
+func isInt(s string) bool {
+	_, err := strconv.Atoi(strings.TrimSpace(s))
+	return err == nil
+}
+
+// stringLiteral returns the value of a JavaScript string in single or double quotes
+func stringLiteral(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'') || s[len(s)-1] != s[0] {
+		return "", false
+	}
+	if s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	v, err := strconv.Unquote(s)
+	return v, err == nil
+}
+
+// splitArgs splits the arguments of a call at the commas outside of strings and parentheses
+func splitArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var args []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
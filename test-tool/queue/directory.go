@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	messageExt = ".xml"       // message body, the file name without extension is the message ID
+	metaExt    = ".meta.json" // optional header fields of the message
+	tmpExt     = ".tmp"       // files being written, renamed when complete
+)
+
+// DirectoryBroker maps every queue to a directory (the mock MQ of the test tool). A message is stored as
+// '<ID>.xml' with its header fields in '<ID>.meta.json'. Files dropped into the directory by other tools
+// are read as well, their file name is the message ID and the modification time the put time.
+type DirectoryBroker struct{}
+
+// NewDirectoryBroker creates a broker with directory queues
+func NewDirectoryBroker() *DirectoryBroker {
+	return &DirectoryBroker{}
+}
+
+// Queue returns the queue stored in the directory 'name', the directory is created by Put
+func (b *DirectoryBroker) Queue(name string) (Queue, error) {
+	if name == "" {
+		return nil, fmt.Errorf("directory queue without path")
+	}
+	return &directoryQueue{dir: name}, nil
+}
+
+func (b *DirectoryBroker) Close() error {
+	return nil
+}
+
+type directoryQueue struct {
+	dir string
+}
+
+// messageMeta is the content of the '.meta.json' file
+type messageMeta struct {
+	CorrelationID string            `json:"correlationId,omitempty"`
+	Properties    map[string]string `json:"properties,omitempty"`
+	PutTime       time.Time         `json:"putTime"`
+}
+
+func (q *directoryQueue) Name() string {
+	return q.dir
+}
+
+// Put writes the message, the body is renamed into place last, so readers never see a partial message
+func (q *directoryQueue) Put(ctx context.Context, msg *Message) error {
+	prepare(msg)
+	if strings.ContainsAny(msg.ID, `/\`) {
+		return fmt.Errorf("message ID %q is not usable as file name", msg.ID)
+	}
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create queue directory %s: %w", q.dir, err)
+	}
+
+	base := filepath.Join(q.dir, msg.ID)
+	meta, err := json.Marshal(messageMeta{msg.CorrelationID, msg.Properties, msg.PutTime})
+	if err != nil {
+		return fmt.Errorf("failed to marshal header of message %s: %w", msg.ID, err)
+	}
+	if err := os.WriteFile(base+metaExt, meta, 0644); err != nil {
+		return fmt.Errorf("failed to write header of message %s: %w", msg.ID, err)
+	}
+	if err := os.WriteFile(base+messageExt+tmpExt, msg.Body, 0644); err != nil {
+		return fmt.Errorf("failed to write message %s: %w", msg.ID, err)
+	}
+	if err := os.Rename(base+messageExt+tmpExt, base+messageExt); err != nil {
+		return fmt.Errorf("failed to write message %s: %w", msg.ID, err)
+	}
+	return nil
+}
+
+func (q *directoryQueue) Get(ctx context.Context, sel Selector) (*Message, error) {
+	msgs, err := q.Browse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		if !sel.Matches(msg) {
+			continue
+		}
+		base := filepath.Join(q.dir, msg.ID)
+		if err := os.Remove(base + messageExt); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // taken by a concurrent reader
+			}
+			return nil, fmt.Errorf("failed to remove message %s: %w", msg.ID, err)
+		}
+		os.Remove(base + metaExt)
+		return msg, nil
+	}
+	return nil, ErrEmpty
+}
+
+func (q *directoryQueue) Browse(ctx context.Context) ([]*Message, error) {
+	entries, err := os.ReadDir(q.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory %s: %w", q.dir, err)
+	}
+
+	var msgs []*Message
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), messageExt) {
+			continue
+		}
+		msg, err := q.read(strings.TrimSuffix(e.Name(), messageExt))
+		if err != nil {
+			continue // removed by a concurrent Get
+		}
+		msgs = append(msgs, msg)
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		if !msgs[i].PutTime.Equal(msgs[j].PutTime) {
+			return msgs[i].PutTime.Before(msgs[j].PutTime)
+		}
+		return msgs[i].ID < msgs[j].ID
+	})
+	return msgs, nil
+}
+
+func (q *directoryQueue) read(id string) (*Message, error) {
+	base := filepath.Join(q.dir, id)
+	body, err := os.ReadFile(base + messageExt)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{ID: id, Body: body}
+
+	var meta messageMeta
+	if data, err := os.ReadFile(base + metaExt); err == nil && json.Unmarshal(data, &meta) == nil {
+		msg.CorrelationID = meta.CorrelationID
+		msg.Properties = meta.Properties
+		msg.PutTime = meta.PutTime
+	} else if info, err := os.Stat(base + messageExt); err == nil {
+		msg.PutTime = info.ModTime()
+	}
+	return msg, nil
+}
+
+func (q *directoryQueue) Purge(ctx context.Context) error {
+	if err := os.RemoveAll(q.dir); err != nil {
+		return fmt.Errorf("failed to purge queue directory %s: %w", q.dir, err)
+	}
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return fmt.Errorf("failed to recreate queue directory %s: %w", q.dir, err)
+	}
+	return nil
+}
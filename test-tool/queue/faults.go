@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by Put of a queue with a fault of kind FaultFail
+var ErrInjectedFault = errors.New("injected queue fault")
+
+// FaultKind is what happens to a message put to a faulty queue
+type FaultKind string
+
+const (
+	FaultFail      FaultKind = "fail"      // Put fails with ErrInjectedFault, the message is not put
+	FaultDrop      FaultKind = "drop"      // Put succeeds, but the message is lost
+	FaultDuplicate FaultKind = "duplicate" // the message is put twice, the copy gets a new message ID
+	FaultReorder   FaultKind = "reorder"   // the message is held back and put after the next message of the queue
+	FaultDelay     FaultKind = "delay"     // Put waits for Delay before the message is put
+)
+
+// Fault is a rule of Faults, it applies to the messages put to a queue
+type Fault struct {
+	ID            string        `json:"id,omitempty"`            // assigned by Add
+	Kind          FaultKind     `json:"kind"`                    // see the FaultKind constants
+	Queue         string        `json:"queue,omitempty"`         // name of the queue, all queues if empty
+	CorrelationID string        `json:"correlationId,omitempty"` // e.g. the client TXID, all messages if empty
+	Delay         time.Duration `json:"-"`                       // of FaultDelay, "delay" in JSON, e.g. "2s"
+	Times         int           `json:"times,omitempty"`         // number of messages the fault applies to, all if 0
+}
+
+// MarshalJSON writes the delay as duration string like "1.5s"
+func (f Fault) MarshalJSON() ([]byte, error) {
+	type plain Fault // without the methods, no recursion
+	out := struct {
+		plain
+		Delay string `json:"delay,omitempty"`
+	}{plain: plain(f)}
+	if f.Delay > 0 {
+		out.Delay = f.Delay.String()
+	}
+	return json.Marshal(out)
+}
+
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	type plain Fault
+	in := struct {
+		*plain
+		Delay string `json:"delay,omitempty"`
+	}{plain: (*plain)(f)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Delay != "" {
+		d, err := time.ParseDuration(in.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay of queue fault: %w", err)
+		}
+		f.Delay = d
+	}
+	return nil
+}
+
+func (f *Fault) matches(queueName string, msg *Message) bool {
+	return (f.Queue == "" || f.Queue == queueName) && (f.CorrelationID == "" || f.CorrelationID == msg.CorrelationID)
+}
+
+// Faults is a set of fault rules for the queues wrapped by it. The first matching rule applies to a
+// message, rules with Times are removed once they have been applied that often.
+type Faults struct {
+	mu     sync.Mutex
+	lastID int
+	faults []*Fault
+	held   map[string][]*Message // messages held back by FaultReorder, keyed by queue name
+}
+
+// NewFaults returns an empty set of fault rules
+func NewFaults() *Faults {
+	return &Faults{held: make(map[string][]*Message)}
+}
+
+// Add adds the rule and returns it with its ID
+func (f *Faults) Add(fault Fault) (Fault, error) {
+	switch fault.Kind {
+	case FaultFail, FaultDrop, FaultDuplicate, FaultReorder:
+	case FaultDelay:
+		if fault.Delay <= 0 {
+			return fault, fmt.Errorf("queue fault %s requires a delay", fault.Kind)
+		}
+	default:
+		return fault, fmt.Errorf("unknown queue fault %q, expected %s, %s, %s, %s or %s",
+			fault.Kind, FaultFail, FaultDrop, FaultDuplicate, FaultReorder, FaultDelay)
+	}
+	if fault.Times < 0 {
+		return fault, fmt.Errorf("times of queue fault must not be negative")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastID++
+	fault.ID = "q" + strconv.Itoa(f.lastID)
+	f.faults = append(f.faults, &fault)
+	return fault, nil
+}
+
+// Remove removes the rule with the ID, it reports whether there was one
+func (f *Faults) Remove(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, fault := range f.faults {
+		if fault.ID == id {
+			f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all rules, messages held back are dropped
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+	f.held = make(map[string][]*Message)
+}
+
+// List returns the rules in the order they are checked
+func (f *Faults) List() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := make([]Fault, len(f.faults))
+	for i, fault := range f.faults {
+		res[i] = *fault
+	}
+	return res
+}
+
+// Wrap returns q with the faults applied to its Put, q itself if f is nil
+func (f *Faults) Wrap(q Queue) Queue {
+	if f == nil {
+		return q
+	}
+	return &faultyQueue{Queue: q, faults: f}
+}
+
+// take returns the rule applying to the message and counts it down. Unless the message is lost
+// or held back itself, the messages held back before are released to be put after it.
+func (f *Faults) take(queueName string, msg *Message) (fault *Fault, released []*Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, candidate := range f.faults {
+		if !candidate.matches(queueName, msg) {
+			continue
+		}
+		applied := *candidate
+		if candidate.Times > 0 {
+			if candidate.Times--; candidate.Times == 0 {
+				f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			}
+		}
+		fault = &applied
+		break
+	}
+	if fault != nil {
+		switch fault.Kind {
+		case FaultFail, FaultDrop:
+			return fault, nil
+		case FaultReorder:
+			prepare(msg)
+			f.held[queueName] = append(f.held[queueName], clone(msg))
+			return fault, nil
+		}
+	}
+	released = f.held[queueName]
+	delete(f.held, queueName)
+	return fault, released
+}
+
+// faultyQueue applies the rules of faults to the messages put to the wrapped queue
+type faultyQueue struct {
+	Queue
+	faults *Faults
+}
+
+func (q *faultyQueue) Put(ctx context.Context, msg *Message) error {
+	fault, released := q.faults.take(q.Name(), msg)
+	if fault != nil {
+		fmt.Printf("MQ: Fault %s (%s) applied to message for %s\n", fault.ID, fault.Kind, q.Name())
+		switch fault.Kind {
+		case FaultFail:
+			return fmt.Errorf("%w %s: put to %s failed", ErrInjectedFault, fault.ID, q.Name())
+		case FaultDrop:
+			prepare(msg)
+			return nil
+		case FaultReorder:
+			return nil
+		case FaultDelay:
+			select {
+			case <-time.After(fault.Delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	if err := q.Queue.Put(ctx, msg); err != nil {
+		return err
+	}
+	if fault != nil && fault.Kind == FaultDuplicate {
+		duplicate := clone(msg)
+		duplicate.ID = ""
+		if err := q.Queue.Put(ctx, duplicate); err != nil {
+			return err
+		}
+	}
+	for _, held := range released {
+		if err := q.Queue.Put(ctx, held); err != nil {
+			return err
+		}
+	}
+	return nil
+}
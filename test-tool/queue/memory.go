@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker keeps all queues in memory, messages are lost when the process ends
+type MemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+// NewMemoryBroker creates an empty in-memory broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// Queue returns the queue with the given name, it is created on first use
+func (b *MemoryBroker) Queue(name string) (Queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{name: name}
+		b.queues[name] = q
+	}
+	return q, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
+
+type memoryQueue struct {
+	name     string
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func (q *memoryQueue) Name() string {
+	return q.name
+}
+
+func (q *memoryQueue) Put(ctx context.Context, msg *Message) error {
+	prepare(msg)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = append(q.messages, clone(msg))
+	return nil
+}
+
+func (q *memoryQueue) Get(ctx context.Context, sel Selector) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, msg := range q.messages {
+		if sel.Matches(msg) {
+			q.messages = append(q.messages[:i:i], q.messages[i+1:]...)
+			return msg, nil
+		}
+	}
+	return nil, ErrEmpty
+}
+
+func (q *memoryQueue) Browse(ctx context.Context) ([]*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res := make([]*Message, len(q.messages))
+	for i, msg := range q.messages {
+		res[i] = clone(msg)
+	}
+	return res, nil
+}
+
+func (q *memoryQueue) Purge(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = nil
+	return nil
+}
+
+// clone copies the message, so neither the sender nor a browser can change a queued message
+func clone(msg *Message) *Message {
+	c := *msg
+	c.Body = append([]byte(nil), msg.Body...)
+	if msg.Properties != nil {
+		c.Properties = make(map[string]string, len(msg.Properties))
+		for k, v := range msg.Properties {
+			c.Properties[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"html/template"
+	"time"
+)
+
+// minWidth is the width in percent of events too short to be visible on the timeline
+const minWidth = 0.4
+
+type pageView struct {
+	Generated string
+	Failed    int
+	Scenarios []scenarioView
+}
+
+type scenarioView struct {
+	Name     string
+	URI      string
+	Failed   bool
+	Error    string
+	Duration time.Duration
+	Events   []eventView
+
+	start time.Time
+}
+
+type eventView struct {
+	Event
+	Offset time.Duration // since the start of the scenario
+	Left   float64       // position on the timeline in percent
+	Width  float64
+}
+
+// view positions the events of the scenario on its timeline
+func (s *Scenario) view() scenarioView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := s.end
+	if end.IsZero() { // scenario still running or aborted
+		end = s.start
+		for _, e := range s.events {
+			if t := e.Start.Add(e.Duration); t.After(end) {
+				end = t
+			}
+		}
+	}
+	v := scenarioView{
+		Name:     s.Name,
+		URI:      s.URI,
+		Failed:   s.err != "",
+		Error:    s.err,
+		Duration: end.Sub(s.start).Round(time.Millisecond),
+		start:    s.start,
+	}
+	total := float64(end.Sub(s.start))
+	for _, e := range s.events {
+		ev := eventView{Event: e, Offset: e.Start.Sub(s.start).Round(time.Millisecond), Width: minWidth}
+		ev.Duration = e.Duration.Round(time.Millisecond)
+		if total > 0 {
+			ev.Left = 100 * float64(e.Start.Sub(s.start)) / total
+			ev.Width = max(100*float64(e.Duration)/total, minWidth)
+			ev.Left = min(ev.Left, 100-ev.Width)
+		}
+		v.Events = append(v.Events, ev)
+	}
+	return v
+}
+
+var pageTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"kinds": func() []Kind { return []Kind{KindStep, KindSent, KindReceived, KindPoll, KindQuery, KindNote} },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ELSA test run</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+.scenario { border: 1px solid #ccc; margin: 1em 0; padding: 0.5em 1em; }
+.scenario.failed { border-color: #c33; }
+.scenario h2 { font-size: 1.1em; }
+.error { color: #c33; white-space: pre-wrap; }
+.timeline { position: relative; height: 1.4em; background: #f4f4f4; margin: 0.2em 0; }
+.timeline div { position: absolute; top: 0; bottom: 0; opacity: 0.8; }
+.step { background: #69c; } .step.failed { background: #c33; }
+.sent { background: #3a3; } .received { background: #a63; } .poll { background: #999; } .query { background: #96c; }
+.note { background: #cc3; } .note.error { background: #c33; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+td, th { border-bottom: 1px solid #eee; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+td.kind { width: 6em; } td.time { width: 6em; text-align: right; }
+pre { max-height: 30em; overflow: auto; background: #fafafa; }
+</style>
+</head>
+<body>
+<h1>ELSA test run</h1>
+<p>{{len .Scenarios}} scenarios, {{.Failed}} failed. Generated {{.Generated}}.</p>
+{{range .Scenarios}}
+<div class="scenario{{if .Failed}} failed{{end}}">
+<h2>{{if .Failed}}FAILED{{else}}passed{{end}}: {{.Name}} <small>({{.URI}}, {{.Duration}})</small></h2>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{- $events := .Events}}{{/* one lane per kind of event */}}
+{{range $kind := kinds}}<div class="timeline" title="{{$kind}}">{{range $events}}{{if eq .Kind $kind}}<div class="{{.Kind}} {{.Status}}" style="left: {{printf "%.2f" .Left}}%; width: {{printf "%.2f" .Width}}%" title="{{.Offset}} {{.Title}} {{.Status}} ({{.Duration}})"></div>{{end}}{{end}}</div>
+{{end}}
+<table>
+<tr><th>Offset</th><th>Kind</th><th>Event</th><th>Status</th><th>Duration</th></tr>
+{{range .Events}}<tr>
+<td class="time">{{.Offset}}</td><td class="kind">{{.Kind}}</td>
+<td>{{.Title}}{{if .Detail}}<br><small>{{.Detail}}</small>{{end}}{{if .Body}}<details><summary>content</summary><pre>{{.Body}}</pre></details>{{end}}</td>
+<td>{{.Status}}</td><td class="time">{{.Duration}}</td>
+</tr>
+{{end}}</table>
+</div>
+{{end}}
+</body>
+</html>
+`))
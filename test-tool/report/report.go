@@ -0,0 +1,159 @@
+// Package report records what the scenarios of a test run did: the messages put to and taken from
+// the queues, the calls of the ELSA API with their responses and the timings of the steps. At the end
+// of the run the records of all scenarios are written as an HTML timeline.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind is the kind of a recorded event
+type Kind string
+
+const (
+	KindStep     Kind = "step"     // a step of the scenario
+	KindSent     Kind = "sent"     // a message put to a queue by the test
+	KindReceived Kind = "received" // a message taken from a queue by the test
+	KindPoll     Kind = "poll"     // a call of the ELSA API
+	KindQuery    Kind = "query"    // a query of the ELSA database
+	KindNote     Kind = "note"     // a diagnostic of the steps, e.g. of the CREATION simulator
+)
+
+// Event is one thing a scenario did
+type Event struct {
+	Kind     Kind
+	Start    time.Time
+	Duration time.Duration
+	Title    string // step text, queue name or URL
+	Status   string // step status or HTTP status
+	Detail   string // message and correlation ID, error of a failed step
+	Body     string // message payload or response body
+}
+
+// Scenario collects the events of one scenario, its methods may be called concurrently.
+// A nil Scenario records nothing, so steps run outside of a recorded scenario need no checks.
+type Scenario struct {
+	Name string
+	URI  string
+
+	mu     sync.Mutex
+	start  time.Time
+	end    time.Time
+	err    string
+	events []Event
+}
+
+// Record adds the event, an event without start time started now
+func (s *Scenario) Record(e Event) {
+	if s == nil {
+		return
+	}
+	if e.Start.IsZero() {
+		e.Start = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+}
+
+// Events returns the events of the given kinds which started at or after 'since', all kinds if none are given
+func (s *Scenario) Events(since time.Time, kinds ...Kind) []Event {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []Event
+	for _, e := range s.events {
+		if e.Start.Before(since) || (len(kinds) > 0 && !hasKind(kinds, e.Kind)) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func hasKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Finish ends the scenario, err is the error it failed with
+func (s *Scenario) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+}
+
+// Report holds the scenarios of a test run
+type Report struct {
+	mu        sync.Mutex
+	scenarios []*Scenario
+}
+
+func New() *Report {
+	return &Report{}
+}
+
+// Scenario starts recording a scenario of the run, a nil Report returns a nil Scenario recording nothing
+func (r *Report) Scenario(name, uri string) *Scenario {
+	if r == nil {
+		return nil
+	}
+	s := &Scenario{Name: name, URI: uri, start: time.Now()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scenarios = append(r.scenarios, s)
+	return s
+}
+
+// WriteFile writes the HTML timeline to path, missing directories are created
+func (r *Report) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if err := r.WriteHTML(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteHTML writes the HTML timeline of all scenarios in the order they started
+func (r *Report) WriteHTML(w io.Writer) error {
+	r.mu.Lock()
+	scenarios := make([]*Scenario, len(r.scenarios))
+	copy(scenarios, r.scenarios)
+	r.mu.Unlock()
+
+	page := pageView{Generated: time.Now().Format(time.RFC3339)}
+	for _, s := range scenarios {
+		v := s.view()
+		if v.Failed {
+			page.Failed++
+		}
+		page.Scenarios = append(page.Scenarios, v)
+	}
+	sort.SliceStable(page.Scenarios, func(i, j int) bool { return page.Scenarios[i].start.Before(page.Scenarios[j].start) })
+	return pageTemplate.Execute(w, page)
+}
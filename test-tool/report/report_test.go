@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	r := New()
+	s := r.Scenario("Successful flow", "features/flow.feature")
+	start := time.Now()
+	s.Record(Event{Kind: KindSent, Start: start.Add(-time.Second), Title: "early"})
+	s.Record(Event{Kind: KindSent, Title: "T2S.CLIENT.REQUEST", Body: "<Document/>"})
+	s.Record(Event{Kind: KindPoll, Title: "GET /instructions/S1A"})
+
+	if got := s.Events(start); len(got) != 2 {
+		t.Errorf("got %d events since start, want 2", len(got))
+	}
+	if got := s.Events(time.Time{}, KindSent); len(got) != 2 || got[1].Body != "<Document/>" {
+		t.Errorf("unexpected sent events %+v", got)
+	}
+
+	var none *Scenario
+	none.Record(Event{Kind: KindStep}) // must not panic
+	if got := none.Events(time.Time{}); got != nil {
+		t.Errorf("nil scenario returned events %+v", got)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	r := New()
+	s := r.Scenario("Timeout <without> acceptance", "features/flow.feature")
+	s.Record(Event{Kind: KindStep, Duration: 20 * time.Millisecond, Title: "T2S sends the prepared message", Status: "passed"})
+	s.Record(Event{Kind: KindSent, Title: "T2S.CLIENT.REQUEST", Detail: "message S1A_msg", Body: `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:sese.023.001.10"/>`})
+	s.Record(Event{Kind: KindPoll, Duration: 3 * time.Millisecond, Title: "GET /instructions/S1A", Status: "404"})
+	s.Finish(errors.New("timeout after 1s"))
+	r.Scenario("Still running", "features/other.feature")
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"2 scenarios, 1 failed",
+		"FAILED: Timeout &lt;without&gt; acceptance",
+		"timeout after 1s",
+		"&lt;Document xmlns=",
+		`class="poll 404"`,
+		"passed: Still running",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "ZgotmplZ") {
+		t.Error("report contains values rejected by html/template")
+	}
+}
@@ -0,0 +1,139 @@
+package step_definitions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"test-tool/mock_elsa_server"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// The clock steps let timeouts and retries which take minutes or hours in ELSA happen within seconds: the
+// scenario takes over the clock of the mock ELSA server and advances it, the timers due meanwhile fire
+// before the step ends. With elsaTestClockUrl configured ELSA's test clock endpoint is moved along, it
+// is expected to speak the protocol of the control API of the mock:
+//
+//	PUT  <elsaTestClockUrl>          {"now": "2026-03-02T09:00:00Z"}  take over the clock at the time
+//	POST <elsaTestClockUrl>/advance  {"by": "30m0s"}                  advance it
+
+// timeUnits are the units of the clock steps
+var timeUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+func theTimeIsControlledByTheScenario(ctx context.Context) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	mockServer, err := ScenarioMockServer(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	state := mockServer.ControlClock(time.Time{})
+	if cfg.ElsaTestClockURL != "" {
+		body := fmt.Sprintf(`{"now": %q}`, state.Now.Format(time.RFC3339Nano))
+		if err := callTestClock(ctx, http.MethodPut, cfg.ElsaTestClockURL, body); err != nil {
+			return ctx, err
+		}
+	}
+	recordNote(ctx, "Clock", "", "time controlled by the scenario, standing at %s", state.Now.Format(time.RFC3339))
+	return ctx, nil
+}
+
+func timePasses(ctx context.Context, amount int, unit string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	mockServer, err := ScenarioMockServer(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	d := time.Duration(amount) * timeUnits[unit]
+	state, err := mockServer.AdvanceClock(d)
+	if err != nil {
+		return ctx, fmt.Errorf("%w, use the step \"the time is controlled by the scenario\" first", err)
+	}
+	if cfg.ElsaTestClockURL != "" {
+		body := fmt.Sprintf(`{"by": %q}`, d.String())
+		if err := callTestClock(ctx, http.MethodPost, strings.TrimSuffix(cfg.ElsaTestClockURL, "/")+"/advance", body); err != nil {
+			return ctx, err
+		}
+	}
+	recordNote(ctx, "Clock", "", "%s passed, the time is %s", d, state.Now.Format(time.RFC3339))
+	return ctx, nil
+}
+
+// callTestClock sends a request to ELSA's test clock endpoint, it is recorded like the API calls
+func callTestClock(ctx context.Context, method, target, body string) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("invalid test clock request %s %s: %w", method, target, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	call := report.Event{Kind: report.KindPoll, Start: start, Duration: time.Since(start), Title: method + " " + target, Detail: body, Body: string(respBody)}
+	if err != nil {
+		call.Status, call.Body = "error", err.Error()
+		scenarioRecorder(ctx).Record(call)
+		return fmt.Errorf("test clock request %s %s failed: %w", method, target, err)
+	}
+	call.Status = strconv.Itoa(resp.StatusCode)
+	scenarioRecorder(ctx).Record(call)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("test clock request %s %s returned status %d: %s", method, target, resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func theMockElsaAPIRetriesUnansweredMessages(ctx context.Context, amount int, unit string, retries int) (context.Context, error) {
+	mockServer, err := ScenarioMockServer(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	p := mock_elsa_server.RetryPolicy{Timeout: time.Duration(amount) * timeUnits[unit], MaxRetries: retries}
+	return ctx, mockServer.SetRetryPolicy(p)
+}
+
+func theMockElsaAPIShouldHaveRetriedInstruction(ctx context.Context, clientTXID string, expected int) (context.Context, error) {
+	mockServer, err := ScenarioMockServer(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	txID := scopedTXID(ctx, clientTXID)
+	state, ok := mockServer.Instruction(txID)
+	if !ok {
+		return ctx, fmt.Errorf("instruction %s not found in the mock ELSA API", txID)
+	}
+	if state.RetryCount != expected {
+		history, _ := json.Marshal(state.StatusHistory)
+		return ctx, fmt.Errorf("instruction %s was retried %d times, expected %d, history: %s", txID, state.RetryCount, expected, history)
+	}
+	return ctx, nil
+}
+
+func registerClockSteps(s *godog.ScenarioContext) {
+	s.Step(`^the time is controlled by the scenario$`, theTimeIsControlledByTheScenario)
+	s.Step(`^(\d+) (second|minute|hour|day)s? pass(?:es)?$`, timePasses)
+	s.Step(`^the mock ELSA API retries unanswered messages after (\d+) (second|minute|hour|day)s?, at most (\d+) times?$`, theMockElsaAPIRetriesUnansweredMessages)
+	s.Step(`^the mock ELSA API should have retried instruction "([^"]*)" (\d+) times?$`, theMockElsaAPIShouldHaveRetriedInstruction)
+}
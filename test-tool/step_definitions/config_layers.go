@@ -0,0 +1,520 @@
+package step_definitions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"test-tool/db"
+	"test-tool/queue"
+	"unicode"
+)
+
+// The configuration is built from layers, a later layer overrides the values of the earlier ones:
+//
+//  1. the defaults of configSchema
+//  2. the configuration file, e.g. testdata/config/elsa_services.json
+//  3. the profile file testdata/config/profiles/<profile>.json. The profile is local unless selected by
+//     the flag config.profile, the environment variable ELSA_TEST_PROFILE or per feature by the step
+//     "the configuration profile is".
+//  4. environment variables ELSA_TEST_<KEY>, e.g. ELSA_TEST_POLLING_TIMEOUT_SECONDS or ELSA_TEST_QUEUE_TRANSPORT_URL
+//  5. the flag config.set key=value, e.g. -config.set queueTransport.url=amqp://localhost:5672/
+//
+// Nested keys are joined by a dot, null resets a key to its default and "$comment" keys are ignored.
+// String values may refer to secrets, ${env:NAME} or ${file:path}, so credentials stay out of the files.
+
+const (
+	profileDir     = "testdata/config/profiles"
+	defaultProfile = "local"
+	profileEnv     = "ELSA_TEST_PROFILE"
+	envPrefix      = "ELSA_TEST_"
+	commentKey     = "$comment"
+)
+
+// configField describes a key of the configuration files
+type configField struct {
+	key      string      // JSON key, the keys of nested objects are joined by a dot
+	integer  bool        // a whole number, a string otherwise
+	def      interface{} // default value, nil for none
+	required bool
+	values   []string // allowed values, any if empty
+}
+
+// configSchema lists the keys of the configuration, in the order of the validation errors
+var configSchema = []configField{
+	{key: "elsaApiBaseUrl", def: "http://localhost:8080", required: true},
+	{key: "t2sClientRequestQueuePath", required: true},
+	{key: "t2sAcceptanceQueuePath", required: true},
+	{key: "creationRequestQueuePath", required: true},
+	{key: "creationAcceptanceQueuePath", required: true},
+	{key: "t2sOutboundQueuePath", required: true},
+	{key: "mockMqRootDir"},
+	{key: "pollingIntervalSeconds", integer: true, def: 1},
+	{key: "pollingTimeoutSeconds", integer: true, def: 60},
+	{key: "schemaDir", def: "../elsa-xml/schemas/ISO"},
+	{key: "t2sSchemaDir", def: "../elsa-xml/schemas/T2S"},
+	{key: "statusNotification", values: []string{"", "sse"}},
+	{key: "queueTransport.type", def: queue.TransportDirectory, values: []string{queue.TransportDirectory, queue.TransportMemory, queue.TransportAMQP}},
+	{key: "queueTransport.url"},
+	{key: "elsaTestClockUrl"},
+	{key: "database.driver"},
+	{key: "database.dsn"},
+	{key: "database.queries", def: "testdata/db/elsa_queries.sql"},
+	{key: "database.queryTimeoutSeconds", integer: true, def: 10},
+}
+
+// queueKeys are the keys of the queues, each needs its own queue
+var queueKeys = []string{
+	"t2sClientRequestQueuePath",
+	"t2sAcceptanceQueuePath",
+	"creationRequestQueuePath",
+	"creationAcceptanceQueuePath",
+	"t2sOutboundQueuePath",
+}
+
+func schemaField(key string) (configField, bool) {
+	for _, f := range configSchema {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return configField{}, false
+}
+
+// envName returns the environment variable of a key, e.g. ELSA_TEST_QUEUE_TRANSPORT_URL for queueTransport.url
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0 && key[i-1] != '.':
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// configValue is a value of the merged layers and the layer which set it
+type configValue struct {
+	value  interface{} // string or int
+	source string
+}
+
+// configLoader merges the layers of the configuration
+type configLoader struct {
+	profileDir string
+	profile    string         // profile of the run, from the flag config.profile
+	settings   []configString // from the flag config.set
+	lookupEnv  func(string) (string, bool)
+}
+
+// configString is a key=value setting of the command line
+type configString struct {
+	key, value string
+}
+
+// SetConfigOverrides sets the layers of the command line: the profile (empty for the default) and the
+// key=value settings overriding all other layers
+func (s *Suite) SetConfigOverrides(profile string, settings []string) error {
+	var parsed []configString
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("invalid configuration setting %q, expected key=value", setting)
+		}
+		if _, known := schemaField(key); !known {
+			return fmt.Errorf("invalid configuration setting %q: %s", setting, unknownKey(key))
+		}
+		parsed = append(parsed, configString{key, value})
+	}
+	if profile != "" {
+		if err := checkProfileName(profile); err != nil {
+			return err
+		}
+	}
+	s.config.profile = profile
+	s.config.settings = parsed
+	return nil
+}
+
+// runProfile returns the profile of the run: from the command line, the environment or the default
+func (l *configLoader) runProfile() string {
+	if l.profile != "" {
+		return l.profile
+	}
+	if p, ok := l.lookupEnv(profileEnv); ok && p != "" {
+		return p
+	}
+	return defaultProfile
+}
+
+// load returns the validated configuration of the file with the layers of the profile, the profile
+// of the run if empty. All problems found are reported together.
+func (l *configLoader) load(file, profile string) (*Config, error) {
+	if profile == "" {
+		profile = l.runProfile()
+	}
+	if err := checkProfileName(profile); err != nil {
+		return nil, err
+	}
+	merged := make(map[string]configValue)
+	for _, f := range configSchema {
+		if f.def != nil {
+			merged[f.key] = configValue{f.def, "default"}
+		}
+	}
+	var problems []string
+
+	profileFile := filepath.Join(l.profileDir, profile+".json")
+	if _, err := os.Stat(profileFile); err != nil {
+		return nil, fmt.Errorf("unknown configuration profile %q, expected one of %s", profile, strings.Join(l.profiles(), ", "))
+	}
+	for _, layer := range []string{file, profileFile} {
+		values, err := readConfigFile(layer)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, mergeFile(merged, values, layer)...)
+	}
+	for _, f := range configSchema {
+		if v, ok := l.lookupEnv(envName(f.key)); ok {
+			problems = append(problems, mergeString(merged, f, v, envName(f.key))...)
+		}
+	}
+	for _, s := range l.settings {
+		f, _ := schemaField(s.key)
+		problems = append(problems, mergeString(merged, f, s.value, "-config.set")...)
+	}
+
+	secrets, secretProblems := resolveSecrets(merged)
+	problems = append(problems, secretProblems...)
+	if len(problems) == 0 {
+		problems = validateConfig(merged)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration %s (profile %s):\n  %s", file, profile, strings.Join(problems, "\n  "))
+	}
+
+	cfg, err := buildConfig(merged)
+	if err != nil {
+		return nil, err
+	}
+	cfg.source = file
+	cfg.profile = profile
+	cfg.secrets = secrets
+	return cfg, nil
+}
+
+// profiles returns the names of the profile files
+func (l *configLoader) profiles() []string {
+	files, _ := filepath.Glob(filepath.Join(l.profileDir, "*.json"))
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	return names
+}
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func checkProfileName(profile string) error {
+	if !profileName.MatchString(profile) {
+		return fmt.Errorf("invalid configuration profile %q, expected a name like local or ci", profile)
+	}
+	return nil
+}
+
+// readConfigFile returns the values of a configuration file, the keys of nested objects are joined by a dot
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	values := make(map[string]interface{})
+	flatten(values, "", doc)
+	return values, nil
+}
+
+func flatten(values map[string]interface{}, prefix string, doc map[string]interface{}) {
+	for k, v := range doc {
+		if k == commentKey {
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(values, prefix+k+".", nested)
+			continue
+		}
+		values[prefix+k] = v
+	}
+}
+
+// mergeFile merges the values of a configuration file, it returns the unknown keys and wrong types
+func mergeFile(merged map[string]configValue, values map[string]interface{}, source string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, k := range keys {
+		f, ok := schemaField(k)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: %s", source, unknownKey(k)))
+			continue
+		}
+		switch v := values[k].(type) {
+		case nil:
+			delete(merged, k)
+			if f.def != nil {
+				merged[k] = configValue{f.def, source}
+			}
+		case string:
+			if f.integer {
+				problems = append(problems, fmt.Sprintf("%s: expected a whole number, got a string (set by %s)", k, source))
+				continue
+			}
+			merged[k] = configValue{v, source}
+		case json.Number:
+			n, err := strconv.Atoi(v.String())
+			if !f.integer || err != nil {
+				problems = append(problems, fmt.Sprintf("%s: expected %s, got %s (set by %s)", k, f.kind(), v, source))
+				continue
+			}
+			merged[k] = configValue{n, source}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: expected %s, got %T (set by %s)", k, f.kind(), v, source))
+		}
+	}
+	return problems
+}
+
+// mergeString merges a value of the environment or the command line
+func mergeString(merged map[string]configValue, f configField, value, source string) []string {
+	if !f.integer {
+		merged[f.key] = configValue{value, source}
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: expected a whole number, got %q (set by %s)", f.key, value, source)}
+	}
+	merged[f.key] = configValue{n, source}
+	return nil
+}
+
+func (f configField) kind() string {
+	if f.integer {
+		return "a whole number"
+	}
+	return "a string"
+}
+
+// unknownKey describes a key missing in the schema, with the key probably meant
+func unknownKey(key string) string {
+	lower := strings.ToLower(key)
+	for _, f := range configSchema {
+		if k := strings.ToLower(f.key); strings.HasPrefix(k, lower) || strings.HasPrefix(lower, k) {
+			return fmt.Sprintf("unknown key %q, did you mean %q?", key, f.key)
+		}
+	}
+	return fmt.Sprintf("unknown key %q", key)
+}
+
+// secretRef matches a secret reference in a string value, e.g. ${env:AMQP_PASSWORD}
+var secretRef = regexp.MustCompile(`\$\{([a-z]+):([^}]*)\}`)
+
+// resolveSecrets replaces the secret references by the secrets, it returns the keys with secrets.
+// The problems do not contain the values.
+func resolveSecrets(merged map[string]configValue) ([]string, []string) {
+	var secrets, problems []string
+	for _, f := range configSchema {
+		v, ok := merged[f.key]
+		s, isString := v.value.(string)
+		if !ok || !isString || !secretRef.MatchString(s) {
+			continue
+		}
+		secrets = append(secrets, f.key)
+		resolved := secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+			m := secretRef.FindStringSubmatch(ref)
+			secret, err := lookupSecret(m[1], m[2])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: secret %s: %v (set by %s)", f.key, ref, err, v.source))
+			}
+			return secret
+		})
+		merged[f.key] = configValue{resolved, v.source}
+	}
+	return secrets, problems
+}
+
+func lookupSecret(kind, name string) (string, error) {
+	switch kind {
+	case "env":
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown kind %q, expected env or file", kind)
+}
+
+// validateConfig checks the merged values beyond their types
+func validateConfig(merged map[string]configValue) []string {
+	var problems []string
+	problem := func(key, format string, args ...interface{}) {
+		msg := key + ": " + fmt.Sprintf(format, args...)
+		if v, ok := merged[key]; ok {
+			msg += fmt.Sprintf(" (set by %s)", v.source)
+		}
+		problems = append(problems, msg)
+	}
+	str := func(key string) string {
+		s, _ := merged[key].value.(string)
+		return s
+	}
+	num := func(key string) int {
+		n, _ := merged[key].value.(int)
+		return n
+	}
+
+	for _, f := range configSchema {
+		v, set := merged[f.key]
+		switch {
+		case f.required && (!set || v.value == ""):
+			problem(f.key, "is required")
+		case set && len(f.values) > 0 && !contains(f.values, fmt.Sprint(v.value)):
+			problem(f.key, "must be one of %s, got %q", quotedList(f.values), v.value)
+		}
+	}
+	for _, key := range []string{"elsaApiBaseUrl", "elsaTestClockUrl"} {
+		if raw := str(key); raw != "" {
+			if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problem(key, "must be an absolute http or https URL")
+			}
+		}
+	}
+	if num("pollingIntervalSeconds") < 1 {
+		problem("pollingIntervalSeconds", "must be at least 1, got %d", num("pollingIntervalSeconds"))
+	} else if num("pollingTimeoutSeconds") < num("pollingIntervalSeconds") {
+		problem("pollingTimeoutSeconds", "must not be below pollingIntervalSeconds (%d), got %d", num("pollingIntervalSeconds"), num("pollingTimeoutSeconds"))
+	}
+	if str("queueTransport.type") == queue.TransportAMQP && str("queueTransport.url") == "" {
+		problem("queueTransport.url", "is required for the %s transport", queue.TransportAMQP)
+	}
+
+	if driver := str("database.driver"); driver != "" {
+		if !contains(db.Drivers(), driver) {
+			problem("database.driver", "driver %q is not linked into the test tool, available: %s", driver, strings.Join(db.Drivers(), ", "))
+		}
+		if str("database.dsn") == "" {
+			problem("database.dsn", "is required with a database.driver")
+		}
+		if num("database.queryTimeoutSeconds") < 1 {
+			problem("database.queryTimeoutSeconds", "must be at least 1, got %d", num("database.queryTimeoutSeconds"))
+		}
+	}
+
+	used := make(map[string]string) // queue name -> key
+	for _, key := range queueKeys {
+		name := str(key)
+		if str("queueTransport.type") == queue.TransportDirectory {
+			name = filepath.Clean(name)
+		}
+		if other, ok := used[name]; ok && str(key) != "" {
+			problem(key, "is the queue of %s already, each queue needs its own name", other)
+			continue
+		}
+		used[name] = key
+	}
+	return problems
+}
+
+// buildConfig returns the configuration of the merged values
+func buildConfig(merged map[string]configValue) (*Config, error) {
+	doc := make(map[string]interface{})
+	for key, v := range merged {
+		parent := doc
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			nested, ok := parent[p].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+				parent[p] = nested
+			}
+			parent = nested
+		}
+		parent[parts[len(parts)-1]] = v.value
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build configuration: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to build configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+// configValueOf returns the value of a key of the configuration as text
+func (cfg *Config) configValueOf(key string) (string, error) {
+	if _, ok := schemaField(key); !ok {
+		return "", fmt.Errorf("%s", unknownKey(key))
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	values := make(map[string]interface{})
+	flatten(values, "", doc)
+	if v, ok := values[key].(string); ok {
+		return v, nil
+	}
+	return fmt.Sprint(values[key]), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quotedList(list []string) string {
+	quoted := make([]string, len(list))
+	for i, v := range list {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -2,10 +2,11 @@ package step_definitions
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"test-tool/db"
+	"test-tool/queue"
 
 	"github.com/cucumber/godog"
 )
@@ -17,48 +18,144 @@ type Config struct {
 	T2SAcceptanceQueuePath      string `json:"t2sAcceptanceQueuePath"`
 	CreationRequestQueuePath    string `json:"creationRequestQueuePath"`
 	CreationAcceptanceQueuePath string `json:"creationAcceptanceQueuePath"`
+	T2SOutboundQueuePath        string `json:"t2sOutboundQueuePath"`
 	MockMQRootDir               string `json:"mockMqRootDir"`
 	PollingIntervalSeconds      int    `json:"pollingIntervalSeconds"`
 	PollingTimeoutSeconds       int    `json:"pollingTimeoutSeconds"`
+	SchemaDir                   string `json:"schemaDir"`          // ISO20022 schemas, <message type>.xsd
+	T2SSchemaDir                string `json:"t2sSchemaDir"`       // T2S schemas, messages in the wrapper format (CST2SMsg) are validated against them
+	StatusNotification          string `json:"statusNotification"` // "sse" to wake up waits on status events, polling only if empty
+
+	// QueueTransport selects how messages are exchanged, the queue paths above are
+	// directories for the directory transport and queue names otherwise
+	QueueTransport queue.Config `json:"queueTransport"`
+
+	// ElsaTestClockURL is the test clock endpoint of ELSA, optional. The clock steps move it along with the
+	// clock of the mock, it speaks the protocol of /_mock/clock of the mock (see clock_steps.go).
+	ElsaTestClockURL string `json:"elsaTestClockUrl"`
+
+	// Database is the ELSA database of the database steps, optional
+	Database db.Config `json:"database"`
+
+	queueFaults *queue.Faults // fault rules of the mock server applied to the queues of the scenario, see scope
+	suite       *Suite        // suite of the scenario, it holds the brokers, databases and templates, see scope
+	source      string        // configuration file, the profile step reloads it
+	profile     string        // profile of the layers above the file, see config_layers.go
+	secrets     []string      // keys whose values came from secret references, they are not shown
 }
 
-// TestContextKey is used as a key for values in context.Context
-type TestContextKey string
+// contextKey is used as a key for values in context.Context
+type contextKey string
 
-const ConfigKey TestContextKey = "config"
-const PreparedMessageKey TestContextKey = "preparedMessage"
-const CurrentTXIDKey TestContextKey = "currentTXID"
-const CurrentMitiTXIDKey TestContextKey = "currentMitiTXID" // Added for MitiTXID
+const configKey contextKey = "config"
+const preparedMessageKey contextKey = "preparedMessage"
+const currentTXIDKey contextKey = "currentTXID"
+const currentMitiTXIDKey contextKey = "currentMitiTXID" // Added for MitiTXID
 
-// LoadConfig loads configuration from the specified file path
-func LoadConfig(filePath string) (*Config, error) {
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error getting absolute path for config: %w", err)
+// LoadConfig loads the configuration file with the layers of the profile (the profile of the run if empty):
+// defaults, file, profile file, environment variables and command line, see config_layers.go. The
+// configuration is validated, all problems are reported together.
+func (s *Suite) LoadConfig(filePath, profile string) (*Config, error) {
+	return s.config.load(filePath, profile)
+}
+
+// queueNames returns the names of all configured queues without duplicates
+func (cfg *Config) queueNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range []string{
+		cfg.T2SClientRequestQueuePath,
+		cfg.T2SAcceptanceQueuePath,
+		cfg.CreationRequestQueuePath,
+		cfg.CreationAcceptanceQueuePath,
+		cfg.T2SOutboundQueuePath,
+	} {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
+	return names
+}
 
-	data, err := os.ReadFile(absPath)
+func elsaServicesAreConfiguredFrom(ctx context.Context, configFile string) (context.Context, error) {
+	suite, err := scenarioSuite(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %s: %w", absPath, err)
+		return ctx, err
 	}
+	// Construct the correct path to the config file relative to the testdata of the suite
+	correctConfigPath := suite.path(filepath.Join("testdata", "config", configFile))
 
-	var cfg Config
-	err = json.Unmarshal(data, &cfg)
+	profile := "" // the profile selected by the feature is kept
+	if current, ok := ctx.Value(configKey).(*Config); ok && current != nil {
+		profile = current.profile
+	}
+	cfg, err := suite.LoadConfig(correctConfigPath, profile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config data from %s: %w", absPath, err)
+		return ctx, fmt.Errorf("failed to load configuration from '%s': %w", correctConfigPath, err)
 	}
-	return &cfg, nil
+	return useConfig(ctx, cfg)
 }
 
-func elsaServicesAreConfiguredFrom(ctx context.Context, configFile string) (context.Context, error) {
-	// Construct the correct path to the config file relative to the project root
-	correctConfigPath := filepath.Join("testdata", "config", configFile)
-	fmt.Printf("Attempting to load configuration from: %s\n", correctConfigPath) // Debug print
+// theConfigurationProfileIs reloads the configuration of the scenario with the layers of the profile,
+// in the Background it switches the profile of the feature
+func theConfigurationProfileIs(ctx context.Context, profile string) (context.Context, error) {
+	current, ok := ctx.Value(configKey).(*Config)
+	if !ok || current == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	suite, err := scenarioSuite(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	cfg, err := suite.LoadConfig(current.source, profile)
+	if err != nil {
+		return ctx, err
+	}
+	recordNote(ctx, "Configuration", "", "%s uses profile %s", cfg.source, profile)
+	return useConfig(ctx, cfg)
+}
 
-	cfg, err := LoadConfig(correctConfigPath)
+// theConfigurationValueShouldBe checks a value of the configuration of the scenario, the keys are the ones
+// of the configuration files
+func theConfigurationValueShouldBe(ctx context.Context, key, expected string) error {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return fmt.Errorf("configuration not found in context")
+	}
+	actual, err := cfg.configValueOf(key)
 	if err != nil {
-		return ctx, fmt.Errorf("failed to load configuration from '%s': %w", correctConfigPath, err)
+		return err
+	}
+	if actual != expected {
+		if contains(cfg.secrets, key) {
+			actual = "(secret)"
+		}
+		return fmt.Errorf("configuration value %s of profile %s: expected %q, got %q", key, cfg.profile, expected, actual)
 	}
+	return nil
+}
+
+// useConfig puts the configuration into the context, scoped to the scenario. The queues are prepared:
+// the directories are created, the queues of other transports purged.
+func useConfig(ctx context.Context, cfg *Config) (context.Context, error) {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		cfg = sc.scope(cfg) // queues and mock server of this scenario
+	}
+	if !cfg.isDirectoryTransport() {
+		// Queues of other transports may outlive the test run (AMQP), they are purged so every scenario starts empty.
+		for _, name := range cfg.queueNames() {
+			q, err := openQueue(cfg, name)
+			if err != nil {
+				return ctx, err
+			}
+			if err := q.Purge(ctx); err != nil {
+				return ctx, fmt.Errorf("failed to purge queue %s: %w", name, err)
+			}
+		}
+		return context.WithValue(ctx, configKey, cfg), nil
+	}
+
 	// Ensure mock MQ directories exist
 	// These paths are relative to the project root as defined in elsa_services.json
 	pathsToEnsure := []string{
@@ -66,6 +163,7 @@ func elsaServicesAreConfiguredFrom(ctx context.Context, configFile string) (cont
 		cfg.T2SAcceptanceQueuePath,
 		cfg.CreationRequestQueuePath,
 		cfg.CreationAcceptanceQueuePath,
+		cfg.T2SOutboundQueuePath,
 		cfg.MockMQRootDir,
 	}
 
@@ -74,13 +172,14 @@ func elsaServicesAreConfiguredFrom(ctx context.Context, configFile string) (cont
 			if err := os.MkdirAll(path, 0755); err != nil {
 				return ctx, fmt.Errorf("failed to create mock MQ dir ('%s'): %w", path, err)
 			}
-			fmt.Printf("Ensured directory exists: %s\n", path) // Debug print
 		}
 	}
 
-	return context.WithValue(ctx, ConfigKey, cfg), nil
+	return context.WithValue(ctx, configKey, cfg), nil
 }
 
-func InitializeConfigSteps(s *godog.ScenarioContext) {
+func registerConfigSteps(s *godog.ScenarioContext) {
 	s.Step(`^the system is configured from "([^"]*)"$`, elsaServicesAreConfiguredFrom)
+	s.Step(`^the configuration profile is "([^"]*)"$`, theConfigurationProfileIs)
+	s.Step(`^the configuration value "([^"]*)" should be "([^"]*)"$`, theConfigurationValueShouldBe)
 }
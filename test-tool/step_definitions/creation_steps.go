@@ -0,0 +1,344 @@
+package step_definitions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"test-tool/fixtures"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+const creationMessageKey contextKey = "creationMessage"
+const creationSimulatorKey contextKey = "creationSimulator"
+
+// creationPollInterval is how often the automatic CREATION simulator reads its queue
+const creationPollInterval = 100 * time.Millisecond
+
+// Templates of the messages CREATION sends to ELSA, keyed by the reply used in the steps
+var creationReplyTemplates = map[string]string{
+	"accepts": "creation_acceptance.xml",
+	"rejects": "creation_rejection.xml",
+	"settles": "creation_settlement.xml",
+}
+
+// Kinds of the messages ELSA sends to CREATION, keyed by the name used in the steps
+var creationMessageKinds = map[string]mock_elsa_server.MessageKind{
+	"request": mock_elsa_server.KindClientCopy, // sese.023 sent on behalf of the client
+	"match":   mock_elsa_server.KindMatched,    // sese.024 forwarding the MATCH of T2S
+}
+
+// creationMessage is a message ELSA sent to CREATION, taken from the queue
+type creationMessage struct {
+	msg    *queue.Message
+	parsed *mock_elsa_server.ParsedMessage
+}
+
+// readCreationMessages takes the messages accepted by 'want' from the CREATION request queue in the order they were put,
+// other messages stay in the queue.
+func readCreationMessages(ctx context.Context, cfg *Config, want func(*creationMessage) bool) ([]*creationMessage, error) {
+	q, err := openQueue(cfg, cfg.CreationRequestQueuePath)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := q.Browse(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CREATION request queue: %w", err)
+	}
+
+	var res []*creationMessage
+	for _, msg := range msgs {
+		parsed, err := mock_elsa_server.ParseMessage(msg.Body)
+		if err != nil {
+			recordNote(ctx, "CREATION", "error", "skipping unreadable message %s: %v", msg.ID, err)
+			continue
+		}
+		m := &creationMessage{msg: msg, parsed: parsed}
+		if !want(m) {
+			continue
+		}
+		if _, err := q.Get(ctx, queue.Selector{MessageID: msg.ID}); err != nil {
+			if errors.Is(err, queue.ErrEmpty) {
+				continue // taken by a concurrent reader
+			}
+			return nil, fmt.Errorf("failed to take %s from the CREATION request queue: %w", msg.ID, err)
+		}
+		recordMessage(ctx, report.KindReceived, q.Name(), msg)
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// renderCreationReply renders the reply template for the instruction. Fields of the request
+// (movement and payment type) are taken over, 'values' override them.
+func renderCreationReply(cfg *Config, reply, txID string, request *mock_elsa_server.ParsedMessage, values map[string]string, lookup fixtures.Lookup) (string, error) {
+	templateFileName, ok := creationReplyTemplates[reply]
+	if !ok {
+		return "", fmt.Errorf("unknown CREATION reply %q", reply)
+	}
+	lib, err := fixtureLibrary(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]interface{}{
+		"TXID":       txID,
+		"ReasonCode": "OTHR",
+	}
+	if request != nil {
+		data["MovementType"] = request.MovementType
+		data["PaymentType"] = request.PaymentType
+		data["ISIN"] = request.ISIN
+		data["Quantity"] = request.Quantity
+		data["SafekeepingAccount"] = request.SafekeepingAccount
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+
+	payload, err := lib.Render(templateFileName, data, lookup)
+	if err != nil {
+		return "", fmt.Errorf("reply to %s: %w", txID, err)
+	}
+	return string(payload), nil
+}
+
+// sendCreationReply puts the reply to the queue ELSA reads CREATION messages from
+func sendCreationReply(ctx context.Context, cfg *Config, reply, txID, payload string) error {
+	return putMessage(ctx, cfg, cfg.CreationAcceptanceQueuePath, fmt.Sprintf("%s_creation_%s", txID, reply), txID, payload)
+}
+
+func creationReceivesMessageFor(ctx context.Context, messageName, txID string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	kind := creationMessageKinds[messageName]
+	txID = scopedTXID(ctx, txID)
+	timeout := time.Duration(cfg.PollingTimeoutSeconds) * time.Second
+
+	var received *creationMessage
+	err := waitFor(ctx, cfg, timeout, nil, func(ctx context.Context) (bool, error) {
+		msgs, err := readCreationMessages(ctx, cfg, func(m *creationMessage) bool {
+			return m.parsed.Kind == kind && m.parsed.TXID == txID
+		})
+		if err != nil {
+			recordNote(ctx, "CREATION", "error", "%v, retrying", err)
+			return false, nil
+		}
+		if len(msgs) == 0 {
+			return false, nil
+		}
+		received = msgs[0]
+		return true, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return ctx, fmt.Errorf("timeout after %s waiting for a CREATION %s for %s in %s", timeout, messageName, txID, cfg.CreationRequestQueuePath)
+	}
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, creationMessageKey, received), nil
+}
+
+func theReceivedCreationMessageShouldContain(ctx context.Context, elementPath, expectedValue string) (context.Context, error) {
+	msg, ok := ctx.Value(creationMessageKey).(*creationMessage)
+	if !ok || msg == nil {
+		return ctx, fmt.Errorf("no CREATION message received in this scenario")
+	}
+	expectedValue, err := expandValue(ctx, expectedValue)
+	if err != nil {
+		return ctx, err
+	}
+	value, found, err := mock_elsa_server.FindValue(msg.msg.Body, elementPath)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to read CREATION message %s: %w", msg.msg.ID, err)
+	}
+	if !found {
+		return ctx, fmt.Errorf("CREATION message %s has no element %s", msg.msg.ID, elementPath)
+	}
+	if value != expectedValue {
+		return ctx, fmt.Errorf("CREATION message %s has %s %q, expected %q", msg.msg.ID, elementPath, value, expectedValue)
+	}
+	return ctx, nil
+}
+
+func creationRepliesToInstruction(ctx context.Context, reply, txID string) (context.Context, error) {
+	return creationRepliesToInstructionWithValues(ctx, reply, txID, nil)
+}
+
+func creationRepliesToInstructionWithValues(ctx context.Context, reply, txID string, data *godog.Table) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+
+	values := make(map[string]string)
+	if data != nil && len(data.Rows) > 0 {
+		header := data.Rows[0]
+		if len(header.Cells) != 2 || header.Cells[0].Value != "Field" || header.Cells[1].Value != "Value" {
+			return ctx, fmt.Errorf("expected DataTable header to be | Field | Value |")
+		}
+		for _, row := range data.Rows[1:] {
+			if len(row.Cells) != 2 {
+				return ctx, fmt.Errorf("expected 2 cells per row in DataTable, got %d", len(row.Cells))
+			}
+			value, err := expandValue(ctx, row.Cells[1].Value)
+			if err != nil {
+				return ctx, fmt.Errorf("field %s: %w", row.Cells[0].Value, err)
+			}
+			values[row.Cells[0].Value] = value
+		}
+	}
+	txID = scopedTXID(ctx, txID)
+
+	var request *mock_elsa_server.ParsedMessage
+	if msg, ok := ctx.Value(creationMessageKey).(*creationMessage); ok && msg != nil && msg.parsed.TXID == txID {
+		request = msg.parsed
+	}
+
+	payload, err := renderCreationReply(cfg, reply, txID, request, values, scenarioValues(ctx))
+	if err != nil {
+		return ctx, err
+	}
+	if err := sendCreationReply(ctx, cfg, reply, txID, payload); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+func creationSendsThePreparedMessageToQueueWithCorrelationID(ctx context.Context, queueIdentifierKey string, correlationID string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+
+	payload, ok := ctx.Value(preparedMessageKey).(string)
+	if !ok || payload == "" {
+		return ctx, fmt.Errorf("no prepared message found in context to send")
+	}
+
+	correlationID = scopedTXID(ctx, correlationID)
+	if queueIdentifierKey != "creationAcceptanceQueueName" {
+		return ctx, fmt.Errorf("unknown queue identifier key: %s. Expected 'creationAcceptanceQueueName'", queueIdentifierKey)
+	}
+	queuePath := queuePathForKey(cfg, queueIdentifierKey)
+	if queuePath == "" {
+		return ctx, fmt.Errorf("queue path is empty for identifier %s, check config elsa_services.json", queueIdentifierKey)
+	}
+
+	if err := putMessage(ctx, cfg, queuePath, correlationID+"_creation", correlationID, payload); err != nil {
+		return ctx, fmt.Errorf("failed to send message to %s: %w", queueIdentifierKey, err)
+	}
+	return context.WithValue(ctx, currentTXIDKey, correlationID), nil
+}
+
+// creationSimulator replies automatically to everything ELSA sends to CREATION:
+// requests are accepted or rejected, matches are settled.
+type creationSimulator struct {
+	ctx      context.Context // context of the scenario without its cancellation, the replies are recorded for its report
+	cfg      *Config
+	decision string                                     // "accepts" or "rejects"
+	requests map[string]*mock_elsa_server.ParsedMessage // requests by TXID, their fields are used for the settlement
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func (c *creationSimulator) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(creationPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			msgs, err := readCreationMessages(c.ctx, c.cfg, func(m *creationMessage) bool {
+				return m.parsed.Kind == mock_elsa_server.KindClientCopy || m.parsed.Kind == mock_elsa_server.KindMatched
+			})
+			if err != nil {
+				recordNote(c.ctx, "CREATION simulator", "error", "%v", err)
+				continue
+			}
+			for _, msg := range msgs {
+				c.reply(msg)
+			}
+		}
+	}
+}
+
+func (c *creationSimulator) reply(msg *creationMessage) {
+	txID := msg.parsed.TXID
+	reply := "settles"
+	if msg.parsed.Kind == mock_elsa_server.KindClientCopy {
+		reply = c.decision
+		c.requests[txID] = msg.parsed
+	}
+
+	payload, err := renderCreationReply(c.cfg, reply, txID, c.requests[txID], nil, nil)
+	if err == nil {
+		err = sendCreationReply(c.ctx, c.cfg, reply, txID, payload)
+	}
+	if err != nil {
+		recordNote(c.ctx, "CREATION simulator", "error", "failed to reply to %s: %v", msg.msg.ID, err)
+		return
+	}
+	recordNote(c.ctx, "CREATION simulator", "", "automatically %s %s", reply, txID)
+}
+
+func (c *creationSimulator) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+	})
+}
+
+func theCreationSimulatorAutomaticallyReplies(ctx context.Context, decision string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	if cfg.CreationRequestQueuePath == "" || cfg.CreationAcceptanceQueuePath == "" {
+		return ctx, fmt.Errorf("CREATION queue paths are not configured, check config elsa_services.json")
+	}
+	if sim, ok := ctx.Value(creationSimulatorKey).(*creationSimulator); ok {
+		sim.Stop()
+	}
+
+	sim := &creationSimulator{
+		ctx:      context.WithoutCancel(ctx),
+		cfg:      cfg,
+		decision: decision,
+		requests: make(map[string]*mock_elsa_server.ParsedMessage),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go sim.run()
+	return context.WithValue(ctx, creationSimulatorKey, sim), nil
+}
+
+// stopCreationSimulator ends the automatic replies at the end of the scenario
+func stopCreationSimulator(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+	if sim, ok := ctx.Value(creationSimulatorKey).(*creationSimulator); ok {
+		sim.Stop()
+	}
+	return ctx, nil
+}
+
+func registerCreationSteps(s *godog.ScenarioContext) {
+	s.Step(`^CREATION receives a (request|match) for "([^"]*)" within configured polling limits$`, creationReceivesMessageFor)
+	s.Step(`^the received CREATION message should contain "([^"]*)" with value "([^"]*)"$`, theReceivedCreationMessageShouldContain)
+	s.Step(`^CREATION (accepts|rejects|settles) the instruction "([^"]*)"$`, creationRepliesToInstruction)
+	s.Step(`^CREATION (accepts|rejects|settles) the instruction "([^"]*)" with values:$`, creationRepliesToInstructionWithValues)
+	s.Step(`^CREATION prepares a message using template "([^"]*)" with values:$`, t2sPreparesAMessageWithValues)
+	s.Step(`^CREATION sends the prepared message to the "([^"]*)" queue with correlation ID "([^"]*)"$`, creationSendsThePreparedMessageToQueueWithCorrelationID)
+	s.Step(`^the CREATION simulator automatically (accepts|rejects) requests and settles matches$`, theCreationSimulatorAutomaticallyReplies)
+
+	s.After(stopCreationSimulator)
+}
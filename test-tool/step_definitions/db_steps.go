@@ -0,0 +1,334 @@
+package step_definitions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"test-tool/db"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// dbSetupDir holds the scripts of the step setting up a local ELSA database
+const dbSetupDir = "testdata/db"
+
+// databaseFor returns the database of the scenario: the local one if it set up one, the configured one
+// otherwise. Databases are opened once per configuration and shared by the scenarios of the suite like
+// the brokers.
+func databaseFor(ctx context.Context, cfg *Config) (*db.DB, error) {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		if d := sc.localDatabase(); d != nil {
+			return d, nil
+		}
+	}
+	if !cfg.Database.Enabled() {
+		return nil, fmt.Errorf("no ELSA database configured, set database.driver and database.dsn (e.g. ELSA_TEST_DATABASE_DRIVER and ELSA_TEST_DATABASE_DSN)")
+	}
+	s := cfg.suite
+	if s == nil {
+		return nil, fmt.Errorf("databases are only available within a scenario")
+	}
+	s.databasesMu.Lock()
+	defer s.databasesMu.Unlock()
+	if d, ok := s.databases[cfg.Database]; ok {
+		return d, nil
+	}
+	d, err := db.Open(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	s.databases[cfg.Database] = d
+	return d, nil
+}
+
+// namedQuery returns a query of the configured queries file, the files are read once per suite
+func namedQuery(cfg *Config, name string) (string, error) {
+	s := cfg.suite
+	if s == nil {
+		return "", fmt.Errorf("queries are only available within a scenario")
+	}
+	s.databasesMu.Lock()
+	defer s.databasesMu.Unlock()
+	queries, ok := s.queryFiles[cfg.Database.Queries]
+	if !ok {
+		var err error
+		if queries, err = db.LoadQueries(cfg.Database.Queries); err != nil {
+			return "", err
+		}
+		s.queryFiles[cfg.Database.Queries] = queries
+	}
+	q, ok := queries[name]
+	if !ok {
+		return "", fmt.Errorf("query %q not found in %s", name, cfg.Database.Queries)
+	}
+	return q, nil
+}
+
+// closeDatabases closes all shared databases, called at the end of the suite
+func (s *Suite) closeDatabases() {
+	s.databasesMu.Lock()
+	defer s.databasesMu.Unlock()
+	for c, d := range s.databases {
+		if err := d.Close(); err != nil {
+			fmt.Printf("Warning: closing database %s failed: %v\n", c.Driver, err)
+		}
+		delete(s.databases, c)
+	}
+}
+
+// txidColumn matches the columns and parameters holding a TXID of the feature file, they are replaced
+// by the TXIDs of the scenario like the TransactionId of a prepared message
+var txidColumn = regexp.MustCompile(`(?i)txid`)
+
+// dbValue returns the value of a cell or parameter for the scenario
+func dbValue(ctx context.Context, column, value string) (string, error) {
+	if txidColumn.MatchString(column) {
+		return scopedTXID(ctx, value), nil
+	}
+	return expandValue(ctx, value)
+}
+
+func theLocalELSADatabaseIsSetUpFrom(ctx context.Context, scriptFile string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok {
+		return ctx, fmt.Errorf("scenario scope not found in context")
+	}
+	script, err := os.ReadFile(sc.suite.path(filepath.Join(dbSetupDir, scriptFile)))
+	if err != nil {
+		return ctx, fmt.Errorf("failed to read database script: %w", err)
+	}
+	path, err := sc.createLocalDatabase(ctx, string(script), cfg.Database)
+	if err != nil {
+		return ctx, err
+	}
+	recordNote(ctx, "Database", "", "local ELSA database %s set up from %s", path, scriptFile)
+	return ctx, nil
+}
+
+func theLocalELSADatabaseTableHasTheRows(ctx context.Context, table string, data *godog.Table) error {
+	sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok || sc.localDatabasePath() == "" {
+		return fmt.Errorf("no local ELSA database, only a database set up by the scenario is written to")
+	}
+	if len(data.Rows) < 2 {
+		return fmt.Errorf("table %s: a header row and at least one row expected", table)
+	}
+	var columns []string
+	for _, cell := range data.Rows[0].Cells {
+		columns = append(columns, cell.Value)
+	}
+	var rows [][]string
+	for _, row := range data.Rows[1:] {
+		if len(row.Cells) != len(columns) {
+			return fmt.Errorf("expected %d cells per row in DataTable, got %d", len(columns), len(row.Cells))
+		}
+		values := make([]string, len(columns))
+		for i, cell := range row.Cells {
+			v, err := dbValue(ctx, columns[i], cell.Value)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+		rows = append(rows, values)
+	}
+	return db.InsertSQLite(ctx, sc.localDatabasePath(), table, columns, rows)
+}
+
+// queryTheELSADatabase runs a named query and keeps its result for the assertion steps
+func queryTheELSADatabase(ctx context.Context, queryName string, params map[string]string) error {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return fmt.Errorf("configuration not found in context")
+	}
+	sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok {
+		return fmt.Errorf("scenario scope not found in context")
+	}
+	d, err := databaseFor(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	query, err := namedQuery(cfg, queryName)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	res, err := d.Query(ctx, query, params)
+	event := report.Event{Kind: report.KindQuery, Start: start, Duration: time.Since(start), Title: queryName, Detail: formatParams(params)}
+	if err != nil {
+		event.Status, event.Body = "error", err.Error()
+		sc.recorder.Record(event)
+		return fmt.Errorf("database query %s failed: %w", queryName, err)
+	}
+	event.Status = fmt.Sprintf("%d rows", len(res.Rows))
+	event.Body = formatResult(res)
+	sc.recorder.Record(event)
+	sc.setQueryResult(res)
+	return nil
+}
+
+func theELSADatabaseIsQueriedForInstruction(ctx context.Context, queryName, clientTXID string) error {
+	return queryTheELSADatabase(ctx, queryName, map[string]string{"txid": scopedTXID(ctx, clientTXID)})
+}
+
+func theELSADatabaseIsQueriedForCollection(ctx context.Context, queryName, collectionID string) error {
+	id, err := expandValue(ctx, collectionID)
+	if err != nil {
+		return err
+	}
+	return queryTheELSADatabase(ctx, queryName, map[string]string{"collection": id})
+}
+
+func theELSADatabaseIsQueriedWithTheParameters(ctx context.Context, queryName string, data *godog.Table) error {
+	records, err := tableRecords(data, "Name", "Value")
+	if err != nil {
+		return err
+	}
+	params := make(map[string]string)
+	for _, r := range records {
+		v, err := dbValue(ctx, r["Name"], r["Value"])
+		if err != nil {
+			return err
+		}
+		params[r["Name"]] = v
+	}
+	return queryTheELSADatabase(ctx, queryName, params)
+}
+
+func scenarioQueryResult(ctx context.Context) (*db.Result, error) {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		if res := sc.queryResult(); res != nil {
+			return res, nil
+		}
+	}
+	return nil, fmt.Errorf("no database query run in this scenario")
+}
+
+func theDatabaseResultShouldHaveRows(ctx context.Context, expected int) error {
+	res, err := scenarioQueryResult(ctx)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) != expected {
+		return fmt.Errorf("expected %d rows, got %d:\n%s", expected, len(res.Rows), formatResult(res))
+	}
+	return nil
+}
+
+// theDatabaseResultShouldContainTheRows checks that every row of the table matches a different row of
+// the result in the columns of the table, the order of the rows does not matter
+func theDatabaseResultShouldContainTheRows(ctx context.Context, data *godog.Table) error {
+	res, err := scenarioQueryResult(ctx)
+	if err != nil {
+		return err
+	}
+	if len(data.Rows) < 2 {
+		return fmt.Errorf("a header row and at least one expected row required")
+	}
+	var indexes []int
+	for _, cell := range data.Rows[0].Cells {
+		i, ok := res.Column(cell.Value)
+		if !ok {
+			return fmt.Errorf("column %s not in the result, columns: %s", cell.Value, strings.Join(res.Columns, ", "))
+		}
+		indexes = append(indexes, i)
+	}
+	used := make([]bool, len(res.Rows))
+	for _, row := range data.Rows[1:] {
+		if len(row.Cells) != len(indexes) {
+			return fmt.Errorf("expected %d cells per row in DataTable, got %d", len(indexes), len(row.Cells))
+		}
+		expected := make([]string, len(row.Cells))
+		for i, cell := range row.Cells {
+			v, err := expandValue(ctx, cell.Value)
+			if err != nil {
+				return err
+			}
+			expected[i] = v
+		}
+		found := false
+		for r, actual := range res.Rows {
+			if used[r] {
+				continue
+			}
+			matches := true
+			for i, col := range indexes {
+				if actual[col] != expected[i] {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				used[r], found = true, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no row with %s in the result:\n%s", strings.Join(expected, " | "), formatResult(res))
+		}
+	}
+	return nil
+}
+
+func theDatabaseValueShouldBe(ctx context.Context, column, expected string) error {
+	res, err := scenarioQueryResult(ctx)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) != 1 {
+		return fmt.Errorf("expected a single row, got %d:\n%s", len(res.Rows), formatResult(res))
+	}
+	i, ok := res.Column(column)
+	if !ok {
+		return fmt.Errorf("column %s not in the result, columns: %s", column, strings.Join(res.Columns, ", "))
+	}
+	if expected, err = expandValue(ctx, expected); err != nil {
+		return err
+	}
+	if res.Rows[0][i] != expected {
+		return fmt.Errorf("expected %s to be %q, got %q", column, expected, res.Rows[0][i])
+	}
+	return nil
+}
+
+// formatResult returns the rows of a result as table for the messages and the report
+func formatResult(res *db.Result) string {
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(res.Columns, " | ") + " |\n")
+	for _, row := range res.Rows {
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+func formatParams(params map[string]string) string {
+	parts := make([]string, 0, len(params))
+	for name, v := range params {
+		parts = append(parts, fmt.Sprintf(":%s=%s", name, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func registerDatabaseSteps(s *godog.ScenarioContext) {
+	s.Step(`^the local ELSA database is set up from "([^"]*)"$`, theLocalELSADatabaseIsSetUpFrom)
+	s.Step(`^the local ELSA database table "([^"]*)" has the rows:$`, theLocalELSADatabaseTableHasTheRows)
+	s.Step(`^the ELSA database is queried with "([^"]*)" for instruction "([^"]*)"$`, theELSADatabaseIsQueriedForInstruction)
+	s.Step(`^the ELSA database is queried with "([^"]*)" for collection "([^"]*)"$`, theELSADatabaseIsQueriedForCollection)
+	s.Step(`^the ELSA database is queried with "([^"]*)" and the parameters:$`, theELSADatabaseIsQueriedWithTheParameters)
+	s.Step(`^the database result should have (\d+) rows?$`, theDatabaseResultShouldHaveRows)
+	s.Step(`^the database result should contain the rows:$`, theDatabaseResultShouldContainTheRows)
+	s.Step(`^the database value "([^"]*)" should be "([^"]*)"$`, theDatabaseValueShouldBe)
+}
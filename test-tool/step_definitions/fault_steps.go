@@ -0,0 +1,176 @@
+package step_definitions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// tableRecords returns the rows of a DataTable as maps from the column names of the header to the
+// cell values, columns other than the allowed ones are rejected
+func tableRecords(data *godog.Table, allowed ...string) ([]map[string]string, error) {
+	if data == nil || len(data.Rows) < 2 {
+		return nil, fmt.Errorf("expected DataTable with header | %s | and at least one row", strings.Join(allowed, " | "))
+	}
+	header := data.Rows[0]
+	for _, cell := range header.Cells {
+		known := false
+		for _, name := range allowed {
+			known = known || cell.Value == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q, expected any of | %s |", cell.Value, strings.Join(allowed, " | "))
+		}
+	}
+	var records []map[string]string
+	for _, row := range data.Rows[1:] {
+		if len(row.Cells) != len(header.Cells) {
+			return nil, fmt.Errorf("expected %d cells per row in DataTable, got %d", len(header.Cells), len(row.Cells))
+		}
+		record := make(map[string]string, len(row.Cells))
+		for i, cell := range row.Cells {
+			record[header.Cells[i].Value] = strings.TrimSpace(cell.Value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// optionalInt parses a cell which may be empty
+func optionalInt(record map[string]string, column string) (int, error) {
+	if record[column] == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(record[column])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", column, record[column], err)
+	}
+	return n, nil
+}
+
+// optionalDuration parses a cell which may be empty, e.g. 500ms
+func optionalDuration(record map[string]string, column string) (time.Duration, error) {
+	if record[column] == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(record[column])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", column, record[column], err)
+	}
+	return d, nil
+}
+
+// theMockElsaAPIInjectsTheFaults adds fault rules to the mock ELSA API server of the scenario, one per row:
+//
+//	| TXID   | Path            | Latency | Status | Malformed | Times |
+//	| TXN_01 | /instructions/* |         | 500    |           | 2     |
+func theMockElsaAPIInjectsTheFaults(ctx context.Context, data *godog.Table) (context.Context, error) {
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	if !ok || mockServer == nil {
+		return ctx, fmt.Errorf("mock ELSA API server not found in context")
+	}
+	records, err := tableRecords(data, "TXID", "Path", "Latency", "Status", "Malformed", "Times")
+	if err != nil {
+		return ctx, err
+	}
+	for _, record := range records {
+		fault := mock_elsa_server.Fault{
+			TXID:      scopedTXID(ctx, record["TXID"]),
+			Path:      record["Path"],
+			Malformed: record["Malformed"] == "true" || record["Malformed"] == "yes",
+		}
+		if fault.Latency, err = optionalDuration(record, "Latency"); err != nil {
+			return ctx, err
+		}
+		if fault.Status, err = optionalInt(record, "Status"); err != nil {
+			return ctx, err
+		}
+		if fault.Times, err = optionalInt(record, "Times"); err != nil {
+			return ctx, err
+		}
+		added, err := mockServer.AddFault(fault)
+		if err != nil {
+			return ctx, err
+		}
+		recordNote(ctx, "Faults", "", "mock ELSA API server injects fault %s: %+v", added.ID, added)
+	}
+	return ctx, nil
+}
+
+// theQueuesInjectTheFaults adds fault rules to the queues of the scenario, one per row. The queue is
+// given by its identifier in the feature files, the rule applies to all queues if it is empty:
+//
+//	| Queue                     | Kind      | TXID   | Delay | Times |
+//	| t2sClientRequestQueueName | duplicate | TXN_01 |       | 1     |
+func theQueuesInjectTheFaults(ctx context.Context, data *godog.Table) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	if cfg.queueFaults == nil {
+		return ctx, fmt.Errorf("queue faults are only available within a scenario")
+	}
+	records, err := tableRecords(data, "Queue", "Kind", "TXID", "Delay", "Times")
+	if err != nil {
+		return ctx, err
+	}
+	for _, record := range records {
+		fault := queue.Fault{
+			Kind:          queue.FaultKind(record["Kind"]),
+			CorrelationID: scopedTXID(ctx, record["TXID"]),
+		}
+		if key := record["Queue"]; key != "" {
+			if fault.Queue = queuePathForKey(cfg, key); fault.Queue == "" {
+				return ctx, fmt.Errorf("unknown queue identifier key: %s", key)
+			}
+		}
+		if fault.Delay, err = optionalDuration(record, "Delay"); err != nil {
+			return ctx, err
+		}
+		if fault.Times, err = optionalInt(record, "Times"); err != nil {
+			return ctx, err
+		}
+		added, err := cfg.queueFaults.Add(fault)
+		if err != nil {
+			return ctx, err
+		}
+		recordNote(ctx, "Faults", "", "queues inject fault %s: %+v", added.ID, added)
+	}
+	return ctx, nil
+}
+
+// allInjectedFaultsShouldHaveBeenApplied checks that the faults limited by Times were applied that often,
+// so a scenario cannot pass because its faults never happened
+func allInjectedFaultsShouldHaveBeenApplied(ctx context.Context) (context.Context, error) {
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	if !ok || mockServer == nil {
+		return ctx, fmt.Errorf("mock ELSA API server not found in context")
+	}
+	var pending []string
+	for _, fault := range mockServer.Faults() {
+		if fault.Times > 0 {
+			pending = append(pending, fmt.Sprintf("API fault %s (%d more times)", fault.ID, fault.Times))
+		}
+	}
+	for _, fault := range mockServer.QueueFaults().List() {
+		if fault.Times > 0 {
+			pending = append(pending, fmt.Sprintf("queue fault %s %s (%d more times)", fault.ID, fault.Kind, fault.Times))
+		}
+	}
+	if len(pending) > 0 {
+		return ctx, fmt.Errorf("injected faults not applied: %s", strings.Join(pending, ", "))
+	}
+	return ctx, nil
+}
+
+func registerFaultSteps(s *godog.ScenarioContext) {
+	s.Step(`^the mock ELSA API injects the faults:$`, theMockElsaAPIInjectsTheFaults)
+	s.Step(`^the queues inject the faults:$`, theQueuesInjectTheFaults)
+	s.Step(`^all injected faults should have been applied$`, allInjectedFaultsShouldHaveBeenApplied)
+}
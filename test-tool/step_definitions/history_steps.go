@@ -0,0 +1,331 @@
+package step_definitions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// Wildcards of the expected status history
+const (
+	anyStatuses = "*" // any number of statuses, also none
+	oneStatus   = "?" // exactly one status
+)
+
+// expectedStatus is a row of the expected status history, within is the maximum time since the previous status
+type expectedStatus struct {
+	name   string
+	within time.Duration
+}
+
+// fetchInstruction reads the instruction from the ELSA API
+func fetchInstruction(ctx context.Context, cfg *Config, clientTXID string) (*APIInstructionResponse, error) {
+	apiURL := fmt.Sprintf("%s/instructions/%s", cfg.ElsaAPIBaseURL, clientTXID)
+	body, status, err := httpGet(ctx, apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("API call failed for %s: %w", clientTXID, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API call for %s returned status %d: %s", clientTXID, status, string(body))
+	}
+	var apiResp APIInstructionResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON response for %s: %w", clientTXID, err)
+	}
+	return &apiResp, nil
+}
+
+// httpGet calls the ELSA API, the call and its response are recorded for the report of the scenario
+func httpGet(ctx context.Context, apiURL string) ([]byte, int, error) {
+	start := time.Now()
+	body, status, err := doGet(ctx, apiURL)
+	poll := report.Event{Kind: report.KindPoll, Start: start, Duration: time.Since(start), Title: "GET " + apiURL, Body: string(body)}
+	if err != nil {
+		poll.Status = "error"
+		poll.Detail = err.Error()
+	} else {
+		poll.Status = strconv.Itoa(status)
+	}
+	scenarioRecorder(ctx).Record(poll)
+	return body, status, err
+}
+
+func doGet(ctx context.Context, apiURL string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+// chronological returns the status history oldest first, the API returns it newest first
+func chronological(statuses []InstructionStatus) []InstructionStatus {
+	res := make([]InstructionStatus, len(statuses))
+	for i, st := range statuses {
+		res[len(statuses)-1-i] = st
+	}
+	return res
+}
+
+func statusNames(statuses []InstructionStatus) string {
+	names := make([]string, len(statuses))
+	for i, st := range statuses {
+		names[i] = st.Name
+	}
+	return strings.Join(names, " -> ")
+}
+
+// parseExpectedHistory reads the | Status | Within | table, the Within column is optional
+func parseExpectedHistory(data *godog.Table) ([]expectedStatus, error) {
+	if data == nil || len(data.Rows) == 0 {
+		return nil, fmt.Errorf("expected DataTable with header | Status |")
+	}
+	header := data.Rows[0]
+	if header.Cells[0].Value != "Status" || len(header.Cells) > 2 || (len(header.Cells) == 2 && header.Cells[1].Value != "Within") {
+		return nil, fmt.Errorf("expected DataTable header to be | Status | or | Status | Within |")
+	}
+
+	var expected []expectedStatus
+	for _, row := range data.Rows[1:] {
+		if len(row.Cells) != len(header.Cells) {
+			return nil, fmt.Errorf("expected %d cells per row in DataTable, got %d", len(header.Cells), len(row.Cells))
+		}
+		st := expectedStatus{name: row.Cells[0].Value}
+		if len(row.Cells) == 2 && row.Cells[1].Value != "" {
+			if st.name == anyStatuses || st.name == oneStatus {
+				return nil, fmt.Errorf("a time limit cannot be set for the wildcard %s", st.name)
+			}
+			d, err := time.ParseDuration(row.Cells[1].Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time limit %q for %s: %w", row.Cells[1].Value, st.name, err)
+			}
+			st.within = d
+		}
+		expected = append(expected, st)
+	}
+	return expected, nil
+}
+
+// matchHistory matches the chronological history against the expected statuses. For every expected
+// row it returns the index of the matched history entry, -1 for wildcards. ok is false if the history does not match.
+func matchHistory(expected []expectedStatus, history []InstructionStatus) ([]int, bool) {
+	pos := make([]int, len(expected))
+	var match func(e, h int) bool
+	match = func(e, h int) bool {
+		if e == len(expected) {
+			return h == len(history)
+		}
+		switch expected[e].name {
+		case anyStatuses:
+			pos[e] = -1
+			for i := h; i <= len(history); i++ {
+				if match(e+1, i) {
+					return true
+				}
+			}
+			return false
+		case oneStatus:
+			pos[e] = -1
+			return h < len(history) && match(e+1, h+1)
+		default:
+			pos[e] = h
+			return h < len(history) && history[h].Name == expected[e].name && match(e+1, h+1)
+		}
+	}
+	return pos, match(0, 0)
+}
+
+// checkTimestamps checks the timestamps of the history are monotonic and the matched statuses
+// were reached within their limit after the previous status
+func checkTimestamps(expected []expectedStatus, pos []int, history []InstructionStatus) error {
+	times := make([]time.Time, len(history))
+	for i, st := range history {
+		t, err := time.Parse(time.RFC3339Nano, st.Timestamp)
+		if err != nil {
+			return fmt.Errorf("status %s has an invalid timestamp %q: %w", st.Name, st.Timestamp, err)
+		}
+		times[i] = t
+		if i > 0 && t.Before(times[i-1]) {
+			return fmt.Errorf("status %s (%s) is older than the previous status %s (%s)", st.Name, st.Timestamp, history[i-1].Name, history[i-1].Timestamp)
+		}
+	}
+	for e, h := range pos {
+		if h <= 0 || expected[e].within == 0 {
+			continue
+		}
+		if d := times[h].Sub(times[h-1]); d > expected[e].within {
+			return fmt.Errorf("status %s was reached %s after %s, expected within %s", history[h].Name, d, history[h-1].Name, expected[e].within)
+		}
+	}
+	return nil
+}
+
+func elsaInstructionShouldHaveStatusHistory(ctx context.Context, clientTXID string, data *godog.Table) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	expected, err := parseExpectedHistory(data)
+	if err != nil {
+		return ctx, err
+	}
+	clientTXID = scopedTXID(ctx, clientTXID)
+
+	timeout := time.Duration(cfg.PollingTimeoutSeconds) * time.Second
+
+	lastHistory, lastStatus := "none", ""
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err = waitFor(waitCtx, cfg, timeout, statusEvents(waitCtx, cfg, clientTXID), func(ctx context.Context) (bool, error) {
+		apiResp, err := fetchInstruction(ctx, cfg, clientTXID)
+		if err != nil {
+			recordNote(ctx, "API", "error", "%v, retrying", err)
+			return false, nil
+		}
+		history := chronological(apiResp.Status)
+		lastHistory = statusNames(history)
+		if len(history) > 0 {
+			lastStatus = history[len(history)-1].Name
+		}
+		pos, ok := matchHistory(expected, history)
+		if !ok {
+			return false, nil
+		}
+		if err := checkTimestamps(expected, pos, history); err != nil {
+			return false, fmt.Errorf("instruction %s: %w", clientTXID, err)
+		}
+		return true, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		recordStatusMissed(ctx, expected[len(expected)-1].name)
+		return ctx, fmt.Errorf("timeout after %s waiting for the status history of instruction %s, last history: %s", timeout, clientTXID, lastHistory)
+	}
+	if err != nil {
+		return ctx, err
+	}
+	recordStatusReached(ctx, clientTXID, lastStatus)
+	return ctx, nil
+}
+
+func elsaInstructionShouldNeverHaveHadStatus(ctx context.Context, clientTXID, unwantedStatus string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	clientTXID = scopedTXID(ctx, clientTXID)
+	apiResp, err := fetchInstruction(ctx, cfg, clientTXID)
+	if err != nil {
+		return ctx, err
+	}
+	for _, st := range apiResp.Status {
+		if st.Name == unwantedStatus {
+			return ctx, fmt.Errorf("instruction %s had status %s at %s, history: %s", clientTXID, unwantedStatus, st.Timestamp, statusNames(chronological(apiResp.Status)))
+		}
+	}
+	return ctx, nil
+}
+
+// elsaInstructionShouldKeepStatus checks the instruction stays in its status, e.g. when a party does not answer
+func elsaInstructionShouldKeepStatus(ctx context.Context, clientTXID, expectedStatus string, seconds int) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	clientTXID = scopedTXID(ctx, clientTXID)
+
+	pollingInterval := time.Duration(cfg.PollingIntervalSeconds) * time.Second
+	window := time.Duration(seconds) * time.Second
+	startTime := time.Now()
+
+	for {
+		apiResp, err := fetchInstruction(ctx, cfg, clientTXID)
+		if err != nil {
+			return ctx, err
+		}
+		if len(apiResp.Status) == 0 || apiResp.Status[0].Name != expectedStatus {
+			return ctx, fmt.Errorf("instruction %s left status %s, history: %s", clientTXID, expectedStatus, statusNames(chronological(apiResp.Status)))
+		}
+		if time.Since(startTime) >= window {
+			return ctx, nil
+		}
+		time.Sleep(min(pollingInterval, window-time.Since(startTime)))
+	}
+}
+
+// resolveLink makes a relative link absolute with the configured API base URL
+func resolveLink(cfg *Config, link string) (string, error) {
+	base, err := url.Parse(cfg.ElsaAPIBaseURL + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid ELSA API base URL %s: %w", cfg.ElsaAPIBaseURL, err)
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid link %s: %w", link, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func theMessageOfStatusShouldContain(ctx context.Context, statusName, clientTXID string, data *godog.Table) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	clientTXID = scopedTXID(ctx, clientTXID)
+	apiResp, err := fetchInstruction(ctx, cfg, clientTXID)
+	if err != nil {
+		return ctx, err
+	}
+
+	var link string
+	for _, st := range apiResp.Status {
+		if st.Name == statusName {
+			link = st.Message
+			break
+		}
+	}
+	if link == "" {
+		return ctx, fmt.Errorf("instruction %s has no message for status %s, history: %s", clientTXID, statusName, statusNames(chronological(apiResp.Status)))
+	}
+
+	messageURL, err := resolveLink(cfg, link)
+	if err != nil {
+		return ctx, err
+	}
+	body, status, err := httpGet(ctx, messageURL)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to fetch message %s: %w", messageURL, err)
+	}
+	if status != http.StatusOK {
+		return ctx, fmt.Errorf("fetching message %s returned status %d: %s", messageURL, status, string(body))
+	}
+	if err := checkXPathValues(ctx, body, data); err != nil {
+		return ctx, fmt.Errorf("message of status %s of instruction %s: %w", statusName, clientTXID, err)
+	}
+	return ctx, nil
+}
+
+func registerHistorySteps(s *godog.ScenarioContext) {
+	s.Step(`^ELSA instruction "([^"]*)" should have the status history:$`, elsaInstructionShouldHaveStatusHistory)
+	s.Step(`^ELSA instruction "([^"]*)" should never have had status "([^"]*)"$`, elsaInstructionShouldNeverHaveHadStatus)
+	s.Step(`^ELSA instruction "([^"]*)" should keep status "([^"]*)" for (\d+) seconds?$`, elsaInstructionShouldKeepStatus)
+	s.Step(`^the message of status "([^"]*)" of ELSA instruction "([^"]*)" should contain:$`, theMessageOfStatusShouldContain)
+}
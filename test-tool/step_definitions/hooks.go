@@ -3,185 +3,195 @@ package step_definitions
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"net/url" // Added import
 	"os"
-	"strconv" // Added import
-	"strings"
-	"sync" // Added for server start synchronization
+	"path/filepath"
 	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"test-tool/report"
 	"time"
 
 	"github.com/cucumber/godog"
 )
 
-const MockServerKey TestContextKey = "mockElsaServer"
+const mockServerKey contextKey = "mockElsaServer"
 
-var serverInstance *mock_elsa_server.MockElsaAPIServer
-var httpServer *http.Server
-var serverStartOnce sync.Once
-var serverErr error
+// defaultConfigFile is used by scenarios which do not configure the system themselves
+const defaultConfigFile = "testdata/config/elsa_services.json"
 
-// ensureMockServerIsRunningAndResetState starts the mock server if not already running,
-// and resets its state. It's designed to be called before each scenario.
-func ensureMockServerIsRunningAndResetState(ctx context.Context) (context.Context, error) {
-	cfg, ok := ctx.Value(ConfigKey).(*Config)
-	if !ok || cfg == nil { // Attempt to load a default config if not found, for server startup
-		defaultCfg, err := LoadConfig("testdata/config/elsa_services.json")
+// cassetteDir holds the API recordings of the elsaproxy tool
+const cassetteDir = "testdata/cassettes"
+
+// startScenarioScope starts the mock server of the scenario on an ephemeral port and
+// puts the scoped default configuration into the context.
+func (s *Suite) startScenarioScope(ctx context.Context, recorder *report.Scenario) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil { // Attempt to load a default config if not found
+		defaultCfg, err := s.LoadConfig(s.path(defaultConfigFile), "")
 		if err != nil {
 			return ctx, fmt.Errorf("configuration not found and default config failed to load: %w", err)
 		}
 		cfg = defaultCfg
-		ctx = context.WithValue(ctx, ConfigKey, cfg) // Add loaded default config to context
-		fmt.Println("Loaded default configuration for server startup.")
-	}
-
-	serverStartOnce.Do(func() {
-		serverInstance = mock_elsa_server.NewMockElsaAPIServer()
-		addr := ":8080" // Default port
-
-		if cfg.ElsaAPIBaseURL != "" {
-			parsedURL, err := url.Parse(cfg.ElsaAPIBaseURL)
-			if err == nil && parsedURL.Port() != "" {
-				addr = ":" + parsedURL.Port()
-			} else if err == nil && parsedURL.Host != "" && !strings.Contains(parsedURL.Host, ":") {
-				// If URL is like http://localhost (no port), default to 80 or 443 based on scheme
-				// For this mock, we'll stick to a configurable default or 8080 if not parseable.
-				// Or, if host is just a port like ":8081"
-				if strings.HasPrefix(parsedURL.Host, ":") {
-					addr = parsedURL.Host
-				}
-			} else if err != nil {
-				// Fallback for simple "hostname:port" or ":port" strings if url.Parse fails
-				parts := strings.Split(cfg.ElsaAPIBaseURL, ":")
-				if len(parts) > 1 {
-					potentialPort := parts[len(parts)-1]
-					if _, e := strconv.Atoi(potentialPort); e == nil {
-						addr = ":" + potentialPort
-					}
-				}
-			}
-		}
+		note(recorder, "Configuration", "", "loaded the default configuration "+defaultConfigFile)
+	}
 
-		httpServer = &http.Server{
-			Addr:    addr,
-			Handler: serverInstance,
-		}
+	sc, err := newScenarioScope(s, recorder)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, scenarioScopeKey, sc)
+	ctx = context.WithValue(ctx, configKey, sc.scope(cfg))
+	return context.WithValue(ctx, mockServerKey, sc.server), nil
+}
+
+func mockElsaAPIServerIsRunning(ctx context.Context) (context.Context, error) {
+	if mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer); !ok || mockServer == nil {
+		return ctx, fmt.Errorf("mock server is not running")
+	}
+	return ctx, nil
+}
 
-		fmt.Printf("Attempting to start mock ELSA API server on %s...\n", addr)
-		go func() {
-			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				serverErr = fmt.Errorf("mock ELSA API server ListenAndServe error: %v", err)
-				fmt.Fprintln(os.Stderr, serverErr)
-			}
-		}()
-		// Increased sleep and added a simple check. Replace with a proper health check.
-		time.Sleep(250 * time.Millisecond)
-		// Basic check (not a real health check)
-		if serverErr == nil {
-			fmt.Printf("Mock ELSA API server presumed started on %s.\n", addr)
+// mockElsaAPIServerFollowsTheBusinessFlow switches the mock into state-machine mode, statuses are then
+// derived from the messages dropped into the mock MQ directories instead of being set by the steps.
+func mockElsaAPIServerFollowsTheBusinessFlow(ctx context.Context) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	if !ok || mockServer == nil {
+		return ctx, fmt.Errorf("mock ELSA API server not found in context")
+	}
+
+	var queues mock_elsa_server.FlowQueues
+	for _, q := range []struct {
+		name string
+		dst  *queue.Queue
+	}{
+		{cfg.T2SClientRequestQueuePath, nil},
+		{cfg.T2SAcceptanceQueuePath, nil},
+		{cfg.CreationAcceptanceQueuePath, &queues.CreationInbound},
+		{cfg.T2SOutboundQueuePath, &queues.T2SOutbound},
+		{cfg.CreationRequestQueuePath, &queues.CreationOutbound},
+	} {
+		opened, err := openQueue(cfg, q.name)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to open queue for the business flow: %w", err)
+		}
+		if q.dst == nil {
+			queues.T2SInbound = append(queues.T2SInbound, opened)
 		} else {
-			fmt.Printf("Mock ELSA API server may not have started correctly on %s.\n", addr)
+			*q.dst = opened
 		}
-	})
+	}
 
-	if serverErr != nil {
-		return ctx, fmt.Errorf("mock server failed to start previously: %w", serverErr)
+	err := mockServer.StartFlow(queues)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to start the business flow of the mock ELSA API server: %w", err)
+	}
+	return ctx, nil
+}
+
+// mockElsaAPIServerReplaysTheCassette makes the mock answer the API requests with the responses
+// recorded by the elsaproxy tool, the TXIDs are taken from the requests of the scenario.
+func mockElsaAPIServerReplaysTheCassette(ctx context.Context, cassetteFile string) (context.Context, error) {
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	if !ok || mockServer == nil {
+		return ctx, fmt.Errorf("mock ELSA API server not found in context")
 	}
-	if serverInstance == nil {
-		// This should not happen if serverStartOnce.Do completed without error
-		return ctx, fmt.Errorf("server instance is nil after startup attempt")
+	suite, err := scenarioSuite(ctx)
+	if err != nil {
+		return ctx, err
 	}
+	cassette, err := mock_elsa_server.LoadCassette(suite.path(filepath.Join(cassetteDir, cassetteFile)))
+	if err != nil {
+		return ctx, err
+	}
+	if err := mockServer.ReplayCassette(cassette, time.Now()); err != nil {
+		return ctx, fmt.Errorf("failed to replay cassette %s: %w", cassetteFile, err)
+	}
+	recordNote(ctx, "Mock ELSA", "", "replaying %d recorded interactions of %s", len(cassette.Interactions), cassetteFile)
+	return ctx, nil
+}
 
-	fmt.Println("Resetting mock server state.")
-	serverInstance.ResetState()
-	return context.WithValue(ctx, MockServerKey, serverInstance), nil
+// beforeScenario gives the scenario its own mock server, queues and TXID prefix,
+// so scenarios can run concurrently. In a load test it starts when the load profile schedules it.
+func (s *Suite) beforeScenario(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	if err := s.waitForLoadSlot(ctx); err != nil {
+		return ctx, err
+	}
+	fmt.Printf("Starting scenario %q...\n", sc.Name)
+	ctx, err := s.startScenarioScope(ctx, s.report.Scenario(sc.Name, sc.Uri))
+	if err != nil {
+		return ctx, fmt.Errorf("failed in beforeScenario while starting the scenario scope: %w", err)
+	}
+	return ctx, nil
 }
 
-func mockElsaAPIServerIsRunning(ctx context.Context) (context.Context, error) {
-	fmt.Println("Step: mock ELSA API server is running")
-	if serverInstance == nil || serverErr != nil {
-		return ctx, fmt.Errorf("mock server is not running or encountered an error: %v", serverErr)
+// afterScenario stops the mock server of the scenario and cleans up its queues
+func (s *Suite) afterScenario(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+	if scope, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		scope.recorder.Finish(err)
+		s.recordScenario(scope, err)
+		scope.close(err != nil)
 	}
-	fmt.Println("Mock ELSA API server is confirmed running.")
 	return ctx, nil
 }
 
-// BeforeScenarioHook ensures the mock server is running and state is reset,
-// and cleans up mock MQ directories.
-func BeforeScenarioHook(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
-	fmt.Println("Executing BeforeScenarioHook...")
-	var err error
+// beforeStep remembers the start of the step for the timings of the report
+func beforeStep(ctx context.Context, st *godog.Step) (context.Context, error) {
+	if scope, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		scope.startStep()
+	}
+	return ctx, nil
+}
 
-	// Ensure server is running and state is reset
-	ctx, err = ensureMockServerIsRunningAndResetState(ctx)
+// afterStep records the step for the report. The XML messages of the scenario and the last
+// API response are attached to a failed step, the Cucumber JSON report embeds them.
+func afterStep(ctx context.Context, st *godog.Step, status godog.StepResultStatus, err error) (context.Context, error) {
+	scope, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok {
+		return ctx, nil
+	}
+	start := scope.stepStarted()
+	step := report.Event{Kind: report.KindStep, Start: start, Duration: time.Since(start), Title: st.Text, Status: status.String()}
 	if err != nil {
-		return ctx, fmt.Errorf("failed in BeforeScenarioHook while ensuring server state: %w", err)
-	}
-
-	// MQ Cleanup logic
-	cfg, ok := ctx.Value(ConfigKey).(*Config)
-	if ok && cfg != nil {
-		fmt.Printf("Cleaning up mock MQ directories based on config (root: %s)\n", cfg.MockMQRootDir)
-		pathsToClean := []string{
-			cfg.T2SClientRequestQueuePath,
-			cfg.T2SAcceptanceQueuePath,
-			cfg.CreationRequestQueuePath,
-			cfg.CreationAcceptanceQueuePath,
-			// Add any other dynamic queue paths from config here
-		}
-		cleanedPaths := make(map[string]bool) // To avoid double processing
-
-		for _, qPath := range pathsToClean {
-			if qPath != "" && !cleanedPaths[qPath] {
-				fmt.Printf("Cleaning and recreating: %s\n", qPath)
-				if err := os.RemoveAll(qPath); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: could not remove mock queue dir %s: %v\n", qPath, err)
-				}
-				if err := os.MkdirAll(qPath, 0755); err != nil {
-					// Log error but continue if possible, or return error if critical
-					fmt.Fprintf(os.Stderr, "Error: failed to recreate mock MQ dir %s: %v. Continuing cleanup.\n", qPath, err)
-					// return ctx, fmt.Errorf("failed to recreate mock MQ dir %s: %w", qPath, err)
-				}
-				cleanedPaths[qPath] = true
-			}
-		}
-	} else {
-		fmt.Println("Config not found in BeforeScenarioHook, attempting to skip MQ cleanup or use defaults if applicable.")
-		// Optionally, try to load a default config here too for MQ cleanup if that makes sense for your setup
+		step.Detail = err.Error()
+	}
+	scope.recorder.Record(step)
+	if status == godog.StepFailed {
+		ctx = godog.Attach(ctx, failureAttachments(scope.recorder, start)...)
 	}
-
 	return ctx, nil
 }
 
-// AfterSuiteHook shuts down the mock server
-func AfterSuiteHook() {
-	fmt.Println("Executing AfterSuiteHook...")
-	if httpServer != nil {
-		fmt.Println("Shutting down mock ELSA API server...")
-		ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := httpServer.Shutdown(ctxShutDown); err != nil {
-			fmt.Fprintf(os.Stderr, "Mock ELSA API server shutdown error: %v\n", err)
+// Close reports the business flow coverage of all scenarios, writes the HTML timeline of the run to
+// reportDir (unless it is empty) and closes the queue transports and databases. It is called once the
+// scenarios of the suite ran.
+func (s *Suite) Close(reportDir string) {
+	fmt.Println("Closing the test suite...")
+	if reportDir != "" && s.report != nil {
+		path := filepath.Join(reportDir, timelineFile)
+		if err := s.report.WriteFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Writing the report failed: %v\n", err)
 		} else {
-			fmt.Println("Mock ELSA API server shut down successfully.")
+			fmt.Printf("Timeline of the test run written to %s\n", path)
+		}
+	}
+	s.coverageMu.Lock()
+	coverage := s.coverage
+	s.coverage = nil
+	s.coverageMu.Unlock()
+	if coverage != nil {
+		if err := mock_elsa_server.WriteCoverageReport(os.Stdout, coverage); err != nil {
+			fmt.Fprintf(os.Stderr, "Writing the business flow coverage failed: %v\n", err)
 		}
-		httpServer = nil
-		serverInstance = nil
-		serverErr = nil               // Reset server error
-		serverStartOnce = sync.Once{} // Reset sync.Once for potential re-runs in tests (though typically AfterSuite is final)
-
-	} else {
-		fmt.Println("Mock ELSA API server was not running or already shut down.")
 	}
+	s.closeBrokers()
+	s.closeDatabases()
 }
 
-func InitializeHookSteps(s *godog.ScenarioContext) {
+func registerMockSteps(s *godog.ScenarioContext) {
 	s.Step(`^the mock ELSA API server is running$`, mockElsaAPIServerIsRunning)
-
-	s.Before(BeforeScenarioHook) // Godog v0.12.x uses s.Before
+	s.Step(`^the mock ELSA API server follows the business flow$`, mockElsaAPIServerFollowsTheBusinessFlow)
+	s.Step(`^the mock ELSA API server replays the cassette "([^"]*)"$`, mockElsaAPIServerReplaysTheCassette)
 }
-
-// Note: For AfterSuite, Godog doesn't have a direct hook in ScenarioContext.
-// It's typically handled in TestMain or by registering a function with `testing.M.Run()`.
-// For this PoC, we'll call AfterSuiteHook from main_test.go.
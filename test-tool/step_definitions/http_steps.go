@@ -0,0 +1,407 @@
+package step_definitions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// Generic HTTP steps, they are the bindings of the features cmd/postman2feature converts from Postman
+// collections. Postman variables are values of the scenario, {{ref "name"}} in a URL, header, body or
+// expected value refers to them. The last argument of the steps may contain quotes for these references.
+
+// httpResponse is the response of the last request of the scenario
+type httpResponse struct {
+	request  string // method and URL, for the error messages
+	status   int
+	header   http.Header
+	body     []byte
+	duration time.Duration
+}
+
+// setRequestHeaders sets the headers of the next request of the scenario
+func (sc *scenarioScope) setRequestHeaders(header http.Header) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.headers = header
+}
+
+// takeRequestHeaders returns the headers of the next request, they apply to one request only
+func (sc *scenarioScope) takeRequestHeaders() http.Header {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	header := sc.headers
+	sc.headers = nil
+	return header
+}
+
+func (sc *scenarioScope) setResponse(resp *httpResponse) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.response = resp
+}
+
+func (sc *scenarioScope) lastResponse() (*httpResponse, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.response == nil {
+		return nil, fmt.Errorf("no request sent in this scenario")
+	}
+	return sc.response, nil
+}
+
+func currentScope(ctx context.Context) (*scenarioScope, error) {
+	sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok {
+		return nil, fmt.Errorf("scenario scope not found in context")
+	}
+	return sc, nil
+}
+
+func theValuesAre(ctx context.Context, data *godog.Table) (context.Context, error) {
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	records, err := tableRecords(data, "Name", "Value")
+	if err != nil {
+		return ctx, err
+	}
+	for _, r := range records {
+		value, err := expandValue(ctx, r["Value"])
+		if err != nil {
+			return ctx, fmt.Errorf("value %s: %w", r["Name"], err)
+		}
+		sc.setValue(r["Name"], value) // later rows can already refer to it
+	}
+	return ctx, nil
+}
+
+func theValueIsTheElsaAPIBaseURL(ctx context.Context, name string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	sc.setValue(name, cfg.ElsaAPIBaseURL)
+	return ctx, nil
+}
+
+func theRequestHeadersAre(ctx context.Context, data *godog.Table) (context.Context, error) {
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	records, err := tableRecords(data, "Header", "Value")
+	if err != nil {
+		return ctx, err
+	}
+	header := make(http.Header)
+	for _, r := range records {
+		value, err := expandValue(ctx, r["Value"])
+		if err != nil {
+			return ctx, fmt.Errorf("header %s: %w", r["Header"], err)
+		}
+		header.Add(r["Header"], value)
+	}
+	sc.setRequestHeaders(header)
+	return ctx, nil
+}
+
+func iSendARequestTo(ctx context.Context, method, rawURL string) (context.Context, error) {
+	return sendRequest(ctx, method, rawURL, "")
+}
+
+func iSendARequestWithBody(ctx context.Context, method, rawURL string, body *godog.DocString) (context.Context, error) {
+	return sendRequest(ctx, method, rawURL, body.Content)
+}
+
+// sendRequest sends the request with the headers set before, the call and its response are recorded
+// for the report of the scenario like the polls of the ELSA API
+func sendRequest(ctx context.Context, method, rawURL, body string) (context.Context, error) {
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	target, err := expandValue(ctx, rawURL)
+	if err != nil {
+		return ctx, fmt.Errorf("URL: %w", err)
+	}
+	if body, err = expandValue(ctx, body); err != nil {
+		return ctx, fmt.Errorf("body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		return ctx, fmt.Errorf("invalid request %s %s: %w", method, target, err)
+	}
+	for name, values := range sc.takeRequestHeaders() {
+		req.Header[name] = values
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	call := report.Event{Kind: report.KindPoll, Start: start, Duration: time.Since(start), Title: method + " " + target, Body: string(respBody)}
+	if err != nil {
+		call.Status = "error"
+		call.Detail = err.Error()
+		scenarioRecorder(ctx).Record(call)
+		return ctx, fmt.Errorf("request %s %s failed: %w", method, target, err)
+	}
+	call.Status = strconv.Itoa(resp.StatusCode)
+	scenarioRecorder(ctx).Record(call)
+	sc.setResponse(&httpResponse{request: method + " " + target, status: resp.StatusCode, header: resp.Header, body: respBody, duration: call.Duration})
+	return ctx, nil
+}
+
+func theResponseStatusShouldBe(ctx context.Context, expected int) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if resp.status != expected {
+		return ctx, fmt.Errorf("%s returned status %d, expected %d: %s", resp.request, resp.status, expected, resp.body)
+	}
+	return ctx, nil
+}
+
+func theResponseTimeShouldBeBelow(ctx context.Context, ms int) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if limit := time.Duration(ms) * time.Millisecond; resp.duration >= limit {
+		return ctx, fmt.Errorf("%s took %s, expected below %s", resp.request, resp.duration.Round(time.Millisecond), limit)
+	}
+	return ctx, nil
+}
+
+func theResponseShouldHaveTheHeader(ctx context.Context, name string) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if _, ok := resp.header[http.CanonicalHeaderKey(name)]; !ok {
+		return ctx, fmt.Errorf("response of %s has no header %s", resp.request, name)
+	}
+	return ctx, nil
+}
+
+func theResponseHeaderShouldBe(ctx context.Context, name, expected string) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if expected, err = expandValue(ctx, expected); err != nil {
+		return ctx, err
+	}
+	if actual := resp.header.Get(name); actual != expected {
+		return ctx, fmt.Errorf("response of %s has header %s %q, expected %q", resp.request, name, actual, expected)
+	}
+	return ctx, nil
+}
+
+func theResponseHeaderShouldContain(ctx context.Context, name, expected string) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if expected, err = expandValue(ctx, expected); err != nil {
+		return ctx, err
+	}
+	if actual := resp.header.Get(name); !strings.Contains(actual, expected) {
+		return ctx, fmt.Errorf("response of %s has header %s %q, expected it to contain %q", resp.request, name, actual, expected)
+	}
+	return ctx, nil
+}
+
+func theResponseBodyShouldContain(ctx context.Context, expected string) (context.Context, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if expected, err = expandValue(ctx, expected); err != nil {
+		return ctx, err
+	}
+	if !bytes.Contains(resp.body, []byte(expected)) {
+		return ctx, fmt.Errorf("response of %s does not contain %q: %s", resp.request, expected, resp.body)
+	}
+	return ctx, nil
+}
+
+func theResponseJSONShouldBe(ctx context.Context, path, expected string) (context.Context, error) {
+	resp, value, err := responseJSON(ctx, path)
+	if err != nil {
+		return ctx, err
+	}
+	if expected, err = expandValue(ctx, expected); err != nil {
+		return ctx, err
+	}
+	if actual := jsonText(value); actual != expected {
+		return ctx, fmt.Errorf("response of %s has %s %s, expected %s", resp.request, path, actual, expected)
+	}
+	return ctx, nil
+}
+
+func theResponseJSONShouldExist(ctx context.Context, path string) (context.Context, error) {
+	_, _, err := responseJSON(ctx, path)
+	return ctx, err
+}
+
+func theResponseJSONShouldHaveItems(ctx context.Context, path string, expected int) (context.Context, error) {
+	resp, value, err := responseJSON(ctx, path)
+	if err != nil {
+		return ctx, err
+	}
+	var n int
+	switch v := value.(type) {
+	case []interface{}:
+		n = len(v)
+	case map[string]interface{}:
+		n = len(v)
+	case string:
+		n = len(v)
+	default:
+		return ctx, fmt.Errorf("response of %s has no items at %s: %s", resp.request, path, jsonText(value))
+	}
+	if n != expected {
+		return ctx, fmt.Errorf("response of %s has %d items at %s, expected %d", resp.request, n, path, expected)
+	}
+	return ctx, nil
+}
+
+func theResponseJSONIsSavedAs(ctx context.Context, path, name string) (context.Context, error) {
+	_, value, err := responseJSON(ctx, path)
+	if err != nil {
+		return ctx, err
+	}
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	sc.setValue(name, jsonText(value))
+	return ctx, nil
+}
+
+func scenarioResponse(ctx context.Context) (*httpResponse, error) {
+	sc, err := currentScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sc.lastResponse()
+}
+
+// responseJSON returns the value at the path of the last response, see jsonPath
+func responseJSON(ctx context.Context, path string) (*httpResponse, interface{}, error) {
+	resp, err := scenarioResponse(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(resp.body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("response of %s is no JSON: %w", resp.request, err)
+	}
+	value, err := jsonPath(doc, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("response of %s: %w", resp.request, err)
+	}
+	return resp, value, nil
+}
+
+// jsonPath returns the value at a path like status[0].name or links['audit'], an empty path is the whole document
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	value := doc
+	rest := path
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			continue
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %s", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && inner[0] == '\'' && inner[len(inner)-1] == '\'' {
+				key = inner[1 : len(inner)-1]
+			} else if i, err := strconv.Atoi(inner); err == nil {
+				index = i
+			} else {
+				return nil, fmt.Errorf("invalid index %s in path %s", inner, path)
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+		}
+
+		if index >= 0 {
+			items, ok := value.([]interface{})
+			if !ok || index >= len(items) {
+				return nil, fmt.Errorf("no element %d at %s", index, path)
+			}
+			value = items[index]
+			continue
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no field %s at %s", key, path)
+		}
+		if value, ok = fields[key]; !ok {
+			return nil, fmt.Errorf("no field %s at %s", key, path)
+		}
+	}
+	return value, nil
+}
+
+// jsonText returns a string as is and other values as JSON, e.g. 200, true or null
+func jsonText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func registerHTTPSteps(s *godog.ScenarioContext) {
+	s.Step(`^the values are:$`, theValuesAre)
+	s.Step(`^the value "([^"]*)" is the ELSA API base URL$`, theValueIsTheElsaAPIBaseURL)
+	s.Step(`^the request headers are:$`, theRequestHeadersAre)
+	s.Step(`^I send a (GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS) request to "(.*)"$`, iSendARequestTo)
+	s.Step(`^I send a (GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS) request to "(.*)" with body:$`, iSendARequestWithBody)
+	s.Step(`^the response status should be (\d+)$`, theResponseStatusShouldBe)
+	s.Step(`^the response time should be below (\d+) ms$`, theResponseTimeShouldBeBelow)
+	s.Step(`^the response should have the header "([^"]*)"$`, theResponseShouldHaveTheHeader)
+	s.Step(`^the response header "([^"]*)" should be "(.*)"$`, theResponseHeaderShouldBe)
+	s.Step(`^the response header "([^"]*)" should contain "(.*)"$`, theResponseHeaderShouldContain)
+	s.Step(`^the response body should contain "(.*)"$`, theResponseBodyShouldContain)
+	s.Step(`^the response JSON "([^"]*)" should be "(.*)"$`, theResponseJSONShouldBe)
+	s.Step(`^the response JSON "([^"]*)" should exist$`, theResponseJSONShouldExist)
+	s.Step(`^the response JSON "([^"]*)" should have (\d+) items?$`, theResponseJSONShouldHaveItems)
+	s.Step(`^the response JSON "([^"]*)" is saved as "([^"]*)"$`, theResponseJSONIsSavedAs)
+}
@@ -0,0 +1,55 @@
+package step_definitions
+
+import (
+	"context"
+	"fmt"
+	"test-tool/load"
+	"time"
+)
+
+// StartLoadTest makes the scenarios start when the pacer schedules them and record their end-to-end
+// latencies. The HTML timeline is not recorded, it would keep the messages of all scenarios in memory.
+func (s *Suite) StartLoadTest(pacer *load.Pacer, recorder *load.Recorder) {
+	s.loadPacer, s.loadRecorder = pacer, recorder
+	s.report = nil
+}
+
+// waitForLoadSlot delays the start of a scenario until it is scheduled by the load profile
+func (s *Suite) waitForLoadSlot(ctx context.Context) error {
+	if s.loadPacer == nil {
+		return nil
+	}
+	i, err := s.loadPacer.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for the start of load test scenario %d: %w", i+1, err)
+	}
+	return nil
+}
+
+// recordStatusReached records the time from the first message put for the TXID to the status the
+// polling observed, it is the end-to-end latency of the status in the load report
+func recordStatusReached(ctx context.Context, clientTXID, status string) {
+	sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	if !ok || sc.suite.loadRecorder == nil {
+		return
+	}
+	if sent, ok := sc.sentAt(clientTXID); ok {
+		sc.suite.loadRecorder.Record(status, time.Since(sent))
+	}
+}
+
+// recordStatusMissed counts a status which was not reached within the polling timeout
+func recordStatusMissed(ctx context.Context, status string) {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		sc.suite.loadRecorder.Fail(status)
+	}
+}
+
+// recordScenario records the duration of the scenario of the scope, a failed one as error
+func (s *Suite) recordScenario(sc *scenarioScope, err error) {
+	if err != nil {
+		s.loadRecorder.Fail(load.Scenario)
+		return
+	}
+	s.loadRecorder.Record(load.Scenario, time.Since(sc.started))
+}
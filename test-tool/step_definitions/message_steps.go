@@ -1,108 +1,141 @@
 package step_definitions
 
 import (
-	"bytes"
 	"context"
+	"elsa-xml/pkg/validator"
 	"fmt"
-	"os"
-	"path/filepath"
+	"test-tool/fixtures"
 	"test-tool/mock_elsa_server"
-	"text/template"
 
 	"github.com/cucumber/godog"
 )
 
 const messageTemplateDir = "testdata/messages/templates"
 
-func t2sPreparesAMessageWithValues(ctx context.Context, templateFileName string, data *godog.Table) (context.Context, error) {
-	cfg, ok := ctx.Value(ConfigKey).(*Config)
-	if !ok || cfg == nil {
-		return ctx, fmt.Errorf("configuration not found in context")
+// fixtureLibrary returns the library of the message templates, the rendered messages are validated
+// against the schemas of the configured directories. The libraries are shared by the scenarios of the
+// suite, so templates and schemas are loaded once.
+func fixtureLibrary(cfg *Config) (*fixtures.Library, error) {
+	if cfg.SchemaDir == "" && cfg.T2SSchemaDir == "" {
+		return nil, fmt.Errorf("schema directories are empty, check config elsa_services.json")
 	}
-
-	templatePath := filepath.Join(messageTemplateDir, templateFileName)
-	tmplContent, err := os.ReadFile(templatePath)
-	if err != nil {
-		return ctx, fmt.Errorf("failed to read template file %s: %w", templatePath, err)
+	s := cfg.suite
+	if s == nil {
+		return nil, fmt.Errorf("message templates are only available within a scenario")
 	}
+	key := [2]string{cfg.SchemaDir, cfg.T2SSchemaDir}
 
-	tmpl, err := template.New(templateFileName).Parse(string(tmplContent))
+	s.fixtureLibrariesMu.Lock()
+	defer s.fixtureLibrariesMu.Unlock()
+	if lib, ok := s.fixtureLibraries[key]; ok {
+		return lib, nil
+	}
+	v, err := validator.NewGoValidator(cfg.SchemaDir, cfg.T2SSchemaDir)
 	if err != nil {
-		return ctx, fmt.Errorf("failed to parse template %s: %w", templatePath, err)
+		return nil, fmt.Errorf("failed to load the schemas for the message templates: %w", err)
 	}
+	lib := fixtures.NewLibrary(s.path(messageTemplateDir), v)
+	s.fixtureLibraries[key] = lib
+	return lib, nil
+}
 
-	templateData := make(map[string]interface{})
-	currentTXID := ""
-	currentMitiTXID := ""
+// templateValues reads the | Field | Value | table of a prepare step. TXIDs of the feature file are replaced by the
+// ones of the scenario, helper functions like {{businessDate 2}} are evaluated. The values are remembered for later
+// steps ({{ref "Field"}}), TransactionId and MitiTransactionId are also available as TXID and MitiTXID.
+func templateValues(ctx context.Context, data *godog.Table) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if data == nil || len(data.Rows) == 0 {
+		return values, nil
+	}
+	header := data.Rows[0]
+	if len(header.Cells) != 2 || header.Cells[0].Value != "Field" || header.Cells[1].Value != "Value" {
+		return nil, fmt.Errorf("expected DataTable header to be | Field | Value |, got | %s | %s |", header.Cells[0].Value, header.Cells[1].Value)
+	}
 
-	if len(data.Rows) > 0 { // Check if there are any rows, including header
-		header := data.Rows[0]
-		if len(header.Cells) != 2 || header.Cells[0].Value != "Field" || header.Cells[1].Value != "Value" {
-			return ctx, fmt.Errorf("expected DataTable header to be | Field | Value |, got | %s | %s |", header.Cells[0].Value, header.Cells[1].Value)
+	sc, _ := ctx.Value(scenarioScopeKey).(*scenarioScope)
+	for _, row := range data.Rows[1:] { // Skip header row
+		if len(row.Cells) != 2 {
+			return nil, fmt.Errorf("expected 2 cells per row in DataTable, got %d", len(row.Cells))
 		}
-		for _, row := range data.Rows[1:] { // Skip header row
-			if len(row.Cells) != 2 {
-				return ctx, fmt.Errorf("expected 2 cells per row in DataTable, got %d", len(row.Cells))
-			}
-			fieldName := row.Cells[0].Value
-			fieldValue := row.Cells[1].Value
-			templateData[fieldName] = fieldValue // Store with original field name
-
-			// Handle TXID variations for context and template
-			if fieldName == "TransactionId" || fieldName == "TXID" {
-				currentTXID = fieldValue
-				templateData["TXID"] = fieldValue // Ensure template data has "TXID" key
-			}
-			// Handle MitiTXID variations (if Gherkin might use different names)
-			if fieldName == "MitiTransactionId" || fieldName == "MitiTXID" {
-				currentMitiTXID = fieldValue
-				templateData["MitiTXID"] = fieldValue // Ensure template data has "MitiTXID" key
+		fieldName := row.Cells[0].Value
+		fieldValue := row.Cells[1].Value
+
+		// TXIDs of the feature file are replaced by the ones of the scenario, see scenarioScope
+		if fieldName == "TransactionId" || fieldName == "TXID" {
+			fieldValue = scopedTXID(ctx, fieldValue)
+		} else {
+			expanded, err := expandValue(ctx, fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", fieldName, err)
 			}
+			fieldValue = expanded
 		}
-	}
 
-	// If MitiTXID was not in the table but TXID was, construct MitiTXID
-	if currentTXID != "" && currentMitiTXID == "" {
-		// Check if the template expects MitiTXID. This is a simple check;
-		// a more robust way might involve inspecting template fields.
-		if _, ok := templateData["MitiTXID"]; !ok && bytes.Contains(tmplContent, []byte("MitiTXID")) {
-			templateData["MitiTXID"] = "miti-" + currentTXID
-			currentMitiTXID = "miti-" + currentTXID
+		names := []string{fieldName}
+		switch fieldName {
+		case "TransactionId":
+			names = append(names, "TXID")
+		case "MitiTransactionId":
+			names = append(names, "MitiTXID")
+		}
+		for _, name := range names {
+			values[name] = fieldValue
+			if sc != nil {
+				sc.setValue(name, fieldValue) // later rows can already refer to it
+			}
 		}
 	}
+	return values, nil
+}
 
-	var processedXML bytes.Buffer
-	if err := tmpl.Execute(&processedXML, templateData); err != nil {
-		return ctx, fmt.Errorf("failed to execute template %s with data %+v: %w", templateFileName, templateData, err)
+func t2sPreparesAMessageWithValues(ctx context.Context, templateFileName string, data *godog.Table) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	lib, err := fixtureLibrary(cfg)
+	if err != nil {
+		return ctx, err
 	}
 
-	newCtx := context.WithValue(ctx, PreparedMessageKey, processedXML.String())
-	if currentTXID != "" {
-		newCtx = context.WithValue(newCtx, CurrentTXIDKey, currentTXID)
+	values, err := templateValues(ctx, data)
+	if err != nil {
+		return ctx, err
 	}
-	if currentMitiTXID != "" {
-		newCtx = context.WithValue(newCtx, CurrentMitiTXIDKey, currentMitiTXID)
+	payload, err := lib.Render(templateFileName, values, scenarioValues(ctx))
+	if err != nil {
+		return ctx, err
+	}
+
+	// The IDs are taken from the message, the template may have generated them
+	newCtx := context.WithValue(ctx, preparedMessageKey, string(payload))
+	if parsed, err := mock_elsa_server.ParseMessage(payload); err == nil {
+		if parsed.TXID != "" {
+			newCtx = context.WithValue(newCtx, currentTXIDKey, parsed.TXID)
+		}
+		if parsed.MitiTXID != "" {
+			newCtx = context.WithValue(newCtx, currentMitiTXIDKey, parsed.MitiTXID)
+		}
 	}
 	return newCtx, nil
 }
 
 func t2sSendsThePreparedMessageToQueueWithCorrelationID(ctx context.Context, queueIdentifierKey string, correlationID string) (context.Context, error) {
-	cfg, ok := ctx.Value(ConfigKey).(*Config)
+	cfg, ok := ctx.Value(configKey).(*Config)
 	if !ok || cfg == nil {
 		return ctx, fmt.Errorf("configuration not found in context")
 	}
 
-	payload, ok := ctx.Value(PreparedMessageKey).(string)
+	payload, ok := ctx.Value(preparedMessageKey).(string)
 	if !ok || payload == "" {
 		return ctx, fmt.Errorf("no prepared message found in context to send")
 	}
+	correlationID = scopedTXID(ctx, correlationID)
 
 	var queuePath string
 	switch queueIdentifierKey {
-	case "t2sClientRequestQueueName": // Matches the string in the feature file
-		queuePath = cfg.T2SClientRequestQueuePath
-	case "t2sAcceptanceQueueName": // Matches the string in the feature file
-		queuePath = cfg.T2SAcceptanceQueuePath
+	case "t2sClientRequestQueueName", "t2sAcceptanceQueueName": // Matches the string in the feature file
+		queuePath = queuePathForKey(cfg, queueIdentifierKey)
 	default:
 		return ctx, fmt.Errorf("unknown queue identifier key: %s. Expected 't2sClientRequestQueueName' or 't2sAcceptanceQueueName'", queueIdentifierKey)
 	}
@@ -111,41 +144,48 @@ func t2sSendsThePreparedMessageToQueueWithCorrelationID(ctx context.Context, que
 		return ctx, fmt.Errorf("queue path is empty for identifier %s, check config elsa_services.json", queueIdentifierKey)
 	}
 
-	// Ensure the directory for the queue exists
-	// The queuePath from config is expected to be a directory.
-	err := os.MkdirAll(queuePath, 0755)
-	if err != nil {
-		return ctx, fmt.Errorf("failed to create mock MQ directory %s: %w", queuePath, err)
-	}
-
-	fileName := correlationID + ".xml"
+	messageID := correlationID
 	if queueIdentifierKey == "t2sAcceptanceQueueName" {
-		fileName = correlationID + "_accept.xml"
+		messageID = correlationID + "_accept"
 	}
-	filePath := filepath.Join(queuePath, fileName)
-
-	err = os.WriteFile(filePath, []byte(payload), 0644)
-	if err != nil {
-		return ctx, fmt.Errorf("failed to write message to mock queue file %s: %w", filePath, err)
+	if err := putMessage(ctx, cfg, queuePath, messageID, correlationID, payload); err != nil {
+		return ctx, fmt.Errorf("failed to send message to %s: %w", queueIdentifierKey, err)
 	}
-	fmt.Printf("MockMQ: Message written to %s\\n", filePath)
 
-	// After writing to the mock queue, simulate ELSA receiving the message by setting an initial status
-	mockServer, ok := ctx.Value(MockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	// After writing to the mock queue, simulate ELSA receiving the message by setting an initial status.
+	// In business flow mode the mock reads the message from the queue and derives the status itself.
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
 	if !ok || mockServer == nil {
 		return ctx, fmt.Errorf("mock ELSA API server not found in context")
 	}
 
-	mockServer.SetInstructionStatus(correlationID, "created") // TODO
+	if !mockServer.FlowEnabled() {
+		mockServer.SetInstructionStatus(correlationID, "created")
+	}
+
+	return context.WithValue(ctx, currentTXIDKey, correlationID), nil
+}
 
-	return context.WithValue(ctx, CurrentTXIDKey, correlationID), nil
+// queuePathForKey resolves the queue identifiers used in the feature files to the configured queue names
+// (the mock MQ directories for the directory transport)
+func queuePathForKey(cfg *Config, queueIdentifierKey string) string {
+	switch queueIdentifierKey {
+	case "t2sClientRequestQueueName":
+		return cfg.T2SClientRequestQueuePath
+	case "t2sAcceptanceQueueName":
+		return cfg.T2SAcceptanceQueuePath
+	case "creationRequestQueueName":
+		return cfg.CreationRequestQueuePath
+	case "creationAcceptanceQueueName":
+		return cfg.CreationAcceptanceQueuePath
+	case "t2sOutboundQueueName":
+		return cfg.T2SOutboundQueuePath
+	}
+	return ""
 }
 
-func InitializeMessageSteps(s *godog.ScenarioContext) {
+func registerT2SSteps(s *godog.ScenarioContext) {
 	s.Step(`^T2S prepares an initial client request message using template "([^"]*)" with values:$`, t2sPreparesAMessageWithValues)
 	s.Step(`^T2S prepares an acceptance message using template "([^"]*)" with values:$`, t2sPreparesAMessageWithValues)
 	s.Step(`^T2S sends the prepared message to the "([^"]*)" queue with correlation ID "([^"]*)"$`, t2sSendsThePreparedMessageToQueueWithCorrelationID)
-	// Remove or comment out old step definitions if they are no longer used by this new single step
-	// s.Step(\`^T2S sends the prepared message to the T2S client request queue with client TXID "([^"]*)"$\`, t2sSendsThePreparedMessageToQueueWithClientTXID)
-	// s.Step(\`^T2S sends the prepared acceptance message to the T2S acceptance queue with client TXID "([^"]*)"$\`, t2sSendsThePreparedMessageToQueueWithClientTXID)
 }
@@ -0,0 +1,266 @@
+package step_definitions
+
+import (
+	"bytes"
+	"context"
+	"elsa-xml/pkg/schema"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"test-tool/report"
+	"time"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/cucumber/godog"
+)
+
+const outboundMessageKey contextKey = "outboundMessage"
+
+// outboundMessage is a message ELSA sent to T2S or CREATION, taken from the queue
+type outboundMessage struct {
+	party  string
+	msg    *queue.Message
+	parsed *mock_elsa_server.ParsedMessage
+}
+
+// outboundQueueName returns the queue ELSA sends the messages for the party to
+func outboundQueueName(cfg *Config, party string) (string, error) {
+	switch party {
+	case "T2S":
+		return cfg.T2SOutboundQueuePath, nil
+	case "CREATION":
+		return cfg.CreationRequestQueuePath, nil
+	}
+	return "", fmt.Errorf("unknown party %q", party)
+}
+
+// findOutboundMessages returns the messages for the instruction in the outbound queue of the party,
+// a message belongs to the instruction if it is correlated with the TXID or refers to it in the payload.
+func findOutboundMessages(ctx context.Context, cfg *Config, party, txID string) (queue.Queue, []*outboundMessage, error) {
+	name, err := outboundQueueName(cfg, party)
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := openQueue(cfg, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs, err := q.Browse(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read queue %s: %w", name, err)
+	}
+
+	var res []*outboundMessage
+	for _, msg := range msgs {
+		parsed, err := mock_elsa_server.ParseMessage(msg.Body)
+		if err != nil {
+			parsed = &mock_elsa_server.ParsedMessage{} // still checked by correlation ID, the schema check reports the error
+		}
+		if msg.CorrelationID != txID && parsed.TXID != txID {
+			continue
+		}
+		res = append(res, &outboundMessage{party: party, msg: msg, parsed: parsed})
+	}
+	return q, res, nil
+}
+
+func elsaSendsMessageTo(ctx context.Context, party, txID string) (context.Context, error) {
+	return elsaSendsTypedMessageTo(ctx, "", party, txID)
+}
+
+func elsaSendsTypedMessageTo(ctx context.Context, messageType, party, txID string) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	txID = scopedTXID(ctx, txID)
+	timeout := time.Duration(cfg.PollingTimeoutSeconds) * time.Second
+
+	var received *outboundMessage
+	err := waitFor(ctx, cfg, timeout, nil, func(ctx context.Context) (bool, error) {
+		q, msgs, err := findOutboundMessages(ctx, cfg, party, txID)
+		if err != nil {
+			recordNote(ctx, "Outbound", "error", "%v, retrying", err)
+			return false, nil
+		}
+		for _, m := range msgs {
+			if !strings.HasPrefix(m.parsed.MessageType, messageType) {
+				continue
+			}
+			if _, err := q.Get(ctx, queue.Selector{MessageID: m.msg.ID}); err != nil {
+				if errors.Is(err, queue.ErrEmpty) {
+					continue // taken by a concurrent reader, e.g. the CREATION simulator
+				}
+				return false, fmt.Errorf("failed to take %s from queue %s: %w", m.msg.ID, q.Name(), err)
+			}
+			recordMessage(ctx, report.KindReceived, q.Name(), m.msg)
+			received = m
+			return true, nil
+		}
+		return false, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		what := "a message"
+		if messageType != "" {
+			what = "a " + messageType + " message"
+		}
+		return ctx, fmt.Errorf("timeout after %s waiting for %s to %s for %s", timeout, what, party, txID)
+	}
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, outboundMessageKey, received), nil
+}
+
+// elsaSendsNoMessageToWithin watches the outbound queue of the party for the whole window
+func elsaSendsNoMessageToWithin(ctx context.Context, party, txID string, seconds int) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	txID = scopedTXID(ctx, txID)
+	window := time.Duration(seconds) * time.Second
+
+	err := waitFor(ctx, cfg, window, nil, func(ctx context.Context) (bool, error) {
+		_, msgs, err := findOutboundMessages(ctx, cfg, party, txID)
+		if err != nil {
+			return false, err
+		}
+		if len(msgs) > 0 {
+			m := msgs[0]
+			return false, fmt.Errorf("unexpected %s message %s sent to %s for %s", m.parsed.MessageType, m.msg.ID, party, txID)
+		}
+		return false, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return ctx, nil // no message within the window
+	}
+	return ctx, err
+}
+
+// schemaFor returns the schema of the message, <message type>.xsd of the configured schema directory. The
+// schemas are loaded once per file and shared by the scenarios of the suite.
+func schemaFor(cfg *Config, messageType string) (*schema.Set, error) {
+	if cfg.SchemaDir == "" {
+		return nil, fmt.Errorf("schema directory is empty, check config elsa_services.json")
+	}
+	suite := cfg.suite
+	if suite == nil {
+		return nil, fmt.Errorf("message schemas are only available within a scenario")
+	}
+	path := filepath.Join(cfg.SchemaDir, messageType+".xsd")
+
+	suite.schemasMu.Lock()
+	defer suite.schemasMu.Unlock()
+	if s, ok := suite.schemas[path]; ok {
+		return s, nil
+	}
+	s, err := schema.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema of %s: %w", messageType, err)
+	}
+	suite.schemas[path] = s
+	return s, nil
+}
+
+func theOutboundMessageShouldBeValidAgainstItsSchema(ctx context.Context) (context.Context, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return ctx, fmt.Errorf("configuration not found in context")
+	}
+	m, ok := ctx.Value(outboundMessageKey).(*outboundMessage)
+	if !ok || m == nil {
+		return ctx, fmt.Errorf("no outbound message received in this scenario")
+	}
+	if m.parsed.MessageType == "" {
+		return ctx, fmt.Errorf("message %s is no ISO20022 document", m.msg.ID)
+	}
+
+	s, err := schemaFor(cfg, m.parsed.MessageType)
+	if err != nil {
+		return ctx, err
+	}
+	if err := s.Validate(m.msg.Body); err != nil {
+		var vErr *schema.ValidationError
+		if errors.As(err, &vErr) {
+			for _, e := range vErr.Errors() {
+				recordNote(ctx, "Schema", "error", "%s", e)
+			}
+		}
+		return ctx, fmt.Errorf("message %s is not valid against %s: %w", m.msg.ID, m.parsed.MessageType, err)
+	}
+	return ctx, nil
+}
+
+// xpathOf makes a relative path like TxId/MktInfrstrctrTxId match anywhere in the message
+func xpathOf(path string) string {
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, "(") {
+		return path
+	}
+	return "//" + path
+}
+
+// checkXPathValues checks the | XPath | Value | rows of the table against the message,
+// the mismatches are returned as one error
+func checkXPathValues(ctx context.Context, body []byte, data *godog.Table) error {
+	if data == nil || len(data.Rows) == 0 {
+		return fmt.Errorf("expected DataTable with header | XPath | Value |")
+	}
+	header := data.Rows[0]
+	if len(header.Cells) != 2 || header.Cells[0].Value != "XPath" || header.Cells[1].Value != "Value" {
+		return fmt.Errorf("expected DataTable header to be | XPath | Value |")
+	}
+
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	var failures []string
+	for _, row := range data.Rows[1:] {
+		if len(row.Cells) != 2 {
+			return fmt.Errorf("expected 2 cells per row in DataTable, got %d", len(row.Cells))
+		}
+		path := row.Cells[0].Value
+		expected, err := expandValue(ctx, row.Cells[1].Value)
+		if err != nil {
+			return err
+		}
+		node, err := xmlquery.Query(doc, xpathOf(path))
+		if err != nil {
+			return fmt.Errorf("invalid XPath %s: %w", path, err)
+		}
+		switch {
+		case node == nil:
+			failures = append(failures, fmt.Sprintf("%s not found", path))
+		case strings.TrimSpace(node.InnerText()) != expected:
+			failures = append(failures, fmt.Sprintf("%s is %q, expected %q", path, strings.TrimSpace(node.InnerText()), expected))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func theOutboundMessageShouldContain(ctx context.Context, data *godog.Table) (context.Context, error) {
+	m, ok := ctx.Value(outboundMessageKey).(*outboundMessage)
+	if !ok || m == nil {
+		return ctx, fmt.Errorf("no outbound message received in this scenario")
+	}
+	if err := checkXPathValues(ctx, m.msg.Body, data); err != nil {
+		return ctx, fmt.Errorf("message %s sent to %s: %w", m.msg.ID, m.party, err)
+	}
+	return ctx, nil
+}
+
+func registerMessageSteps(s *godog.ScenarioContext) {
+	s.Step(`^ELSA sends a message to (T2S|CREATION) for "([^"]*)" within configured polling limits$`, elsaSendsMessageTo)
+	s.Step(`^ELSA sends a "([^"]*)" message to (T2S|CREATION) for "([^"]*)" within configured polling limits$`, elsaSendsTypedMessageTo)
+	s.Step(`^ELSA should send no (?:further )?message to (T2S|CREATION) for "([^"]*)" within (\d+) seconds?$`, elsaSendsNoMessageToWithin)
+	s.Step(`^the outbound message should be valid against its schema$`, theOutboundMessageShouldBeValidAgainstItsSchema)
+	s.Step(`^the outbound message should contain:$`, theOutboundMessageShouldContain)
+}
@@ -0,0 +1,69 @@
+package step_definitions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"test-tool/queue"
+	"test-tool/report"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// timelineFile is the name of the HTML timeline in the report directory
+const timelineFile = "timeline.html"
+
+// scenarioRecorder returns the recorder of the current scenario, nil outside of a scenario
+func scenarioRecorder(ctx context.Context) *report.Scenario {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		return sc.recorder
+	}
+	return nil
+}
+
+// recordMessage records a message the test put to (report.KindSent) or took from (report.KindReceived) a queue
+func recordMessage(ctx context.Context, kind report.Kind, queueName string, msg *queue.Message) {
+	scenarioRecorder(ctx).Record(report.Event{
+		Kind:   kind,
+		Title:  queueName,
+		Detail: fmt.Sprintf("message %s, correlation ID %s", msg.ID, msg.CorrelationID),
+		Body:   string(msg.Body),
+	})
+}
+
+// recordNote records a diagnostic of the steps with the status "error" or "", unlike console output the
+// notes of concurrent scenarios stay apart
+func recordNote(ctx context.Context, title, status, format string, args ...any) {
+	note(scenarioRecorder(ctx), title, status, fmt.Sprintf(format, args...))
+}
+
+// note records a diagnostic to the recorder of a scenario, see recordNote
+func note(rec *report.Scenario, title, status, detail string) {
+	rec.Record(report.Event{Kind: report.KindNote, Title: title, Status: status, Detail: detail})
+}
+
+// failureAttachments returns the messages the scenario exchanged so far and the last API response
+// of the step started at stepStart, they are attached to the failed step in the Cucumber JSON report.
+func failureAttachments(rec *report.Scenario, stepStart time.Time) []godog.Attachment {
+	var res []godog.Attachment
+	for i, e := range rec.Events(time.Time{}, report.KindSent, report.KindReceived) {
+		res = append(res, godog.Attachment{
+			Body:      []byte(e.Body),
+			FileName:  fmt.Sprintf("%02d-%s.xml", i+1, e.Kind),
+			MediaType: "application/xml",
+		})
+	}
+	if polls := rec.Events(stepStart, report.KindPoll); len(polls) > 0 {
+		last := polls[len(polls)-1]
+		att := godog.Attachment{Body: []byte(last.Body), FileName: "last-api-response.txt", MediaType: "text/plain"}
+		switch body := strings.TrimSpace(last.Body); {
+		case strings.HasPrefix(body, "{"):
+			att.FileName, att.MediaType = "last-api-response.json", "application/json"
+		case strings.HasPrefix(body, "<"):
+			att.FileName, att.MediaType = "last-api-response.xml", "application/xml"
+		}
+		res = append(res, att)
+	}
+	return res
+}
@@ -0,0 +1,353 @@
+package step_definitions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"test-tool/db"
+	"test-tool/fixtures"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"test-tool/report"
+	"time"
+)
+
+const scenarioScopeKey contextKey = "scenarioScope"
+
+// scenarioCounter numbers the scenarios of the process, the number keeps the scope IDs unique also
+// across suites sharing queues and directories
+var scenarioCounter atomic.Int64
+
+// scenarioScope isolates a scenario from the scenarios running concurrently: it has its own mock ELSA
+// API server on an ephemeral port, its own queues and replaces the TXIDs used in the feature files
+// by generated ones.
+type scenarioScope struct {
+	id         string
+	suite      *Suite
+	server     *mock_elsa_server.MockElsaAPIServer
+	httpServer *http.Server
+	baseURL    string
+	recorder   *report.Scenario // records messages, API calls and steps for the report
+	started    time.Time
+
+	mu        sync.Mutex
+	cfg       *Config              // the scoped configuration, its queues are cleaned up with the scope
+	txids     map[string]string    // TXID of the feature file -> TXID used in this scenario
+	values    map[string]string    // values of the prepared messages, referenced by later steps
+	sent      map[string]time.Time // put of the first message per correlation ID, the start of the load test latencies
+	stepStart time.Time            // start of the running step
+	headers   http.Header          // headers of the next request of the HTTP steps
+	response  *httpResponse        // response of the last request of the HTTP steps
+	localDB   *db.DB               // database set up by the scenario, replaces the configured one
+	localPath string               // SQLite file of localDB, removed with the scope
+	result    *db.Result           // result of the last query of the database steps
+}
+
+// newScenarioScope starts the mock ELSA API server of a new scenario
+func newScenarioScope(suite *Suite, recorder *report.Scenario) (*scenarioScope, error) {
+	sc := &scenarioScope{
+		id:       fmt.Sprintf("S%d", scenarioCounter.Add(1)),
+		suite:    suite,
+		server:   mock_elsa_server.NewMockElsaAPIServer(),
+		recorder: recorder,
+		started:  time.Now(),
+		txids:    make(map[string]string),
+		values:   make(map[string]string),
+		sent:     make(map[string]time.Time),
+	}
+	sc.server.SetLogger(func(failed bool, msg string) {
+		status := ""
+		if failed {
+			status = "error"
+		}
+		note(recorder, "Mock ELSA", status, msg)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the mock ELSA API server: %w", err)
+	}
+	sc.baseURL = "http://" + ln.Addr().String()
+	sc.server.SetAPIBaseURL(sc.baseURL)
+	sc.httpServer = &http.Server{Handler: sc.server}
+	sc.httpServer.RegisterOnShutdown(sc.server.CloseEvents) // event streams would block the shutdown
+	go func() {
+		if err := sc.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			note(recorder, "Mock ELSA", "error", fmt.Sprintf("API server failed: %v", err))
+		}
+	}()
+	note(recorder, "Mock ELSA", "", "API server listening on "+sc.baseURL)
+	return sc, nil
+}
+
+// scope returns a copy of the configuration which uses the queues and mock server of the scenario,
+// the fault rules of the mock server apply to the queues. Queue directories get a sub-directory named like the scope below the mock MQ root directory,
+// other transports prefix the queue names.
+func (sc *scenarioScope) scope(cfg *Config) *Config {
+	scoped := *cfg
+	scoped.ElsaAPIBaseURL = sc.baseURL
+	scoped.queueFaults = sc.server.QueueFaults()
+	scoped.suite = sc.suite
+	for _, name := range []*string{
+		&scoped.T2SClientRequestQueuePath,
+		&scoped.T2SAcceptanceQueuePath,
+		&scoped.CreationRequestQueuePath,
+		&scoped.CreationAcceptanceQueuePath,
+		&scoped.T2SOutboundQueuePath,
+	} {
+		if *name != "" {
+			*name = sc.queueName(cfg, *name)
+		}
+	}
+	if cfg.isDirectoryTransport() && cfg.MockMQRootDir != "" {
+		scoped.MockMQRootDir = filepath.Join(cfg.MockMQRootDir, sc.id)
+	}
+
+	sc.mu.Lock()
+	sc.cfg = &scoped
+	sc.mu.Unlock()
+	return &scoped
+}
+
+func (sc *scenarioScope) queueName(cfg *Config, name string) string {
+	if !cfg.isDirectoryTransport() {
+		return sc.id + "." + name
+	}
+	if cfg.MockMQRootDir != "" {
+		if rel, err := filepath.Rel(cfg.MockMQRootDir, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join(cfg.MockMQRootDir, sc.id, rel)
+		}
+	}
+	return filepath.Join(name, sc.id)
+}
+
+// txid returns the TXID the scenario uses for a TXID of the feature file. It is generated on first use,
+// short enough for the T2S references (max. 16 characters, also with the "miti-" prefix of the mock).
+func (sc *scenarioScope) txid(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	scoped, ok := sc.txids[raw]
+	if !ok {
+		scoped = fixtures.UniqueID(sc.id)
+		sc.txids[raw] = scoped
+		note(sc.recorder, "TXID", "", fmt.Sprintf("%s is %s", raw, scoped))
+	}
+	return scoped
+}
+
+// setValue remembers a value of a prepared message, a later value of the same name replaces it
+func (sc *scenarioScope) setValue(name, value string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.values[name] = value
+}
+
+// value returns a value remembered by setValue, it is the lookup of the fixtures' ref function
+func (sc *scenarioScope) value(name string) (string, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	v, ok := sc.values[name]
+	return v, ok
+}
+
+// markSent remembers the put of the first message with the correlation ID
+func (sc *scenarioScope) markSent(correlationID string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if _, ok := sc.sent[correlationID]; !ok {
+		sc.sent[correlationID] = time.Now()
+	}
+}
+
+// sentAt returns when the first message with the correlation ID was put
+func (sc *scenarioScope) sentAt(correlationID string) (time.Time, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	t, ok := sc.sent[correlationID]
+	return t, ok
+}
+
+// startStep remembers the start of a step, stepStarted returns it
+func (sc *scenarioScope) startStep() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.stepStart = time.Now()
+}
+
+func (sc *scenarioScope) stepStarted() time.Time {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.stepStart
+}
+
+// createLocalDatabase sets up an SQLite database of the scenario with the statements of script, the queries
+// of the scenario use it instead of the configured database. It returns the path of the database file.
+func (sc *scenarioScope) createLocalDatabase(ctx context.Context, script string, cfg db.Config) (string, error) {
+	dir, err := os.MkdirTemp("", "elsa-db-"+sc.id+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory of the local database: %w", err)
+	}
+	path := filepath.Join(dir, "elsa.db")
+	if err := db.CreateSQLite(ctx, path, script); err != nil {
+		return "", err
+	}
+	d, err := db.Open(db.Config{Driver: "sqlite", DSN: path, Queries: cfg.Queries, QueryTimeoutSeconds: cfg.QueryTimeoutSeconds})
+	if err != nil {
+		return "", err
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.closeLocalDatabase()
+	sc.localDB, sc.localPath = d, path
+	return path, nil
+}
+
+// closeLocalDatabase closes and removes the local database, sc.mu is held
+func (sc *scenarioScope) closeLocalDatabase() {
+	if sc.localDB == nil {
+		return
+	}
+	sc.localDB.Close()
+	os.RemoveAll(filepath.Dir(sc.localPath))
+	sc.localDB, sc.localPath = nil, ""
+}
+
+func (sc *scenarioScope) localDatabase() *db.DB {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.localDB
+}
+
+func (sc *scenarioScope) localDatabasePath() string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.localPath
+}
+
+// setQueryResult remembers the result of a query for the assertion steps, queryResult returns it
+func (sc *scenarioScope) setQueryResult(res *db.Result) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.result = res
+}
+
+func (sc *scenarioScope) queryResult() *db.Result {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.result
+}
+
+// expand replaces the TXIDs of the feature file used so far in text, e.g. in an expected value like miti-TXN_001
+func (sc *scenarioScope) expand(text string) string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.txids) == 0 {
+		return text
+	}
+	raws := make([]string, 0, len(sc.txids))
+	for raw := range sc.txids {
+		raws = append(raws, raw)
+	}
+	sort.Slice(raws, func(i, j int) bool { return len(raws[i]) > len(raws[j]) }) // TXN_10 before TXN_1
+	pairs := make([]string, 0, 2*len(raws))
+	for _, raw := range raws {
+		pairs = append(pairs, raw, sc.txids[raw])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// close stops the mock server and removes the queues of the scenario. The queue directories
+// of a failed scenario are kept for analysis.
+func (sc *scenarioScope) close(failed bool) {
+	sc.server.StopFlow()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sc.httpServer.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Scenario %s: mock ELSA API server shutdown error: %v\n", sc.id, err)
+	}
+	sc.suite.recordCoverage(sc.server.Coverage())
+
+	sc.mu.Lock()
+	cfg := sc.cfg
+	sc.closeLocalDatabase()
+	sc.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+	if cfg.isDirectoryTransport() {
+		if !failed && cfg.MockMQRootDir != "" {
+			if err := os.RemoveAll(cfg.MockMQRootDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Scenario %s: failed to remove %s: %v\n", sc.id, cfg.MockMQRootDir, err)
+			}
+		}
+		return
+	}
+	for _, name := range cfg.queueNames() {
+		q, err := openQueue(cfg, name)
+		if err == nil {
+			err = q.Purge(ctx)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Scenario %s: failed to purge queue %s: %v\n", sc.id, name, err)
+		}
+	}
+}
+
+// scopedTXID returns the TXID the current scenario uses for a TXID of the feature file
+func scopedTXID(ctx context.Context, raw string) string {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		return sc.txid(raw)
+	}
+	return raw
+}
+
+// scenarioValues returns the lookup of the values of the current scenario, nil outside of a scenario
+func scenarioValues(ctx context.Context) fixtures.Lookup {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		return sc.value
+	}
+	return nil
+}
+
+// expandTXIDs replaces the TXIDs of the feature file in an expected value by the ones of the current scenario
+func expandTXIDs(ctx context.Context, text string) string {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		return sc.expand(text)
+	}
+	return text
+}
+
+// expandValue replaces the TXIDs of the feature file and evaluates the fixture helpers in a value of a step,
+// e.g. an expected value {{ref "ISIN"}} refers to the ISIN of a prepared message
+func expandValue(ctx context.Context, text string) (string, error) {
+	return fixtures.Expand(expandTXIDs(ctx, text), scenarioValues(ctx))
+}
+
+// recordCoverage adds the business flow coverage of the mock server of a scenario to the one of the suite
+func (s *Suite) recordCoverage(coverage []mock_elsa_server.TransitionCoverage) {
+	s.coverageMu.Lock()
+	defer s.coverageMu.Unlock()
+	if s.coverage == nil {
+		s.coverage = coverage
+		return
+	}
+	for i := range s.coverage {
+		s.coverage[i].Count += coverage[i].Count
+	}
+}
+
+// isDirectoryTransport reports whether the queues are mock MQ directories
+func (cfg *Config) isDirectoryTransport() bool {
+	return cfg.QueueTransport.Type == "" || cfg.QueueTransport.Type == queue.TransportDirectory
+}
@@ -0,0 +1,159 @@
+// Package step_definitions is the library of the ELSA test steps. A Suite owns the state shared by the
+// scenarios of a run, the steps are registered in groups, so a team can compose its own suite of the
+// groups it needs and add its own steps:
+//
+//	suite := step_definitions.NewSuite("")
+//	defer suite.Close("reports")
+//	godog.TestSuite{
+//		ScenarioInitializer: func(sc *godog.ScenarioContext) {
+//			suite.Register(sc, step_definitions.ConfigSteps, step_definitions.T2SSteps, step_definitions.APISteps)
+//			sc.Step(`^our system books instruction "([^"]*)"$`, ourSystemBooks)
+//		},
+//	}.Run()
+//
+// Custom steps reach the scenario through ScenarioConfig, ScenarioMockServer, ScenarioTXID and ExpandValue.
+package step_definitions
+
+import (
+	"context"
+	"elsa-xml/pkg/schema"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"test-tool/db"
+	"test-tool/fixtures"
+	"test-tool/load"
+	"test-tool/mock_elsa_server"
+	"test-tool/queue"
+	"test-tool/report"
+
+	"github.com/cucumber/godog"
+)
+
+// Suite is a run of scenarios with the steps of the library. It opens the queue transports, databases,
+// message templates and schemas once for all scenarios, collects the report and the business flow coverage
+// and paces the scenarios of a load test. Each scenario gets its own scope (mock ELSA server, queues and TXIDs).
+type Suite struct {
+	dir    string        // holds the testdata directory, see NewSuite
+	config *configLoader // layers of the configuration files, see SetConfigOverrides
+
+	brokersMu sync.Mutex
+	brokers   map[queue.Config]queue.Broker
+
+	databasesMu sync.Mutex
+	databases   map[db.Config]*db.DB
+	queryFiles  map[string]map[string]string
+
+	fixtureLibrariesMu sync.Mutex
+	fixtureLibraries   map[[2]string]*fixtures.Library
+
+	schemasMu sync.Mutex
+	schemas   map[string]*schema.Set // schemas of the outbound message checks by file, see schemaFor
+
+	coverageMu sync.Mutex
+	coverage   []mock_elsa_server.TransitionCoverage
+
+	report       *report.Report // nil in a load test
+	loadPacer    *load.Pacer    // nil in a functional test run, see StartLoadTest
+	loadRecorder *load.Recorder
+}
+
+// NewSuite returns a suite reading the configurations, message templates, cassettes and database scripts
+// from the testdata directory below dir, the working directory if empty. The paths within the
+// configuration files stay relative to the working directory.
+func NewSuite(dir string) *Suite {
+	return &Suite{
+		dir:              dir,
+		config:           &configLoader{profileDir: filepath.Join(dir, profileDir), lookupEnv: os.LookupEnv},
+		brokers:          make(map[queue.Config]queue.Broker),
+		databases:        make(map[db.Config]*db.DB),
+		queryFiles:       make(map[string]map[string]string),
+		fixtureLibraries: make(map[[2]string]*fixtures.Library),
+		schemas:          make(map[string]*schema.Set),
+		report:           report.New(),
+	}
+}
+
+// path returns the path of a file of the testdata directory of the suite
+func (s *Suite) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// StepGroup is a group of steps of the library, see Suite.Register
+type StepGroup struct {
+	Name  string
+	steps func(*godog.ScenarioContext)
+}
+
+// The step groups of the library
+var (
+	ConfigSteps   = StepGroup{"config", registerConfigSteps}     // configuration files and profiles
+	MockSteps     = StepGroup{"mock", registerMockSteps}         // business flow and cassettes of the mock ELSA server
+	T2SSteps      = StepGroup{"T2S", registerT2SSteps}           // messages T2S prepares and sends
+	CreationSteps = StepGroup{"CREATION", registerCreationSteps} // messages CREATION receives and replies
+	MessageSteps  = StepGroup{"messages", registerMessageSteps}  // messages ELSA sends to T2S and CREATION
+	APISteps      = StepGroup{"API", registerAPISteps}           // statuses, audit trail and collections of the ELSA API
+	HistorySteps  = StepGroup{"history", registerHistorySteps}   // status histories of the ELSA API
+	HTTPSteps     = StepGroup{"HTTP", registerHTTPSteps}         // generic HTTP requests
+	FaultSteps    = StepGroup{"faults", registerFaultSteps}      // faults of the mock ELSA server and the queues
+	DatabaseSteps = StepGroup{"database", registerDatabaseSteps} // assertions on the ELSA database
+	ClockSteps    = StepGroup{"clock", registerClockSteps}       // controlled time, timeouts and retries
+
+	AllSteps = []StepGroup{ConfigSteps, MockSteps, T2SSteps, CreationSteps, MessageSteps, APISteps,
+		HistorySteps, HTTPSteps, FaultSteps, DatabaseSteps, ClockSteps}
+)
+
+// Register adds the hooks of the suite and the steps of the groups to the context of a scenario, it is
+// called once per scenario from the ScenarioInitializer of godog
+func (s *Suite) Register(sc *godog.ScenarioContext, groups ...StepGroup) {
+	registered := make(map[string]bool)
+	for _, g := range groups {
+		if !registered[g.Name] {
+			g.steps(sc)
+			registered[g.Name] = true
+		}
+	}
+	sc.Before(s.beforeScenario)
+	sc.After(s.afterScenario)
+	sc.StepContext().Before(beforeStep)
+	sc.StepContext().After(afterStep)
+}
+
+// ScenarioConfig returns the configuration of the running scenario, its queues and API base URL are
+// the ones of the scenario
+func ScenarioConfig(ctx context.Context) (*Config, error) {
+	cfg, ok := ctx.Value(configKey).(*Config)
+	if !ok || cfg == nil {
+		return nil, fmt.Errorf("configuration not found in context")
+	}
+	return cfg, nil
+}
+
+// ScenarioMockServer returns the mock ELSA server of the running scenario
+func ScenarioMockServer(ctx context.Context) (*mock_elsa_server.MockElsaAPIServer, error) {
+	mockServer, ok := ctx.Value(mockServerKey).(*mock_elsa_server.MockElsaAPIServer)
+	if !ok || mockServer == nil {
+		return nil, fmt.Errorf("mock ELSA API server not found in context")
+	}
+	return mockServer, nil
+}
+
+// ScenarioTXID returns the TXID the running scenario uses for a TXID of the feature file
+func ScenarioTXID(ctx context.Context, txID string) string {
+	return scopedTXID(ctx, txID)
+}
+
+// ExpandValue replaces the TXIDs of the feature file and evaluates the fixture helpers in a value of a
+// step, like the steps of the library do
+func ExpandValue(ctx context.Context, text string) (string, error) {
+	return expandValue(ctx, text)
+}
+
+// scenarioSuite returns the suite of the running scenario
+func scenarioSuite(ctx context.Context) (*Suite, error) {
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		return sc.suite, nil
+	}
+	return nil, fmt.Errorf("scenario scope not found in context")
+}
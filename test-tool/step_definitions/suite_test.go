@@ -0,0 +1,62 @@
+package step_definitions
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cucumber/godog"
+)
+
+const customFeature = `Feature: Suite of another team
+
+  Scenario: Custom step next to the steps of the library
+    Given the system is configured from "elsa_services_memory.json"
+    When our system books instruction "TXN_TEAM_001"
+    Then ELSA instruction "TXN_TEAM_001" should have status "created" within configured polling limits
+`
+
+// runSuite runs the feature with a suite of the step groups and the custom step of another team
+func runSuite(t *testing.T, feature string, groups ...StepGroup) (int, string) {
+	t.Helper()
+	suite := NewSuite("..")
+	defer suite.Close("")
+	var out bytes.Buffer
+	status := godog.TestSuite{
+		Name: "team",
+		ScenarioInitializer: func(sc *godog.ScenarioContext) {
+			suite.Register(sc, groups...)
+			sc.Step(`^our system books instruction "([^"]*)"$`, func(ctx context.Context, txID string) error {
+				mockServer, err := ScenarioMockServer(ctx)
+				if err != nil {
+					return err
+				}
+				mockServer.SetInstructionStatus(ScenarioTXID(ctx, txID), "created")
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format: "progress",
+			Output: &out,
+			Strict: true,
+			Paths:  []string{"features"},
+			FS:     fstest.MapFS{"features/team.feature": {Data: []byte(feature)}},
+		},
+	}.Run()
+	return status, out.String()
+}
+
+func TestSuiteWithCustomSteps(t *testing.T) {
+	if status, out := runSuite(t, customFeature, ConfigSteps, APISteps, APISteps); status != 0 {
+		t.Errorf("suite failed:\n%s", out)
+	}
+
+	// the steps of groups not registered are undefined
+	feature := customFeature + `    When CREATION accepts the instruction "TXN_TEAM_001"
+`
+	if status, out := runSuite(t, feature, ConfigSteps, APISteps); status == 0 || !strings.Contains(out, "undefined") {
+		t.Errorf("expected an undefined step, got status %d:\n%s", status, out)
+	}
+}
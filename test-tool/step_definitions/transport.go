@@ -0,0 +1,75 @@
+package step_definitions
+
+import (
+	"context"
+	"fmt"
+	"test-tool/queue"
+	"test-tool/report"
+)
+
+// brokerFor returns the broker of the configured queue transport. Brokers are opened once per transport
+// configuration and shared by the scenarios of the suite, the in-memory broker has to be the same for the
+// steps and the mock ELSA server.
+func brokerFor(cfg *Config) (queue.Broker, error) {
+	s := cfg.suite
+	if s == nil {
+		return nil, fmt.Errorf("queues are only available within a scenario")
+	}
+	s.brokersMu.Lock()
+	defer s.brokersMu.Unlock()
+	if b, ok := s.brokers[cfg.QueueTransport]; ok {
+		return b, nil
+	}
+	b, err := queue.Open(cfg.QueueTransport)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue transport: %w", err)
+	}
+	s.brokers[cfg.QueueTransport] = b
+	return b, nil
+}
+
+// closeBrokers closes all brokers, called at the end of the suite
+func (s *Suite) closeBrokers() {
+	s.brokersMu.Lock()
+	defer s.brokersMu.Unlock()
+	for c, b := range s.brokers {
+		if err := b.Close(); err != nil {
+			fmt.Printf("Warning: closing queue transport %s failed: %v\n", c.Type, err)
+		}
+		delete(s.brokers, c)
+	}
+}
+
+// openQueue returns the queue with the configured name (the directory path for the directory transport),
+// puts to it are subject to the queue faults of the scenario
+func openQueue(cfg *Config, name string) (queue.Queue, error) {
+	if name == "" {
+		return nil, fmt.Errorf("queue name is empty, check config elsa_services.json")
+	}
+	b, err := brokerFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	q, err := b.Queue(name)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.queueFaults.Wrap(q), nil
+}
+
+// putMessage puts the payload with the given message and correlation ID to the queue, the message is recorded for the report
+func putMessage(ctx context.Context, cfg *Config, queueName, messageID, correlationID, payload string) error {
+	q, err := openQueue(cfg, queueName)
+	if err != nil {
+		return err
+	}
+	msg := &queue.Message{ID: messageID, CorrelationID: correlationID, Body: []byte(payload)}
+	if err := q.Put(ctx, msg); err != nil {
+		return err
+	}
+	recordMessage(ctx, report.KindSent, queueName, msg)
+	if sc, ok := ctx.Value(scenarioScopeKey).(*scenarioScope); ok {
+		sc.markSent(correlationID)
+	}
+	return nil
+}
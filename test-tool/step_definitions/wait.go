@@ -0,0 +1,115 @@
+package step_definitions
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Backoff of the waits: the first check is repeated after initialPollInterval, the interval doubles
+// up to the configured polling interval.
+const (
+	initialPollInterval = 100 * time.Millisecond
+	backoffFactor       = 2
+)
+
+// Supported values of Config.StatusNotification
+const (
+	NotificationNone = ""    // status changes are only detected by polling
+	NotificationSSE  = "sse" // server-sent events of GET /events, offered by the mock ELSA API server
+)
+
+// errWaitTimeout is returned by waitFor when the condition was not met in time
+var errWaitTimeout = errors.New("timeout")
+
+// waitFor calls check until it reports done, returns an error, the timeout expires or ctx is cancelled.
+// Between the checks it waits with exponential backoff, a signal on notify triggers the next check at once.
+// The context passed to check ends at the timeout, so a check hanging in a request cannot outlast it.
+func waitFor(ctx context.Context, cfg *Config, timeout time.Duration, notify <-chan struct{}, check func(ctx context.Context) (bool, error)) error {
+	maxInterval := time.Duration(cfg.PollingIntervalSeconds) * time.Second
+	if maxInterval <= 0 {
+		maxInterval = time.Second
+	}
+	interval := min(initialPollInterval, maxInterval)
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		done, err := check(checkCtx)
+		switch {
+		case err == nil && done:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case checkCtx.Err() != nil:
+			return errWaitTimeout // also if the check failed because the deadline passed meanwhile
+		case err != nil:
+			return err
+		}
+
+		wait := time.NewTimer(interval)
+		select {
+		case <-checkCtx.Done():
+			wait.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errWaitTimeout
+		case _, ok := <-notify:
+			wait.Stop()
+			if !ok {
+				notify = nil // subscription ended, continue polling
+			}
+		case <-wait.C:
+			interval = min(interval*backoffFactor, maxInterval)
+		}
+	}
+}
+
+// statusEvents subscribes to the status events of the instruction if a notification channel is configured,
+// every event is signalled on the returned channel. The subscription ends with ctx. Without notification
+// or if the subscription fails nil is returned and waits rely on polling.
+func statusEvents(ctx context.Context, cfg *Config, clientTXID string) <-chan struct{} {
+	if cfg.StatusNotification != NotificationSSE {
+		return nil
+	}
+	eventsURL := fmt.Sprintf("%s/events?txID=%s", cfg.ElsaAPIBaseURL, url.QueryEscape(clientTXID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, eventsURL, nil)
+	if err != nil {
+		recordNote(ctx, "Status events", "", "not available for %s: %v", clientTXID, err)
+		return nil
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		recordNote(ctx, "Status events", "", "not available for %s: %v", clientTXID, err)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		recordNote(ctx, "Status events", "", "not available for %s: status %d", clientTXID, resp.StatusCode)
+		return nil
+	}
+
+	notify := make(chan struct{}, 1)
+	go func() {
+		defer close(notify)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if !strings.HasPrefix(scanner.Text(), "data:") {
+				continue
+			}
+			select {
+			case notify <- struct{}{}:
+			default: // a check is already pending
+			}
+		}
+	}()
+	return notify
+}
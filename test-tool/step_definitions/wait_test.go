@@ -0,0 +1,44 @@
+package step_definitions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitForEndsHangingCheck(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	err := waitFor(context.Background(), &Config{PollingIntervalSeconds: 1}, 200*time.Millisecond, nil, func(ctx context.Context) (bool, error) {
+		_, _, err := httpGet(ctx, srv.URL)
+		return false, err
+	})
+	if !errors.Is(err, errWaitTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("the wait took %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err = waitFor(ctx, &Config{PollingIntervalSeconds: 1}, time.Minute, nil, func(ctx context.Context) (bool, error) {
+		_, _, err := httpGet(ctx, srv.URL)
+		return false, err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation, got %v", err)
+	}
+}
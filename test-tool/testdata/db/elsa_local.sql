@@ -0,0 +1,19 @@
+-- Local stand-in for the tables of the ELSA database used by elsa_queries.sql, see the step
+-- "the local ELSA database is set up from". The rows are added by the scenarios.
+
+CREATE TABLE instruction (
+    client_txid   TEXT PRIMARY KEY,
+    miti_txid     TEXT,
+    status        TEXT NOT NULL,
+    retry_count   INTEGER NOT NULL DEFAULT 0,
+    last_retry_at TEXT
+);
+
+CREATE TABLE collection_message (
+    collection_id TEXT NOT NULL,
+    sequence      INTEGER NOT NULL,
+    client_txid   TEXT NOT NULL,
+    message_type  TEXT NOT NULL,
+    status        TEXT NOT NULL,
+    PRIMARY KEY (collection_id, sequence)
+);
@@ -0,0 +1,25 @@
+-- Named queries of the database steps, the parameters are written as :name.
+-- The steps querying for an instruction pass :txid, the ones querying for a collection :collection.
+-- The table and column names are the ones of elsa_local.sql, align them with the ELSA schema.
+
+-- name: instruction_state
+SELECT client_txid, status, retry_count
+FROM instruction
+WHERE client_txid = :txid
+
+-- name: instruction_retries
+SELECT retry_count, last_retry_at
+FROM instruction
+WHERE client_txid = :txid
+
+-- name: collection_messages
+SELECT client_txid, message_type, status
+FROM collection_message
+WHERE collection_id = :collection
+ORDER BY sequence
+
+-- name: instructions_retried_in_status
+SELECT client_txid, retry_count
+FROM instruction
+WHERE status = :status AND retry_count >= :minRetries
+ORDER BY client_txid